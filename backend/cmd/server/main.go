@@ -23,6 +23,14 @@ func main() {
 	}
 	defer database.Close()
 
+	// "server migrate <command>" manages the schema without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := db.RunMigrateCommand(database, os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Run migrations
 	if err := db.Migrate(database); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
@@ -30,6 +38,11 @@ func main() {
 
 	// Initialize handlers
 	h := handlers.New(database)
+	// Thin out old note revisions per the retention policy
+	go h.PruneRevisions("")
+	go h.ExtractAttachments()
+	go h.RunBackupSchedule(nil)
+	go h.RunReminders(nil)
 
 	// Setup Gin router
 	router := gin.Default()
@@ -39,6 +52,7 @@ func main() {
 	config.AllowOrigins = []string{"http://localhost:5173", "http://localhost:3000"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
 	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.ExposeHeaders = []string{"ETag", "X-Total-Count", "X-Next-Cursor"}
 	router.Use(cors.New(config))
 
 	// Health check
@@ -61,17 +75,27 @@ func main() {
 		api.DELETE("/accounts/:id", h.DeleteAccount)
 		api.POST("/accounts/:id/restore", h.RestoreAccount)
 		api.DELETE("/accounts/:id/permanent", h.PermanentDeleteAccount)
+		api.GET("/accounts/:id/export", h.ExportAccount)
 
 		// Notes - archived must come before :id to avoid route capture
 		api.GET("/notes/archived", h.GetArchivedNotes)
 		api.GET("/notes/deleted", h.GetDeletedNotes)
 		api.GET("/notes", h.GetNotes)
+		api.POST("/notes/bulk", h.BulkNotesOperation)
 		api.GET("/notes/:id", h.GetNote)
 		api.POST("/notes", h.CreateNote)
 		api.PUT("/notes/:id", h.UpdateNote)
 		api.DELETE("/notes/:id", h.DeleteNote)
 		api.POST("/notes/:id/restore", h.RestoreNote)
 		api.DELETE("/notes/:id/permanent", h.PermanentDeleteNote)
+		api.GET("/notes/:id/similar", h.SimilarNotes)
+		api.POST("/notes/:id/extract-todos", h.ExtractNoteTodos)
+
+		// Note revisions
+		api.GET("/notes/:id/revisions", h.GetNoteRevisions)
+		api.GET("/notes/:id/revisions/:rev", h.GetNoteRevision)
+		api.GET("/notes/:id/revisions/:rev/diff", h.DiffNoteRevision)
+		api.POST("/notes/:id/revisions/:rev/restore", h.RestoreNoteRevision)
 		api.GET("/accounts/:id/notes", h.GetNotesByAccount)
 
 		// Todos
@@ -79,23 +103,58 @@ func main() {
 		api.GET("/todos/deleted", h.GetDeletedTodos)
 		api.GET("/todos/:id", h.GetTodo)
 		api.POST("/todos", h.CreateTodo)
+		api.POST("/todos/bulk", h.BulkTodosOperation)
 		api.PUT("/todos/:id", h.UpdateTodo)
 		api.DELETE("/todos/:id", h.DeleteTodo)
 		api.POST("/todos/:id/restore", h.RestoreTodo)
 		api.DELETE("/todos/:id/permanent", h.PermanentDeleteTodo)
 		api.POST("/todos/:id/notes/:noteId", h.LinkTodoToNote)
 		api.DELETE("/todos/:id/notes/:noteId", h.UnlinkTodoFromNote)
+		api.GET("/todos/:id/completions", h.GetTodoCompletions)
+		api.POST("/todos/:id/blocked-by/:blockerId", h.AddTodoBlocker)
+		api.DELETE("/todos/:id/blocked-by/:blockerId", h.RemoveTodoBlocker)
+		api.PUT("/todos/:id/reminders", h.SetTodoReminders)
+		api.POST("/todos/:id/reminders/:lead/snooze", h.SnoozeReminder)
+		api.POST("/todos/:id/timer/start", h.StartTodoTimer)
+		api.POST("/todos/:id/timer/stop", h.StopTodoTimer)
+		api.GET("/todos/:id/time-entries", h.GetTodoTimeEntries)
+		api.POST("/todos/:id/time-entries", h.CreateTimeEntry)
+		api.DELETE("/time-entries/:id", h.DeleteTimeEntry)
+		api.GET("/todos/:id/history", h.GetTodoHistory)
+
+		// Workflow
+		api.GET("/workflow", h.GetWorkflow)
+		api.PUT("/workflow", h.UpdateWorkflow)
+
+		// Reminders
+		api.GET("/reminders/upcoming", h.GetUpcomingReminders)
+		api.GET("/reminders/stream", h.StreamReminders)
 
 		// Search
 		api.GET("/search", h.Search)
+		api.POST("/search/reindex", h.ReindexSearch)
+
+		// Saved searches
+		api.GET("/saved-searches", h.GetSavedSearches)
+		api.POST("/saved-searches", h.CreateSavedSearch)
+		api.GET("/saved-searches/:id", h.GetSavedSearch)
+		api.PUT("/saved-searches/:id", h.UpdateSavedSearch)
+		api.DELETE("/saved-searches/:id", h.DeleteSavedSearch)
+		api.GET("/saved-searches/:id/results", h.RunSavedSearch)
 
 		// Analytics
 		api.GET("/analytics", h.GetAnalytics)
 		api.GET("/analytics/incomplete", h.GetIncompleteFields)
+		api.GET("/analytics/time", h.GetTimeAnalytics)
+		api.GET("/analytics/status", h.GetStatusAnalytics)
 
 		// Data management
 		api.GET("/export", h.ExportAllData)
+		api.POST("/import", h.ImportData)
 		api.DELETE("/data", h.ClearAllData)
+		api.GET("/backups", h.GetBackups)
+		api.POST("/backups", h.CreateBackup)
+		api.POST("/backups/:id/restore", h.RestoreBackup)
 
 		// PDF Export
 		api.GET("/notes/:id/export", h.ExportNotePDF)
@@ -119,9 +178,16 @@ func main() {
 		api.POST("/tags", h.CreateTag)
 		api.PUT("/tags/:id", h.UpdateTag)
 		api.DELETE("/tags/:id", h.DeleteTag)
+		api.POST("/tags/:id/merge", h.MergeTag)
 		api.GET("/notes/:id/tags", h.GetNoteTags)
 		api.POST("/notes/:id/tags/:tagId", h.AddTagToNote)
 		api.DELETE("/notes/:id/tags/:tagId", h.RemoveTagFromNote)
+		api.GET("/todos/:id/tags", h.GetTodoTags)
+		api.POST("/todos/:id/tags/:tagId", h.AddTagToTodo)
+		api.DELETE("/todos/:id/tags/:tagId", h.RemoveTagFromTodo)
+		api.GET("/accounts/:id/tags", h.GetAccountTags)
+		api.POST("/accounts/:id/tags/:tagId", h.AddTagToAccount)
+		api.DELETE("/accounts/:id/tags/:tagId", h.RemoveTagFromAccount)
 
 		// Activities
 		api.GET("/accounts/:id/activities", h.GetActivities)
@@ -130,6 +196,7 @@ func main() {
 		// Attachments
 		api.GET("/notes/:id/attachments", h.GetAttachments)
 		api.POST("/notes/:id/attachments", h.UploadAttachment)
+		api.GET("/notes/:id/attachments/:attachmentId/text", h.GetAttachmentText)
 		api.DELETE("/notes/:id/attachments/:attachmentId", h.DeleteAttachment)
 
 		// Reorder notes
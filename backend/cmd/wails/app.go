@@ -10,8 +10,11 @@ import (
 
 	"github.com/factory-sagar/notes-droid/backend/internal/db"
 	"github.com/factory-sagar/notes-droid/backend/internal/handlers"
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/remind"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 type App struct {
@@ -35,6 +38,16 @@ func (a *App) shutdown_(ctx context.Context) {
 	close(a.shutdown)
 }
 
+// desktopReminder emits a "reminder" event, which the frontend turns into a
+// native notification; the desktop app does not open the reminder stream
+func (a *App) desktopReminder(r models.Reminder) error {
+	if a.ctx == nil {
+		return fmt.Errorf("app not started")
+	}
+	runtime.EventsEmit(a.ctx, "reminder", r)
+	return nil
+}
+
 func (a *App) GetServerPort() int {
 	return a.port
 }
@@ -78,6 +91,13 @@ func (a *App) startServer() {
 	}
 
 	h := handlers.NewWithUploadsDir(database, uploadsDir)
+	// Thin out old note revisions per the retention policy
+	go h.PruneRevisions("")
+	go h.ExtractAttachments()
+	go h.RunBackupSchedule(a.shutdown)
+	// Reminders show as native notifications, see desktopReminder
+	h.AddReminderNotifier(remind.NotifierFunc(a.desktopReminder))
+	go h.RunReminders(a.shutdown)
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
@@ -87,6 +107,7 @@ func (a *App) startServer() {
 	config.AllowAllOrigins = true
 	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
 	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.ExposeHeaders = []string{"ETag", "X-Total-Count", "X-Next-Cursor"}
 	router.Use(cors.New(config))
 
 	router.GET("/health", func(c *gin.Context) {
@@ -105,21 +126,32 @@ func (a *App) startServer() {
 		api.DELETE("/accounts/:id", h.DeleteAccount)
 		api.POST("/accounts/:id/restore", h.RestoreAccount)
 		api.DELETE("/accounts/:id/permanent", h.PermanentDeleteAccount)
+		api.GET("/accounts/:id/export", h.ExportAccount)
 
 		api.GET("/notes/archived", h.GetArchivedNotes)
 		api.GET("/notes", h.GetNotes)
+		api.POST("/notes/bulk", h.BulkNotesOperation)
 		api.GET("/notes/:id", h.GetNote)
 		api.POST("/notes", h.CreateNote)
 		api.PUT("/notes/:id", h.UpdateNote)
 		api.DELETE("/notes/:id", h.DeleteNote)
 		api.POST("/notes/:id/restore", h.RestoreNote)
 		api.DELETE("/notes/:id/permanent", h.PermanentDeleteNote)
+		api.GET("/notes/:id/similar", h.SimilarNotes)
+		api.POST("/notes/:id/extract-todos", h.ExtractNoteTodos)
+
+		// Note revisions
+		api.GET("/notes/:id/revisions", h.GetNoteRevisions)
+		api.GET("/notes/:id/revisions/:rev", h.GetNoteRevision)
+		api.GET("/notes/:id/revisions/:rev/diff", h.DiffNoteRevision)
+		api.POST("/notes/:id/revisions/:rev/restore", h.RestoreNoteRevision)
 		api.GET("/notes/deleted", h.GetDeletedNotes)
 		api.GET("/accounts/:id/notes", h.GetNotesByAccount)
 
 		api.GET("/todos", h.GetTodos)
 		api.GET("/todos/:id", h.GetTodo)
 		api.POST("/todos", h.CreateTodo)
+		api.POST("/todos/bulk", h.BulkTodosOperation)
 		api.PUT("/todos/:id", h.UpdateTodo)
 		api.DELETE("/todos/:id", h.DeleteTodo)
 		api.POST("/todos/:id/restore", h.RestoreTodo)
@@ -127,17 +159,54 @@ func (a *App) startServer() {
 		api.GET("/todos/deleted", h.GetDeletedTodos)
 		api.POST("/todos/:id/notes/:noteId", h.LinkTodoToNote)
 		api.DELETE("/todos/:id/notes/:noteId", h.UnlinkTodoFromNote)
+		api.GET("/todos/:id/completions", h.GetTodoCompletions)
+		api.POST("/todos/:id/blocked-by/:blockerId", h.AddTodoBlocker)
+		api.DELETE("/todos/:id/blocked-by/:blockerId", h.RemoveTodoBlocker)
+		api.PUT("/todos/:id/reminders", h.SetTodoReminders)
+		api.POST("/todos/:id/reminders/:lead/snooze", h.SnoozeReminder)
+		api.POST("/todos/:id/timer/start", h.StartTodoTimer)
+		api.POST("/todos/:id/timer/stop", h.StopTodoTimer)
+		api.GET("/todos/:id/time-entries", h.GetTodoTimeEntries)
+		api.POST("/todos/:id/time-entries", h.CreateTimeEntry)
+		api.DELETE("/time-entries/:id", h.DeleteTimeEntry)
+		api.GET("/todos/:id/history", h.GetTodoHistory)
+
+		// Workflow
+		api.GET("/workflow", h.GetWorkflow)
+		api.PUT("/workflow", h.UpdateWorkflow)
+
+		// Reminders
+		api.GET("/reminders/upcoming", h.GetUpcomingReminders)
+		api.GET("/reminders/stream", h.StreamReminders)
 
 		api.GET("/search", h.Search)
+		api.POST("/search/reindex", h.ReindexSearch)
+
+		// Saved searches
+		api.GET("/saved-searches", h.GetSavedSearches)
+		api.POST("/saved-searches", h.CreateSavedSearch)
+		api.GET("/saved-searches/:id", h.GetSavedSearch)
+		api.PUT("/saved-searches/:id", h.UpdateSavedSearch)
+		api.DELETE("/saved-searches/:id", h.DeleteSavedSearch)
+		api.GET("/saved-searches/:id/results", h.RunSavedSearch)
 
 		api.GET("/analytics", h.GetAnalytics)
 		api.GET("/analytics/incomplete", h.GetIncompleteFields)
+		api.GET("/analytics/time", h.GetTimeAnalytics)
+		api.GET("/analytics/status", h.GetStatusAnalytics)
 
 		api.GET("/export", h.ExportAllData)
+		api.POST("/import", h.ImportData)
 		api.DELETE("/data", h.ClearAllData)
+		api.GET("/backups", h.GetBackups)
+		api.POST("/backups", h.CreateBackup)
+		api.POST("/backups/:id/restore", h.RestoreBackup)
 
 		api.GET("/notes/:id/export", h.ExportNotePDF)
 
+		api.POST("/import/markdown", h.ImportMarkdown)
+		api.GET("/notes/:id/export/markdown", h.ExportMarkdown)
+
 		// Apple Calendar (EventKit) - native macOS integration
 		api.GET("/calendar/config", h.GetAppleCalendarStatus)
 		api.POST("/calendar/connect", h.RequestAppleCalendarAccess)
@@ -150,15 +219,23 @@ func (a *App) startServer() {
 		api.POST("/tags", h.CreateTag)
 		api.PUT("/tags/:id", h.UpdateTag)
 		api.DELETE("/tags/:id", h.DeleteTag)
+		api.POST("/tags/:id/merge", h.MergeTag)
 		api.GET("/notes/:id/tags", h.GetNoteTags)
 		api.POST("/notes/:id/tags/:tagId", h.AddTagToNote)
 		api.DELETE("/notes/:id/tags/:tagId", h.RemoveTagFromNote)
+		api.GET("/todos/:id/tags", h.GetTodoTags)
+		api.POST("/todos/:id/tags/:tagId", h.AddTagToTodo)
+		api.DELETE("/todos/:id/tags/:tagId", h.RemoveTagFromTodo)
+		api.GET("/accounts/:id/tags", h.GetAccountTags)
+		api.POST("/accounts/:id/tags/:tagId", h.AddTagToAccount)
+		api.DELETE("/accounts/:id/tags/:tagId", h.RemoveTagFromAccount)
 
 		api.GET("/accounts/:id/activities", h.GetActivities)
 		api.POST("/activities", h.CreateActivity)
 
 		api.GET("/notes/:id/attachments", h.GetAttachments)
 		api.POST("/notes/:id/attachments", h.UploadAttachment)
+		api.GET("/notes/:id/attachments/:attachmentId/text", h.GetAttachmentText)
 		api.DELETE("/notes/:id/attachments/:attachmentId", h.DeleteAttachment)
 
 		api.POST("/accounts/:id/notes/reorder", h.ReorderNotes)
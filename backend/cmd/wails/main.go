@@ -2,7 +2,11 @@ package main
 
 import (
 	"embed"
+	"log"
+	"os"
+	"path/filepath"
 
+	"github.com/factory-sagar/notes-droid/backend/internal/db"
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
@@ -13,6 +17,12 @@ import (
 var assets embed.FS
 
 func main() {
+	// "Noted migrate <command>" manages the schema of the desktop database
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	app := NewApp()
 
 	err := wails.Run(&options.App{
@@ -52,3 +62,15 @@ func main() {
 		println("Error:", err.Error())
 	}
 }
+
+func runMigrate(args []string) {
+	database, err := db.Initialize(filepath.Join(getDataDir(), "notes.db"))
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	if err := db.RunMigrateCommand(database, args, os.Stdout); err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+}
@@ -0,0 +1,443 @@
+// Package backup takes and restores snapshots of the database and the
+// uploads directory. The database is copied with SQLite's online backup API,
+// so snapshots are consistent while the app keeps writing.
+//
+// Each snapshot is a directory named after its UTC timestamp:
+//
+//	backups/20240115T100000Z/
+//	    notes.db
+//	    uploads/...
+//	    backup.json
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/db"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrNotFound is returned for a backup id that does not exist
+var ErrNotFound = errors.New("backup not found")
+
+// Why a snapshot was taken
+const (
+	ReasonScheduled  = "scheduled"
+	ReasonManual     = "manual"
+	ReasonPreRestore = "pre_restore"
+)
+
+const (
+	dbFile     = "notes.db"
+	uploadsDir = "uploads"
+	metaFile   = "backup.json"
+	idLayout   = "20060102T150405Z"
+	// pagesPerStep copies the database in chunks so writers are only
+	// locked out briefly
+	pagesPerStep = 256
+)
+
+// Backup describes a snapshot
+type Backup struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Reason    string    `json:"reason"`
+	// Size is the total size of the snapshot in bytes
+	Size int64 `json:"size"`
+	// Files counts the uploaded files in the snapshot
+	Files int `json:"files"`
+}
+
+// Manager takes snapshots of one database and uploads directory
+type Manager struct {
+	db         *sql.DB
+	uploadsDir string
+	dir        string
+	// keep is how many snapshots are kept; older ones are deleted after each
+	// new snapshot. Zero keeps everything.
+	keep int
+
+	mu sync.Mutex
+}
+
+// New returns a Manager writing snapshots to dir
+func New(database *sql.DB, uploadsDir, dir string, keep int) *Manager {
+	return &Manager{db: database, uploadsDir: uploadsDir, dir: dir, keep: keep}
+}
+
+// Create takes a snapshot and then applies the retention limit
+func (m *Manager) Create(reason string) (*Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, err := m.create(reason)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.prune(); err != nil {
+		return b, fmt.Errorf("pruning old backups: %w", err)
+	}
+	return b, nil
+}
+
+func (m *Manager) create(reason string) (*Backup, error) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	id, err := m.nextID(now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the snapshot under a hidden name so a failed or interrupted
+	// backup is never listed
+	partial := filepath.Join(m.dir, "."+id+".partial")
+	if err := os.MkdirAll(partial, 0755); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(partial)
+
+	if err := m.copyDatabase(filepath.Join(partial, dbFile)); err != nil {
+		return nil, fmt.Errorf("copying database: %w", err)
+	}
+	files, err := copyDir(m.uploadsDir, filepath.Join(partial, uploadsDir))
+	if err != nil {
+		return nil, fmt.Errorf("copying uploads: %w", err)
+	}
+
+	b := &Backup{ID: id, CreatedAt: now, Reason: reason, Files: files}
+	if b.Size, err = dirSize(partial); err != nil {
+		return nil, err
+	}
+	meta, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(partial, metaFile), meta, 0644); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(partial, filepath.Join(m.dir, id)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// nextID names a snapshot after its timestamp, numbering snapshots taken
+// within the same second after the highest number used so far, so that ids
+// keep sorting in creation order even once older ones are pruned
+func (m *Manager) nextID(now time.Time) (string, error) {
+	base := now.Format(idLayout)
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return "", err
+	}
+
+	next := 1
+	for _, e := range entries {
+		name := strings.TrimSuffix(strings.TrimPrefix(e.Name(), "."), ".partial")
+		rest, ok := strings.CutPrefix(name, base)
+		if !ok {
+			continue
+		}
+		n := 1
+		if rest != "" {
+			if _, err := fmt.Sscanf(rest, "-%d", &n); err != nil {
+				continue
+			}
+		}
+		if n >= next {
+			next = n + 1
+		}
+	}
+	if next == 1 {
+		return base, nil
+	}
+	return fmt.Sprintf("%s-%d", base, next), nil
+}
+
+// List returns the snapshots, newest first
+func (m *Manager) List() ([]Backup, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []Backup{}
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		b, err := m.read(e.Name())
+		if err != nil {
+			continue
+		}
+		backups = append(backups, *b)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+func (m *Manager) read(id string) (*Backup, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(m.dir, id, metaFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b Backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Restore replaces the database and uploads with a snapshot. A pre_restore
+// snapshot of the current state is taken first and returned, so a restore
+// can itself be undone.
+func (m *Manager) Restore(id string) (*Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.read(id); err != nil {
+		return nil, err
+	}
+	safety, err := m.create(ReasonPreRestore)
+	if err != nil {
+		return nil, fmt.Errorf("backing up current data: %w", err)
+	}
+
+	snapshot := filepath.Join(m.dir, id)
+	if err := m.restoreDatabase(filepath.Join(snapshot, dbFile)); err != nil {
+		return safety, fmt.Errorf("restoring database: %w", err)
+	}
+	// A snapshot from an older release may predate some migrations
+	if err := db.Migrate(m.db); err != nil {
+		return safety, fmt.Errorf("migrating restored database: %w", err)
+	}
+	if err := replaceDir(filepath.Join(snapshot, uploadsDir), m.uploadsDir); err != nil {
+		return safety, fmt.Errorf("restoring uploads: %w", err)
+	}
+	return safety, nil
+}
+
+// prune deletes the oldest snapshots beyond the retention limit
+func (m *Manager) prune() error {
+	if m.keep <= 0 {
+		return nil
+	}
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+	for i := m.keep; i < len(backups); i++ {
+		if err := os.RemoveAll(filepath.Join(m.dir, backups[i].ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Schedule takes a snapshot every interval until stop is closed. If there is
+// no snapshot yet, or the newest is already older than interval, one is
+// taken right away.
+func (m *Manager) Schedule(interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	if interval <= 0 {
+		return
+	}
+
+	wait := interval
+	if backups, err := m.List(); err == nil {
+		wait = 0
+		if len(backups) > 0 {
+			wait = interval - time.Since(backups[0].CreatedAt)
+		}
+	}
+
+	timer := time.NewTimer(max(wait, 0))
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			if _, err := m.Create(ReasonScheduled); err != nil && onError != nil {
+				onError(err)
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+// copyDatabase writes the live database to path with the online backup API
+func (m *Manager) copyDatabase(path string) error {
+	dest, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	return backupBetween(dest, m.db)
+}
+
+// restoreDatabase overwrites the live database with the one at path
+func (m *Manager) restoreDatabase(path string) error {
+	if !exists(path) {
+		return fmt.Errorf("%s is missing", dbFile)
+	}
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return backupBetween(m.db, src)
+}
+
+// backupBetween copies the main database of src into dest
+func backupBetween(dest, src *sql.DB) error {
+	ctx := context.Background()
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			d, ok := destRaw.(*sqlite3.SQLiteConn)
+			s, ok2 := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return errors.New("not a SQLite connection")
+			}
+
+			b, err := d.Backup("main", s, "main")
+			if err != nil {
+				return err
+			}
+			for {
+				done, err := b.Step(pagesPerStep)
+				if err != nil {
+					b.Close()
+					return err
+				}
+				if done {
+					return b.Finish()
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	})
+}
+
+// copyDir copies the regular files of src into dst and returns how many it
+// copied. A missing src is treated as empty.
+func copyDir(src, dst string) (int, error) {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if err := copyFile(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// replaceDir makes dst hold exactly the files in src
+func replaceDir(src, dst string) error {
+	keep := map[string]bool{}
+	if entries, err := os.ReadDir(src); err == nil {
+		for _, e := range entries {
+			keep[e.Name()] = true
+		}
+	}
+	if entries, err := os.ReadDir(dst); err == nil {
+		for _, e := range entries {
+			if e.Type().IsRegular() && !keep[e.Name()] {
+				if err := os.Remove(filepath.Join(dst, e.Name())); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	_, err := copyDir(src, dst)
+	return err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
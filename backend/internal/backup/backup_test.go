@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T) (*Manager, *sql.DB, string) {
+	dir := t.TempDir()
+	database, err := db.Initialize(filepath.Join(dir, "notes.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	require.NoError(t, db.Migrate(database))
+
+	uploads := filepath.Join(dir, "uploads")
+	require.NoError(t, os.MkdirAll(uploads, 0755))
+	return New(database, uploads, filepath.Join(dir, "backups"), 3), database, uploads
+}
+
+func accountNames(t *testing.T, database *sql.DB) []string {
+	rows, err := database.Query(`SELECT name FROM accounts ORDER BY name`)
+	require.NoError(t, err)
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	m, database, uploads := setup(t)
+	_, err := database.Exec(`INSERT INTO accounts (id, name) VALUES ('acc-1', 'Acme')`)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(uploads, "a.txt"), []byte("a"), 0644))
+
+	b, err := m.Create(ReasonManual)
+	require.NoError(t, err)
+	assert.Equal(t, 1, b.Files)
+	assert.Greater(t, b.Size, int64(0))
+
+	// Change everything after the snapshot
+	_, err = database.Exec(`INSERT INTO accounts (id, name) VALUES ('acc-2', 'Globex')`)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(uploads, "a.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(uploads, "b.txt"), []byte("b"), 0644))
+
+	safety, err := m.Restore(b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ReasonPreRestore, safety.Reason)
+
+	assert.Equal(t, []string{"Acme"}, accountNames(t, database))
+	assert.FileExists(t, filepath.Join(uploads, "a.txt"))
+	assert.NoFileExists(t, filepath.Join(uploads, "b.txt"))
+
+	// The safety snapshot undoes the restore
+	_, err = m.Restore(safety.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Acme", "Globex"}, accountNames(t, database))
+	assert.FileExists(t, filepath.Join(uploads, "b.txt"))
+}
+
+func TestListAndRetention(t *testing.T) {
+	m, _, _ := setup(t)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		b, err := m.Create(ReasonScheduled)
+		require.NoError(t, err)
+		ids = append(ids, b.ID)
+	}
+
+	backups, err := m.List()
+	require.NoError(t, err)
+	require.Len(t, backups, 3)
+	assert.Equal(t, ids[4], backups[0].ID, "newest first")
+	assert.Equal(t, ids[2], backups[2].ID)
+
+	_, err = m.Restore(ids[0])
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = m.Restore("../backups")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
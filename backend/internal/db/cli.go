@@ -0,0 +1,95 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const migrateUsage = `usage: migrate <command>
+
+commands:
+  status          list migrations and whether they are applied
+  up [version]    apply pending migrations (up to version, default latest)
+  down [steps]    revert the most recent migrations (default 1)
+  to <version>    migrate up or down to an exact version`
+
+// RunMigrateCommand implements the "migrate" subcommand shared by the server
+// and desktop binaries. args excludes the leading "migrate".
+func RunMigrateCommand(database *sql.DB, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "status":
+		return printStatus(database, out)
+
+	case "up":
+		target := LatestVersion()
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid version %q", args[1])
+			}
+			target = v
+		}
+		current, err := CurrentVersion(database)
+		if err != nil {
+			return err
+		}
+		if target < current {
+			return fmt.Errorf("version %d is below the current version %d; use \"down\" or \"to\"", target, current)
+		}
+		if err := MigrateTo(database, target); err != nil {
+			return err
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		if err := Rollback(database, steps); err != nil {
+			return err
+		}
+
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("missing target version\n\n%s", migrateUsage)
+		}
+		v, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := MigrateTo(database, v); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], migrateUsage)
+	}
+
+	return printStatus(database, out)
+}
+
+func printStatus(database *sql.DB, out io.Writer) error {
+	statuses, err := Status(database)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		state := "pending"
+		if s.Applied {
+			state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(out, "%4d  %-28s %s\n", s.Version, s.Name, state)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Migration is a single numbered schema change. Up and Down each run inside
+// their own transaction together with the schema_migrations bookkeeping, so a
+// failed migration never leaves the database half-applied.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error // nil if the migration cannot be reverted
+}
+
+// MigrationStatus describes whether a known migration has been applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// ErrIrreversible is returned when rolling back a migration without a Down step
+var ErrIrreversible = errors.New("migration cannot be reverted")
+
+// Migrate applies every pending migration in version order
+func Migrate(db *sql.DB) error {
+	return MigrateTo(db, LatestVersion())
+}
+
+// LatestVersion returns the highest migration version known to this build
+func LatestVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// CurrentVersion returns the highest applied migration version
+func CurrentVersion(db *sql.DB) (int, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// MigrateTo migrates the schema up or down until target is the highest
+// applied version
+func MigrateTo(db *sql.DB, target int) error {
+	if err := validateMigrations(); err != nil {
+		return err
+	}
+	if target < 0 || target > LatestVersion() {
+		return fmt.Errorf("unknown migration version %d (latest is %d)", target, LatestVersion())
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+	for version := range applied {
+		if findMigration(version) == nil {
+			return fmt.Errorf("database has migration %d applied, which is newer than this build (latest %d)", version, LatestVersion())
+		}
+	}
+
+	for _, m := range migrations {
+		if m.Version > target || applied[m.Version] {
+			continue
+		}
+		if err := runUp(db, m); err != nil {
+			return err
+		}
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= target || !applied[m.Version] {
+			continue
+		}
+		if err := runDown(db, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Rollback reverts the most recently applied migrations, newest first
+func Rollback(db *sql.DB, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("steps must be at least 1")
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	if steps > len(versions) {
+		steps = len(versions)
+	}
+
+	for _, version := range versions[:steps] {
+		m := findMigration(version)
+		if m == nil {
+			return fmt.Errorf("migration %d is not known to this build", version)
+		}
+		if err := runDown(db, *m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status reports every known migration and whether it has been applied
+func Status(db *sql.DB) ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := appliedAt[m.Version]; ok {
+			at := at
+			s.Applied = true
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+func appliedVersions(db *sql.DB) (map[int]bool, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+func runUp(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.Up(tx); err != nil {
+		return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func runDown(db *sql.DB, m Migration) error {
+	if m.Down == nil {
+		return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, ErrIrreversible)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.Down(tx); err != nil {
+		return fmt.Errorf("revert migration %d (%s): %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func findMigration(version int) *Migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+func validateMigrations() error {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return fmt.Errorf("migration %q has version %d, expected %d", m.Name, m.Version, i+1)
+		}
+		if m.Up == nil {
+			return fmt.Errorf("migration %d (%s) has no Up step", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// execAll runs each statement in order, stopping at the first error
+func execAll(tx *sql.Tx, statements ...string) error {
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column unless it already exists. Databases created before
+// schema_migrations existed may already have some of these columns.
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+	if columnExists(tx, table, column) {
+		return nil
+	}
+	_, err := tx.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
+// dropColumn removes a column if present
+func dropColumn(tx *sql.Tx, table, column string) error {
+	if !columnExists(tx, table, column) {
+		return nil
+	}
+	_, err := tx.Exec("ALTER TABLE " + table + " DROP COLUMN " + column)
+	return err
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	database, err := Initialize(filepath.Join(t.TempDir(), "notes.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	require.NoError(t, Migrate(database))
+
+	version, err := CurrentVersion(database)
+	require.NoError(t, err)
+	assert.Equal(t, LatestVersion(), version)
+
+	statuses, err := Status(database)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.True(t, s.Applied, "migration %d should be applied", s.Version)
+	}
+
+	// Running again is a no-op
+	require.NoError(t, Migrate(database))
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	database, err := Initialize(filepath.Join(t.TempDir(), "notes.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	// A database created before schema_migrations existed already has the
+	// columns that later migrations add.
+	_, err = database.Exec(`
+		CREATE TABLE accounts (id TEXT PRIMARY KEY, name TEXT NOT NULL, account_owner TEXT,
+			budget REAL, est_engineers INTEGER, created_at DATETIME, updated_at DATETIME, deleted_at DATETIME);
+		CREATE TABLE notes (id TEXT PRIMARY KEY, title TEXT NOT NULL, account_id TEXT NOT NULL,
+			template_type TEXT, internal_participants TEXT, external_participants TEXT, content TEXT,
+			meeting_id TEXT, meeting_date DATETIME, created_at DATETIME, updated_at DATETIME,
+			pinned INTEGER DEFAULT 0, archived INTEGER DEFAULT 0);
+		INSERT INTO accounts (id, name) VALUES ('acc-1', 'Acme');
+		INSERT INTO notes (id, title, account_id, pinned) VALUES ('note-1', 'Kickoff', 'acc-1', 1);
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, Migrate(database))
+	assert.True(t, columnExists(database, "notes", "sort_order"))
+	assert.True(t, columnExists(database, "contacts", "deleted_at"))
+
+	var pinned int
+	require.NoError(t, database.QueryRow("SELECT pinned FROM notes WHERE id = 'note-1'").Scan(&pinned))
+	assert.Equal(t, 1, pinned)
+}
+
+func TestRollback(t *testing.T) {
+	database, err := Initialize(filepath.Join(t.TempDir(), "notes.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	require.NoError(t, Migrate(database))
+
+	require.NoError(t, MigrateTo(database, 3))
+	version, err := CurrentVersion(database)
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+	assert.False(t, columnExists(database, "contacts", "id"))
+	assert.False(t, columnExists(database, "notes", "deleted_at"))
+
+	require.NoError(t, MigrateTo(database, 1))
+	version, err = CurrentVersion(database)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.False(t, columnExists(database, "todos", "account_id"))
+
+	require.NoError(t, Migrate(database))
+	assert.True(t, columnExists(database, "contacts", "deleted_at"))
+}
+
+func TestStatusWorkflowMigration(t *testing.T) {
+	database, err := Initialize(filepath.Join(t.TempDir(), "notes.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	require.NoError(t, MigrateTo(database, 17))
+	_, err = database.Exec(`
+		INSERT INTO todos (id, title, status) VALUES ('t1', 'Blank', ''), ('t2', 'Odd', 'waiting'), ('t3', 'Done', 'completed')
+	`)
+	require.NoError(t, err)
+	require.NoError(t, Migrate(database))
+
+	var status string
+	require.NoError(t, database.QueryRow("SELECT status FROM todos WHERE id = 't1'").Scan(&status))
+	assert.Equal(t, "not_started", status)
+
+	var category string
+	var position int
+	require.NoError(t, database.QueryRow("SELECT category, position FROM todo_statuses WHERE key = 'waiting'").Scan(&category, &position))
+	assert.Equal(t, "open", category, "unknown statuses join the workflow")
+	assert.Equal(t, 4, position)
+
+	var transitions int
+	require.NoError(t, database.QueryRow("SELECT COUNT(*) FROM todo_status_transitions").Scan(&transitions))
+	assert.Equal(t, 20, transitions, "every status can move to every other")
+}
+
+func TestTagNameCaseMigration(t *testing.T) {
+	database, err := Initialize(filepath.Join(t.TempDir(), "notes.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	require.NoError(t, MigrateTo(database, 18))
+	_, err = database.Exec(`
+		INSERT INTO tags (id, name, created_at) VALUES
+			('t1', 'Customer', '2026-01-01'), ('t2', 'customer', '2026-01-02'),
+			('t3', 'customer/poc', '2026-01-03'), ('t4', 'Customer/POC', '2026-01-04');
+		INSERT INTO todos (id, title) VALUES ('todo-1', 'Follow up');
+		INSERT INTO todo_tags (todo_id, tag_id) VALUES ('todo-1', 't1'), ('todo-1', 't2'), ('todo-1', 't4');
+	`)
+	require.NoError(t, err)
+	require.NoError(t, Migrate(database))
+
+	names := map[string]string{}
+	rows, err := database.Query("SELECT id, name FROM tags")
+	require.NoError(t, err)
+	for rows.Next() {
+		var id, name string
+		require.NoError(t, rows.Scan(&id, &name))
+		names[id] = name
+	}
+	require.NoError(t, rows.Err())
+	rows.Close()
+	assert.Equal(t, map[string]string{"t1": "Customer", "t3": "Customer/poc"}, names,
+		"the oldest spelling is kept and children follow their parent")
+
+	var tagged []string
+	rows, err = database.Query("SELECT tag_id FROM todo_tags WHERE todo_id = 'todo-1' ORDER BY tag_id")
+	require.NoError(t, err)
+	for rows.Next() {
+		var id string
+		require.NoError(t, rows.Scan(&id))
+		tagged = append(tagged, id)
+	}
+	rows.Close()
+	assert.Equal(t, []string{"t1", "t3"}, tagged)
+
+	_, err = database.Exec("INSERT INTO tags (id, name) VALUES ('t5', 'CUSTOMER')")
+	assert.Error(t, err, "names are unique regardless of case")
+}
@@ -0,0 +1,786 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// migrations is the ordered schema history. Never edit or renumber a
+// migration once it has shipped; add a new one instead.
+//
+// Migrations 1-6 reproduce the schema that used to be created ad hoc by
+// Migrate, so they tolerate databases where those objects already exist.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				// Accounts table
+				`CREATE TABLE IF NOT EXISTS accounts (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL,
+					account_owner TEXT,
+					budget REAL,
+					est_engineers INTEGER,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				)`,
+
+				// Notes table
+				`CREATE TABLE IF NOT EXISTS notes (
+					id TEXT PRIMARY KEY,
+					title TEXT NOT NULL,
+					account_id TEXT NOT NULL,
+					template_type TEXT DEFAULT 'initial',
+					internal_participants TEXT DEFAULT '[]',
+					external_participants TEXT DEFAULT '[]',
+					content TEXT DEFAULT '',
+					meeting_id TEXT,
+					meeting_date DATETIME,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+				)`,
+
+				// Todos table
+				`CREATE TABLE IF NOT EXISTS todos (
+					id TEXT PRIMARY KEY,
+					title TEXT NOT NULL,
+					description TEXT DEFAULT '',
+					status TEXT DEFAULT 'not_started',
+					priority TEXT DEFAULT 'medium',
+					due_date DATETIME,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				)`,
+
+				// Note-Todo junction table (many-to-many)
+				`CREATE TABLE IF NOT EXISTS note_todos (
+					note_id TEXT NOT NULL,
+					todo_id TEXT NOT NULL,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (note_id, todo_id),
+					FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+					FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+				)`,
+
+				// Full-text search for notes (using FTS4 for broader compatibility)
+				`CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts USING fts4(
+					title,
+					content,
+					content='notes',
+					tokenize=porter
+				)`,
+
+				// Triggers to keep FTS in sync
+				`CREATE TRIGGER IF NOT EXISTS notes_ai AFTER INSERT ON notes BEGIN
+					INSERT INTO notes_fts(docid, title, content) VALUES (NEW.rowid, NEW.title, NEW.content);
+				END`,
+
+				`CREATE TRIGGER IF NOT EXISTS notes_ad AFTER DELETE ON notes BEGIN
+					DELETE FROM notes_fts WHERE docid = OLD.rowid;
+				END`,
+
+				`CREATE TRIGGER IF NOT EXISTS notes_au AFTER UPDATE ON notes BEGIN
+					DELETE FROM notes_fts WHERE docid = OLD.rowid;
+					INSERT INTO notes_fts(docid, title, content) VALUES (NEW.rowid, NEW.title, NEW.content);
+				END`,
+
+				// Settings table for OAuth tokens and preferences
+				`CREATE TABLE IF NOT EXISTS settings (
+					key TEXT PRIMARY KEY,
+					value TEXT NOT NULL,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				)`,
+
+				// Tags table
+				`CREATE TABLE IF NOT EXISTS tags (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL UNIQUE,
+					color TEXT DEFAULT '#6b7280',
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				)`,
+
+				// Note-Tags junction table (many-to-many)
+				`CREATE TABLE IF NOT EXISTS note_tags (
+					note_id TEXT NOT NULL,
+					tag_id TEXT NOT NULL,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (note_id, tag_id),
+					FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+					FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+				)`,
+
+				// Activity log table
+				`CREATE TABLE IF NOT EXISTS activities (
+					id TEXT PRIMARY KEY,
+					account_id TEXT NOT NULL,
+					type TEXT NOT NULL,
+					title TEXT NOT NULL,
+					description TEXT DEFAULT '',
+					entity_type TEXT,
+					entity_id TEXT,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+				)`,
+
+				// Attachments table
+				`CREATE TABLE IF NOT EXISTS attachments (
+					id TEXT PRIMARY KEY,
+					note_id TEXT NOT NULL,
+					filename TEXT NOT NULL,
+					original_name TEXT NOT NULL,
+					mime_type TEXT NOT NULL,
+					size INTEGER NOT NULL,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+				)`,
+
+				// Indexes
+				`CREATE INDEX IF NOT EXISTS idx_notes_account_id ON notes(account_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_notes_meeting_date ON notes(meeting_date)`,
+				`CREATE INDEX IF NOT EXISTS idx_todos_status ON todos(status)`,
+				`CREATE INDEX IF NOT EXISTS idx_note_todos_note_id ON note_todos(note_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_note_todos_todo_id ON note_todos(todo_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_note_tags_note_id ON note_tags(note_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_note_tags_tag_id ON note_tags(tag_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_activities_account_id ON activities(account_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_attachments_note_id ON attachments(note_id)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`DROP TRIGGER IF EXISTS notes_ai`,
+				`DROP TRIGGER IF EXISTS notes_ad`,
+				`DROP TRIGGER IF EXISTS notes_au`,
+				`DROP TABLE IF EXISTS notes_fts`,
+				`DROP TABLE IF EXISTS attachments`,
+				`DROP TABLE IF EXISTS activities`,
+				`DROP TABLE IF EXISTS note_tags`,
+				`DROP TABLE IF EXISTS tags`,
+				`DROP TABLE IF EXISTS settings`,
+				`DROP TABLE IF EXISTS note_todos`,
+				`DROP TABLE IF EXISTS todos`,
+				`DROP TABLE IF EXISTS notes`,
+				`DROP TABLE IF EXISTS accounts`,
+			)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "todos_account_id",
+		Up: func(tx *sql.Tx) error {
+			if err := addColumn(tx, "todos", "account_id", "TEXT REFERENCES accounts(id)"); err != nil {
+				return err
+			}
+			return execAll(tx, `CREATE INDEX IF NOT EXISTS idx_todos_account_id ON todos(account_id)`)
+		},
+		Down: func(tx *sql.Tx) error {
+			if err := execAll(tx, `DROP INDEX IF EXISTS idx_todos_account_id`); err != nil {
+				return err
+			}
+			return dropColumn(tx, "todos", "account_id")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "pin_archive_sort_order",
+		Up: func(tx *sql.Tx) error {
+			if err := addColumn(tx, "notes", "pinned", "INTEGER DEFAULT 0"); err != nil {
+				return err
+			}
+			if err := addColumn(tx, "notes", "archived", "INTEGER DEFAULT 0"); err != nil {
+				return err
+			}
+			if err := addColumn(tx, "notes", "sort_order", "INTEGER DEFAULT 0"); err != nil {
+				return err
+			}
+			return addColumn(tx, "todos", "pinned", "INTEGER DEFAULT 0")
+		},
+		Down: func(tx *sql.Tx) error {
+			if err := dropColumn(tx, "todos", "pinned"); err != nil {
+				return err
+			}
+			if err := dropColumn(tx, "notes", "sort_order"); err != nil {
+				return err
+			}
+			if err := dropColumn(tx, "notes", "archived"); err != nil {
+				return err
+			}
+			return dropColumn(tx, "notes", "pinned")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "soft_delete",
+		Up: func(tx *sql.Tx) error {
+			for _, table := range []string{"notes", "todos", "accounts"} {
+				if err := addColumn(tx, table, "deleted_at", "DATETIME"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *sql.Tx) error {
+			for _, table := range []string{"accounts", "todos", "notes"} {
+				if err := dropColumn(tx, table, "deleted_at"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 5,
+		Name:    "contacts",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS contacts (
+					id TEXT PRIMARY KEY,
+					email TEXT NOT NULL UNIQUE,
+					name TEXT DEFAULT '',
+					company TEXT DEFAULT '',
+					domain TEXT NOT NULL,
+					is_internal INTEGER DEFAULT 0,
+					account_id TEXT,
+					suggested_account_id TEXT,
+					suggestion_confirmed INTEGER DEFAULT 0,
+					source TEXT DEFAULT 'manual',
+					first_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+					last_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+					meeting_count INTEGER DEFAULT 0,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE SET NULL,
+					FOREIGN KEY (suggested_account_id) REFERENCES accounts(id) ON DELETE SET NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_contacts_email ON contacts(email)`,
+				`CREATE INDEX IF NOT EXISTS idx_contacts_domain ON contacts(domain)`,
+				`CREATE INDEX IF NOT EXISTS idx_contacts_account_id ON contacts(account_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_contacts_is_internal ON contacts(is_internal)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE IF EXISTS contacts`)
+		},
+	},
+	{
+		Version: 6,
+		Name:    "contacts_soft_delete",
+		Up: func(tx *sql.Tx) error {
+			return addColumn(tx, "contacts", "deleted_at", "DATETIME")
+		},
+		Down: func(tx *sql.Tx) error {
+			return dropColumn(tx, "contacts", "deleted_at")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "note_revisions",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS note_revisions (
+					note_id TEXT NOT NULL,
+					rev INTEGER NOT NULL,
+					title TEXT NOT NULL,
+					content TEXT,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (note_id, rev),
+					FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_note_revisions_created_at ON note_revisions(created_at)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE IF EXISTS note_revisions`)
+		},
+	},
+	{
+		Version: 8,
+		Name:    "row_versions",
+		Up: func(tx *sql.Tx) error {
+			for _, table := range []string{"notes", "todos"} {
+				if err := addColumn(tx, table, "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *sql.Tx) error {
+			for _, table := range []string{"notes", "todos"} {
+				if err := dropColumn(tx, table, "version"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 9,
+		Name:    "search_index",
+		Up: func(tx *sql.Tx) error {
+			if err := execAll(tx,
+				`DROP TRIGGER IF EXISTS notes_ai`,
+				`DROP TRIGGER IF EXISTS notes_ad`,
+				`DROP TRIGGER IF EXISTS notes_au`,
+				`DROP TABLE IF EXISTS notes_fts`,
+
+				// One row per indexed note, todo, account or contact; its
+				// rowid is the rowid of the document in search_index
+				`CREATE TABLE IF NOT EXISTS search_docs (
+					rowid INTEGER PRIMARY KEY,
+					type TEXT NOT NULL,
+					id TEXT NOT NULL,
+					UNIQUE (type, id)
+				)`,
+
+				// Rows whose document needs rebuilding, filled by triggers
+				// and drained by the indexer in internal/search
+				`CREATE TABLE IF NOT EXISTS search_queue (
+					type TEXT NOT NULL,
+					id TEXT NOT NULL,
+					PRIMARY KEY (type, id)
+				) WITHOUT ROWID`,
+
+				SearchIndexTable(tx),
+			); err != nil {
+				return err
+			}
+			if err := execAll(tx, searchTriggers()...); err != nil {
+				return err
+			}
+			return execAll(tx,
+				`INSERT OR IGNORE INTO search_queue (type, id)
+					SELECT 'note', id FROM notes UNION ALL
+					SELECT 'todo', id FROM todos UNION ALL
+					SELECT 'account', id FROM accounts UNION ALL
+					SELECT 'contact', id FROM contacts`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			for _, q := range queued {
+				if err := execAll(tx, dropTriggers(q.table)...); err != nil {
+					return err
+				}
+			}
+			return execAll(tx,
+				`DROP TABLE IF EXISTS search_index`,
+				`DROP TABLE IF EXISTS search_queue`,
+				`DROP TABLE IF EXISTS search_docs`,
+				`CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts USING fts4(
+					title,
+					content,
+					content='notes',
+					tokenize=porter
+				)`,
+				`CREATE TRIGGER IF NOT EXISTS notes_ai AFTER INSERT ON notes BEGIN
+					INSERT INTO notes_fts(docid, title, content) VALUES (NEW.rowid, NEW.title, NEW.content);
+				END`,
+				`CREATE TRIGGER IF NOT EXISTS notes_ad AFTER DELETE ON notes BEGIN
+					DELETE FROM notes_fts WHERE docid = OLD.rowid;
+				END`,
+				`CREATE TRIGGER IF NOT EXISTS notes_au AFTER UPDATE ON notes BEGIN
+					DELETE FROM notes_fts WHERE docid = OLD.rowid;
+					INSERT INTO notes_fts(docid, title, content) VALUES (NEW.rowid, NEW.title, NEW.content);
+				END`,
+				`INSERT INTO notes_fts(notes_fts) VALUES ('rebuild')`,
+			)
+		},
+	},
+	{
+		Version: 10,
+		Name:    "attachment_text",
+		Up: func(tx *sql.Tx) error {
+			for _, col := range []struct{ name, definition string }{
+				{"text", "TEXT"},
+				{"page_count", "INTEGER"},
+				{"width", "INTEGER"},
+				{"height", "INTEGER"},
+				{"extracted_at", "DATETIME"},
+			} {
+				if err := addColumn(tx, "attachments", col.name, col.definition); err != nil {
+					return err
+				}
+			}
+			if err := execAll(tx, dropTriggers("attachments")...); err != nil {
+				return err
+			}
+			if err := execAll(tx, queueTriggers("attachments", attachmentQueued)...); err != nil {
+				return err
+			}
+			return execAll(tx, `INSERT OR IGNORE INTO search_queue (type, id) SELECT 'attachment', id FROM attachments`)
+		},
+		Down: func(tx *sql.Tx) error {
+			if err := execAll(tx, dropTriggers("attachments")...); err != nil {
+				return err
+			}
+			if err := execAll(tx, queueTriggers("attachments", attachmentQueued[:1])...); err != nil {
+				return err
+			}
+			if err := execAll(tx,
+				`DELETE FROM search_index WHERE rowid IN (SELECT rowid FROM search_docs WHERE type = 'attachment')`,
+				`DELETE FROM search_docs WHERE type = 'attachment'`,
+				`DELETE FROM search_queue WHERE type = 'attachment'`,
+			); err != nil {
+				return err
+			}
+			for _, col := range []string{"extracted_at", "height", "width", "page_count", "text"} {
+				if err := dropColumn(tx, "attachments", col); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 11,
+		Name:    "saved_searches",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS saved_searches (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+					query TEXT NOT NULL DEFAULT '',
+					filters TEXT NOT NULL DEFAULT '{}',
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE IF EXISTS saved_searches`)
+		},
+	},
+	{
+		Version: 12,
+		Name:    "note_embeddings",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS note_embeddings (
+					note_id TEXT PRIMARY KEY,
+					provider TEXT NOT NULL,
+					vector BLOB NOT NULL,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+				)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE IF EXISTS note_embeddings`)
+		},
+	},
+	{
+		Version: 13,
+		Name:    "todo_account_tags",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS todo_tags (
+					todo_id TEXT NOT NULL,
+					tag_id TEXT NOT NULL,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (todo_id, tag_id),
+					FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+					FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS account_tags (
+					account_id TEXT NOT NULL,
+					tag_id TEXT NOT NULL,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (account_id, tag_id),
+					FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
+					FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_todo_tags_tag_id ON todo_tags(tag_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_account_tags_tag_id ON account_tags(tag_id)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE IF EXISTS account_tags`, `DROP TABLE IF EXISTS todo_tags`)
+		},
+	},
+	{
+		Version: 14,
+		Name:    "recurring_todos",
+		Up: func(tx *sql.Tx) error {
+			// Every occurrence of a recurring todo is its own row; series_id
+			// groups them and occurrence counts from 1 for the rule's COUNT
+			for _, col := range []struct{ name, definition string }{
+				{"recurrence", "TEXT"},
+				{"series_id", "TEXT"},
+				{"occurrence", "INTEGER"},
+			} {
+				if err := addColumn(tx, "todos", col.name, col.definition); err != nil {
+					return err
+				}
+			}
+			return execAll(tx,
+				`CREATE INDEX IF NOT EXISTS idx_todos_series ON todos(series_id, occurrence)`,
+				`CREATE TABLE IF NOT EXISTS todo_completions (
+					id TEXT PRIMARY KEY,
+					series_id TEXT NOT NULL,
+					todo_id TEXT,
+					occurrence INTEGER NOT NULL,
+					due_date DATETIME,
+					completed_at DATETIME NOT NULL,
+					FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE SET NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_todo_completions_series ON todo_completions(series_id, completed_at)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			if err := execAll(tx,
+				`DROP TABLE IF EXISTS todo_completions`,
+				`DROP INDEX IF EXISTS idx_todos_series`,
+			); err != nil {
+				return err
+			}
+			for _, name := range []string{"occurrence", "series_id", "recurrence"} {
+				if err := dropColumn(tx, "todos", name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 15,
+		Name:    "todo_subtasks_dependencies",
+		Up: func(tx *sql.Tx) error {
+			// Deferred so that an import can insert a subtask before its
+			// parent
+			if err := addColumn(tx, "todos", "parent_id",
+				"TEXT REFERENCES todos(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED"); err != nil {
+				return err
+			}
+			return execAll(tx,
+				`CREATE INDEX IF NOT EXISTS idx_todos_parent_id ON todos(parent_id)`,
+				`CREATE TABLE IF NOT EXISTS todo_dependencies (
+					todo_id TEXT NOT NULL,
+					blocked_by TEXT NOT NULL,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (todo_id, blocked_by),
+					FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+					FOREIGN KEY (blocked_by) REFERENCES todos(id) ON DELETE CASCADE
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_todo_dependencies_blocked_by ON todo_dependencies(blocked_by)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			if err := execAll(tx,
+				`DROP TABLE IF EXISTS todo_dependencies`,
+				`DROP INDEX IF EXISTS idx_todos_parent_id`,
+			); err != nil {
+				return err
+			}
+			return dropColumn(tx, "todos", "parent_id")
+		},
+	},
+	{
+		Version: 16,
+		Name:    "todo_reminders",
+		Up: func(tx *sql.Tx) error {
+			// A JSON array of minutes before the due date, NULL for the
+			// configured default
+			if err := addColumn(tx, "todos", "reminder_minutes", "TEXT"); err != nil {
+				return err
+			}
+			// Rows only exist for reminders that fired or were snoozed
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS todo_reminders (
+					todo_id TEXT NOT NULL,
+					lead_minutes INTEGER NOT NULL,
+					fired_at DATETIME,
+					snoozed_until DATETIME,
+					PRIMARY KEY (todo_id, lead_minutes),
+					FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+				)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			if err := execAll(tx, `DROP TABLE IF EXISTS todo_reminders`); err != nil {
+				return err
+			}
+			return dropColumn(tx, "todos", "reminder_minutes")
+		},
+	},
+	{
+		Version: 17,
+		Name:    "todo_time_tracking",
+		Up: func(tx *sql.Tx) error {
+			if err := addColumn(tx, "todos", "estimate_minutes", "INTEGER"); err != nil {
+				return err
+			}
+			// ended_at and minutes are NULL while a timer runs, and only
+			// one timer can run per todo
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS time_entries (
+					id TEXT PRIMARY KEY,
+					todo_id TEXT NOT NULL,
+					started_at DATETIME NOT NULL,
+					ended_at DATETIME,
+					minutes INTEGER,
+					note TEXT NOT NULL DEFAULT '',
+					source TEXT NOT NULL DEFAULT 'timer',
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_time_entries_todo ON time_entries(todo_id, started_at)`,
+				`CREATE INDEX IF NOT EXISTS idx_time_entries_started ON time_entries(started_at)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_time_entries_running ON time_entries(todo_id) WHERE ended_at IS NULL`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			if err := execAll(tx, `DROP TABLE IF EXISTS time_entries`); err != nil {
+				return err
+			}
+			return dropColumn(tx, "todos", "estimate_minutes")
+		},
+	},
+	{
+		Version: 18,
+		Name:    "todo_status_workflow",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				// The board shows statuses by position. The category tells
+				// what a status means whatever it is called: "done" ends a
+				// todo and "blocked" is shown while a blocker is open.
+				`CREATE TABLE IF NOT EXISTS todo_statuses (
+					key TEXT PRIMARY KEY,
+					label TEXT NOT NULL,
+					category TEXT NOT NULL CHECK (category IN ('open', 'active', 'blocked', 'done')),
+					color TEXT NOT NULL DEFAULT '',
+					position INTEGER NOT NULL DEFAULT 0
+				)`,
+				`CREATE TABLE IF NOT EXISTS todo_status_transitions (
+					from_status TEXT NOT NULL,
+					to_status TEXT NOT NULL,
+					PRIMARY KEY (from_status, to_status),
+					FOREIGN KEY (from_status) REFERENCES todo_statuses(key) ON DELETE CASCADE,
+					FOREIGN KEY (to_status) REFERENCES todo_statuses(key) ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS todo_status_history (
+					id TEXT PRIMARY KEY,
+					todo_id TEXT NOT NULL,
+					from_status TEXT,
+					to_status TEXT NOT NULL,
+					changed_at DATETIME NOT NULL,
+					FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_todo_status_history_todo ON todo_status_history(todo_id, changed_at)`,
+				`CREATE INDEX IF NOT EXISTS idx_todo_status_history_changed ON todo_status_history(changed_at)`,
+
+				// The statuses todos always had, free to move between
+				`INSERT OR IGNORE INTO todo_statuses (key, label, category, position) VALUES
+					('not_started', 'Not Started', 'open', 0),
+					('in_progress', 'In Progress', 'active', 1),
+					('stuck', 'Stuck', 'blocked', 2),
+					('completed', 'Completed', 'done', 3)`,
+				`UPDATE todos SET status = 'not_started' WHERE status IS NULL OR status = ''`,
+				// Any other status a todo was given joins the workflow
+				`INSERT OR IGNORE INTO todo_statuses (key, label, category, position)
+					SELECT status, status, 'open', 3 + ROW_NUMBER() OVER (ORDER BY status)
+					FROM (SELECT DISTINCT status FROM todos)
+					WHERE status NOT IN (SELECT key FROM todo_statuses)`,
+				`INSERT OR IGNORE INTO todo_status_transitions (from_status, to_status)
+					SELECT f.key, t.key FROM todo_statuses f, todo_statuses t WHERE f.key != t.key`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`DROP TABLE IF EXISTS todo_status_history`,
+				`DROP TABLE IF EXISTS todo_status_transitions`,
+				`DROP TABLE IF EXISTS todo_statuses`,
+			)
+		},
+	},
+	{
+		Version: 19,
+		Name:    "tag_names_nocase",
+		Up: func(tx *sql.Tx) error {
+			if err := mergeTagCase(tx); err != nil {
+				return err
+			}
+			// SQLite can't change the collation of the existing UNIQUE
+			// constraint without rebuilding tags, which would cascade to
+			// every tag assignment, so the index enforces it instead
+			return execAll(tx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name_nocase ON tags(name COLLATE NOCASE)`)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP INDEX IF EXISTS idx_tags_name_nocase`)
+		},
+	},
+}
+
+// mergeTagCase makes tag names unique regardless of case. Parents come
+// first, so children are respelled under the name their parent kept, and a
+// tag whose name is then taken is merged into the oldest one holding it.
+func mergeTagCase(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, name FROM tags
+		ORDER BY length(name) - length(replace(name, '/', '')), created_at, rowid`)
+	if err != nil {
+		return err
+	}
+	type tag struct{ id, name string }
+	var tags []tag
+	for rows.Next() {
+		var t tag
+		if err := rows.Scan(&t.id, &t.name); err != nil {
+			rows.Close()
+			return err
+		}
+		tags = append(tags, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// kept maps a name folded the way NOCASE folds it to the tag keeping
+	// it. Renames wait until the tags merged away are gone, as one of them
+	// may hold the new name.
+	kept := map[string]tag{}
+	var renamed []tag
+	for _, t := range tags {
+		name := t.name
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			if parent, ok := kept[foldASCII(name[:i])]; ok {
+				name = parent.name + name[i:]
+			}
+		}
+		key := foldASCII(name)
+		into, ok := kept[key]
+		if !ok {
+			kept[key] = tag{t.id, name}
+			if name != t.name {
+				renamed = append(renamed, tag{t.id, name})
+			}
+			continue
+		}
+		for _, link := range [][2]string{{"note_tags", "note_id"}, {"todo_tags", "todo_id"}, {"account_tags", "account_id"}} {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO `+link[0]+` (`+link[1]+`, tag_id, created_at)
+				SELECT `+link[1]+`, ?, created_at FROM `+link[0]+` WHERE tag_id = ?`, into.id, t.id); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM tags WHERE id = ?", t.id); err != nil {
+			return err
+		}
+	}
+	for _, t := range renamed {
+		if _, err := tx.Exec("UPDATE tags SET name = ? WHERE id = ?", t.name, t.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// foldASCII lowercases ASCII letters only, as SQLite's NOCASE does
+func foldASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"fmt"
+)
+
+// FTS5Available reports whether the linked SQLite has FTS5, which go-sqlite3
+// only compiles in with the sqlite_fts5 build tag
+func FTS5Available(q querier) bool {
+	var fts5 bool
+	q.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5)
+	return fts5
+}
+
+// SearchIndexTable returns the statement creating search_index. Builds
+// without FTS5 get an FTS4 table with the same columns and tokenizer instead;
+// search only relies on what both support.
+func SearchIndexTable(q querier) string {
+	if FTS5Available(q) {
+		return `CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+			title, body, extra,
+			tokenize = 'unicode61 remove_diacritics 0'
+		)`
+	}
+	return `CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts4(
+		title, body, extra,
+		tokenize=unicode61 "remove_diacritics=0"
+	)`
+}
+
+// queued lists, for each table, the statements that queue the documents a
+// change to one of its rows affects. OLD or NEW is substituted for %[1]s;
+// updates run them for both, in case the change moved the row.
+var queued = []struct {
+	table      string
+	statements []string
+}{
+	{"notes", []string{`INSERT OR IGNORE INTO search_queue (type, id) VALUES ('note', %[1]s.id)`}},
+	{"todos", []string{`INSERT OR IGNORE INTO search_queue (type, id) VALUES ('todo', %[1]s.id)`}},
+	{"accounts", []string{`INSERT OR IGNORE INTO search_queue (type, id) VALUES ('account', %[1]s.id)`}},
+	{"contacts", []string{
+		`INSERT OR IGNORE INTO search_queue (type, id) VALUES ('contact', %[1]s.id)`,
+		// Notes index the names of their participants
+		`INSERT OR IGNORE INTO search_queue (type, id)
+			SELECT 'note', id FROM notes
+			WHERE instr(LOWER(COALESCE(internal_participants, '') || ' ' || COALESCE(external_participants, '')), LOWER(%[1]s.email)) > 0`,
+	}},
+	{"note_tags", []string{`INSERT OR IGNORE INTO search_queue (type, id) VALUES ('note', %[1]s.note_id)`}},
+	{"tags", []string{`INSERT OR IGNORE INTO search_queue (type, id) SELECT 'note', note_id FROM note_tags WHERE tag_id = %[1]s.id`}},
+	{"attachments", []string{`INSERT OR IGNORE INTO search_queue (type, id) VALUES ('note', %[1]s.note_id)`}},
+}
+
+// searchTriggers returns the statements creating the triggers that keep
+// search_queue filled
+func searchTriggers() []string {
+	var stmts []string
+	for _, q := range queued {
+		stmts = append(stmts, queueTriggers(q.table, q.statements)...)
+	}
+	return stmts
+}
+
+// queueTriggers returns the statements creating the insert, update and
+// delete triggers on table that run statements
+func queueTriggers(table string, statements []string) []string {
+	var stmts []string
+	for _, event := range []struct {
+		name, when string
+		rows       []string
+	}{
+		{"ai", "INSERT", []string{"NEW"}},
+		{"au", "UPDATE", []string{"OLD", "NEW"}},
+		{"ad", "DELETE", []string{"OLD"}},
+	} {
+		body := ""
+		for _, row := range event.rows {
+			for _, stmt := range statements {
+				body += fmt.Sprintf(stmt, row) + ";\n"
+			}
+		}
+		stmts = append(stmts, fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS search_%s_%s AFTER %s ON %s BEGIN\n%sEND",
+			table, event.name, event.when, table, body))
+	}
+	return stmts
+}
+
+// attachmentQueued replaces the attachments entry of queued from migration
+// 10, when attachments became documents of their own
+var attachmentQueued = []string{
+	`INSERT OR IGNORE INTO search_queue (type, id) VALUES ('note', %[1]s.note_id)`,
+	`INSERT OR IGNORE INTO search_queue (type, id) VALUES ('attachment', %[1]s.id)`,
+}
+
+func dropTriggers(table string) []string {
+	var stmts []string
+	for _, event := range []string{"ai", "au", "ad"} {
+		stmts = append(stmts, "DROP TRIGGER IF EXISTS search_"+table+"_"+event)
+	}
+	return stmts
+}
@@ -0,0 +1,122 @@
+// Package diff computes line-based differences between two versions of a
+// note. Note content is TipTap HTML, so SplitHTML first breaks it into one
+// line per block element to give readable hunks.
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Op is the kind of change a line represents
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Line is a single line of diff output
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// blockEnd matches the closing tags after which TipTap starts a new block
+var blockEnd = regexp.MustCompile(`(?i)(</(p|h[1-6]|li|ul|ol|blockquote|pre|tr|table)>|<br\s*/?>|<hr\s*/?>)`)
+
+// SplitHTML breaks HTML into lines at block boundaries, dropping blank lines
+func SplitHTML(html string) []string {
+	marked := blockEnd.ReplaceAllString(html, "$1\n")
+	lines := []string{}
+	for _, line := range strings.Split(marked, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Lines returns the edit script turning a into b, using the longest common
+// subsequence of lines. Deletions are listed before insertions within a hunk.
+func Lines(a, b []string) []Line {
+	// Trim the common prefix and suffix so the table only covers the edit
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	out := make([]Line, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		out = append(out, Line{Op: Equal, Text: text})
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	out = append(out, lcsDiff(ma, mb)...)
+
+	for _, text := range a[len(a)-suffix:] {
+		out = append(out, Line{Op: Equal, Text: text})
+	}
+	return out
+}
+
+func lcsDiff(a, b []string) []Line {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	out := []Line{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, Line{Op: Equal, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{Op: Delete, Text: a[i]})
+			i++
+		default:
+			out = append(out, Line{Op: Insert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, Line{Op: Delete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, Line{Op: Insert, Text: b[j]})
+	}
+	return out
+}
+
+// Stats counts the inserted and deleted lines in a diff
+func Stats(lines []Line) (added, removed int) {
+	for _, l := range lines {
+		switch l.Op {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
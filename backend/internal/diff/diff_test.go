@@ -0,0 +1,34 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitHTML(t *testing.T) {
+	lines := SplitHTML("<h2>Agenda</h2><p>Intro</p><ul><li>One</li><li>Two</li></ul>")
+	assert.Equal(t, []string{"<h2>Agenda</h2>", "<p>Intro</p>", "<ul><li>One</li>", "<li>Two</li>", "</ul>"}, lines)
+}
+
+func TestLines(t *testing.T) {
+	a := []string{"a", "b", "c", "d"}
+	b := []string{"a", "x", "c", "d", "e"}
+
+	got := Lines(a, b)
+	assert.Equal(t, []Line{
+		{Equal, "a"},
+		{Delete, "b"},
+		{Insert, "x"},
+		{Equal, "c"},
+		{Equal, "d"},
+		{Insert, "e"},
+	}, got)
+
+	added, removed := Stats(got)
+	assert.Equal(t, 2, added)
+	assert.Equal(t, 1, removed)
+
+	assert.Empty(t, Lines(nil, nil))
+	assert.Equal(t, []Line{{Insert, "a"}}, Lines(nil, []string{"a"}))
+}
@@ -0,0 +1,200 @@
+// Package embed turns text into vectors whose cosine similarity reflects
+// how close two texts are in topic. Providers are pluggable; the default is
+// a local one that hashes words and character n-grams, so it needs no model
+// files or network access.
+package embed
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Provider computes embeddings
+type Provider interface {
+	// Name identifies the provider and its settings. It is stored with each
+	// vector, and vectors stored under another name are recomputed.
+	Name() string
+	// Embed returns one vector per text, all of the same length
+	Embed(texts []string) ([][]float32, error)
+}
+
+// DefaultProvider is the provider used unless another is configured
+const DefaultProvider = "hashed"
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]func() (Provider, error){
+		DefaultProvider: func() (Provider, error) { return NewHashed(DefaultDims), nil },
+	}
+)
+
+// Register makes a provider available to New under name
+func Register(name string, factory func() (Provider, error)) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// New creates the provider registered under name
+func New(name string) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown embedding provider %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+	return factory()
+}
+
+// Names lists the registered providers
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultDims is the length of the vectors the default provider makes
+const DefaultDims = 512
+
+// Hashed embeds text by hashing its words, word pairs and the character
+// trigrams of each word into a fixed number of dimensions. Trigrams let
+// different forms of a word, such as "integrate" and "integration", count
+// as partly the same.
+type Hashed struct {
+	dims int
+}
+
+// NewHashed returns a hashed provider making vectors of dims dimensions
+func NewHashed(dims int) *Hashed {
+	return &Hashed{dims: dims}
+}
+
+func (h *Hashed) Name() string { return fmt.Sprintf("hashed-%d", h.dims) }
+
+func (h *Hashed) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = h.embed(text)
+	}
+	return vectors, nil
+}
+
+// Feature weights: whole words count most, trigrams least since a word has
+// several of them
+const (
+	wordWeight    = 1.0
+	pairWeight    = 0.5
+	trigramWeight = 0.25
+)
+
+func (h *Hashed) embed(text string) []float32 {
+	v := make([]float32, h.dims)
+	prev := ""
+	for _, w := range words(text) {
+		if stopWords[w] {
+			prev = ""
+			continue
+		}
+		h.add(v, "w:"+w, wordWeight)
+		if prev != "" {
+			h.add(v, "p:"+prev+" "+w, pairWeight)
+		}
+		prev = w
+		padded := []rune("<" + w + ">")
+		for i := 0; i+3 <= len(padded); i++ {
+			h.add(v, "t:"+string(padded[i:i+3]), trigramWeight)
+		}
+	}
+	normalize(v)
+	return v
+}
+
+// add hashes a feature to a dimension, with a sign from the hash so that
+// collisions tend to cancel out rather than pile up
+func (h *Hashed) add(v []float32, feature string, weight float32) {
+	f := fnv.New64a()
+	f.Write([]byte(feature))
+	sum := f.Sum64()
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	v[sum%uint64(h.dims)] += weight
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a about after all also an and any are as at be been but by can could did do
+		does for from had has have he her his how i if in into is it its just me more my no not of on or our out
+		she so some than that the their them then there these they this to up us was we were what when which
+		who will with would you your`) {
+		stopWords[w] = true
+	}
+}
+
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 if either is
+// zero or their lengths differ
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// Encode packs a vector for storage as little-endian float32s
+func Encode(v []float32) []byte {
+	data := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(x))
+	}
+	return data
+}
+
+// Decode unpacks a vector written by Encode
+func Decode(data []byte) []float32 {
+	v := make([]float32, len(data)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return v
+}
@@ -0,0 +1,51 @@
+package embed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashed(t *testing.T) {
+	p, err := New(DefaultProvider)
+	require.NoError(t, err)
+	assert.Equal(t, "hashed-512", p.Name())
+
+	vectors, err := p.Embed([]string{
+		"Kubernetes cluster migration plan for the platform team",
+		"Planning the migration of our Kubernetes clusters",
+		"Quarterly pricing and renewal discount negotiation",
+		"",
+	})
+	require.NoError(t, err)
+	require.Len(t, vectors, 4)
+	for _, v := range vectors {
+		assert.Len(t, v, DefaultDims)
+	}
+
+	related := Cosine(vectors[0], vectors[1])
+	unrelated := Cosine(vectors[0], vectors[2])
+	assert.Greater(t, related, 0.3)
+	assert.Less(t, unrelated, 0.15)
+	assert.InDelta(t, 1, Cosine(vectors[0], vectors[0]), 1e-6)
+	assert.Zero(t, Cosine(vectors[0], vectors[3]), "empty text")
+
+	again, _ := p.Embed([]string{"Kubernetes cluster migration plan for the platform team"})
+	assert.Equal(t, vectors[0], again[0], "deterministic")
+}
+
+func TestEncode(t *testing.T) {
+	v := []float32{0.5, -1.25, 0, 3e-8}
+	assert.Equal(t, v, Decode(Encode(v)))
+}
+
+func TestRegister(t *testing.T) {
+	Register("tiny", func() (Provider, error) { return NewHashed(8), nil })
+	p, err := New("tiny")
+	require.NoError(t, err)
+	assert.Equal(t, "hashed-8", p.Name())
+
+	_, err = New("missing")
+	assert.ErrorContains(t, err, "expected one of hashed, tiny")
+}
@@ -0,0 +1,268 @@
+// Package extract pulls plain text and basic metadata out of attached files
+// so that they can be searched and previewed. It reads PDFs, Word documents,
+// text-like files and HTML, and the dimensions of images.
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// MaxText caps the text kept from one file, in bytes
+const MaxText = 1 << 20
+
+// ErrUnsupported is returned for files nothing can be extracted from
+var ErrUnsupported = errors.New("unsupported file type")
+
+// Result is what was extracted from a file. Pages is set for PDFs and Word
+// documents that record it, Width and Height for images.
+type Result struct {
+	Text   string
+	Pages  int
+	Width  int
+	Height int
+}
+
+// Kinds of file
+const (
+	kindPDF   = "pdf"
+	kindDOCX  = "docx"
+	kindText  = "text"
+	kindHTML  = "html"
+	kindImage = "image"
+)
+
+const docxType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true, ".csv": true, ".tsv": true,
+	".json": true, ".log": true, ".xml": true, ".yaml": true, ".yml": true,
+}
+
+var tagPattern = regexp.MustCompile(`(?s)<(script|style)[^>]*>.*?</(script|style)>|<[^>]*>`)
+
+// File extracts from the file at path. name is the original filename and
+// mimeType the type it was uploaded with; the content is sniffed when
+// neither says what the file is.
+func File(path, name, mimeType string) (*Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch kind(name, mimeType, head[:n]) {
+	case kindPDF:
+		return pdfText(f)
+	case kindDOCX:
+		return docxText(path)
+	case kindText:
+		return plain(f, false)
+	case kindHTML:
+		return plain(f, true)
+	case kindImage:
+		cfg, _, err := image.DecodeConfig(f)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Width: cfg.Width, Height: cfg.Height}, nil
+	}
+	return nil, ErrUnsupported
+}
+
+func kind(name, mimeType string, head []byte) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	switch {
+	case ext == ".pdf" || mimeType == "application/pdf":
+		return kindPDF
+	case ext == ".docx" || mimeType == docxType:
+		return kindDOCX
+	case ext == ".html" || ext == ".htm" || mimeType == "text/html":
+		return kindHTML
+	case textExtensions[ext] || strings.HasPrefix(mimeType, "text/"):
+		return kindText
+	case strings.HasPrefix(mimeType, "image/"):
+		return kindImage
+	}
+
+	sniffed := http.DetectContentType(head)
+	switch {
+	case sniffed == "application/pdf":
+		return kindPDF
+	case strings.HasPrefix(sniffed, "text/html"):
+		return kindHTML
+	case strings.HasPrefix(sniffed, "text/"):
+		return kindText
+	case strings.HasPrefix(sniffed, "image/"):
+		return kindImage
+	}
+	return ""
+}
+
+func pdfText(f *os.File) (res *Result, err error) {
+	// The PDF reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = nil, fmt.Errorf("reading pdf: %v", r)
+		}
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	r, err := pdf.NewReader(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("reading pdf: %w", err)
+	}
+	text, err := r.GetPlainText()
+	if err != nil {
+		return nil, fmt.Errorf("reading pdf: %w", err)
+	}
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(text, MaxText)); err != nil {
+		return nil, err
+	}
+	return &Result{Text: clean(buf.String()), Pages: r.NumPage()}, nil
+}
+
+// docxText reads the paragraphs of word/document.xml and the page count
+// Word saves in docProps/app.xml
+func docxText(path string) (*Result, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading docx: %w", err)
+	}
+	defer zr.Close()
+
+	res := &Result{}
+	found := false
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			found = true
+			if res.Text, err = readZipped(f, docxBody); err != nil {
+				return nil, fmt.Errorf("reading docx: %w", err)
+			}
+		case "docProps/app.xml":
+			readZipped(f, func(r io.Reader) (string, error) {
+				var props struct {
+					Pages int `xml:"Pages"`
+				}
+				err := xml.NewDecoder(r).Decode(&props)
+				res.Pages = props.Pages
+				return "", err
+			})
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("reading docx: no word/document.xml")
+	}
+	return res, nil
+}
+
+func readZipped(f *zip.File, read func(io.Reader) (string, error)) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return read(io.LimitReader(rc, 64*MaxText))
+}
+
+// docxBody collects the text runs of a document body, ending a line at
+// every paragraph and break
+func docxBody(r io.Reader) (string, error) {
+	var sb strings.Builder
+	dec := xml.NewDecoder(r)
+	inText := false
+	for sb.Len() < MaxText {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br", "cr":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+	return clean(sb.String()), nil
+}
+
+func plain(r io.Reader, markup bool) (*Result, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxText))
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ToValidUTF8(string(data), "")
+	if markup {
+		text = html.UnescapeString(tagPattern.ReplaceAllString(text, "\n"))
+	}
+	return &Result{Text: clean(text)}, nil
+}
+
+// clean trims every line, drops blank ones and caps the length
+func clean(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	text = strings.Join(lines, "\n")
+	if len(text) > MaxText {
+		text = text[:MaxText]
+		for !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+	}
+	return text
+}
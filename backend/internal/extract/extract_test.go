@@ -0,0 +1,82 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func write(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return path
+}
+
+func TestPDF(t *testing.T) {
+	doc := fpdf.New("P", "mm", "A4", "")
+	doc.SetFont("Helvetica", "", 12)
+	doc.AddPage()
+	doc.Cell(40, 10, "Quarterly pricing roadmap")
+	doc.AddPage()
+	doc.Cell(40, 10, "Renewal terms")
+	var buf bytes.Buffer
+	require.NoError(t, doc.Output(&buf))
+
+	// The type comes from the content when the name and type don't say
+	res, err := File(write(t, "upload", buf.Bytes()), "upload", "application/octet-stream")
+	require.NoError(t, err)
+	assert.Equal(t, "Quarterly pricing roadmap\nRenewal terms", res.Text)
+	assert.Equal(t, 2, res.Pages)
+
+	_, err = File(write(t, "broken.pdf", []byte("%PDF-1.4 not really")), "broken.pdf", "application/pdf")
+	assert.Error(t, err)
+}
+
+func TestDOCX(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte(`<?xml version="1.0"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Statement of</w:t></w:r><w:r><w:t xml:space="preserve"> work</w:t></w:r></w:p>
+<w:p><w:r><w:t>Phase</w:t><w:tab/><w:t>one</w:t></w:r></w:p>
+</w:body></w:document>`))
+	w, _ = zw.Create("docProps/app.xml")
+	w.Write([]byte(`<?xml version="1.0"?><Properties><Pages>3</Pages></Properties>`))
+	require.NoError(t, zw.Close())
+
+	res, err := File(write(t, "sow.docx", buf.Bytes()), "SOW.docx", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Statement of work\nPhase one", res.Text)
+	assert.Equal(t, 3, res.Pages)
+}
+
+func TestTextAndHTML(t *testing.T) {
+	res, err := File(write(t, "notes.md", []byte("# Agenda\n\n  - pricing   review \n")), "notes.md", "")
+	require.NoError(t, err)
+	assert.Equal(t, "# Agenda\n- pricing review", res.Text)
+
+	res, err = File(write(t, "page.html", []byte(`<html><style>p{}</style><p>Tom &amp; Jerry</p><script>x()</script></html>`)), "page.html", "text/html")
+	require.NoError(t, err)
+	assert.Equal(t, "Tom & Jerry", res.Text)
+}
+
+func TestImage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 32, 18))))
+
+	res, err := File(write(t, "shot.png", buf.Bytes()), "shot.png", "image/png")
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Width: 32, Height: 18}, res)
+
+	_, err = File(write(t, "blob.bin", []byte{0, 1, 2, 3, 0xff}), "blob.bin", "application/octet-stream")
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
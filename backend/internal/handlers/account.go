@@ -1,63 +1,38 @@
 package handlers
 
 import (
-	"database/sql"
 	"net/http"
-	"strings"
-	"time"
 
 	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
+// GetAccounts lists accounts not in the trash, filtered by owner and tags
 func (h *Handler) GetAccounts(c *gin.Context) {
-	rows, err := h.db.Query(`
-		SELECT id, name, account_owner, budget, est_engineers, created_at, updated_at 
-		FROM accounts WHERE deleted_at IS NULL ORDER BY name ASC
-	`)
+	o, err := listOptions(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	accounts := []models.Account{}
-	for rows.Next() {
-		var a models.Account
-		var accountOwner sql.NullString
-		if err := rows.Scan(&a.ID, &a.Name, &accountOwner, &a.Budget, &a.EstEngineers, &a.CreatedAt, &a.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if accountOwner.Valid {
-			a.AccountOwner = accountOwner.String
-		}
-		accounts = append(accounts, a)
+	f := store.AccountFilter{Owner: c.Query("owner")}
+	if f.Tags, f.MatchAny, err = tagsParam(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	accounts, info, err := h.accounts.List(f, o)
+	if err != nil {
+		respondListError(c, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, accounts)
+	respondList(c, accounts, info)
 }
 
 func (h *Handler) GetAccount(c *gin.Context) {
-	id := c.Param("id")
-	var a models.Account
-	var accountOwner sql.NullString
-	err := h.db.QueryRow(`
-		SELECT id, name, account_owner, budget, est_engineers, created_at, updated_at 
-		FROM accounts WHERE id = ? AND deleted_at IS NULL
-	`, id).Scan(&a.ID, &a.Name, &accountOwner, &a.Budget, &a.EstEngineers, &a.CreatedAt, &a.UpdatedAt)
-
-	if accountOwner.Valid {
-		a.AccountOwner = accountOwner.String
-	}
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
-		return
-	}
+	a, err := h.accounts.Get(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStoreError(c, err, "Account not found")
 		return
 	}
 
@@ -76,28 +51,18 @@ func (h *Handler) CreateAccount(c *gin.Context) {
 		return
 	}
 
-	id := uuid.New().String()
-	now := time.Now()
-
-	_, err := h.db.Exec(`
-		INSERT INTO accounts (id, name, account_owner, budget, est_engineers, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, id, req.Name, req.AccountOwner, req.Budget, req.EstEngineers, now, now)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusCreated, models.Account{
-		ID:           id,
+	a := &models.Account{
 		Name:         req.Name,
 		AccountOwner: req.AccountOwner,
 		Budget:       req.Budget,
 		EstEngineers: req.EstEngineers,
-		CreatedAt:    now,
-		UpdatedAt:    now,
-	})
+	}
+	if err := h.accounts.Create(a); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, a)
 }
 
 func (h *Handler) UpdateAccount(c *gin.Context) {
@@ -108,46 +73,19 @@ func (h *Handler) UpdateAccount(c *gin.Context) {
 		return
 	}
 
-	// Build dynamic update query
-	updates := []string{}
-	args := []interface{}{}
-
-	if req.Name != nil {
-		updates = append(updates, "name = ?")
-		args = append(args, *req.Name)
-	}
-	if req.AccountOwner != nil {
-		updates = append(updates, "account_owner = ?")
-		args = append(args, *req.AccountOwner)
-	}
-	if req.Budget != nil {
-		updates = append(updates, "budget = ?")
-		args = append(args, *req.Budget)
-	}
-	if req.EstEngineers != nil {
-		updates = append(updates, "est_engineers = ?")
-		args = append(args, *req.EstEngineers)
+	update := store.AccountUpdate{
+		Name:         req.Name,
+		AccountOwner: req.AccountOwner,
+		Budget:       req.Budget,
+		EstEngineers: req.EstEngineers,
 	}
-
-	if len(updates) == 0 {
+	if update.IsEmpty() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
 		return
 	}
 
-	updates = append(updates, "updated_at = ?")
-	args = append(args, time.Now())
-	args = append(args, id)
-
-	query := "UPDATE accounts SET " + strings.Join(updates, ", ") + " WHERE id = ?"
-	result, err := h.db.Exec(query, args...)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+	if err := h.accounts.Update(id, update); err != nil {
+		respondStoreError(c, err, "Account not found")
 		return
 	}
 
@@ -156,17 +94,8 @@ func (h *Handler) UpdateAccount(c *gin.Context) {
 }
 
 func (h *Handler) DeleteAccount(c *gin.Context) {
-	id := c.Param("id")
-	// Soft delete
-	result, err := h.db.Exec("UPDATE accounts SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+	if err := h.accounts.Delete(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Account not found")
 		return
 	}
 
@@ -174,65 +103,41 @@ func (h *Handler) DeleteAccount(c *gin.Context) {
 }
 
 func (h *Handler) RestoreAccount(c *gin.Context) {
-	id := c.Param("id")
-	result, err := h.db.Exec("UPDATE accounts SET deleted_at = NULL WHERE id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+	if err := h.accounts.Restore(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Account not found")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Account restored"})
 }
 
 func (h *Handler) PermanentDeleteAccount(c *gin.Context) {
-	id := c.Param("id")
-	result, err := h.db.Exec("DELETE FROM accounts WHERE id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+	if err := h.accounts.PermanentDelete(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Account not found")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Account permanently deleted"})
 }
 
 func (h *Handler) GetDeletedAccounts(c *gin.Context) {
-	rows, err := h.db.Query(`
-		SELECT id, name, account_owner, budget, est_engineers, created_at, updated_at, deleted_at 
-		FROM accounts WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC
-	`)
+	list, err := h.accounts.ListDeleted()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	accounts := []map[string]interface{}{}
-	for rows.Next() {
-		var a models.Account
-		var accountOwner sql.NullString
-		var deletedAt sql.NullString
-		if err := rows.Scan(&a.ID, &a.Name, &accountOwner, &a.Budget, &a.EstEngineers, &a.CreatedAt, &a.UpdatedAt, &deletedAt); err != nil {
-			continue
-		}
-		acc := map[string]interface{}{
+	accounts := []gin.H{}
+	for _, a := range list {
+		acc := gin.H{
 			"id":            a.ID,
 			"name":          a.Name,
 			"budget":        a.Budget,
 			"est_engineers": a.EstEngineers,
 			"created_at":    a.CreatedAt,
 			"updated_at":    a.UpdatedAt,
-			"deleted_at":    deletedAt.String,
+			"deleted_at":    a.DeletedAt,
 		}
-		if accountOwner.Valid {
-			acc["account_owner"] = accountOwner.String
+		if a.AccountOwner != "" {
+			acc["account_owner"] = a.AccountOwner
 		}
 		accounts = append(accounts, acc)
 	}
@@ -242,12 +147,11 @@ func (h *Handler) GetDeletedAccounts(c *gin.Context) {
 
 // EmptyAccountsTrash permanently deletes all soft-deleted accounts
 func (h *Handler) EmptyAccountsTrash(c *gin.Context) {
-	result, err := h.db.Exec(`DELETE FROM accounts WHERE deleted_at IS NOT NULL`)
+	count, err := h.accounts.EmptyTrash()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	rows, _ := result.RowsAffected()
-	c.JSON(http.StatusOK, gin.H{"message": "Trash emptied", "count": rows})
+	c.JSON(http.StatusOK, gin.H{"message": "Trash emptied", "count": count})
 }
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/markdown"
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/pdf"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// accountBundle is everything exported for an account, loaded up front so
+// that database errors can still be reported before the zip starts streaming
+type accountBundle struct {
+	account     *models.Account
+	notes       []*models.Note
+	noteTags    map[string][]models.Tag
+	attachments map[string][]models.Attachment
+	todos       []models.Todo
+	activities  []models.Activity
+	contacts    []models.Contact
+}
+
+// exportManifest describes the contents of an account bundle
+type exportManifest struct {
+	Account     manifestAccount  `json:"account"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Counts      map[string]int   `json:"counts"`
+	Notes       []manifestNote   `json:"notes"`
+	Files       []string         `json:"files"`
+	Missing     []manifestMissed `json:"missing_attachments,omitempty"`
+}
+
+type manifestAccount struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type manifestNote struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Markdown    string   `json:"markdown"`
+	PDF         string   `json:"pdf"`
+	Attachments []string `json:"attachments"`
+}
+
+type manifestMissed struct {
+	NoteID   string `json:"note_id"`
+	Filename string `json:"filename"`
+}
+
+// ExportAccount downloads a zip bundle of an account: every note as
+// Markdown and PDF, todos as CSV, the activity timeline, contacts, the
+// attachment files and a manifest.json describing it all.
+func (h *Handler) ExportAccount(c *gin.Context) {
+	if format := c.DefaultQuery("format", "zip"); format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported export format, must be 'zip'"})
+		return
+	}
+
+	bundle, err := h.loadAccountBundle(c.Param("id"))
+	if err != nil {
+		respondStoreError(c, err, "Account not found")
+		return
+	}
+
+	filename := exportFilename(bundle.account.Name+" export "+time.Now().Format("2006-01-02"), "zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	// Headers are sent by now, so a failure can only cut the archive short
+	if err := h.writeAccountBundle(c.Writer, bundle); err != nil {
+		log.Printf("Account export for %s failed: %v", bundle.account.ID, err)
+	}
+}
+
+func (h *Handler) loadAccountBundle(accountID string) (*accountBundle, error) {
+	account, err := h.accounts.Get(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &accountBundle{
+		account:     account,
+		noteTags:    map[string][]models.Tag{},
+		attachments: map[string][]models.Attachment{},
+	}
+
+	list, err := h.notes.ListByAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range list {
+		n, err := h.notes.Get(summary.ID)
+		if err != nil {
+			return nil, err
+		}
+		b.notes = append(b.notes, n)
+		if b.noteTags[n.ID], err = h.tags.ListForNote(n.ID); err != nil {
+			return nil, err
+		}
+		if b.attachments[n.ID], err = h.noteAttachments(n.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.todos, err = h.todos.ListByAccount(accountID); err != nil {
+		return nil, err
+	}
+	if b.activities, err = h.accountActivities(accountID, -1); err != nil {
+		return nil, err
+	}
+	if b.contacts, _, err = h.contacts.List(store.ContactFilter{AccountID: accountID}, store.ListOptions{}); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (h *Handler) writeAccountBundle(w io.Writer, b *accountBundle) error {
+	zw := zip.NewWriter(w)
+	names := uniqueNames{}
+	manifest := exportManifest{
+		Account:     manifestAccount{ID: b.account.ID, Name: b.account.Name},
+		GeneratedAt: time.Now().UTC(),
+		Notes:       []manifestNote{},
+		Files:       []string{},
+	}
+	add := func(name string, content []byte) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(content); err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, name)
+		return nil
+	}
+
+	account, err := json.MarshalIndent(b.account, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := add("account.json", account); err != nil {
+		return err
+	}
+
+	attachmentCount := 0
+	for _, n := range b.notes {
+		base := names.take("notes/" + noteFileBase(n))
+		entry := manifestNote{ID: n.ID, Title: n.Title, Markdown: base + ".md", PDF: base + ".pdf", Attachments: []string{}}
+
+		md, err := markdown.Render(noteFrontMatter(n, b.noteTags[n.ID]), n.Content)
+		if err != nil {
+			return err
+		}
+		if err := add(entry.Markdown, md); err != nil {
+			return err
+		}
+
+		var doc bytes.Buffer
+		if err := pdf.Render(&doc, pdf.Note{
+			Note:        n,
+			Tags:        b.noteTags[n.ID],
+			Attachments: b.attachments[n.ID],
+			ImagePath:   h.uploadPath,
+		}); err != nil {
+			return err
+		}
+		if err := add(entry.PDF, doc.Bytes()); err != nil {
+			return err
+		}
+
+		for _, a := range b.attachments[n.ID] {
+			name := names.take("attachments/" + path.Base(base) + "/" + safeName(a.OriginalName))
+			copied, err := h.addAttachment(zw, name, a)
+			if err != nil {
+				return err
+			}
+			if !copied {
+				manifest.Missing = append(manifest.Missing, manifestMissed{NoteID: n.ID, Filename: a.OriginalName})
+				continue
+			}
+			manifest.Files = append(manifest.Files, name)
+			entry.Attachments = append(entry.Attachments, name)
+			attachmentCount++
+		}
+		manifest.Notes = append(manifest.Notes, entry)
+	}
+
+	todos, err := todosCSV(b.todos)
+	if err != nil {
+		return err
+	}
+	if err := add("todos.csv", todos); err != nil {
+		return err
+	}
+
+	activities, err := json.MarshalIndent(b.activities, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := add("activities.json", activities); err != nil {
+		return err
+	}
+
+	contacts, err := contactsCSV(b.contacts)
+	if err != nil {
+		return err
+	}
+	if err := add("contacts.csv", contacts); err != nil {
+		return err
+	}
+
+	manifest.Counts = map[string]int{
+		"notes":       len(b.notes),
+		"todos":       len(b.todos),
+		"activities":  len(b.activities),
+		"contacts":    len(b.contacts),
+		"attachments": attachmentCount,
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// addAttachment copies an uploaded file into the archive. It reports false
+// when the file is no longer on disk.
+func (h *Handler) addAttachment(zw *zip.Writer, name string, a models.Attachment) (bool, error) {
+	src, err := os.Open(h.uploadPath("/uploads/" + a.Filename))
+	if err != nil {
+		return false, nil
+	}
+	defer src.Close()
+
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.CreatedAt}
+	dst, err := zw.CreateHeader(header)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func todosCSV(todos []models.Todo) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"id", "title", "description", "status", "priority", "due_date", "pinned", "linked_notes", "created_at", "updated_at"})
+	for _, t := range todos {
+		var notes []string
+		for _, n := range t.Notes {
+			notes = append(notes, n.Title)
+		}
+		w.Write([]string{
+			t.ID, t.Title, t.Description, t.Status, t.Priority, csvTime(t.DueDate),
+			fmt.Sprint(t.Pinned), strings.Join(notes, "; "),
+			t.CreatedAt.Format(time.RFC3339), t.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func contactsCSV(contacts []models.Contact) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"email", "name", "company", "internal", "meeting_count", "first_seen", "last_seen", "source"})
+	for _, ct := range contacts {
+		w.Write([]string{
+			ct.Email, ct.Name, ct.Company, fmt.Sprint(ct.IsInternal), fmt.Sprint(ct.MeetingCount),
+			ct.FirstSeen.Format(time.RFC3339), ct.LastSeen.Format(time.RFC3339), ct.Source,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// noteFileBase names a note's files by meeting (or creation) date and title
+// so they sort chronologically
+func noteFileBase(n *models.Note) string {
+	date := n.CreatedAt
+	if n.MeetingDate != nil {
+		date = *n.MeetingDate
+	}
+	return date.Format("2006-01-02") + " " + strings.TrimSuffix(exportFilename(n.Title, ""), ".")
+}
+
+// safeName strips path separators and control characters from a filename
+func safeName(name string) string {
+	return strings.TrimSuffix(exportFilename(name, ""), ".")
+}
+
+// uniqueNames hands out archive paths, numbering repeats so that notes or
+// attachments with the same name do not overwrite each other
+type uniqueNames map[string]bool
+
+func (u uniqueNames) take(name string) string {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; u[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+	}
+	u[strings.ToLower(candidate)] = true
+	return candidate
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/factory-sagar/notes-droid/backend/internal/models"
@@ -10,9 +11,23 @@ import (
 )
 
 func (h *Handler) GetActivities(c *gin.Context) {
-	accountID := c.Param("id")
-	limit := c.DefaultQuery("limit", "50")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		limit = 50
+	}
+
+	activities, err := h.accountActivities(c.Param("id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
+	c.JSON(http.StatusOK, activities)
+}
+
+// accountActivities returns an account's timeline, newest first. A negative
+// limit returns everything.
+func (h *Handler) accountActivities(accountID string, limit int) ([]models.Activity, error) {
 	rows, err := h.db.Query(`
 		SELECT id, account_id, type, title, description, entity_type, entity_id, created_at
 		FROM activities
@@ -21,8 +36,7 @@ func (h *Handler) GetActivities(c *gin.Context) {
 		LIMIT ?
 	`, accountID, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -32,8 +46,7 @@ func (h *Handler) GetActivities(c *gin.Context) {
 		rows.Scan(&a.ID, &a.AccountID, &a.Type, &a.Title, &a.Description, &a.EntityType, &a.EntityID, &a.CreatedAt)
 		activities = append(activities, a)
 	}
-
-	c.JSON(http.StatusOK, activities)
+	return activities, rows.Err()
 }
 
 func (h *Handler) CreateActivity(c *gin.Context) {
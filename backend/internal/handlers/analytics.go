@@ -3,8 +3,10 @@ package handlers
 import (
 	"database/sql"
 	"net/http"
+	"time"
 
 	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
 	"github.com/gin-gonic/gin"
 )
 
@@ -116,3 +118,23 @@ func (h *Handler) GetIncompleteFields(c *gin.Context) {
 
 	c.JSON(http.StatusOK, incomplete)
 }
+
+// GetTimeAnalytics rolls up the time logged on todos per account and per
+// week, optionally for one account_id and between from and to
+func (h *Handler) GetTimeAnalytics(c *gin.Context) {
+	f := store.TimeFilter{AccountID: c.Query("account_id")}
+	var err error
+	for name, dest := range map[string]**time.Time{"from": &f.From, "to": &f.To} {
+		if *dest, err = dateParam(c, name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	report, err := h.timesheet.Report(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -1,39 +1,73 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/factory-sagar/notes-droid/backend/internal/extract"
 	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 func (h *Handler) GetAttachments(c *gin.Context) {
-	noteID := c.Param("id")
+	attachments, err := h.noteAttachments(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
+	c.JSON(http.StatusOK, attachments)
+}
+
+// attachmentPreviewLength is how many characters of extracted text an
+// attachment's text_preview holds
+const attachmentPreviewLength = 280
+
+// noteAttachments lists a note's attachments, newest first
+func (h *Handler) noteAttachments(noteID string) ([]models.Attachment, error) {
 	rows, err := h.db.Query(`
-		SELECT id, note_id, filename, original_name, mime_type, size, created_at
+		SELECT id, note_id, filename, original_name, mime_type, size,
+		       COALESCE(page_count, 0), COALESCE(width, 0), COALESCE(height, 0),
+		       SUBSTR(COALESCE(text, ''), 1, ?), created_at
 		FROM attachments
 		WHERE note_id = ?
 		ORDER BY created_at DESC
-	`, noteID)
+	`, attachmentPreviewLength, noteID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
 	attachments := []models.Attachment{}
 	for rows.Next() {
 		var a models.Attachment
-		rows.Scan(&a.ID, &a.NoteID, &a.Filename, &a.OriginalName, &a.MimeType, &a.Size, &a.CreatedAt)
+		rows.Scan(&a.ID, &a.NoteID, &a.Filename, &a.OriginalName, &a.MimeType, &a.Size,
+			&a.PageCount, &a.Width, &a.Height, &a.TextPreview, &a.CreatedAt)
 		attachments = append(attachments, a)
 	}
+	return attachments, rows.Err()
+}
 
-	c.JSON(http.StatusOK, attachments)
+// uploadPath maps an /uploads/ URL, as used for images in note content, to
+// the file in the uploads directory. Other URLs map to "".
+func (h *Handler) uploadPath(src string) string {
+	i := strings.Index(src, "/uploads/")
+	if i < 0 {
+		return ""
+	}
+	name := filepath.Base(strings.TrimPrefix(src[i:], "/uploads/"))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return filepath.Join(h.uploadsDir, name)
 }
 
 func (h *Handler) UploadAttachment(c *gin.Context) {
@@ -65,7 +99,7 @@ func (h *Handler) UploadAttachment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.Attachment{
+	a := models.Attachment{
 		ID:           id,
 		NoteID:       noteID,
 		Filename:     filename,
@@ -73,9 +107,71 @@ func (h *Handler) UploadAttachment(c *gin.Context) {
 		MimeType:     file.Header.Get("Content-Type"),
 		Size:         file.Size,
 		CreatedAt:    now,
+	}
+	if err := h.extractAttachment(&a); err != nil {
+		log.Printf("Error saving text of attachment %s: %v", id, err)
+	}
+
+	c.JSON(http.StatusCreated, a)
+}
+
+// extractAttachment stores the text and metadata extracted from an
+// attachment's file. Files nothing can be read from are marked as extracted
+// all the same, so that they are not tried again.
+func (h *Handler) extractAttachment(a *models.Attachment) error {
+	res, err := extract.File(filepath.Join(h.uploadsDir, a.Filename), a.OriginalName, a.MimeType)
+	if err != nil {
+		if !errors.Is(err, extract.ErrUnsupported) {
+			log.Printf("Error extracting text from attachment %s: %v", a.ID, err)
+		}
+		res = &extract.Result{}
+	}
+
+	a.PageCount, a.Width, a.Height = res.Pages, res.Width, res.Height
+	a.TextPreview = res.Text
+	if runes := []rune(res.Text); len(runes) > attachmentPreviewLength {
+		a.TextPreview = string(runes[:attachmentPreviewLength])
+	}
+
+	return h.attachments.SetExtracted(a.ID, store.AttachmentExtract{
+		Text: res.Text, Pages: res.Pages, Width: res.Width, Height: res.Height,
 	})
 }
 
+// ExtractAttachments extracts the text of attachments that have not been
+// through extraction yet, such as those uploaded before it existed
+func (h *Handler) ExtractAttachments() {
+	pending, err := h.attachments.Unextracted()
+	if err != nil {
+		log.Printf("Error listing attachments to extract: %v", err)
+		return
+	}
+	for i := range pending {
+		if err := h.extractAttachment(&pending[i]); err != nil {
+			log.Printf("Error saving text of attachment %s: %v", pending[i].ID, err)
+		}
+	}
+}
+
+// GetAttachmentText returns all the text extracted from an attachment
+func (h *Handler) GetAttachmentText(c *gin.Context) {
+	id := c.Param("attachmentId")
+	var text sql.NullString
+	var pages sql.NullInt64
+	err := h.db.QueryRow("SELECT text, page_count FROM attachments WHERE id = ? AND note_id = ?", id, c.Param("id")).
+		Scan(&text, &pages)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id, "text": text.String, "page_count": pages.Int64})
+}
+
 func (h *Handler) DeleteAttachment(c *gin.Context) {
 	id := c.Param("attachmentId")
 
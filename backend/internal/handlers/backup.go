@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/backup"
+	"github.com/gin-gonic/gin"
+)
+
+// GetBackupInterval returns how often a backup is taken automatically.
+// Set BACKUP_INTERVAL to a duration such as "6h" to customize, or to "0" to
+// turn scheduled backups off (default: 24h)
+func GetBackupInterval() time.Duration {
+	if v := os.Getenv("BACKUP_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return 24 * time.Hour
+}
+
+// GetBackupKeep returns how many backups are kept before the oldest are
+// deleted. Set BACKUP_KEEP to customize, 0 keeps all (default: 7)
+func GetBackupKeep() int {
+	if v, err := strconv.Atoi(os.Getenv("BACKUP_KEEP")); err == nil && v >= 0 {
+		return v
+	}
+	return 7
+}
+
+// GetBackupDir returns where backups are written. Set BACKUP_DIR to
+// customize (default: a backups directory next to the uploads directory)
+func GetBackupDir(uploadsDir string) string {
+	if dir := os.Getenv("BACKUP_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(uploadsDir), "backups")
+}
+
+// RunBackupSchedule takes backups every GetBackupInterval until stop is
+// closed
+func (h *Handler) RunBackupSchedule(stop <-chan struct{}) {
+	h.backups.Schedule(GetBackupInterval(), stop, func(err error) {
+		log.Printf("Scheduled backup failed: %v", err)
+	})
+}
+
+// GetBackups lists backups, newest first
+func (h *Handler) GetBackups(c *gin.Context) {
+	backups, err := h.backups.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, backups)
+}
+
+// CreateBackup takes a backup right away
+func (h *Handler) CreateBackup(c *gin.Context) {
+	b, err := h.backups.Create(backup.ReasonManual)
+	if err != nil && b == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Backup %s: %v", b.ID, err)
+	}
+	c.JSON(http.StatusCreated, b)
+}
+
+// RestoreBackup replaces the database and uploads with a backup, after
+// backing up the current state
+func (h *Handler) RestoreBackup(c *gin.Context) {
+	id := c.Param("id")
+	safety, err := h.backups.Restore(id)
+	if errors.Is(err, backup.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+		return
+	}
+	if err != nil {
+		resp := gin.H{"error": err.Error()}
+		if safety != nil {
+			resp["safety_backup"] = safety.ID
+		}
+		c.JSON(http.StatusInternalServerError, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Backup restored",
+		"restored":      id,
+		"safety_backup": safety.ID,
+	})
+}
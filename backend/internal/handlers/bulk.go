@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// BulkNotesOperation makes one change to many notes. Either every note is
+// changed or none is; the results say which notes stood in the way.
+func (h *Handler) BulkNotesOperation(c *gin.Context) {
+	var req models.BulkNotesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.NoteIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No notes selected"})
+		return
+	}
+
+	var op store.NoteBulkOp
+	yes, no := true, false
+	switch req.Action {
+	case "set_account":
+		accountID, ok := h.bulkAccount(c, req.Value)
+		if !ok {
+			return
+		}
+		op.Update.AccountID = &accountID
+	case "add_tag", "remove_tag":
+		tagID, _ := req.Value["tag_id"].(string)
+		if _, err := h.tags.Get(tagID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Tag not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		if req.Action == "add_tag" {
+			op.AddTag = tagID
+		} else {
+			op.RemoveTag = tagID
+		}
+	case "archive":
+		op.Update.Archived = &yes
+	case "unarchive":
+		op.Update.Archived = &no
+	case "pin":
+		op.Update.Pinned = &yes
+	case "unpin":
+		op.Update.Pinned = &no
+	case "delete":
+		op.Delete = true
+	case "restore":
+		op.Restore = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
+		return
+	}
+
+	err := h.notes.Bulk(req.NoteIDs, op)
+	respondBulk(c, req.NoteIDs, err, func(err error) string {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return "Note not found"
+		case errors.Is(err, store.ErrTrashed):
+			return "Note is in the trash"
+		}
+		return err.Error()
+	})
+}
+
+// BulkTodosOperation makes one change to many todos, all or nothing like
+// BulkNotesOperation. A status change must be allowed by the workflow for
+// every todo.
+func (h *Handler) BulkTodosOperation(c *gin.Context) {
+	var req models.BulkTodosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.TodoIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No todos selected"})
+		return
+	}
+
+	var op store.TodoBulkOp
+	switch req.Action {
+	case "set_status":
+		status, _ := req.Value["status"].(string)
+		if status == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid value for status"})
+			return
+		}
+		op.Update.Status = &status
+	case "set_priority":
+		priority, _ := req.Value["priority"].(string)
+		if priority != "high" && priority != "medium" && priority != "low" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid value for priority"})
+			return
+		}
+		op.Update.Priority = &priority
+	case "set_due_date":
+		due, _ := req.Value["due_date"].(string)
+		parsed, err := time.Parse(time.RFC3339, due)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due date format"})
+			return
+		}
+		op.Update.DueDate = &parsed
+	case "set_account":
+		accountID, ok := h.bulkAccount(c, req.Value)
+		if !ok {
+			return
+		}
+		op.Update.AccountID = &accountID
+	case "delete":
+		op.Delete = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
+		return
+	}
+
+	err := h.todos.Bulk(req.TodoIDs, op)
+	respondBulk(c, req.TodoIDs, err, func(err error) string {
+		var transitionErr *store.TransitionError
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return "Todo not found"
+		case errors.Is(err, store.ErrTrashed):
+			return "Todo is in the trash"
+		case errors.Is(err, store.ErrUnknownStatus):
+			return "Unknown status"
+		case errors.As(err, &transitionErr):
+			return fmt.Sprintf("Status cannot change from %s to %s", transitionErr.From, transitionErr.To)
+		}
+		return err.Error()
+	})
+}
+
+// bulkAccount reads the account a bulk change moves items to, responding
+// with an error unless it exists
+func (h *Handler) bulkAccount(c *gin.Context, value map[string]interface{}) (string, bool) {
+	accountID, _ := value["account_id"].(string)
+	if _, err := h.accounts.Get(accountID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Account not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return "", false
+	}
+	return accountID, true
+}
+
+// respondBulk reports the outcome of a bulk change for each of ids. When
+// items failed, message describes why for each, and the rest are reported
+// as not changed, since they were rolled back with them.
+func respondBulk(c *gin.Context, ids []string, err error, message func(error) string) {
+	var bulkErr *store.BulkError
+	if err != nil && !errors.As(err, &bulkErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := []models.BulkResult{}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result := models.BulkResult{ID: id, OK: true}
+		if bulkErr != nil {
+			result.OK = false
+			result.Error = "Not changed, another item failed"
+			if itemErr, failed := bulkErr.Items[id]; failed {
+				result.Error = message(itemErr)
+			}
+		}
+		results = append(results, result)
+	}
+
+	if bulkErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   fmt.Sprintf("No changes were made: %d of %d items failed", len(bulkErr.Items), len(results)),
+			"results": results,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": len(results), "results": results})
+}
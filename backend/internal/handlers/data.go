@@ -1,149 +1,115 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
+	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/factory-sagar/notes-droid/backend/internal/transfer"
 	"github.com/gin-gonic/gin"
 )
 
-// ExportAllData exports all data as JSON
+// ExportAllData exports every table as a versioned JSON document. With
+// attachments=base64 the attachment files are embedded in it; format=zip
+// downloads the document as data.json with the files alongside.
 func (h *Handler) ExportAllData(c *gin.Context) {
-	export := make(map[string]interface{})
-
-	// Export accounts
-	accounts := []map[string]interface{}{}
-	rows, _ := h.db.Query(`SELECT id, name, account_owner, budget, est_engineers, created_at, updated_at FROM accounts`)
-	if rows != nil {
-		defer rows.Close()
-		for rows.Next() {
-			var id, name string
-			var accountOwner sql.NullString
-			var budget sql.NullFloat64
-			var estEngineers sql.NullInt64
-			var createdAt, updatedAt string
-			rows.Scan(&id, &name, &accountOwner, &budget, &estEngineers, &createdAt, &updatedAt)
-			accounts = append(accounts, map[string]interface{}{
-				"id":            id,
-				"name":          name,
-				"account_owner": accountOwner.String,
-				"budget":        budget.Float64,
-				"est_engineers": estEngineers.Int64,
-				"created_at":    createdAt,
-				"updated_at":    updatedAt,
-			})
-		}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported export format, must be 'json' or 'zip'"})
+		return
 	}
-	export["accounts"] = accounts
-
-	// Export notes
-	notes := []map[string]interface{}{}
-	rows2, _ := h.db.Query(`SELECT id, title, account_id, template_type, internal_participants, external_participants, content, meeting_id, meeting_date, pinned, archived, created_at, updated_at FROM notes WHERE deleted_at IS NULL`)
-	if rows2 != nil {
-		defer rows2.Close()
-		for rows2.Next() {
-			var id, title, accountID, templateType, internalPart, externalPart, content string
-			var meetingID, meetingDate sql.NullString
-			var pinned, archived int
-			var createdAt, updatedAt string
-			rows2.Scan(&id, &title, &accountID, &templateType, &internalPart, &externalPart, &content, &meetingID, &meetingDate, &pinned, &archived, &createdAt, &updatedAt)
-			notes = append(notes, map[string]interface{}{
-				"id":                    id,
-				"title":                 title,
-				"account_id":            accountID,
-				"template_type":         templateType,
-				"internal_participants": internalPart,
-				"external_participants": externalPart,
-				"content":               content,
-				"meeting_id":            meetingID.String,
-				"meeting_date":          meetingDate.String,
-				"pinned":                pinned == 1,
-				"archived":              archived == 1,
-				"created_at":            createdAt,
-				"updated_at":            updatedAt,
-			})
-		}
+
+	embed := format == "json" && c.Query("attachments") == "base64"
+	doc, err := transfer.Export(h.db, h.uploadsDir, embed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	export["notes"] = notes
-
-	// Export todos
-	todos := []map[string]interface{}{}
-	rows3, _ := h.db.Query(`SELECT id, title, description, status, priority, due_date, account_id, pinned, created_at, updated_at FROM todos WHERE deleted_at IS NULL`)
-	if rows3 != nil {
-		defer rows3.Close()
-		for rows3.Next() {
-			var id, title, description, status, priority string
-			var dueDate, accountID sql.NullString
-			var pinned int
-			var createdAt, updatedAt string
-			rows3.Scan(&id, &title, &description, &status, &priority, &dueDate, &accountID, &pinned, &createdAt, &updatedAt)
-			todos = append(todos, map[string]interface{}{
-				"id":          id,
-				"title":       title,
-				"description": description,
-				"status":      status,
-				"priority":    priority,
-				"due_date":    dueDate.String,
-				"account_id":  accountID.String,
-				"pinned":      pinned == 1,
-				"created_at":  createdAt,
-				"updated_at":  updatedAt,
-			})
-		}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, doc)
+		return
 	}
-	export["todos"] = todos
-
-	// Export tags
-	tags := []map[string]interface{}{}
-	rows4, _ := h.db.Query(`SELECT id, name, color, created_at FROM tags`)
-	if rows4 != nil {
-		defer rows4.Close()
-		for rows4.Next() {
-			var id, name, color, createdAt string
-			rows4.Scan(&id, &name, &color, &createdAt)
-			tags = append(tags, map[string]interface{}{
-				"id":         id,
-				"name":       name,
-				"color":      color,
-				"created_at": createdAt,
-			})
-		}
+
+	filename := "notes-export-" + time.Now().Format("2006-01-02") + ".zip"
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	if err := transfer.WriteZip(c.Writer, doc, h.uploadsDir); err != nil {
+		log.Printf("Export failed: %v", err)
 	}
-	export["tags"] = tags
-
-	// Export contacts
-	contacts := []map[string]interface{}{}
-	rows5, _ := h.db.Query(`SELECT id, email, name, company, domain, is_internal, account_id, meeting_count, created_at FROM contacts`)
-	if rows5 != nil {
-		defer rows5.Close()
-		for rows5.Next() {
-			var id, email, name, company, domain string
-			var isInternal, meetingCount int
-			var accountID sql.NullString
-			var createdAt string
-			rows5.Scan(&id, &email, &name, &company, &domain, &isInternal, &accountID, &meetingCount, &createdAt)
-			contacts = append(contacts, map[string]interface{}{
-				"id":            id,
-				"email":         email,
-				"name":          name,
-				"company":       company,
-				"domain":        domain,
-				"is_internal":   isInternal == 1,
-				"account_id":    accountID.String,
-				"meeting_count": meetingCount,
-				"created_at":    createdAt,
-			})
-		}
+}
+
+// ImportData loads a document written by ExportAllData, either as the JSON
+// request body or as an uploaded .json or .zip file. mode=merge (default)
+// adds to the existing data and mode=replace swaps it out; on_conflict
+// decides what a merge does with rows that already exist. Everything runs
+// in one transaction, and dry_run=true rolls it back after building the
+// report.
+func (h *Handler) ImportData(c *gin.Context) {
+	opts := transfer.Options{
+		Mode:       transfer.Mode(c.DefaultQuery("mode", string(transfer.Merge))),
+		OnConflict: transfer.OnConflict(c.DefaultQuery("on_conflict", string(transfer.Skip))),
+		DryRun:     c.Query("dry_run") == "true",
+		UploadsDir: h.uploadsDir,
+	}
+	if opts.Mode != transfer.Merge && opts.Mode != transfer.Replace {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode, must be 'merge' or 'replace'"})
+		return
+	}
+	switch opts.OnConflict {
+	case transfer.Skip, transfer.Overwrite, transfer.Fail:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid on_conflict, must be 'skip', 'overwrite' or 'fail'"})
+		return
+	}
+
+	doc, err := readImportDocument(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import file: " + err.Error()})
+		return
 	}
-	export["contacts"] = contacts
 
-	export["exported_at"] = time.Now().Format(time.RFC3339)
-	export["version"] = "1.0"
+	report, err := transfer.Import(h.db, doc, opts)
+	var rowErr *transfer.RowError
+	switch {
+	case errors.Is(err, transfer.ErrConflicts):
+		c.JSON(http.StatusConflict, gin.H{"error": "Import conflicts with existing data", "report": report})
+	case errors.As(err, &rowErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": rowErr.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
 
-	c.JSON(http.StatusOK, export)
+// readImportDocument reads the document from a multipart "file" upload, or
+// from the body when it is sent as JSON
+func readImportDocument(c *gin.Context) (*transfer.Document, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return transfer.Decode(c.Request.Body)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, errors.New("no file uploaded")
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if isZip(header.Filename) {
+		return transfer.ReadZip(f, header.Size)
+	}
+	return transfer.Decode(f)
 }
 
 // ClearAllData deletes all user data
@@ -152,12 +118,21 @@ func (h *Handler) ClearAllData(c *gin.Context) {
 	tables := []string{
 		"note_todos",
 		"note_tags",
+		"todo_tags",
+		"account_tags",
 		"attachments",
 		"activities",
 		"contacts",
+		"todo_completions",
+		"todo_dependencies",
+		"todo_reminders",
+		"time_entries",
+		"todo_status_history",
 		"todos",
+		"note_revisions",
 		"notes",
 		"tags",
+		"saved_searches",
 		"accounts",
 	}
 
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setETag exposes a row version so clients can send it back in If-Match
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// expectedVersion returns the version an update must match, taken from the
+// If-Match header or else the request body. A nil result means the client
+// did not ask for a check. On a malformed header it writes a 400 and
+// returns false.
+func expectedVersion(c *gin.Context, bodyVersion *int) (*int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return bodyVersion, true
+	}
+	if header == "*" {
+		return nil, true
+	}
+
+	value := strings.TrimPrefix(header, "W/")
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return nil, false
+	}
+	return &version, true
+}
@@ -2,20 +2,86 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/backup"
+	"github.com/factory-sagar/notes-droid/backend/internal/embed"
+	"github.com/factory-sagar/notes-droid/backend/internal/remind"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/gin-gonic/gin"
 )
 
-// Handler holds database connection and provides HTTP handlers
+// Handler holds the data stores and provides HTTP handlers
 type Handler struct {
 	db         *sql.DB
 	uploadsDir string
+
+	notes       store.NoteStore
+	todos       store.TodoStore
+	accounts    store.AccountStore
+	contacts    store.ContactStore
+	tags        store.TagStore
+	revisions   store.RevisionStore
+	searches    store.SavedSearchStore
+	reminders   store.ReminderStore
+	timesheet   store.TimeStore
+	workflow    store.WorkflowStore
+	attachments store.AttachmentStore
+
+	// embedder embeds notes for semantic search
+	embedder embed.Provider
+
+	backups *backup.Manager
+
+	// scheduler fires reminders and reminderStream passes them on to the
+	// web UI
+	scheduler      *remind.Scheduler
+	reminderStream *remind.Broadcaster
 }
 
 // New creates a new Handler
 func New(db *sql.DB) *Handler {
-	return &Handler{db: db, uploadsDir: "./data/uploads"}
+	return NewWithUploadsDir(db, "./data/uploads")
 }
 
 // NewWithUploadsDir creates a new Handler with custom uploads directory
 func NewWithUploadsDir(db *sql.DB, uploadsDir string) *Handler {
-	return &Handler{db: db, uploadsDir: uploadsDir}
+	return NewWithStore(db, store.NewSQLite(db), uploadsDir)
+}
+
+// NewWithStore creates a new Handler backed by the given stores. db is still
+// used by handlers that have not moved to a store (search, analytics, etc).
+func NewWithStore(db *sql.DB, s *store.Store, uploadsDir string) *Handler {
+	stream := remind.NewBroadcaster()
+	return &Handler{
+		db:          db,
+		uploadsDir:  uploadsDir,
+		notes:       s.Notes,
+		todos:       s.Todos,
+		accounts:    s.Accounts,
+		contacts:    s.Contacts,
+		tags:        s.Tags,
+		revisions:   s.Revisions,
+		searches:    s.Searches,
+		reminders:   s.Reminders,
+		timesheet:   s.Time,
+		workflow:    s.Workflow,
+		attachments: s.Attachments,
+		embedder:    embeddingProvider(),
+		backups:     backup.New(db, uploadsDir, GetBackupDir(uploadsDir), GetBackupKeep()),
+
+		scheduler:      reminderScheduler(s.Reminders, stream),
+		reminderStream: stream,
+	}
+}
+
+// respondStoreError writes the response for a failed store call, using
+// notFound as the message when the row does not exist
+func respondStoreError(c *gin.Context, err error, notFound string) {
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 }
@@ -1,16 +1,26 @@
 package handlers
 
 import (
+	"archive/zip"
 	"bytes"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	database "github.com/factory-sagar/notes-droid/backend/internal/db"
 	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/search"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
 	"github.com/gin-gonic/gin"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
@@ -32,7 +42,8 @@ func setupTestDB(t *testing.T) *sql.DB {
 		budget REAL,
 		est_engineers INTEGER,
 		created_at DATETIME,
-		updated_at DATETIME
+		updated_at DATETIME,
+		deleted_at DATETIME
 	);
 	CREATE TABLE notes (
 		id TEXT PRIMARY KEY,
@@ -49,7 +60,8 @@ func setupTestDB(t *testing.T) *sql.DB {
 		deleted_at DATETIME,
 		created_at DATETIME,
 		updated_at DATETIME,
-		sort_order INTEGER DEFAULT 0
+		sort_order INTEGER DEFAULT 0,
+		version INTEGER NOT NULL DEFAULT 1
 	);
 	CREATE TABLE todos (
 		id TEXT PRIMARY KEY,
@@ -60,17 +72,180 @@ func setupTestDB(t *testing.T) *sql.DB {
 		due_date DATETIME,
 		account_id TEXT,
 		pinned INTEGER DEFAULT 0,
+		recurrence TEXT,
+		series_id TEXT,
+		occurrence INTEGER,
+		parent_id TEXT,
+		reminder_minutes TEXT,
+		estimate_minutes INTEGER,
 		deleted_at DATETIME,
 		created_at DATETIME,
-		updated_at DATETIME
+		updated_at DATETIME,
+		version INTEGER NOT NULL DEFAULT 1
+	);
+	CREATE TABLE time_entries (
+		id TEXT PRIMARY KEY,
+		todo_id TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME,
+		minutes INTEGER,
+		note TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL DEFAULT 'timer',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE todo_statuses (
+		key TEXT PRIMARY KEY,
+		label TEXT NOT NULL,
+		category TEXT NOT NULL,
+		color TEXT NOT NULL DEFAULT '',
+		position INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT INTO todo_statuses (key, label, category, position) VALUES
+		('not_started', 'Not Started', 'open', 0),
+		('in_progress', 'In Progress', 'active', 1),
+		('stuck', 'Stuck', 'blocked', 2),
+		('completed', 'Completed', 'done', 3);
+	CREATE TABLE todo_status_transitions (
+		from_status TEXT,
+		to_status TEXT,
+		PRIMARY KEY (from_status, to_status)
+	);
+	INSERT INTO todo_status_transitions (from_status, to_status)
+		SELECT f.key, t.key FROM todo_statuses f, todo_statuses t WHERE f.key != t.key;
+	CREATE TABLE todo_status_history (
+		id TEXT PRIMARY KEY,
+		todo_id TEXT NOT NULL,
+		from_status TEXT,
+		to_status TEXT NOT NULL,
+		changed_at DATETIME NOT NULL
+	);
+	CREATE TABLE todo_reminders (
+		todo_id TEXT,
+		lead_minutes INTEGER,
+		fired_at DATETIME,
+		snoozed_until DATETIME,
+		PRIMARY KEY (todo_id, lead_minutes)
+	);
+	CREATE TABLE todo_dependencies (
+		todo_id TEXT,
+		blocked_by TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (todo_id, blocked_by)
+	);
+	CREATE TABLE todo_completions (
+		id TEXT PRIMARY KEY,
+		series_id TEXT NOT NULL,
+		todo_id TEXT,
+		occurrence INTEGER NOT NULL,
+		due_date DATETIME,
+		completed_at DATETIME NOT NULL
+	);
+	CREATE TABLE note_revisions (
+		note_id TEXT NOT NULL,
+		rev INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		content TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (note_id, rev)
 	);
 	CREATE TABLE note_todos (
 		note_id TEXT,
 		todo_id TEXT,
 		PRIMARY KEY (note_id, todo_id)
 	);
+	CREATE TABLE tags (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		color TEXT,
+		created_at DATETIME
+	);
+	CREATE TABLE note_tags (
+		note_id TEXT,
+		tag_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (note_id, tag_id)
+	);
+	CREATE TABLE todo_tags (
+		todo_id TEXT,
+		tag_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (todo_id, tag_id)
+	);
+	CREATE TABLE account_tags (
+		account_id TEXT,
+		tag_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (account_id, tag_id)
+	);
+	CREATE TABLE attachments (
+		id TEXT PRIMARY KEY,
+		note_id TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		original_name TEXT NOT NULL,
+		mime_type TEXT,
+		size INTEGER,
+		text TEXT,
+		page_count INTEGER,
+		width INTEGER,
+		height INTEGER,
+		extracted_at DATETIME,
+		created_at DATETIME
+	);
+	CREATE TABLE note_embeddings (
+		note_id TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		vector BLOB NOT NULL,
+		created_at DATETIME
+	);
+	CREATE TABLE saved_searches (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		query TEXT NOT NULL DEFAULT '',
+		filters TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME,
+		updated_at DATETIME
+	);
+	CREATE TABLE activities (
+		id TEXT PRIMARY KEY,
+		account_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT DEFAULT '',
+		entity_type TEXT,
+		entity_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE contacts (
+		id TEXT PRIMARY KEY,
+		email TEXT NOT NULL UNIQUE,
+		name TEXT DEFAULT '',
+		company TEXT DEFAULT '',
+		domain TEXT NOT NULL,
+		is_internal INTEGER DEFAULT 0,
+		account_id TEXT,
+		suggested_account_id TEXT,
+		suggestion_confirmed INTEGER DEFAULT 0,
+		source TEXT DEFAULT 'manual',
+		first_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+		meeting_count INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		deleted_at DATETIME
+	);
+	CREATE TABLE search_docs (
+		rowid INTEGER PRIMARY KEY,
+		type TEXT NOT NULL,
+		id TEXT NOT NULL,
+		UNIQUE (type, id)
+	);
+	CREATE TABLE search_queue (
+		type TEXT NOT NULL,
+		id TEXT NOT NULL,
+		PRIMARY KEY (type, id)
+	) WITHOUT ROWID;
 	`
-	_, err = db.Exec(schema)
+	_, err = db.Exec(schema + database.SearchIndexTable(db))
 	if err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
 	}
@@ -313,3 +488,1472 @@ func TestDeleteNote(t *testing.T) {
 		assert.True(t, deletedAt.Valid)
 	})
 }
+
+// fakeNoteStore serves notes from memory; unimplemented methods panic via
+// the embedded nil interface
+type fakeNoteStore struct {
+	store.NoteStore
+	notes map[string]*models.Note
+}
+
+func (f *fakeNoteStore) Get(id string) (*models.Note, error) {
+	n, ok := f.notes[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return n, nil
+}
+
+func TestGetNote_FakeStore(t *testing.T) {
+	notes := &fakeNoteStore{notes: map[string]*models.Note{
+		"note-1": {ID: "note-1", Title: "Kickoff", AccountName: "Acme"},
+	}}
+	h := NewWithStore(nil, &store.Store{Notes: notes}, t.TempDir())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/notes/:id", h.GetNote)
+
+	req, _ := http.NewRequest("GET", "/notes/note-1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, "Kickoff", resp["title"])
+	assert.Equal(t, "Acme", resp["account_name"])
+
+	req, _ = http.NewRequest("GET", "/notes/missing", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestNoteRevisions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/notes/:id", h.UpdateNote)
+	r.GET("/notes/:id/revisions", h.GetNoteRevisions)
+	r.GET("/notes/:id/revisions/:rev/diff", h.DiffNoteRevision)
+	r.POST("/notes/:id/revisions/:rev/restore", h.RestoreNoteRevision)
+
+	// A note created before history existed has no revisions
+	noteID := "note-history"
+	db.Exec(`INSERT INTO notes (id, title, account_id, template_type, internal_participants, external_participants, content, created_at, updated_at)
+		VALUES (?, 'Kickoff', 'acc-1', 'initial', '[]', '[]', '<p>Original</p>', ?, ?)`, noteID, time.Now(), time.Now())
+
+	content := "<p>Pasted over</p>"
+	body, _ := json.Marshal(models.UpdateNoteRequest{Content: &content})
+	req, _ := http.NewRequest("PUT", "/notes/"+noteID, bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The overwritten content is kept as revision 1
+	req, _ = http.NewRequest("GET", "/notes/"+noteID+"/revisions", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var revisions []models.NoteRevision
+	json.Unmarshal(w.Body.Bytes(), &revisions)
+	assert.Len(t, revisions, 2)
+
+	req, _ = http.NewRequest("GET", "/notes/"+noteID+"/revisions/2/diff", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var diffResp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &diffResp)
+	assert.Equal(t, float64(1), diffResp["added"])
+	assert.Equal(t, float64(1), diffResp["removed"])
+
+	req, _ = http.NewRequest("POST", "/notes/"+noteID+"/revisions/1/restore", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var restored string
+	db.QueryRow("SELECT content FROM notes WHERE id = ?", noteID).Scan(&restored)
+	assert.Equal(t, "<p>Original</p>", restored)
+
+	req, _ = http.NewRequest("GET", "/notes/"+noteID+"/revisions/9/diff", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdateNote_VersionConflict(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/notes/:id", h.UpdateNote)
+	r.POST("/notes/:id/pin", h.ToggleNotePin)
+	r.PUT("/todos/:id", h.UpdateTodo)
+
+	noteID := "note-versioned"
+	db.Exec(`INSERT INTO notes (id, title, account_id, template_type, internal_participants, external_participants, content, created_at, updated_at)
+		VALUES (?, 'Draft', 'acc-1', 'initial', '[]', '[]', '<p>v1</p>', ?, ?)`, noteID, time.Now(), time.Now())
+
+	title := "First writer"
+	body, _ := json.Marshal(models.UpdateNoteRequest{Title: &title})
+	req, _ := http.NewRequest("PUT", "/notes/"+noteID, bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"1"`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	// A second client still holding version 1 is rejected with the current copy
+	title = "Second writer"
+	body, _ = json.Marshal(models.UpdateNoteRequest{Title: &title})
+	req, _ = http.NewRequest("PUT", "/notes/"+noteID, bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var conflict struct {
+		Current map[string]interface{} `json:"current"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &conflict)
+	assert.Equal(t, "First writer", conflict.Current["title"])
+	assert.Equal(t, float64(2), conflict.Current["version"])
+
+	req, _ = http.NewRequest("PUT", "/notes/"+noteID, bytes.NewBuffer(body))
+	req.Header.Set("If-Match", "abc")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Pinning moves the version on too, so a copy from before it is stale
+	req, _ = http.NewRequest("POST", "/notes/"+noteID+"/pin", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	req, _ = http.NewRequest("PUT", "/notes/"+noteID, bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"2"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Todos accept the version in the body as well
+	db.Exec(`INSERT INTO todos (id, title, description, status, priority, created_at, updated_at)
+		VALUES ('todo-versioned', 'Call back', '', 'not_started', 'medium', ?, ?)`, time.Now(), time.Now())
+
+	status := "completed"
+	stale := 3
+	body, _ = json.Marshal(models.UpdateTodoRequest{Status: &status, Version: &stale})
+	req, _ = http.NewRequest("PUT", "/todos/todo-versioned", bytes.NewBuffer(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	current := 1
+	body, _ = json.Marshal(models.UpdateTodoRequest{Status: &status, Version: &current})
+	req, _ = http.NewRequest("PUT", "/todos/todo-versioned", bytes.NewBuffer(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+}
+
+func TestImportMarkdown(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/import/markdown", h.ImportMarkdown)
+
+	db.Exec("INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-import', 'Acme', ?, ?)", time.Now(), time.Now())
+
+	upload := func(fields map[string]string, files map[string][]byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for k, v := range fields {
+			mw.WriteField(k, v)
+		}
+		for name, content := range files {
+			fw, _ := mw.CreateFormFile("files", name)
+			fw.Write(content)
+		}
+		mw.Close()
+		req, _ := http.NewRequest("POST", "/import/markdown", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Single file with front matter", func(t *testing.T) {
+		doc := "---\ntitle: Discovery call\nmeeting_date: 2024-03-05\ntags: [discovery]\n---\n\n- [x] Send deck\n"
+		w := upload(map[string]string{"account_id": "acc-import", "tags": "q1"}, map[string][]byte{"call.md": []byte(doc)})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, "Discovery call", resp["title"])
+
+		var accountID, content string
+		db.QueryRow("SELECT account_id, content FROM notes WHERE id = ?", resp["id"]).Scan(&accountID, &content)
+		assert.Equal(t, "acc-import", accountID)
+		assert.Contains(t, content, `<li data-type="taskItem" data-checked="true"><p>Send deck</p></li>`)
+
+		var tagCount int
+		db.QueryRow("SELECT COUNT(*) FROM note_tags WHERE note_id = ?", resp["id"]).Scan(&tagCount)
+		assert.Equal(t, 2, tagCount)
+	})
+
+	t.Run("Zip archive", func(t *testing.T) {
+		var archive bytes.Buffer
+		zw := zip.NewWriter(&archive)
+		for name, content := range map[string]string{"a.md": "# Alpha\n\nText", "notes/b.md": "Beta body", "readme.pdf": "skip"} {
+			fw, _ := zw.Create(name)
+			fw.Write([]byte(content))
+		}
+		zw.Close()
+
+		w := upload(nil, map[string][]byte{"export.zip": archive.Bytes()})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var resp struct {
+			Count    int                      `json:"count"`
+			Imported []map[string]interface{} `json:"imported"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, 2, resp.Count)
+
+		// Notes without an account land in "Unassigned"
+		var unassigned int
+		db.QueryRow("SELECT COUNT(*) FROM notes n JOIN accounts a ON n.account_id = a.id WHERE a.name = 'Unassigned'").Scan(&unassigned)
+		assert.Equal(t, 2, unassigned)
+	})
+
+	t.Run("Unknown account", func(t *testing.T) {
+		w := upload(map[string]string{"account_id": "missing"}, map[string][]byte{"x.md": []byte("x")})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid front matter leaves nothing behind", func(t *testing.T) {
+		var notesBefore int
+		db.QueryRow("SELECT COUNT(*) FROM notes").Scan(&notesBefore)
+
+		for name, doc := range map[string]string{
+			"tag.md": "---\naccount: Globex\ntags: [a//b]\n---\n\nBody",
+			"due.md": "---\naccount: Globex\ntodos:\n  - title: Follow up\n    due_date: next week\n---\n\nBody",
+		} {
+			w := upload(nil, map[string][]byte{name: []byte(doc)})
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+		}
+
+		var notesAfter, globex int
+		db.QueryRow("SELECT COUNT(*) FROM notes").Scan(&notesAfter)
+		db.QueryRow("SELECT COUNT(*) FROM accounts WHERE name = 'Globex'").Scan(&globex)
+		assert.Equal(t, notesBefore, notesAfter)
+		assert.Equal(t, 0, globex)
+	})
+}
+
+func TestExportMarkdownRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/notes/:id/export/markdown", h.ExportMarkdown)
+	r.POST("/import/markdown", h.ImportMarkdown)
+
+	now := time.Now()
+	db.Exec("INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-rt', 'Globex', ?, ?)", now, now)
+	content := `<h2>Next steps</h2><ul data-type="taskList"><li data-type="taskItem" data-checked="false"><p>Send <a href="https://example.com">pricing</a></p></li></ul>`
+	db.Exec(`INSERT INTO notes (id, title, account_id, template_type, internal_participants, external_participants, content, meeting_date, created_at, updated_at)
+		VALUES ('note-rt', 'Pricing review', 'acc-rt', 'initial', '["me@company.com"]', '["cfo@globex.com"]', ?, ?, ?, ?)`,
+		content, time.Date(2024, 5, 2, 16, 0, 0, 0, time.UTC), now, now)
+	db.Exec("INSERT INTO tags (id, name, color, created_at) VALUES ('tag-rt', 'pricing', '#6b7280', ?)", now)
+	db.Exec("INSERT INTO note_tags (note_id, tag_id) VALUES ('note-rt', 'tag-rt')")
+	db.Exec("INSERT INTO todos (id, title, status, priority, created_at, updated_at) VALUES ('todo-rt', 'Draft quote', 'in_progress', 'high', ?, ?)", now, now)
+	db.Exec("INSERT INTO note_todos (note_id, todo_id) VALUES ('note-rt', 'todo-rt')")
+
+	req, _ := http.NewRequest("GET", "/notes/note-rt/export/markdown", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="Pricing review.md"`)
+	exported := w.Body.Bytes()
+	assert.Contains(t, string(exported), "- [ ] Send [pricing](https://example.com)")
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "Pricing review.md")
+	fw.Write(exported)
+	mw.Close()
+	req, _ = http.NewRequest("POST", "/import/markdown", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	id := created["id"]
+	assert.Equal(t, "Pricing review", created["title"])
+
+	var accountID, imported, internal, external string
+	var meetingDate time.Time
+	db.QueryRow("SELECT account_id, content, internal_participants, external_participants, meeting_date FROM notes WHERE id = ?", id).
+		Scan(&accountID, &imported, &internal, &external, &meetingDate)
+	assert.Equal(t, "acc-rt", accountID)
+	assert.Equal(t, content, strings.ReplaceAll(imported, "\n", ""))
+	assert.Equal(t, `["me@company.com"]`, internal)
+	assert.Equal(t, `["cfo@globex.com"]`, external)
+	assert.True(t, meetingDate.Equal(time.Date(2024, 5, 2, 16, 0, 0, 0, time.UTC)))
+
+	var tagID, todoID string
+	db.QueryRow("SELECT tag_id FROM note_tags WHERE note_id = ?", id).Scan(&tagID)
+	db.QueryRow("SELECT todo_id FROM note_todos WHERE note_id = ?", id).Scan(&todoID)
+	assert.Equal(t, "tag-rt", tagID)
+	assert.Equal(t, "todo-rt", todoID)
+}
+
+func TestExportNotePDF(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/notes/:id/export", h.ExportNotePDF)
+
+	now := time.Now()
+	db.Exec("INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-pdf', 'Initech', ?, ?)", now, now)
+	db.Exec(`INSERT INTO notes (id, title, account_id, template_type, internal_participants, external_participants, content, created_at, updated_at)
+		VALUES ('note-pdf', 'TPS review', 'acc-pdf', 'initial', '[]', '["bill@initech.com"]', '<p>Cover sheets</p>', ?, ?)`, now, now)
+	db.Exec(`INSERT INTO attachments (id, note_id, filename, original_name, mime_type, size, created_at)
+		VALUES ('att-pdf', 'note-pdf', 'att-pdf_memo.txt', 'memo.txt', 'text/plain', 12, ?)`, now)
+
+	req, _ := http.NewRequest("GET", "/notes/note-pdf/export?format=pdf", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="TPS review.pdf"`)
+	assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")))
+
+	// Without format=pdf the JSON payload for client-side rendering is kept
+	req, _ = http.NewRequest("GET", "/notes/note-pdf/export", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "TPS review", resp["title"])
+}
+
+func TestExportAccountZip(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	uploads := t.TempDir()
+	h := NewWithUploadsDir(db, uploads)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/accounts/:id/export", h.ExportAccount)
+
+	now := time.Now()
+	db.Exec("INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-zip', 'Globex', ?, ?)", now, now)
+	for _, id := range []string{"note-zip-1", "note-zip-2"} {
+		db.Exec(`INSERT INTO notes (id, title, account_id, template_type, internal_participants, external_participants, content, meeting_date, created_at, updated_at)
+			VALUES (?, 'Kickoff', 'acc-zip', 'initial', '[]', '[]', '<p>Hammocks</p>', ?, ?, ?)`, id, now, now, now)
+	}
+	db.Exec(`INSERT INTO attachments (id, note_id, filename, original_name, mime_type, size, created_at)
+		VALUES ('att-zip', 'note-zip-1', 'att-zip_plan.txt', 'plan.txt', 'text/plain', 4, ?)`, now)
+	db.Exec(`INSERT INTO attachments (id, note_id, filename, original_name, mime_type, size, created_at)
+		VALUES ('att-gone', 'note-zip-1', 'att-gone_old.txt', 'old.txt', 'text/plain', 4, ?)`, now)
+	os.WriteFile(filepath.Join(uploads, "att-zip_plan.txt"), []byte("plan"), 0644)
+	db.Exec(`INSERT INTO todos (id, title, description, status, priority, account_id, created_at, updated_at)
+		VALUES ('todo-zip', 'Send proposal', '', 'not_started', 'high', 'acc-zip', ?, ?)`, now, now)
+	db.Exec("INSERT INTO note_todos (note_id, todo_id) VALUES ('note-zip-1', 'todo-zip')")
+	db.Exec(`INSERT INTO activities (id, account_id, type, title, created_at) VALUES ('act-zip', 'acc-zip', 'note_created', 'Kickoff', ?)`, now)
+	db.Exec(`INSERT INTO contacts (id, email, name, domain, account_id) VALUES ('con-zip', 'hank@globex.com', 'Hank', 'globex.com', 'acc-zip')`)
+
+	req, _ := http.NewRequest("GET", "/accounts/acc-zip/export?format=zip", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		var buf bytes.Buffer
+		buf.ReadFrom(rc)
+		rc.Close()
+		files[f.Name] = buf.String()
+	}
+
+	date := now.Format("2006-01-02")
+	for _, name := range []string{
+		"account.json", "manifest.json", "todos.csv", "activities.json", "contacts.csv",
+		"notes/" + date + " Kickoff.md", "notes/" + date + " Kickoff.pdf",
+		"notes/" + date + " Kickoff (2).md", "notes/" + date + " Kickoff (2).pdf",
+	} {
+		assert.Contains(t, files, name)
+	}
+	assert.Equal(t, "plan", files["attachments/"+date+" Kickoff/plan.txt"])
+	assert.Contains(t, files["todos.csv"], "todo-zip,Send proposal,,not_started,high")
+	assert.Contains(t, files["contacts.csv"], "hank@globex.com,Hank")
+	assert.Contains(t, files["activities.json"], "act-zip")
+
+	var manifest exportManifest
+	assert.NoError(t, json.Unmarshal([]byte(files["manifest.json"]), &manifest))
+	assert.Equal(t, 2, manifest.Counts["notes"])
+	assert.Equal(t, 1, manifest.Counts["attachments"])
+	assert.Equal(t, []manifestMissed{{NoteID: "note-zip-1", Filename: "old.txt"}}, manifest.Missing)
+
+	req, _ = http.NewRequest("GET", "/accounts/missing/export", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, _ = http.NewRequest("GET", "/accounts/acc-zip/export?format=tar", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestExportImportData(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := NewWithUploadsDir(db, t.TempDir())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/export", h.ExportAllData)
+	r.POST("/import", h.ImportData)
+
+	now := time.Now()
+	db.Exec("INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-exp', 'Hooli', ?, ?)", now, now)
+	db.Exec(`INSERT INTO notes (id, title, account_id, content, created_at, updated_at) VALUES ('note-exp', 'Sync', 'acc-exp', '', ?, ?)`, now, now)
+	db.Exec(`INSERT INTO tags (id, name, color, created_at) VALUES ('tag-exp', 'renewal', '#000000', ?)`, now)
+	db.Exec("INSERT INTO note_tags (note_id, tag_id) VALUES ('note-exp', 'tag-exp')")
+
+	req, _ := http.NewRequest("GET", "/export", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	exported := w.Body.Bytes()
+
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(exported, &doc))
+	assert.Equal(t, "2.0", doc["version"])
+	assert.Len(t, doc["note_tags"], 1)
+
+	// Replace into a cleared database
+	db.Exec("DELETE FROM note_tags")
+	db.Exec("DELETE FROM notes")
+
+	req, _ = http.NewRequest("POST", "/import?mode=replace&dry_run=true", bytes.NewReader(exported))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM notes").Scan(&count)
+	assert.Equal(t, 0, count, "dry run leaves the database alone")
+
+	req, _ = http.NewRequest("POST", "/import?mode=replace", bytes.NewReader(exported))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	db.QueryRow("SELECT COUNT(*) FROM note_tags").Scan(&count)
+	assert.Equal(t, 1, count)
+
+	// Merging the same document again conflicts on every row
+	req, _ = http.NewRequest("POST", "/import?on_conflict=fail", bytes.NewReader(exported))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	req, _ = http.NewRequest("POST", "/import?mode=upsert", bytes.NewReader(exported))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSearchQuerySyntax(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := NewWithUploadsDir(db, t.TempDir())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/search", h.Search)
+	r.POST("/search/reindex", h.ReindexSearch)
+
+	now := time.Now()
+	db.Exec("INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-s', 'Acme', ?, ?)", now, now)
+	db.Exec(`INSERT INTO todos (id, title, description, status, account_id, created_at, updated_at)
+		VALUES ('todo-s1', 'Renew contract', 'Legal review pending', 'stuck', 'acc-s', ?, ?)`, now, now)
+	db.Exec(`INSERT INTO todos (id, title, description, status, account_id, created_at, updated_at)
+		VALUES ('todo-s2', 'Renew contract', 'Signed', 'completed', 'acc-s', ?, ?)`, now, now)
+
+	// The test schema has no triggers, so nothing is indexed until a reindex
+	req, _ := http.NewRequest("POST", "/search/reindex", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"todo":2`)
+
+	req, _ = http.NewRequest("GET", "/search?"+url.Values{"q": {`renew status:stuck account:acme -signed`}}.Encode(), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+	var results []models.SearchResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "todo-s1", results[0].ID)
+	}
+
+	for _, q := range []string{`"legal review`, `type:email renew`, `-renew`} {
+		req, _ = http.NewRequest("GET", "/search?"+url.Values{"q": {q}}.Encode(), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, q)
+		assert.Contains(t, w.Body.String(), "Invalid query", q)
+	}
+}
+
+func TestUploadAttachment_ExtractsText(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := NewWithUploadsDir(db, t.TempDir())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/notes/:id/attachments", h.UploadAttachment)
+	r.GET("/notes/:id/attachments/:attachmentId/text", h.GetAttachmentText)
+	r.GET("/search", h.Search)
+
+	now := time.Now()
+	db.Exec("INSERT INTO notes (id, title, created_at, updated_at) VALUES ('note-a', 'Kickoff', ?, ?)", now, now)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "terms.txt")
+	fw.Write([]byte("Renewal terms\n\n  Pricing   appendix attached \n"))
+	mw.Close()
+	req, _ := http.NewRequest("POST", "/notes/note-a/attachments", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var a models.Attachment
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &a))
+	assert.Equal(t, "Renewal terms\nPricing appendix attached", a.TextPreview)
+
+	req, _ = http.NewRequest("GET", "/notes/note-a/attachments/"+a.ID+"/text", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"text":"Renewal terms\nPricing appendix attached"`)
+
+	req, _ = http.NewRequest("GET", "/notes/other/attachments/"+a.ID+"/text", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	db.Exec("INSERT INTO search_queue (type, id) VALUES ('attachment', ?)", a.ID)
+	req, _ = http.NewRequest("GET", "/search?q=appendix", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results []models.SearchResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "attachment", results[0].Type)
+		assert.Equal(t, "note-a", results[0].NoteID)
+		assert.Contains(t, results[0].Snippet, "<mark>appendix</mark>")
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := NewWithUploadsDir(db, t.TempDir())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/saved-searches", h.GetSavedSearches)
+	r.POST("/saved-searches", h.CreateSavedSearch)
+	r.PUT("/saved-searches/:id", h.UpdateSavedSearch)
+	r.DELETE("/saved-searches/:id", h.DeleteSavedSearch)
+	r.GET("/saved-searches/:id/results", h.RunSavedSearch)
+
+	now := time.Now()
+	for i, status := range []string{"stuck", "stuck", "completed"} {
+		db.Exec(`INSERT INTO todos (id, title, status, account_id, created_at, updated_at) VALUES (?, 'Follow up', ?, 'acc-1', ?, ?)`,
+			fmt.Sprintf("todo-%d", i), status, now, now)
+	}
+	search.Reindex(db)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/saved-searches", `{"name": "Stuck", "query": "status:stuck", "filters": {"account_id": "acc-1", "from": "-30d"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var saved models.SavedSearch
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+	if assert.NotNil(t, saved.Count) {
+		assert.Equal(t, 2, *saved.Count)
+	}
+
+	assert.Equal(t, http.StatusConflict, send("POST", "/saved-searches", `{"name": "stuck", "query": "todo"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/saved-searches", `{"name": "Broken", "query": "before:someday"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/saved-searches", `{"name": "Empty"}`).Code)
+
+	w = send("GET", "/saved-searches/"+saved.ID+"/results?limit=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	assert.NotEmpty(t, w.Header().Get("X-Next-Cursor"))
+
+	w = send("PUT", "/saved-searches/"+saved.ID, `{"query": "status:completed"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	assert.Contains(t, w.Body.String(), `"account_id":"acc-1"`)
+
+	w = send("GET", "/saved-searches", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Stuck"`)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+
+	assert.Equal(t, http.StatusOK, send("DELETE", "/saved-searches/"+saved.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", "/saved-searches/"+saved.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/saved-searches/"+saved.ID+"/results", "").Code)
+}
+
+func TestSemanticSearch(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := NewWithUploadsDir(db, t.TempDir())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/search", h.Search)
+	r.GET("/notes/:id/similar", h.SimilarNotes)
+
+	now := time.Now()
+	for id, content := range map[string]string{
+		"note-1": "Kubernetes cluster migration plan",
+		"note-2": "Timeline for migrating the Kubernetes clusters",
+		"note-3": "Renewal discount negotiation",
+	} {
+		db.Exec("INSERT INTO notes (id, title, content, created_at, updated_at) VALUES (?, 'Call', ?, ?, ?)", id, content, now, now)
+	}
+	search.Reindex(db)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/notes/note-1/similar")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results []models.SearchResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "note-2", results[0].ID)
+	}
+	assert.Equal(t, http.StatusNotFound, get("/notes/missing/similar").Code)
+
+	w = get("/search?mode=semantic&q=" + url.QueryEscape("discounts for renewals"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "note-3", results[0].ID)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get("/search?mode=fuzzy&q=renewal").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/search?mode=semantic&type=note").Code)
+}
+
+func TestListPaging(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/notes", h.GetNotes)
+	r.GET("/todos", h.GetTodos)
+	r.GET("/accounts", h.GetAccounts)
+
+	now := time.Now()
+	db.Exec(`INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-1', 'Acme', ?, ?)`, now, now)
+	for i := 0; i < 3; i++ {
+		db.Exec(`INSERT INTO notes (id, title, account_id, content, pinned, created_at, updated_at) VALUES (?, ?, 'acc-1', '<p>Long body</p>', ?, ?, ?)`,
+			fmt.Sprintf("note-%d", i), fmt.Sprintf("Note %d", i), i == 1, now.Add(time.Duration(i)*time.Minute), now)
+	}
+	db.Exec(`INSERT INTO todos (id, title, description, status, priority, due_date, created_at, updated_at) VALUES ('todo-1', 'Call', '', 'not_started', 'high', ?, ?, ?)`,
+		now.AddDate(0, 0, 3), now, now)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/notes?limit=2&fields=title")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+	cursor := w.Header().Get("X-Next-Cursor")
+	assert.NotEmpty(t, cursor)
+	var notes []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &notes))
+	assert.Equal(t, []map[string]interface{}{
+		{"id": "note-2", "title": "Note 2"},
+		{"id": "note-1", "title": "Note 1"},
+	}, notes)
+
+	w = get("/notes?limit=2&fields=-content&cursor=" + url.QueryEscape(cursor))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Next-Cursor"))
+	assert.Contains(t, w.Body.String(), `"id":"note-0"`)
+	assert.NotContains(t, w.Body.String(), "Long body")
+	w = get("/notes?fields=content")
+	assert.Contains(t, w.Body.String(), "Long body", "content is only loaded when it is returned")
+
+	w = get("/notes?pinned=true")
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+	assert.Contains(t, w.Body.String(), `"id":"note-1"`)
+
+	w = get("/todos?due_before=-1d")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-Total-Count"))
+	w = get("/todos?due_after=today&priority=high")
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+
+	w = get("/accounts?sort=budget&order=asc")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+
+	assert.Equal(t, http.StatusBadRequest, get("/notes?sort=content").Code)
+	assert.Contains(t, get("/notes?sort=content").Body.String(), "must be one of: created_at")
+	assert.Equal(t, http.StatusBadRequest, get("/notes?order=up").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/notes?cursor=bogus").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/notes?limit=0").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/notes?pinned=maybe").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/todos?due_before=soon").Code)
+}
+
+func TestTagHierarchyAndMerge(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/tags", h.GetTags)
+	r.POST("/tags", h.CreateTag)
+	r.PUT("/tags/:id", h.UpdateTag)
+	r.POST("/tags/:id/merge", h.MergeTag)
+	r.GET("/notes", h.GetNotes)
+	r.POST("/notes/:id/tags/:tagId", h.AddTagToNote)
+	r.GET("/todos/:id/tags", h.GetTodoTags)
+	r.POST("/todos/:id/tags/:tagId", h.AddTagToTodo)
+
+	now := time.Now()
+	db.Exec(`INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-1', 'Acme', ?, ?)`, now, now)
+	db.Exec(`INSERT INTO notes (id, title, account_id, created_at, updated_at) VALUES ('note-1', 'Kickoff', 'acc-1', ?, ?)`, now, now)
+	db.Exec(`INSERT INTO notes (id, title, account_id, created_at, updated_at) VALUES ('note-2', 'Pricing', 'acc-1', ?, ?)`, now, now)
+	db.Exec(`INSERT INTO todos (id, title, description, status, priority, created_at, updated_at) VALUES ('todo-1', 'Call', '', 'not_started', 'low', ?, ?)`, now, now)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	create := func(name string) models.Tag {
+		w := send("POST", "/tags", `{"name": "`+name+`"}`)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var tag models.Tag
+		json.Unmarshal(w.Body.Bytes(), &tag)
+		return tag
+	}
+
+	poc := create("customer/poc")
+	assert.Equal(t, "customer", poc.Parent)
+	pricing := create("pricing")
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/tags", `{"name": "customer/"}`).Code)
+
+	send("POST", "/notes/note-1/tags/"+poc.ID, "")
+	send("POST", "/notes/note-2/tags/"+pricing.ID, "")
+	assert.Equal(t, http.StatusOK, send("POST", "/todos/todo-1/tags/"+poc.ID, "").Code)
+
+	w := send("GET", "/notes?tags=customer", "")
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+	w = send("GET", "/notes?tags=customer,pricing&match=any", "")
+	assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	w = send("GET", "/notes?tags=customer,pricing", "")
+	assert.Equal(t, "0", w.Header().Get("X-Total-Count"))
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/notes?tags=customer&match=most", "").Code)
+
+	w = send("POST", "/tags/"+poc.ID+"/merge", `{"into": "`+pricing.ID+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send("GET", "/todos/todo-1/tags", "")
+	assert.Contains(t, w.Body.String(), `"name":"pricing"`)
+	assert.NotContains(t, w.Body.String(), "customer/poc")
+	assert.Equal(t, http.StatusNotFound, send("POST", "/tags/"+poc.ID+"/merge", `{"into": "`+pricing.ID+`"}`).Code)
+
+	var tags []models.Tag
+	w = send("GET", "/tags", "")
+	json.Unmarshal(w.Body.Bytes(), &tags)
+	for _, tag := range tags {
+		if tag.Name == "pricing" && assert.NotNil(t, tag.Usage) {
+			assert.Equal(t, models.TagUsage{Notes: 2, Todos: 1}, *tag.Usage)
+		}
+	}
+
+	// Renaming a parent into its own child is refused
+	w = send("PUT", "/tags/"+pricing.ID, `{"name": "pricing/old"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRecurringTodo(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/todos", h.GetTodos)
+	r.POST("/todos", h.CreateTodo)
+	r.PUT("/todos/:id", h.UpdateTodo)
+	r.GET("/todos/:id/completions", h.GetTodoCompletions)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/todos", `{"title": "Check in", "recurrence": "FREQ=FORTNIGHTLY"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid recurrence")
+
+	w = send("POST", "/todos", `{"title": "Check in", "due_date": "2026-03-02T10:00:00Z", "recurrence": "freq=weekly;byday=mo"}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	var todo map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &todo)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO", todo["recurrence"])
+	id := todo["id"].(string)
+
+	w = send("PUT", "/todos/"+id, `{"status": "completed"}`)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+
+	w = send("GET", "/todos?status=not_started", "")
+	var open []map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &open)
+	if !assert.Len(t, open, 1) {
+		return
+	}
+	assert.NotEqual(t, id, open[0]["id"])
+	assert.Equal(t, "2026-03-09T10:00:00Z", open[0]["due_date"])
+	assert.EqualValues(t, 2, open[0]["occurrence"])
+
+	w = send("GET", "/todos/"+id+"/completions", "")
+	var completions []models.TodoCompletion
+	json.Unmarshal(w.Body.Bytes(), &completions)
+	if !assert.Len(t, completions, 1) {
+		return
+	}
+	assert.Equal(t, id, *completions[0].TodoID)
+
+	// Clearing the rule stops the series
+	next := open[0]["id"].(string)
+	assert.Equal(t, http.StatusOK, send("PUT", "/todos/"+next, `{"recurrence": ""}`).Code)
+	send("PUT", "/todos/"+next, `{"status": "completed"}`)
+	w = send("GET", "/todos?status=not_started", "")
+	assert.Equal(t, "[]", w.Body.String())
+}
+
+func TestTodoSubtasksAndBlockers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/todos", h.GetTodos)
+	r.GET("/todos/:id", h.GetTodo)
+	r.POST("/todos", h.CreateTodo)
+	r.PUT("/todos/:id", h.UpdateTodo)
+	r.POST("/todos/:id/blocked-by/:blockerId", h.AddTodoBlocker)
+	r.DELETE("/todos/:id/blocked-by/:blockerId", h.RemoveTodoBlocker)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	create := func(body string) string {
+		w := send("POST", "/todos", body)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			return ""
+		}
+		var todo map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &todo)
+		return todo["id"].(string)
+	}
+
+	poc := create(`{"title": "Set up POC environment"}`)
+	step := create(`{"title": "Create VPC", "parent_id": "` + poc + `"}`)
+	create(`{"title": "Grant access", "parent_id": "` + poc + `"}`)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/todos", `{"title": "Orphan", "parent_id": "missing"}`).Code)
+
+	send("PUT", "/todos/"+step, `{"status": "completed"}`)
+	w := send("PUT", "/todos/"+poc, `{"parent_id": "`+step+`"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "under itself")
+
+	review := create(`{"title": "Security review"}`)
+	assert.Equal(t, http.StatusOK, send("POST", "/todos/"+poc+"/blocked-by/"+review, "").Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/todos/"+review+"/blocked-by/"+poc, "").Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/todos/"+poc+"/blocked-by/missing", "").Code)
+
+	var todo struct {
+		Status    string               `json:"status"`
+		Blocked   bool                 `json:"blocked"`
+		BlockedBy []models.TodoRef     `json:"blocked_by"`
+		Subtasks  []models.TodoRef     `json:"subtasks"`
+		Progress  *models.TodoProgress `json:"progress"`
+	}
+	json.Unmarshal(send("GET", "/todos/"+poc, "").Body.Bytes(), &todo)
+	assert.Equal(t, "stuck", todo.Status)
+	assert.True(t, todo.Blocked)
+	assert.Len(t, todo.BlockedBy, 1)
+	assert.Len(t, todo.Subtasks, 2)
+	assert.Equal(t, &models.TodoProgress{Total: 2, Completed: 1, Percent: 50}, todo.Progress)
+
+	var list []map[string]interface{}
+	json.Unmarshal(send("GET", "/todos?top_level=true&status=stuck", "").Body.Bytes(), &list)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, poc, list[0]["id"])
+	}
+	json.Unmarshal(send("GET", "/todos?parent_id="+poc, "").Body.Bytes(), &list)
+	assert.Len(t, list, 2)
+
+	send("DELETE", "/todos/"+poc+"/blocked-by/"+review, "")
+	json.Unmarshal(send("GET", "/todos/"+poc, "").Body.Bytes(), &todo)
+	assert.Equal(t, "not_started", todo.Status)
+	assert.Empty(t, todo.BlockedBy)
+}
+
+func TestTodoReminders(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/todos/:id", h.GetTodo)
+	r.POST("/todos", h.CreateTodo)
+	r.PUT("/todos/:id/reminders", h.SetTodoReminders)
+	r.POST("/todos/:id/reminders/:lead/snooze", h.SnoozeReminder)
+	r.GET("/reminders/upcoming", h.GetUpcomingReminders)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	upcoming := func(within string) []map[string]interface{} {
+		w := send("GET", "/reminders/upcoming?within="+within, "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var reminders []map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &reminders)
+		return reminders
+	}
+
+	due := time.Now().Add(30 * time.Minute).UTC().Format(time.RFC3339)
+	w := send("POST", "/todos", `{"title": "Call Acme", "due_date": "`+due+`", "reminder_minutes": [-5]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send("POST", "/todos", `{"title": "Call Acme", "due_date": "`+due+`"}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	var todo map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &todo)
+	id := todo["id"].(string)
+	assert.Nil(t, todo["reminder_minutes"], "uses the default")
+
+	reminders := upcoming("1h")
+	if !assert.Len(t, reminders, 1) {
+		return
+	}
+	assert.Equal(t, id, reminders[0]["todo_id"])
+	assert.EqualValues(t, 60, reminders[0]["lead_minutes"])
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/reminders/upcoming?within=soon", "").Code)
+
+	w = send("PUT", "/todos/"+id+"/reminders", `{"minutes": [10, 20, 10]}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	json.Unmarshal(w.Body.Bytes(), &todo)
+	assert.Equal(t, []interface{}{20.0, 10.0}, todo["reminder_minutes"])
+	assert.Len(t, upcoming("1h"), 2)
+	assert.Empty(t, upcoming("5m"))
+
+	w = send("POST", "/todos/"+id+"/reminders/20/snooze", `{"minutes": 45}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	reminders = upcoming("40m")
+	if assert.Len(t, reminders, 1, "snoozed for 45 minutes") {
+		assert.EqualValues(t, 10, reminders[0]["lead_minutes"])
+	}
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/todos/"+id+"/reminders/20/snooze", `{"until": "`+past+`"}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/todos/missing/reminders/20/snooze", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/todos/"+id+"/reminders/60/snooze", "").Code, "not one of the todo's lead times")
+	assert.Equal(t, http.StatusNotFound, send("PUT", "/todos/missing/reminders", `{"minutes": null}`).Code)
+}
+
+func TestTodoTimeTracking(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/todos/:id", h.GetTodo)
+	r.POST("/todos", h.CreateTodo)
+	r.PUT("/todos/:id", h.UpdateTodo)
+	r.POST("/todos/:id/timer/start", h.StartTodoTimer)
+	r.POST("/todos/:id/timer/stop", h.StopTodoTimer)
+	r.GET("/todos/:id/time-entries", h.GetTodoTimeEntries)
+	r.POST("/todos/:id/time-entries", h.CreateTimeEntry)
+	r.DELETE("/time-entries/:id", h.DeleteTimeEntry)
+	r.GET("/analytics/time", h.GetTimeAnalytics)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	getTodo := func(id string) map[string]interface{} {
+		var todo map[string]interface{}
+		json.Unmarshal(send("GET", "/todos/"+id, "").Body.Bytes(), &todo)
+		return todo
+	}
+
+	db.Exec("INSERT INTO accounts (id, name) VALUES ('acc-1', 'Acme')")
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/todos", `{"title": "POC", "estimate_minutes": -5}`).Code)
+	w := send("POST", "/todos", `{"title": "POC", "account_id": "acc-1", "estimate_minutes": 480}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	var todo map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &todo)
+	id := todo["id"].(string)
+	assert.EqualValues(t, 480, todo["estimate_minutes"])
+
+	w = send("POST", "/todos/"+id+"/timer/start", "")
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusConflict, send("POST", "/todos/"+id+"/timer/start", "").Code)
+	assert.NotNil(t, getTodo(id)["timer_started_at"])
+	assert.Equal(t, http.StatusNotFound, send("POST", "/todos/missing/timer/start", "").Code)
+
+	w = send("POST", "/todos/"+id+"/timer/stop", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusConflict, send("POST", "/todos/"+id+"/timer/stop", "").Code)
+	assert.Nil(t, getTodo(id)["timer_started_at"])
+
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/todos/"+id+"/time-entries", `{"minutes": 2000}`).Code)
+	w = send("POST", "/todos/"+id+"/time-entries", `{"minutes": 90, "started_at": "2026-03-03T14:00:00Z", "note": "Workshop"}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	var entry map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &entry)
+	assert.Equal(t, "manual", entry["source"])
+	assert.Equal(t, "2026-03-03T15:30:00Z", entry["ended_at"])
+
+	todo = getTodo(id)
+	assert.EqualValues(t, 90, todo["tracked_minutes"])
+	w = send("PUT", "/todos/"+id, `{"estimate_minutes": 0}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Nil(t, getTodo(id)["estimate_minutes"], "0 clears the estimate")
+
+	var entries []map[string]interface{}
+	json.Unmarshal(send("GET", "/todos/"+id+"/time-entries", "").Body.Bytes(), &entries)
+	assert.Len(t, entries, 2)
+
+	var report models.TimeReport
+	w = send("GET", "/analytics/time?from=2026-03-01&to=2026-03-08", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, 90, report.TotalMinutes)
+	if assert.Len(t, report.ByAccount, 1) {
+		assert.Equal(t, "Acme", report.ByAccount[0].AccountName)
+	}
+	if assert.Len(t, report.ByWeek, 1) {
+		assert.Equal(t, "2026-03-02", report.ByWeek[0].WeekStart)
+	}
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/analytics/time?from=someday", "").Code)
+
+	assert.Equal(t, http.StatusOK, send("DELETE", "/time-entries/"+entry["id"].(string), "").Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", "/time-entries/"+entry["id"].(string), "").Code)
+}
+
+func TestTodoStatusWorkflow(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/todos", h.CreateTodo)
+	r.PUT("/todos/:id", h.UpdateTodo)
+	r.GET("/todos/:id/history", h.GetTodoHistory)
+	r.GET("/workflow", h.GetWorkflow)
+	r.PUT("/workflow", h.UpdateWorkflow)
+	r.GET("/analytics/status", h.GetStatusAnalytics)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var workflow models.Workflow
+	json.Unmarshal(send("GET", "/workflow", "").Body.Bytes(), &workflow)
+	assert.Len(t, workflow.Statuses, 4)
+
+	w := send("PUT", "/workflow", `{"statuses": [{"key": "not_started", "label": "To do", "category": "open"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "done status")
+
+	w = send("PUT", "/workflow", `{
+		"statuses": [
+			{"key": "not_started", "label": "To do", "category": "open"},
+			{"key": "in_progress", "label": "Doing", "category": "active"},
+			{"key": "review", "label": "Review", "category": "active"},
+			{"key": "stuck", "label": "Stuck", "category": "blocked"},
+			{"key": "completed", "label": "Done", "category": "done"}
+		],
+		"transitions": {
+			"not_started": ["in_progress"],
+			"in_progress": ["review", "stuck"],
+			"stuck": ["in_progress"],
+			"review": ["completed", "in_progress"],
+			"completed": ["in_progress"]
+		}
+	}`)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	json.Unmarshal(w.Body.Bytes(), &workflow)
+	assert.Equal(t, "review", workflow.Statuses[2].Key)
+
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/todos", `{"title": "POC", "status": "someday"}`).Code)
+	w = send("POST", "/todos", `{"title": "POC"}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	var todo map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &todo)
+	id := todo["id"].(string)
+	assert.Equal(t, "not_started", todo["status"])
+
+	w = send("PUT", "/todos/"+id, `{"status": "completed"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Status cannot change from not_started to completed")
+	for _, status := range []string{"in_progress", "review", "completed"} {
+		w = send("PUT", "/todos/"+id, `{"status": "`+status+`"}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var history []models.StatusChange
+	json.Unmarshal(send("GET", "/todos/"+id+"/history", "").Body.Bytes(), &history)
+	if assert.Len(t, history, 3) {
+		assert.Equal(t, "review", history[2].FromStatus)
+		assert.Equal(t, "completed", history[2].ToStatus)
+	}
+	assert.Equal(t, http.StatusNotFound, send("GET", "/todos/missing/history", "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/analytics/status?from=soon", "").Code)
+	var report models.StatusReport
+	w = send("GET", "/analytics/status", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, 1, report.Completed)
+	assert.Len(t, report.Statuses, 5)
+}
+
+func TestNoteTaskItems(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/notes", h.CreateNote)
+	r.GET("/notes/:id", h.GetNote)
+	r.PUT("/notes/:id", h.UpdateNote)
+	r.POST("/notes/:id/extract-todos", h.ExtractNoteTodos)
+	r.GET("/todos/:id", h.GetTodo)
+	r.PUT("/todos/:id", h.UpdateTodo)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	getContent := func(id string) string {
+		var note map[string]interface{}
+		json.Unmarshal(send("GET", "/notes/"+id, nil).Body.Bytes(), &note)
+		return note["content"].(string)
+	}
+
+	db.Exec("INSERT INTO accounts (id, name) VALUES ('acc-1', 'Acme')")
+	content := `<ul data-type="taskList">` +
+		`<li data-type="taskItem" data-checked="false"><p>Send pricing deck @due(2026-11-01) !high</p></li>` +
+		`<li data-type="taskItem" data-checked="true"><p>Book room</p></li></ul>`
+	w := send("POST", "/notes", gin.H{"title": "Kickoff", "account_id": "acc-1", "content": content})
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	var note map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &note)
+	noteID := note["id"].(string)
+
+	var linked struct {
+		Todos []models.Todo `json:"todos"`
+	}
+	json.Unmarshal(send("GET", "/notes/"+noteID, nil).Body.Bytes(), &linked)
+	if !assert.Len(t, linked.Todos, 2) {
+		return
+	}
+	var deck, room models.Todo
+	for _, todo := range linked.Todos {
+		if todo.Title == "Send pricing deck" {
+			deck = todo
+		} else {
+			room = todo
+		}
+	}
+	assert.Equal(t, "high", deck.Priority)
+	assert.Equal(t, "2026-11-01", deck.DueDate.Format("2006-01-02"))
+	assert.Equal(t, "completed", room.Status)
+	content = getContent(noteID)
+	assert.Contains(t, content, `data-todo-id="`+deck.ID+`"`)
+
+	// Ticking the item in the note completes the todo
+	ticked := strings.Replace(content, `data-checked="false"`, `data-checked="true"`, 1)
+	w = send("PUT", "/notes/"+noteID, gin.H{"content": ticked})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var todo map[string]interface{}
+	json.Unmarshal(send("GET", "/todos/"+deck.ID, nil).Body.Bytes(), &todo)
+	assert.Equal(t, "completed", todo["status"])
+	assert.Equal(t, "acc-1", todo["account_id"])
+
+	// Reopening the todo unticks the item, which moves the note's version
+	// on without adding a revision
+	noteState := func() (revisions, version int) {
+		db.QueryRow("SELECT COUNT(*) FROM note_revisions WHERE note_id = ?", noteID).Scan(&revisions)
+		db.QueryRow("SELECT version FROM notes WHERE id = ?", noteID).Scan(&version)
+		return revisions, version
+	}
+	revisions, version := noteState()
+	w = send("PUT", "/todos/"+deck.ID, gin.H{"status": "in_progress"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, getContent(noteID), `data-checked="false" data-todo-id="`+deck.ID+`"`)
+	revisionsAfter, versionAfter := noteState()
+	assert.Equal(t, revisions, revisionsAfter)
+	assert.Equal(t, version+1, versionAfter)
+
+	// An editor that drops the ids does not duplicate the todos
+	w = send("PUT", "/notes/"+noteID, gin.H{"content": content + `<p>More notes</p>`})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM note_todos WHERE note_id = ?", noteID).Scan(&count)
+	assert.Equal(t, 2, count)
+
+	// An item pasted from another note keeps its todo, which the other note
+	// then follows too
+	pasted := `<ul data-type="taskList"><li data-type="taskItem" data-checked="false" data-todo-id="` + deck.ID + `"><p>Send pricing deck</p></li></ul>`
+	w = send("POST", "/notes", gin.H{"title": "Follow-up", "account_id": "acc-1", "content": pasted})
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	var followUp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &followUp)
+	followUpID := followUp["id"].(string)
+	w = send("PUT", "/todos/"+deck.ID, gin.H{"status": "completed"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, getContent(followUpID), `data-checked="true" data-todo-id="`+deck.ID+`"`)
+	db.QueryRow("SELECT COUNT(*) FROM todos").Scan(&count)
+	assert.Equal(t, 2, count)
+
+	db.Exec("INSERT INTO notes (id, title, account_id, content, created_at, updated_at) VALUES ('note-old', 'Old', 'acc-1', ?, ?, ?)",
+		`<ul data-type="taskList"><li data-type="taskItem" data-checked="false"><p>Draft SOW</p></li></ul>`, time.Now(), time.Now())
+	w = send("POST", "/notes/note-old/extract-todos", nil)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	var extracted struct {
+		Created []models.Todo `json:"created"`
+		Content string        `json:"content"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &extracted)
+	if assert.Len(t, extracted.Created, 1) {
+		assert.Equal(t, "Draft SOW", extracted.Created[0].Title)
+		assert.Contains(t, getContent("note-old"), extracted.Created[0].ID)
+	}
+	w = send("POST", "/notes/note-old/extract-todos", nil)
+	assert.Contains(t, w.Body.String(), `"created":[]`, "extracting again finds nothing new")
+	assert.Equal(t, http.StatusNotFound, send("POST", "/notes/missing/extract-todos", nil).Code)
+}
+
+func TestBulkOperations(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	h := New(db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/notes", h.CreateNote)
+	r.POST("/notes/bulk", h.BulkNotesOperation)
+	r.GET("/notes/:id", h.GetNote)
+	r.POST("/todos/bulk", h.BulkTodosOperation)
+	r.GET("/todos/:id", h.GetTodo)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	type bulkResponse struct {
+		Error   string              `json:"error"`
+		Updated int                 `json:"updated"`
+		Results []models.BulkResult `json:"results"`
+	}
+
+	now := time.Now()
+	db.Exec("INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-1', 'Acme', ?, ?), ('acc-2', 'Globex', ?, ?)", now, now, now, now)
+	db.Exec("INSERT INTO tags (id, name, color, created_at) VALUES ('tag-1', 'poc', '#3b82f6', ?)", now)
+	db.Exec("INSERT INTO notes (id, title, account_id, created_at, updated_at) VALUES ('note-1', 'Kickoff', 'acc-1', ?, ?), ('note-2', 'Demo', 'acc-1', ?, ?)",
+		now, now, now, now)
+	noteIDs := []string{"note-1", "note-2"}
+
+	w := send("POST", "/notes/bulk", gin.H{"note_ids": noteIDs, "action": "set_account", "value": gin.H{"account_id": "acc-2"}})
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	var resp bulkResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 2, resp.Updated)
+	w = send("POST", "/notes/bulk", gin.H{"note_ids": noteIDs, "action": "add_tag", "value": gin.H{"tag_id": "tag-1"}})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var note map[string]interface{}
+	json.Unmarshal(send("GET", "/notes/note-2", nil).Body.Bytes(), &note)
+	assert.Equal(t, "acc-2", note["account_id"])
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM note_tags WHERE tag_id = 'tag-1'").Scan(&count)
+	assert.Equal(t, 2, count)
+
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/notes/bulk", gin.H{"note_ids": noteIDs, "action": "set_account", "value": gin.H{"account_id": "missing"}}).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/notes/bulk", gin.H{"note_ids": noteIDs, "action": "shred"}).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/notes/bulk", gin.H{"note_ids": []string{}, "action": "pin"}).Code)
+
+	// One missing note keeps the others out of the trash
+	w = send("POST", "/notes/bulk", gin.H{"note_ids": append(noteIDs, "missing"), "action": "delete"})
+	if !assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String()) {
+		return
+	}
+	resp = bulkResponse{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if assert.Len(t, resp.Results, 3) {
+		assert.False(t, resp.Results[0].OK, "rolled back with the failed note")
+		assert.Equal(t, "Not changed, another item failed", resp.Results[0].Error)
+		assert.False(t, resp.Results[2].OK)
+		assert.Equal(t, "Note not found", resp.Results[2].Error)
+	}
+	assert.Equal(t, http.StatusOK, send("GET", "/notes/note-1", nil).Code)
+
+	// Completing todos ticks their task items
+	content := `<ul data-type="taskList"><li data-type="taskItem" data-checked="false"><p>Send deck</p></li>` +
+		`<li data-type="taskItem" data-checked="false"><p>Book room</p></li></ul>`
+	w = send("POST", "/notes", gin.H{"title": "Planning", "account_id": "acc-1", "content": content})
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	json.Unmarshal(w.Body.Bytes(), &note)
+	noteID := note["id"].(string)
+	var linked struct {
+		Todos []models.Todo `json:"todos"`
+	}
+	json.Unmarshal(send("GET", "/notes/"+noteID, nil).Body.Bytes(), &linked)
+	if !assert.Len(t, linked.Todos, 2) {
+		return
+	}
+	todoIDs := []string{linked.Todos[0].ID, linked.Todos[1].ID}
+
+	w = send("POST", "/todos/bulk", gin.H{"todo_ids": todoIDs, "action": "set_status", "value": gin.H{"status": "completed"}})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	json.Unmarshal(send("GET", "/notes/"+noteID, nil).Body.Bytes(), &note)
+	assert.NotContains(t, note["content"], `data-checked="false"`)
+
+	w = send("POST", "/todos/bulk", gin.H{"todo_ids": todoIDs, "action": "set_due_date", "value": gin.H{"due_date": "2026-11-01T00:00:00Z"}})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var todo map[string]interface{}
+	json.Unmarshal(send("GET", "/todos/"+todoIDs[1], nil).Body.Bytes(), &todo)
+	assert.Equal(t, "2026-11-01T00:00:00Z", todo["due_date"])
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/todos/bulk", gin.H{"todo_ids": todoIDs, "action": "set_priority", "value": gin.H{"priority": "urgent"}}).Code)
+
+	w = send("POST", "/todos/bulk", gin.H{"todo_ids": todoIDs, "action": "set_status", "value": gin.H{"status": "someday"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Unknown status")
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/search"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// List endpoints share limit, cursor, sort and order parameters. Without a
+// limit they return every row, so clients that predate paging keep working.
+
+// listOptions reads the paging and sort parameters of a list request
+func listOptions(c *gin.Context) (store.ListOptions, error) {
+	o := store.ListOptions{Cursor: c.Query("cursor"), Sort: c.Query("sort"), Order: c.Query("order")}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return o, errors.New("Invalid limit")
+		}
+		o.Limit = limit
+	}
+	return o, nil
+}
+
+// boolParam reads an optional true/false query parameter
+func boolParam(c *gin.Context, name string) (*bool, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, errors.New("Invalid " + name + ", must be true or false")
+	}
+	return &b, nil
+}
+
+// dateParam reads an optional date query parameter in any format search
+// accepts
+func dateParam(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := search.ParseDate(v)
+	if err != nil {
+		return nil, errors.New("Invalid " + name + " date, use YYYY-MM-DD, RFC3339 or a relative date like -30d")
+	}
+	return &t, nil
+}
+
+// tagsParam reads the tags a list is filtered by, from the comma separated
+// tags parameter and tag, and whether any of them is enough
+func tagsParam(c *gin.Context) ([]string, bool, error) {
+	var tags []string
+	for _, v := range append(strings.Split(c.Query("tags"), ","), c.Query("tag")) {
+		if v = strings.TrimSpace(v); v != "" {
+			tags = append(tags, v)
+		}
+	}
+	switch c.Query("match") {
+	case "", "all":
+		return tags, false, nil
+	case "any":
+		return tags, true, nil
+	}
+	return nil, false, errors.New("Invalid match, must be 'all' or 'any'")
+}
+
+// respondList writes a page of a list. The total is in X-Total-Count and the
+// cursor for the next page, when there is one, in X-Next-Cursor. fields
+// narrows each item to the named keys; names prefixed with - are left out
+// instead.
+func respondList(c *gin.Context, items interface{}, info store.PageInfo) {
+	c.Header("X-Total-Count", strconv.Itoa(info.Total))
+	if info.NextCursor != "" {
+		c.Header("X-Next-Cursor", info.NextCursor)
+	}
+	fields := c.Query("fields")
+	if fields == "" {
+		c.JSON(http.StatusOK, items)
+		return
+	}
+
+	projected, err := project(items, strings.Split(fields, ","))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, projected)
+}
+
+// project keeps the named keys of each item, always including id, or drops
+// the ones prefixed with -
+func project(items interface{}, fields []string) ([]map[string]json.RawMessage, error) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+
+	keep, drop := parseFields(fields)
+	for _, row := range rows {
+		for key := range row {
+			if drop[key] || (len(keep) > 0 && !keep[key]) {
+				delete(row, key)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// parseFields splits fields into the keys to keep, always including id when
+// there are any, and the ones to drop
+func parseFields(fields []string) (keep, drop map[string]bool) {
+	keep, drop = map[string]bool{}, map[string]bool{}
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if name, ok := strings.CutPrefix(f, "-"); ok {
+			drop[name] = true
+		} else if f != "" {
+			keep[f] = true
+		}
+	}
+	if len(keep) > 0 {
+		keep["id"] = true
+	}
+	return keep, drop
+}
+
+// fieldWanted reports whether the fields parameter leaves key in each item,
+// so lists can skip loading what won't be returned
+func fieldWanted(c *gin.Context, key string) bool {
+	fields := c.Query("fields")
+	if fields == "" {
+		return true
+	}
+	keep, drop := parseFields(strings.Split(fields, ","))
+	return !drop[key] && (len(keep) == 0 || keep[key])
+}
+
+// respondListError maps a list store error to a response
+func respondListError(c *gin.Context, err error) {
+	var sortErr *store.InvalidSortError
+	switch {
+	case errors.Is(err, store.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+	case errors.As(err, &sortErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + sortErr.Option + ", must be one of: " + strings.Join(sortErr.Allowed, ", ")})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/markdown"
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportFileSize caps each Markdown file, including files inside a zip
+const maxImportFileSize = 10 << 20
+
+// markdownFile is one document pulled out of an import upload
+type markdownFile struct {
+	name    string
+	content []byte
+}
+
+// importFailure records a file that could not be imported
+type importFailure struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// ImportMarkdown creates notes from uploaded Markdown. It accepts one or
+// more .md files (form fields "file" or "files") and .zip archives of them,
+// an optional account_id and comma-separated tags applied to every note.
+// Front matter in each file can set the title, account, meeting date,
+// participants, linked todos and further tags, which is how files written
+// by ExportMarkdown come back in unchanged.
+//
+// A single Markdown file responds with the created note's id and title, as
+// it always has; batches respond with the imported notes and any failures.
+func (h *Handler) ImportMarkdown(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	uploads := append(form.File["file"], form.File["files"]...)
+	if len(uploads) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+
+	accountID := c.PostForm("account_id")
+	if accountID != "" {
+		if _, err := h.accounts.Get(accountID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Account not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var tags []string
+	for _, value := range c.PostFormArray("tags") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				tags = append(tags, name)
+			}
+		}
+	}
+
+	var files []markdownFile
+	failed := []importFailure{}
+	for _, upload := range uploads {
+		extracted, err := readMarkdownUpload(upload)
+		if err != nil {
+			failed = append(failed, importFailure{File: upload.Filename, Error: err.Error()})
+			continue
+		}
+		files = append(files, extracted...)
+	}
+
+	imported := []gin.H{}
+	for _, file := range files {
+		n, err := h.importMarkdownFile(file, accountID, tags)
+		if err != nil {
+			failed = append(failed, importFailure{File: file.name, Error: err.Error()})
+			continue
+		}
+		imported = append(imported, gin.H{"id": n.ID, "title": n.Title, "file": file.name})
+	}
+
+	single := len(uploads) == 1 && !isZip(uploads[0].Filename)
+	if single && len(imported) == 1 {
+		c.JSON(http.StatusCreated, gin.H{"id": imported[0]["id"], "title": imported[0]["title"]})
+		return
+	}
+	if len(imported) == 0 {
+		msg := "No Markdown files found"
+		if len(failed) > 0 {
+			msg = failed[0].Error
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "failed": failed})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"imported": imported,
+		"failed":   failed,
+		"count":    len(imported),
+	})
+}
+
+// importMarkdownFile parses one document and stores it as a note. Dates
+// and tag names are checked before anything is written, so that a file
+// that fails leaves no note or account behind.
+func (h *Handler) importMarkdownFile(file markdownFile, accountID string, tags []string) (*models.Note, error) {
+	doc, err := markdown.Parse(file.content)
+	if err != nil {
+		return nil, err
+	}
+	fm := doc.FrontMatter
+
+	title := doc.Title
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(file.name), path.Ext(file.name))
+	}
+
+	n := &models.Note{
+		Title:                title,
+		TemplateType:         "imported",
+		Content:              doc.HTML,
+		InternalParticipants: append([]string{}, fm.InternalParticipants...),
+		ExternalParticipants: append([]string{}, fm.ExternalParticipants...),
+	}
+	for _, p := range fm.Participants {
+		if isInternalEmail(p) {
+			n.InternalParticipants = append(n.InternalParticipants, p)
+		} else {
+			n.ExternalParticipants = append(n.ExternalParticipants, p)
+		}
+	}
+	if fm.MeetingDate != "" {
+		meetingDate, err := parseFrontMatterDate(fm.MeetingDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid meeting_date %q", fm.MeetingDate)
+		}
+		n.MeetingDate = &meetingDate
+	}
+
+	var tagNames []string
+	for _, name := range append(append([]string{}, tags...), fm.Tags...) {
+		clean, err := store.CleanTagName(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag %q", name)
+		}
+		tagNames = append(tagNames, clean)
+	}
+	todos, err := frontMatterTodos(fm.Todos)
+	if err != nil {
+		return nil, err
+	}
+
+	if accountID == "" {
+		if accountID, err = h.frontMatterAccountID(fm); err != nil {
+			return nil, err
+		}
+	}
+	n.AccountID = accountID
+	if err := h.notes.Create(n); err != nil {
+		return nil, err
+	}
+	for _, name := range tagNames {
+		tag, err := h.tagByName(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.tags.AddToNote(n.ID, tag.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := h.importLinkedTodos(n, todos); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// frontMatterAccountID picks the account named by front matter: an existing
+// account_id first, then the account name, creating it if needed. Files
+// that name neither go to "Unassigned".
+func (h *Handler) frontMatterAccountID(fm markdown.FrontMatter) (string, error) {
+	if fm.AccountID != "" {
+		if _, err := h.accounts.Get(fm.AccountID); err == nil {
+			return fm.AccountID, nil
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return "", err
+		}
+	}
+	if fm.Account == "" {
+		return h.unassignedAccountID()
+	}
+
+	account, err := h.accounts.GetByName(fm.Account)
+	if errors.Is(err, store.ErrNotFound) {
+		account = &models.Account{Name: fm.Account}
+		err = h.accounts.Create(account)
+	}
+	if err != nil {
+		return "", err
+	}
+	return account.ID, nil
+}
+
+// frontMatterTodos turns the todos listed in front matter into todos,
+// keeping the ID each was exported with
+func frontMatterTodos(refs []markdown.Todo) ([]*models.Todo, error) {
+	todos := []*models.Todo{}
+	for _, ref := range refs {
+		t := &models.Todo{
+			ID:       ref.ID,
+			Title:    ref.Title,
+			Status:   ref.Status,
+			Priority: ref.Priority,
+		}
+		if t.Priority == "" {
+			t.Priority = "medium"
+		}
+		if ref.DueDate != "" {
+			dueDate, err := parseFrontMatterDate(ref.DueDate)
+			if err != nil {
+				return nil, fmt.Errorf("invalid due_date %q for todo %q", ref.DueDate, ref.Title)
+			}
+			t.DueDate = &dueDate
+		}
+		todos = append(todos, t)
+	}
+	return todos, nil
+}
+
+// importLinkedTodos links the todos listed in front matter to a new note.
+// Todos that still exist are linked as they are; the rest are recreated.
+func (h *Handler) importLinkedTodos(n *models.Note, todos []*models.Todo) error {
+	for _, t := range todos {
+		if t.ID != "" {
+			if _, err := h.todos.Get(t.ID); err == nil {
+				if err := h.todos.LinkNote(t.ID, n.ID); err != nil {
+					return err
+				}
+				continue
+			} else if !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+		}
+		if t.Title == "" {
+			continue
+		}
+
+		t.ID = ""
+		t.AccountID = &n.AccountID
+		err := h.todos.Create(t, &n.ID)
+		if errors.Is(err, store.ErrUnknownStatus) {
+			// The note came from a workflow with other statuses
+			t.Status = ""
+			err = h.todos.Create(t, &n.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportMarkdown downloads a note as GitHub-flavored Markdown with its
+// metadata in YAML front matter, in the form ImportMarkdown reads back
+func (h *Handler) ExportMarkdown(c *gin.Context) {
+	n, err := h.notes.Get(c.Param("id"))
+	if err != nil {
+		respondStoreError(c, err, "Note not found")
+		return
+	}
+
+	tags, err := h.tags.ListForNote(n.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	content, err := markdown.Render(noteFrontMatter(n, tags), n.Content)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": exportFilename(n.Title, "md"),
+	}))
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", content)
+}
+
+// noteFrontMatter collects the metadata written above an exported note
+func noteFrontMatter(n *models.Note, tags []models.Tag) markdown.FrontMatter {
+	fm := markdown.FrontMatter{
+		Title:                n.Title,
+		Account:              n.AccountName,
+		AccountID:            n.AccountID,
+		InternalParticipants: n.InternalParticipants,
+		ExternalParticipants: n.ExternalParticipants,
+	}
+	if n.MeetingDate != nil {
+		fm.MeetingDate = n.MeetingDate.Format(time.RFC3339)
+	}
+	for _, tag := range tags {
+		fm.Tags = append(fm.Tags, tag.Name)
+	}
+	for _, t := range n.Todos {
+		todo := markdown.Todo{ID: t.ID, Title: t.Title, Status: t.Status, Priority: t.Priority}
+		if t.DueDate != nil {
+			todo.DueDate = t.DueDate.Format(time.RFC3339)
+		}
+		fm.Todos = append(fm.Todos, todo)
+	}
+	return fm
+}
+
+// exportFilename turns a note title into a safe download name
+func exportFilename(title, ext string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		name = "note"
+	}
+	return name + "." + ext
+}
+
+// tagByName finds a tag, creating it when it does not exist yet
+func (h *Handler) tagByName(name string) (*models.Tag, error) {
+	tag, err := h.tags.GetByName(name)
+	if errors.Is(err, store.ErrNotFound) {
+		tag = &models.Tag{Name: name, Color: "#6b7280"}
+		err = h.tags.Create(tag)
+	}
+	return tag, err
+}
+
+// parseFrontMatterDate accepts the API's timestamp formats plus the plain
+// dates people tend to write by hand
+func parseFrontMatterDate(value string) (time.Time, error) {
+	if parsed, err := parseMeetingDate(value); err == nil {
+		return parsed, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", value)
+}
+
+// readMarkdownUpload returns the Markdown documents in an uploaded file,
+// expanding zip archives
+func readMarkdownUpload(upload *multipart.FileHeader) ([]markdownFile, error) {
+	src, err := upload.Open()
+	if err != nil {
+		return nil, errors.New("failed to open file")
+	}
+	defer src.Close()
+
+	if !isZip(upload.Filename) {
+		if !isMarkdown(upload.Filename) {
+			return nil, errors.New("not a Markdown file")
+		}
+		content, err := readLimited(src)
+		if err != nil {
+			return nil, err
+		}
+		return []markdownFile{{name: upload.Filename, content: content}}, nil
+	}
+
+	archive, err := zip.NewReader(src, upload.Size)
+	if err != nil {
+		return nil, errors.New("invalid zip archive")
+	}
+
+	var files []markdownFile
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !isMarkdown(entry.Name) ||
+			strings.HasPrefix(entry.Name, "__MACOSX/") || strings.HasPrefix(path.Base(entry.Name), ".") {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		content, err := readLimited(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		files = append(files, markdownFile{name: entry.Name, content: content})
+	}
+	return files, nil
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxImportFileSize+1))
+	if err != nil {
+		return nil, errors.New("failed to read file")
+	}
+	if len(content) > maxImportFileSize {
+		return nil, errors.New("file is too large")
+	}
+	return content, nil
+}
+
+func isMarkdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown", ".mdown", ".txt":
+		return true
+	}
+	return false
+}
+
+func isZip(name string) bool {
+	return strings.ToLower(path.Ext(name)) == ".zip"
+}
@@ -1,138 +1,107 @@
 package handlers
 
 import (
-	"database/sql"
-	"encoding/json"
-	"log"
+	"bytes"
+	"errors"
+	"mime"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/pdf"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
-func (h *Handler) GetNotes(c *gin.Context) {
-	rows, err := h.db.Query(`
-		SELECT n.id, n.title, n.account_id, n.template_type, n.internal_participants, 
-			   n.external_participants, n.content, n.meeting_id, n.meeting_date, 
-			   n.created_at, n.updated_at, a.name as account_name
-		FROM notes n
-		LEFT JOIN accounts a ON n.account_id = a.id
-		WHERE n.deleted_at IS NULL
-		ORDER BY n.created_at DESC
-	`)
+// parseMeetingDate accepts RFC3339 and the Apple calendar format, which has
+// no colon in the offset
+func parseMeetingDate(value string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, value)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		parsed, err = time.Parse("2006-01-02T15:04:05-0700", value)
 	}
-	defer rows.Close()
-
-	notes := []map[string]interface{}{}
-	for rows.Next() {
-		var n models.Note
-		var internalJSON, externalJSON string
-		var accountName sql.NullString
-
-		if err := rows.Scan(&n.ID, &n.Title, &n.AccountID, &n.TemplateType, &internalJSON,
-			&externalJSON, &n.Content, &n.MeetingID, &n.MeetingDate, &n.CreatedAt, &n.UpdatedAt, &accountName); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		if err := json.Unmarshal([]byte(internalJSON), &n.InternalParticipants); err != nil {
-			log.Printf("Error unmarshalling internal participants for note %s: %v", n.ID, err)
-		}
-		if err := json.Unmarshal([]byte(externalJSON), &n.ExternalParticipants); err != nil {
-			log.Printf("Error unmarshalling external participants for note %s: %v", n.ID, err)
-		}
+	return parsed, err
+}
 
-		note := map[string]interface{}{
-			"id":                    n.ID,
-			"title":                 n.Title,
-			"account_id":            n.AccountID,
-			"account_name":          accountName.String,
-			"template_type":         n.TemplateType,
-			"internal_participants": n.InternalParticipants,
-			"external_participants": n.ExternalParticipants,
-			"content":               n.Content,
-			"meeting_id":            n.MeetingID,
-			"meeting_date":          n.MeetingDate,
-			"created_at":            n.CreatedAt,
-			"updated_at":            n.UpdatedAt,
-		}
-		notes = append(notes, note)
+// noteSummary is the list representation of a note
+func noteSummary(n models.Note) gin.H {
+	return gin.H{
+		"id":                    n.ID,
+		"title":                 n.Title,
+		"account_id":            n.AccountID,
+		"account_name":          n.AccountName,
+		"template_type":         n.TemplateType,
+		"internal_participants": n.InternalParticipants,
+		"external_participants": n.ExternalParticipants,
+		"content":               n.Content,
+		"meeting_id":            n.MeetingID,
+		"meeting_date":          n.MeetingDate,
+		"version":               n.Version,
+		"created_at":            n.CreatedAt,
+		"updated_at":            n.UpdatedAt,
 	}
+}
 
-	c.JSON(http.StatusOK, notes)
+// noteDetail is a note with its linked todos, as GetNote returns it
+func noteDetail(n models.Note) gin.H {
+	response := noteSummary(n)
+	response["todos"] = n.Todos
+	return response
 }
 
-func (h *Handler) GetNote(c *gin.Context) {
-	id := c.Param("id")
-	var n models.Note
-	var internalJSON, externalJSON string
-	var accountName sql.NullString
-
-	err := h.db.QueryRow(`
-		SELECT n.id, n.title, n.account_id, n.template_type, n.internal_participants, 
-			   n.external_participants, n.content, n.meeting_id, n.meeting_date, 
-			   n.created_at, n.updated_at, a.name as account_name
-		FROM notes n
-		LEFT JOIN accounts a ON n.account_id = a.id
-		WHERE n.id = ?
-	`, id).Scan(&n.ID, &n.Title, &n.AccountID, &n.TemplateType, &internalJSON,
-		&externalJSON, &n.Content, &n.MeetingID, &n.MeetingDate, &n.CreatedAt, &n.UpdatedAt, &accountName)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+// GetNotes lists notes not in the trash, filtered by account_id, tags,
+// template_type, pinned, archived and a from/to meeting date range
+func (h *Handler) GetNotes(c *gin.Context) {
+	o, err := listOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err != nil {
-		// Log the error for debugging
-		log.Printf("GetNote error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	f := store.NoteFilter{
+		AccountID:    c.Query("account_id"),
+		TemplateType: c.Query("template_type"),
+		OmitContent:  !fieldWanted(c, "content"),
+	}
+	if f.Tags, f.MatchAny, err = tagsParam(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	for name, dest := range map[string]**bool{"pinned": &f.Pinned, "archived": &f.Archived} {
+		if *dest, err = boolParam(c, name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	for name, dest := range map[string]**time.Time{"from": &f.From, "to": &f.To} {
+		if *dest, err = dateParam(c, name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	if err := json.Unmarshal([]byte(internalJSON), &n.InternalParticipants); err != nil {
-		// log.Printf("Error unmarshalling internal participants for note %s: %v", n.ID, err)
+	list, info, err := h.notes.List(f, o)
+	if err != nil {
+		respondListError(c, err)
+		return
 	}
-	if err := json.Unmarshal([]byte(externalJSON), &n.ExternalParticipants); err != nil {
-		// log.Printf("Error unmarshalling external participants for note %s: %v", n.ID, err)
+
+	notes := []gin.H{}
+	for _, n := range list {
+		notes = append(notes, noteSummary(n))
 	}
 
-	// Get linked todos
-	todoRows, err := h.db.Query(`
-		SELECT t.id, t.title, t.description, t.status, t.priority, t.due_date, t.created_at, t.updated_at
-		FROM todos t
-		JOIN note_todos nt ON t.id = nt.todo_id
-		WHERE nt.note_id = ?
-	`, id)
-	if err == nil {
-		defer todoRows.Close()
-		for todoRows.Next() {
-			var todo models.Todo
-			todoRows.Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Status, &todo.Priority, &todo.DueDate, &todo.CreatedAt, &todo.UpdatedAt)
-			n.Todos = append(n.Todos, todo)
-		}
+	respondList(c, notes, info)
+}
+
+func (h *Handler) GetNote(c *gin.Context) {
+	n, err := h.notes.Get(c.Param("id"))
+	if err != nil {
+		respondStoreError(c, err, "Note not found")
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":                    n.ID,
-		"title":                 n.Title,
-		"account_id":            n.AccountID,
-		"account_name":          accountName.String,
-		"template_type":         n.TemplateType,
-		"internal_participants": n.InternalParticipants,
-		"external_participants": n.ExternalParticipants,
-		"content":               n.Content,
-		"meeting_id":            n.MeetingID,
-		"meeting_date":          n.MeetingDate,
-		"created_at":            n.CreatedAt,
-		"updated_at":            n.UpdatedAt,
-		"todos":                 n.Todos,
-	})
+	setETag(c, n.Version)
+	c.JSON(http.StatusOK, noteDetail(*n))
 }
 
 func (h *Handler) CreateNote(c *gin.Context) {
@@ -147,35 +116,37 @@ func (h *Handler) CreateNote(c *gin.Context) {
 		return
 	}
 
-	id := uuid.New().String()
-	now := time.Now()
-
 	if req.TemplateType == "" {
 		req.TemplateType = "initial"
 	}
 
-	internalJSON, _ := json.Marshal(req.InternalParticipants)
-	externalJSON, _ := json.Marshal(req.ExternalParticipants)
-
 	var meetingDate *time.Time
 	if req.MeetingDate != nil {
-		parsed, err := time.Parse(time.RFC3339, *req.MeetingDate)
+		parsed, err := parseMeetingDate(*req.MeetingDate)
 		if err != nil {
-			// Try parsing apple format (no colon in offset)
-			parsed, err = time.Parse("2006-01-02T15:04:05-0700", *req.MeetingDate)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid meeting date format"})
-				return
-			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid meeting date format"})
+			return
 		}
 		meetingDate = &parsed
 	}
 
-	_, err := h.db.Exec(`
-		INSERT INTO notes (id, title, account_id, template_type, internal_participants, external_participants, content, meeting_id, meeting_date, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, id, req.Title, req.AccountID, req.TemplateType, string(internalJSON), string(externalJSON), req.Content, req.MeetingID, meetingDate, now, now)
+	content, sync, err := h.linkTaskItems(req.Content, req.AccountID, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
+	n := &models.Note{
+		Title:                req.Title,
+		AccountID:            req.AccountID,
+		TemplateType:         req.TemplateType,
+		InternalParticipants: req.InternalParticipants,
+		ExternalParticipants: req.ExternalParticipants,
+		Content:              content,
+		MeetingID:            req.MeetingID,
+		MeetingDate:          meetingDate,
+	}
+	result, err := h.notes.CreateWithTasks(n, sync)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -184,19 +155,23 @@ func (h *Handler) CreateNote(c *gin.Context) {
 	// Auto-extract contacts from participants
 	go h.ExtractContactsFromNote(req.InternalParticipants, req.ExternalParticipants)
 
-	c.JSON(http.StatusCreated, gin.H{
-		"id":                    id,
-		"title":                 req.Title,
-		"account_id":            req.AccountID,
-		"template_type":         req.TemplateType,
-		"internal_participants": req.InternalParticipants,
-		"external_participants": req.ExternalParticipants,
-		"content":               req.Content,
-		"meeting_id":            req.MeetingID,
-		"meeting_date":          meetingDate,
-		"created_at":            now,
-		"updated_at":            now,
-	})
+	response := gin.H{
+		"id":                    n.ID,
+		"title":                 n.Title,
+		"account_id":            n.AccountID,
+		"template_type":         n.TemplateType,
+		"internal_participants": n.InternalParticipants,
+		"external_participants": n.ExternalParticipants,
+		"content":               n.Content,
+		"meeting_id":            n.MeetingID,
+		"meeting_date":          n.MeetingDate,
+		"created_at":            n.CreatedAt,
+		"updated_at":            n.UpdatedAt,
+	}
+	if len(result.Skipped) > 0 {
+		response["task_errors"] = taskErrors(result)
+	}
+	c.JSON(http.StatusCreated, response)
 }
 
 func (h *Handler) UpdateNote(c *gin.Context) {
@@ -207,90 +182,98 @@ func (h *Handler) UpdateNote(c *gin.Context) {
 		return
 	}
 
-	updates := []string{}
-	args := []interface{}{}
-
-	if req.Title != nil {
-		updates = append(updates, "title = ?")
-		args = append(args, *req.Title)
-	}
-	if req.AccountID != nil {
-		updates = append(updates, "account_id = ?")
-		args = append(args, *req.AccountID)
-	}
-	if req.TemplateType != nil {
-		updates = append(updates, "template_type = ?")
-		args = append(args, *req.TemplateType)
-	}
-	if req.InternalParticipants != nil {
-		internalJSON, _ := json.Marshal(req.InternalParticipants)
-		updates = append(updates, "internal_participants = ?")
-		args = append(args, string(internalJSON))
-	}
-	if req.ExternalParticipants != nil {
-		externalJSON, _ := json.Marshal(req.ExternalParticipants)
-		updates = append(updates, "external_participants = ?")
-		args = append(args, string(externalJSON))
-	}
-	if req.Content != nil {
-		updates = append(updates, "content = ?")
-		args = append(args, *req.Content)
-	}
-	if req.MeetingID != nil {
-		updates = append(updates, "meeting_id = ?")
-		args = append(args, *req.MeetingID)
+	update := store.NoteUpdate{
+		Title:                req.Title,
+		AccountID:            req.AccountID,
+		TemplateType:         req.TemplateType,
+		InternalParticipants: req.InternalParticipants,
+		ExternalParticipants: req.ExternalParticipants,
+		Content:              req.Content,
+		MeetingID:            req.MeetingID,
 	}
 	if req.MeetingDate != nil {
-		parsed, err := time.Parse(time.RFC3339, *req.MeetingDate)
+		parsed, err := parseMeetingDate(*req.MeetingDate)
 		if err != nil {
-			// Try parsing apple format
-			parsed, err = time.Parse("2006-01-02T15:04:05-0700", *req.MeetingDate)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid meeting date format"})
-				return
-			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid meeting date format"})
+			return
 		}
-		updates = append(updates, "meeting_date = ?")
-		args = append(args, parsed)
+		update.MeetingDate = &parsed
 	}
 
-	if len(updates) == 0 {
+	if update.IsEmpty() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
 		return
 	}
 
-	updates = append(updates, "updated_at = ?")
-	args = append(args, time.Now())
-	args = append(args, id)
+	expected, ok := expectedVersion(c, req.Version)
+	if !ok {
+		return
+	}
+	update.ExpectedVersion = expected
+
+	var sync store.TaskSync
+	if req.Content != nil {
+		n, err := h.notes.Get(id)
+		if err != nil {
+			respondStoreError(c, err, "Note not found")
+			return
+		}
+		accountID := n.AccountID
+		if req.AccountID != nil {
+			accountID = *req.AccountID
+		}
+		content, s, err := h.linkTaskItems(*req.Content, accountID, n.Todos)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		update.Content, sync = &content, s
+	}
 
-	query := "UPDATE notes SET " + strings.Join(updates, ", ") + " WHERE id = ?"
-	result, err := h.db.Exec(query, args...)
+	result, err := h.notes.UpdateWithTasks(id, update, sync)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, store.ErrConflict) {
+			h.respondNoteConflict(c, id)
+			return
+		}
+		respondStoreError(c, err, "Note not found")
 		return
 	}
+	h.PruneRevisions(id)
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+	n, err := h.notes.Get(id)
+	if err != nil {
+		respondStoreError(c, err, "Note not found")
 		return
 	}
-
-	h.GetNote(c)
+	response := noteDetail(*n)
+	if len(result.Skipped) > 0 {
+		response["task_errors"] = taskErrors(result)
+	}
+	setETag(c, n.Version)
+	c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) DeleteNote(c *gin.Context) {
-	id := c.Param("id")
-	// Soft delete - set deleted_at timestamp
-	result, err := h.db.Exec("UPDATE notes SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL", id)
+// respondNoteConflict returns 409 with the server's copy of the note so the
+// client can merge or reapply its edit
+func (h *Handler) respondNoteConflict(c *gin.Context, id string) {
+	n, err := h.notes.Get(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStoreError(c, err, "Note not found")
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+	current := noteDetail(*n)
+	setETag(c, n.Version)
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "Note was modified by someone else",
+		"current": current,
+	})
+}
+
+func (h *Handler) DeleteNote(c *gin.Context) {
+	if err := h.notes.Delete(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Note not found")
 		return
 	}
 
@@ -298,170 +281,102 @@ func (h *Handler) DeleteNote(c *gin.Context) {
 }
 
 func (h *Handler) RestoreNote(c *gin.Context) {
-	id := c.Param("id")
-	result, err := h.db.Exec("UPDATE notes SET deleted_at = NULL WHERE id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+	if err := h.notes.Restore(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Note not found")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Note restored"})
 }
 
 func (h *Handler) PermanentDeleteNote(c *gin.Context) {
-	id := c.Param("id")
-	result, err := h.db.Exec("DELETE FROM notes WHERE id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+	if err := h.notes.PermanentDelete(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Note not found")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Note permanently deleted"})
 }
 
 func (h *Handler) GetDeletedNotes(c *gin.Context) {
-	rows, err := h.db.Query(`
-		SELECT n.id, n.title, n.account_id, n.template_type, n.created_at, n.updated_at, 
-		       n.deleted_at, COALESCE(a.name, '') as account_name
-		FROM notes n
-		LEFT JOIN accounts a ON n.account_id = a.id
-		WHERE n.deleted_at IS NOT NULL
-		ORDER BY n.deleted_at DESC
-	`)
+	list, err := h.notes.ListDeleted()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	notes := []map[string]interface{}{}
-	for rows.Next() {
-		var id, title, accountID, templateType, accountName string
-		var deletedAt sql.NullString
-		var createdAt, updatedAt time.Time
-		if err := rows.Scan(&id, &title, &accountID, &templateType, &createdAt, &updatedAt, &deletedAt, &accountName); err != nil {
-			continue
-		}
-		notes = append(notes, map[string]interface{}{
-			"id":            id,
-			"title":         title,
-			"account_id":    accountID,
-			"account_name":  accountName,
-			"template_type": templateType,
-			"created_at":    createdAt,
-			"updated_at":    updatedAt,
-			"deleted_at":    deletedAt.String,
+	notes := []gin.H{}
+	for _, n := range list {
+		notes = append(notes, gin.H{
+			"id":            n.ID,
+			"title":         n.Title,
+			"account_id":    n.AccountID,
+			"account_name":  n.AccountName,
+			"template_type": n.TemplateType,
+			"created_at":    n.CreatedAt,
+			"updated_at":    n.UpdatedAt,
+			"deleted_at":    n.DeletedAt,
 		})
 	}
 	c.JSON(http.StatusOK, notes)
 }
 
 func (h *Handler) GetNotesByAccount(c *gin.Context) {
-	accountID := c.Param("id")
-	rows, err := h.db.Query(`
-		SELECT id, title, account_id, template_type, internal_participants, 
-			   external_participants, content, meeting_id, meeting_date, created_at, updated_at
-		FROM notes WHERE account_id = ? AND deleted_at IS NULL ORDER BY created_at DESC
-	`, accountID)
+	notes, err := h.notes.ListByAccount(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	notes := []models.Note{}
-	for rows.Next() {
-		var n models.Note
-		var internalJSON, externalJSON string
-		if err := rows.Scan(&n.ID, &n.Title, &n.AccountID, &n.TemplateType, &internalJSON,
-			&externalJSON, &n.Content, &n.MeetingID, &n.MeetingDate, &n.CreatedAt, &n.UpdatedAt); err != nil {
-			continue
-		}
-		if err := json.Unmarshal([]byte(internalJSON), &n.InternalParticipants); err != nil {
-			log.Printf("Error unmarshalling internal participants for note %s: %v", n.ID, err)
-		}
-		if err := json.Unmarshal([]byte(externalJSON), &n.ExternalParticipants); err != nil {
-			log.Printf("Error unmarshalling external participants for note %s: %v", n.ID, err)
-		}
-		notes = append(notes, n)
-	}
 
 	c.JSON(http.StatusOK, notes)
 }
 
+// ExportNotePDF returns a note for printing. With format=pdf the PDF is
+// rendered on the server; otherwise the note data is returned as JSON for
+// the frontend to lay out.
 func (h *Handler) ExportNotePDF(c *gin.Context) {
-	// PDF export will be implemented with a proper library
-	// For now, return the note data that can be used for client-side PDF generation
-	id := c.Param("id")
 	exportType := c.DefaultQuery("type", "full") // "full" or "minimal"
 
-	var n models.Note
-	var internalJSON, externalJSON string
-	var accountName, accountOwner string
-	var budget sql.NullFloat64
-	var estEngineers sql.NullInt64
-
-	err := h.db.QueryRow(`
-		SELECT n.id, n.title, n.account_id, n.template_type, n.internal_participants, 
-			   n.external_participants, n.content, n.meeting_id, n.meeting_date, 
-			   n.created_at, n.updated_at, a.name, a.account_owner, a.budget, a.est_engineers
-		FROM notes n
-		LEFT JOIN accounts a ON n.account_id = a.id
-		WHERE n.id = ?
-	`, id).Scan(&n.ID, &n.Title, &n.AccountID, &n.TemplateType, &internalJSON,
-		&externalJSON, &n.Content, &n.MeetingID, &n.MeetingDate, &n.CreatedAt, &n.UpdatedAt,
-		&accountName, &accountOwner, &budget, &estEngineers)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
-		return
-	}
+	n, err := h.notes.Get(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStoreError(c, err, "Note not found")
 		return
 	}
 
-	json.Unmarshal([]byte(internalJSON), &n.InternalParticipants)
-	json.Unmarshal([]byte(externalJSON), &n.ExternalParticipants)
+	if c.Query("format") == "pdf" {
+		h.renderNotePDF(c, n, exportType == "minimal")
+		return
+	}
 
 	response := gin.H{
 		"id":           n.ID,
 		"title":        n.Title,
 		"content":      n.Content,
-		"account_name": accountName,
+		"account_name": n.AccountName,
 		"meeting_date": n.MeetingDate,
 		"export_type":  exportType,
 	}
 
 	if exportType == "full" {
-		// Get linked todos
-		todoRows, _ := h.db.Query(`
-			SELECT t.id, t.title, t.status FROM todos t
-			JOIN note_todos nt ON t.id = nt.todo_id
-			WHERE nt.note_id = ?
-		`, id)
-
 		todos := []map[string]string{}
-		if todoRows != nil {
-			defer todoRows.Close()
-			for todoRows.Next() {
-				var todoID, todoTitle, todoStatus string
-				todoRows.Scan(&todoID, &todoTitle, &todoStatus)
-				todos = append(todos, map[string]string{"id": todoID, "title": todoTitle, "status": todoStatus})
+		for _, t := range n.Todos {
+			todos = append(todos, map[string]string{"id": t.ID, "title": t.Title, "status": t.Status})
+		}
+
+		var accountOwner string
+		var budget float64
+		var estEngineers int
+		if n.Account != nil {
+			accountOwner = n.Account.AccountOwner
+			if n.Account.Budget != nil {
+				budget = *n.Account.Budget
+			}
+			if n.Account.EstEngineers != nil {
+				estEngineers = *n.Account.EstEngineers
 			}
 		}
 
 		response["account_owner"] = accountOwner
-		response["budget"] = budget.Float64
-		response["est_engineers"] = estEngineers.Int64
+		response["budget"] = budget
+		response["est_engineers"] = estEngineers
 		response["internal_participants"] = n.InternalParticipants
 		response["external_participants"] = n.ExternalParticipants
 		response["todos"] = todos
@@ -470,196 +385,79 @@ func (h *Handler) ExportNotePDF(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) ImportMarkdown(c *gin.Context) {
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
-		return
-	}
-
-	// Open uploaded file
-	src, err := file.Open()
+func (h *Handler) renderNotePDF(c *gin.Context, n *models.Note, minimal bool) {
+	doc, err := h.notePDF(n, minimal)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
-		return
-	}
-	defer src.Close()
-
-	// Read content
-	contentBytes := make([]byte, file.Size)
-	if _, err := src.Read(contentBytes); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	content := string(contentBytes)
-
-	// Basic Markdown parsing (very simple for now)
-	// Assume Title is first line # Title, or filename if not present
-	lines := strings.Split(content, "\n")
-	title := strings.TrimSuffix(file.Filename, ".md")
-	if len(lines) > 0 && strings.HasPrefix(lines[0], "# ") {
-		title = strings.TrimPrefix(lines[0], "# ")
-		// Remove title from content if we extracted it
-		content = strings.Join(lines[1:], "\n")
-	}
-
-	// Convert Markdown to HTML (simple conversion or store raw)
-	// For this app, the editor expects HTML. 
-	// Since we don't have a full MD->HTML parser here, we'll just wrap paragraphs.
-	// Ideally, use a library like blackfriday or goldmark.
-	// For now, let's just store it as raw text wrapped in p tags to avoid breaking the editor completely
-	// Or better, just treat it as raw content if the editor supports it.
-	// TipTap can handle some HTML. Let's just replace newlines with <br> or wrap in <p>
-	
-	// Simple naive conversion for lines
-	var htmlBuilder strings.Builder
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		if strings.HasPrefix(line, "# ") {
-			// Skip title if we already extracted it, or convert to h1
-			continue 
-		}
-		if strings.HasPrefix(line, "## ") {
-			htmlBuilder.WriteString("<h2>" + strings.TrimPrefix(line, "## ") + "</h2>")
-		} else if strings.HasPrefix(line, "- ") {
-			htmlBuilder.WriteString("<ul><li>" + strings.TrimPrefix(line, "- ") + "</li></ul>")
-		} else {
-			htmlBuilder.WriteString("<p>" + line + "</p>")
-		}
-	}
-	htmlContent := htmlBuilder.String()
 
-	id := uuid.New().String()
-	now := time.Now()
-
-	// Create note
-	_, err = h.db.Exec(`
-		INSERT INTO notes (id, title, content, template_type, created_at, updated_at)
-		VALUES (?, ?, ?, 'imported', ?, ?)
-	`, id, title, htmlContent, now, now)
-
-	if err != nil {
+	var buf bytes.Buffer
+	if err := pdf.Render(&buf, doc); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"id":    id,
-		"title": title,
-	})
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": exportFilename(n.Title, "pdf"),
+	}))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
 }
 
-func (h *Handler) ExportMarkdown(c *gin.Context) {
-	id := c.Param("id")
-	var n models.Note
-	err := h.db.QueryRow("SELECT title, content FROM notes WHERE id = ?", id).Scan(&n.Title, &n.Content)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
-		return
+// notePDF gathers the tags and attachments printed alongside a note
+func (h *Handler) notePDF(n *models.Note, minimal bool) (pdf.Note, error) {
+	doc := pdf.Note{Note: n, Minimal: minimal, ImagePath: h.uploadPath}
+	if minimal {
+		return doc, nil
 	}
 
-	// Simple HTML to Markdown conversion (naive)
-	// Replace tags with MD equivalents
-	md := n.Content
-	md = strings.ReplaceAll(md, "<h1>", "# ")
-	md = strings.ReplaceAll(md, "</h1>", "\n\n")
-	md = strings.ReplaceAll(md, "<h2>", "## ")
-	md = strings.ReplaceAll(md, "</h2>", "\n\n")
-	md = strings.ReplaceAll(md, "<h3>", "### ")
-	md = strings.ReplaceAll(md, "</h3>", "\n\n")
-	md = strings.ReplaceAll(md, "<p>", "")
-	md = strings.ReplaceAll(md, "</p>", "\n\n")
-	md = strings.ReplaceAll(md, "<b>", "**")
-	md = strings.ReplaceAll(md, "</b>", "**")
-	md = strings.ReplaceAll(md, "<strong>", "**")
-	md = strings.ReplaceAll(md, "</strong>", "**")
-	md = strings.ReplaceAll(md, "<i>", "*")
-	md = strings.ReplaceAll(md, "</i>", "*")
-	md = strings.ReplaceAll(md, "<em>", "*")
-	md = strings.ReplaceAll(md, "</em>", "*")
-	md = strings.ReplaceAll(md, "<ul>", "")
-	md = strings.ReplaceAll(md, "</ul>", "")
-	md = strings.ReplaceAll(md, "<li>", "- ")
-	md = strings.ReplaceAll(md, "</li>", "\n")
-	md = strings.ReplaceAll(md, "<br>", "\n")
-	
-	// Add title at top
-	finalMD := "# " + n.Title + "\n\n" + md
-
-	c.Header("Content-Disposition", "attachment; filename="+n.Title+".md")
-	c.Data(http.StatusOK, "text/markdown", []byte(finalMD))
+	var err error
+	if doc.Tags, err = h.tags.ListForNote(n.ID); err != nil {
+		return doc, err
+	}
+	if doc.Attachments, err = h.noteAttachments(n.ID); err != nil {
+		return doc, err
+	}
+	return doc, nil
 }
 
 func (h *Handler) ToggleNotePin(c *gin.Context) {
-	id := c.Param("id")
-
-	var pinned int
-	err := h.db.QueryRow("SELECT pinned FROM notes WHERE id = ?", id).Scan(&pinned)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+	pinned, err := h.notes.TogglePin(c.Param("id"))
+	if err != nil {
+		respondStoreError(c, err, "Note not found")
 		return
 	}
 
-	newPinned := 1
-	if pinned == 1 {
-		newPinned = 0
-	}
-
-	h.db.Exec("UPDATE notes SET pinned = ?, updated_at = ? WHERE id = ?", newPinned, time.Now(), id)
-
-	c.JSON(http.StatusOK, gin.H{"pinned": newPinned == 1})
+	c.JSON(http.StatusOK, gin.H{"pinned": pinned})
 }
 
 func (h *Handler) ToggleNoteArchive(c *gin.Context) {
-	id := c.Param("id")
-
-	var archived int
-	err := h.db.QueryRow("SELECT archived FROM notes WHERE id = ?", id).Scan(&archived)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+	archived, err := h.notes.ToggleArchive(c.Param("id"))
+	if err != nil {
+		respondStoreError(c, err, "Note not found")
 		return
 	}
 
-	newArchived := 1
-	if archived == 1 {
-		newArchived = 0
-	}
-
-	h.db.Exec("UPDATE notes SET archived = ?, updated_at = ? WHERE id = ?", newArchived, time.Now(), id)
-
-	c.JSON(http.StatusOK, gin.H{"archived": newArchived == 1})
+	c.JSON(http.StatusOK, gin.H{"archived": archived})
 }
 
 func (h *Handler) GetArchivedNotes(c *gin.Context) {
-	rows, err := h.db.Query(`
-		SELECT n.id, n.title, n.account_id, n.template_type, n.created_at, n.updated_at,
-		       COALESCE(a.name, '') as account_name
-		FROM notes n
-		LEFT JOIN accounts a ON n.account_id = a.id
-		WHERE n.archived = 1
-		ORDER BY n.updated_at DESC
-	`)
+	list, err := h.notes.ListArchived()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	notes := []map[string]interface{}{}
-	for rows.Next() {
-		var id, title, accountID, templateType, accountName string
-		var createdAt, updatedAt time.Time
-		rows.Scan(&id, &title, &accountID, &templateType, &createdAt, &updatedAt, &accountName)
-		notes = append(notes, map[string]interface{}{
-			"id":            id,
-			"title":         title,
-			"account_id":    accountID,
-			"account_name":  accountName,
-			"template_type": templateType,
-			"created_at":    createdAt,
-			"updated_at":    updatedAt,
+
+	notes := []gin.H{}
+	for _, n := range list {
+		notes = append(notes, gin.H{
+			"id":            n.ID,
+			"title":         n.Title,
+			"account_id":    n.AccountID,
+			"account_name":  n.AccountName,
+			"template_type": n.TemplateType,
+			"created_at":    n.CreatedAt,
+			"updated_at":    n.UpdatedAt,
 		})
 	}
 
@@ -667,29 +465,13 @@ func (h *Handler) GetArchivedNotes(c *gin.Context) {
 }
 
 func (h *Handler) ReorderNotes(c *gin.Context) {
-	accountID := c.Param("id")
 	var req models.ReorderNotesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	tx, err := h.db.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	for i, noteID := range req.NoteIDs {
-		_, err := tx.Exec("UPDATE notes SET sort_order = ? WHERE id = ? AND account_id = ?", i, noteID, accountID)
-		if err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	}
-
-	if err := tx.Commit(); err != nil {
+	if err := h.notes.Reorder(c.Param("id"), req.NoteIDs); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -699,12 +481,11 @@ func (h *Handler) ReorderNotes(c *gin.Context) {
 
 // EmptyNotesTrash permanently deletes all soft-deleted notes
 func (h *Handler) EmptyNotesTrash(c *gin.Context) {
-	result, err := h.db.Exec(`DELETE FROM notes WHERE deleted_at IS NOT NULL`)
+	count, err := h.notes.EmptyTrash()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	rows, _ := result.RowsAffected()
-	c.JSON(http.StatusOK, gin.H{"message": "Trash emptied", "count": rows})
+	c.JSON(http.StatusOK, gin.H{"message": "Trash emptied", "count": count})
 }
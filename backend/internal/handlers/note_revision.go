@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/diff"
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// GetRevisionRetention returns how long every note revision is kept before
+// older ones are thinned to one per day.
+// Set REVISION_RETENTION_DAYS environment variable to customize (default: 30)
+func GetRevisionRetention() time.Duration {
+	days := 30
+	if v, err := strconv.Atoi(os.Getenv("REVISION_RETENTION_DAYS")); err == nil && v >= 0 {
+		days = v
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// PruneRevisions applies the retention policy to one note, or to every note
+// when noteID is empty
+func (h *Handler) PruneRevisions(noteID string) {
+	if h.revisions == nil {
+		return
+	}
+	if _, err := h.revisions.Prune(noteID, time.Now().Add(-GetRevisionRetention())); err != nil {
+		log.Printf("Error pruning revisions: %v", err)
+	}
+}
+
+// GetNoteRevisions lists a note's revisions, newest first
+func (h *Handler) GetNoteRevisions(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.notes.Get(id); err != nil {
+		respondStoreError(c, err, "Note not found")
+		return
+	}
+
+	revisions, err := h.revisions.List(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetNoteRevision returns a single revision including its content
+func (h *Handler) GetNoteRevision(c *gin.Context) {
+	rev, ok := revisionParam(c)
+	if !ok {
+		return
+	}
+
+	revision, err := h.revisions.Get(c.Param("id"), rev)
+	if err != nil {
+		respondStoreError(c, err, "Revision not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// DiffNoteRevision compares a revision against another one. against may be a
+// revision number or "current"; it defaults to the previous revision.
+func (h *Handler) DiffNoteRevision(c *gin.Context) {
+	id := c.Param("id")
+	rev, ok := revisionParam(c)
+	if !ok {
+		return
+	}
+
+	to, err := h.revisions.Get(id, rev)
+	if err != nil {
+		respondStoreError(c, err, "Revision not found")
+		return
+	}
+
+	// The base defaults to the revision before; revision 1 diffs against empty
+	var from models.NoteRevision
+	var against interface{} = rev - 1
+	switch value := c.Query("against"); value {
+	case "":
+		if rev > 1 {
+			base, err := h.revisions.Get(id, rev-1)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if base != nil {
+				from = *base
+			}
+		}
+	case "current":
+		n, err := h.notes.Get(id)
+		if err != nil {
+			respondStoreError(c, err, "Note not found")
+			return
+		}
+		from = models.NoteRevision{Title: n.Title, Content: n.Content}
+		against = "current"
+	default:
+		other, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "against must be a revision number or 'current'"})
+			return
+		}
+		base, err := h.revisions.Get(id, other)
+		if err != nil {
+			respondStoreError(c, err, "Revision not found")
+			return
+		}
+		from = *base
+		against = other
+	}
+
+	changes := diff.Lines(diff.SplitHTML(from.Content), diff.SplitHTML(to.Content))
+	added, removed := diff.Stats(changes)
+
+	c.JSON(http.StatusOK, gin.H{
+		"note_id":       id,
+		"rev":           rev,
+		"against":       against,
+		"title_from":    from.Title,
+		"title_to":      to.Title,
+		"title_changed": from.Title != to.Title,
+		"added":         added,
+		"removed":       removed,
+		"changes":       changes,
+	})
+}
+
+// RestoreNoteRevision copies a revision's title and content back onto the
+// note. The restore is itself recorded as a new revision, so it can be undone.
+func (h *Handler) RestoreNoteRevision(c *gin.Context) {
+	id := c.Param("id")
+	rev, ok := revisionParam(c)
+	if !ok {
+		return
+	}
+
+	revision, err := h.revisions.Get(id, rev)
+	if err != nil {
+		respondStoreError(c, err, "Revision not found")
+		return
+	}
+
+	if err := h.notes.Update(id, store.NoteUpdate{Title: &revision.Title, Content: &revision.Content}); err != nil {
+		respondStoreError(c, err, "Note not found")
+		return
+	}
+	h.PruneRevisions(id)
+
+	h.GetNote(c)
+}
+
+func revisionParam(c *gin.Context) (int, bool) {
+	rev, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || rev < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision number"})
+		return 0, false
+	}
+	return rev, true
+}
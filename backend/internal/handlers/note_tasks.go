@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/factory-sagar/notes-droid/backend/internal/tasks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// checkboxStatuses maps task item checkboxes to the workflow: ticking an
+// item moves its todo to the first done status, unticking it to the first
+// open one
+type checkboxStatuses struct {
+	category map[string]string
+	open     string
+	done     string
+}
+
+func (h *Handler) checkboxStatuses() (*checkboxStatuses, error) {
+	w, err := h.workflow.Get()
+	if err != nil {
+		return nil, err
+	}
+	s := &checkboxStatuses{category: map[string]string{}}
+	for _, st := range w.Statuses {
+		s.category[st.Key] = st.Category
+		if st.Category == "open" && s.open == "" {
+			s.open = st.Key
+		}
+		if st.Category == "done" && s.done == "" {
+			s.done = st.Key
+		}
+	}
+	return s, nil
+}
+
+func (s *checkboxStatuses) isDone(status string) bool {
+	return s.category[status] == "done"
+}
+
+// linkTaskItems gives every task item in content a todo: the one named by
+// its data-todo-id, else a todo already linked to the note with the same
+// title, else a new one. It returns content with the ids written in, and
+// the todos to create, link and move once the note is saved, which the note
+// store does in the same transaction. Items pasted from another note keep
+// their todo, which is linked to this note as well.
+func (h *Handler) linkTaskItems(content, accountID string, linked []models.Todo) (string, store.TaskSync, error) {
+	sync := store.TaskSync{Status: map[string]string{}}
+	items := tasks.Parse(content)
+	if len(items) == 0 {
+		return content, sync, nil
+	}
+	statuses, err := h.checkboxStatuses()
+	if err != nil {
+		return "", sync, err
+	}
+
+	claimed := map[string]bool{}
+	for _, item := range items {
+		claimed[item.TodoID] = true
+	}
+	isLinked := map[string]bool{}
+	byTitle := map[string]models.Todo{}
+	for _, t := range linked {
+		isLinked[t.ID] = true
+		key := strings.ToLower(t.Title)
+		if _, ok := byTitle[key]; !ok && !claimed[t.ID] {
+			byTitle[key] = t
+		}
+	}
+
+	for i, item := range items {
+		if item.TodoID == "" {
+			if t, ok := byTitle[strings.ToLower(item.Title)]; ok {
+				// The editor dropped the id; the item is still the same todo
+				delete(byTitle, strings.ToLower(item.Title))
+				items[i].TodoID = t.ID
+				item.TodoID = t.ID
+			}
+		}
+
+		if item.TodoID == "" {
+			if item.Title == "" {
+				continue
+			}
+			t := &models.Todo{
+				ID:       uuid.New().String(),
+				Title:    item.Title,
+				Priority: item.Priority,
+				DueDate:  item.Due,
+			}
+			if t.Priority == "" {
+				t.Priority = "medium"
+			}
+			if accountID != "" {
+				t.AccountID = &accountID
+			}
+			if item.Checked {
+				t.Status = statuses.done
+			}
+			sync.Create = append(sync.Create, t)
+			items[i].TodoID = t.ID
+			continue
+		}
+
+		t, err := h.todos.Get(item.TodoID)
+		if errors.Is(err, store.ErrNotFound) {
+			// Deleted for good; the item stays as plain text
+			continue
+		}
+		if err != nil {
+			return "", sync, err
+		}
+		if !isLinked[t.ID] {
+			isLinked[t.ID] = true
+			sync.Link = append(sync.Link, t.ID)
+		}
+		if t.DeletedAt != nil || item.Checked == statuses.isDone(t.Status) {
+			continue
+		}
+		if item.Checked {
+			sync.Status[t.ID] = statuses.done
+		} else {
+			sync.Status[t.ID] = statuses.open
+		}
+	}
+	return tasks.Update(content, items), sync, nil
+}
+
+// taskErrors describes the task items whose checkbox was reset because the
+// workflow does not allow their todo to follow it
+func taskErrors(result *store.TaskResult) []gin.H {
+	errs := []gin.H{}
+	for id, err := range result.Skipped {
+		message := err.Error()
+		var transitionErr *store.TransitionError
+		if errors.As(err, &transitionErr) {
+			message = fmt.Sprintf("Status cannot change from %s to %s", transitionErr.From, transitionErr.To)
+		}
+		errs = append(errs, gin.H{"todo_id": id, "error": message})
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i]["todo_id"].(string) < errs[j]["todo_id"].(string) })
+	return errs
+}
+
+// ExtractNoteTodos turns the task items in a note's saved content into
+// todos, the same way saving the note does
+func (h *Handler) ExtractNoteTodos(c *gin.Context) {
+	n, err := h.notes.Get(c.Param("id"))
+	if err != nil {
+		respondStoreError(c, err, "Note not found")
+		return
+	}
+
+	content, sync, err := h.linkTaskItems(n.Content, n.AccountID, n.Todos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result := &store.TaskResult{}
+	if content != n.Content || len(sync.Link) > 0 || len(sync.Status) > 0 {
+		if result, err = h.notes.UpdateWithTasks(n.ID, store.NoteUpdate{Content: &content}, sync); err != nil {
+			respondStoreError(c, err, "Note not found")
+			return
+		}
+	}
+
+	created := []models.Todo{}
+	for _, t := range sync.Create {
+		created = append(created, *t)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"created":     created,
+		"updated":     result.Moved,
+		"content":     content,
+		"task_errors": taskErrors(result),
+	})
+}
@@ -1,15 +1,28 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
-	"time"
 
 	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
+// unassignedAccountID returns the "Unassigned" account used for notes
+// created without one, creating it on first use
+func (h *Handler) unassignedAccountID() (string, error) {
+	account, err := h.accounts.GetByName("Unassigned")
+	if errors.Is(err, store.ErrNotFound) {
+		account = &models.Account{Name: "Unassigned"}
+		err = h.accounts.Create(account)
+	}
+	if err != nil {
+		return "", err
+	}
+	return account.ID, nil
+}
+
 func (h *Handler) QuickCapture(c *gin.Context) {
 	var req models.QuickCaptureRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -17,38 +30,34 @@ func (h *Handler) QuickCapture(c *gin.Context) {
 		return
 	}
 
-	id := uuid.New().String()
-	now := time.Now()
-
 	if req.Type == "note" {
 		accountID := req.AccountID
 		if accountID == nil {
-			// Create a default "Unassigned" account if none provided
-			var defaultAccountID string
-			err := h.db.QueryRow("SELECT id FROM accounts WHERE name = 'Unassigned'").Scan(&defaultAccountID)
-			if err == sql.ErrNoRows {
-				defaultAccountID = uuid.New().String()
-				h.db.Exec("INSERT INTO accounts (id, name, created_at, updated_at) VALUES (?, 'Unassigned', ?, ?)",
-					defaultAccountID, now, now)
+			id, err := h.unassignedAccountID()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
 			}
-			accountID = &defaultAccountID
+			accountID = &id
 		}
 
-		_, err := h.db.Exec(`
-			INSERT INTO notes (id, title, account_id, template_type, content, created_at, updated_at)
-			VALUES (?, ?, ?, 'quick', ?, ?, ?)
-		`, id, req.Title, *accountID, req.Content, now, now)
-		if err != nil {
+		n := &models.Note{
+			Title:        req.Title,
+			AccountID:    *accountID,
+			TemplateType: "quick",
+			Content:      req.Content,
+		}
+		if err := h.notes.Create(n); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
 		c.JSON(http.StatusCreated, gin.H{
-			"id":         id,
+			"id":         n.ID,
 			"type":       "note",
-			"title":      req.Title,
-			"account_id": *accountID,
-			"created_at": now,
+			"title":      n.Title,
+			"account_id": n.AccountID,
+			"created_at": n.CreatedAt,
 		})
 	} else if req.Type == "todo" {
 		priority := req.Priority
@@ -56,21 +65,23 @@ func (h *Handler) QuickCapture(c *gin.Context) {
 			priority = "medium"
 		}
 
-		_, err := h.db.Exec(`
-			INSERT INTO todos (id, title, description, status, priority, account_id, created_at, updated_at)
-			VALUES (?, ?, ?, 'not_started', ?, ?, ?, ?)
-		`, id, req.Title, req.Description, priority, req.AccountID, now, now)
-		if err != nil {
+		t := &models.Todo{
+			Title:       req.Title,
+			Description: req.Description,
+			Priority:    priority,
+			AccountID:   req.AccountID,
+		}
+		if err := h.todos.Create(t, nil); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
 		c.JSON(http.StatusCreated, gin.H{
-			"id":         id,
+			"id":         t.ID,
 			"type":       "todo",
-			"title":      req.Title,
-			"priority":   priority,
-			"created_at": now,
+			"title":      t.Title,
+			"priority":   t.Priority,
+			"created_at": t.CreatedAt,
 		})
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type, must be 'note' or 'todo'"})
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/remind"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultSnooze is how long a reminder is snoozed when no time is given
+	defaultSnooze = 10 * time.Minute
+	// maxReminderMinutes is the longest lead time, 30 days
+	maxReminderMinutes = 30 * 24 * 60
+	// streamKeepAlive is how often an idle reminder stream sends a comment
+	// so that proxies keep the connection open
+	streamKeepAlive = 30 * time.Second
+)
+
+// GetReminderLeadTimes returns how many minutes before its due date a todo
+// without its own reminders is reminded. Set REMINDER_LEAD_TIMES to a comma
+// separated list of durations such as "24h,1h" to customize (default: 1h)
+func GetReminderLeadTimes() []int {
+	if v := os.Getenv("REMINDER_LEAD_TIMES"); v != "" {
+		var minutes []int
+		for _, part := range strings.Split(v, ",") {
+			d, err := time.ParseDuration(strings.TrimSpace(part))
+			if err != nil || d < 0 {
+				log.Printf("Error reading REMINDER_LEAD_TIMES: invalid duration %q, using 1h", part)
+				return []int{60}
+			}
+			minutes = append(minutes, int(d/time.Minute))
+		}
+		return minutes
+	}
+	return []int{60}
+}
+
+// GetReminderInterval returns how often due reminders are checked. Set
+// REMINDER_INTERVAL to a duration to customize, or to "0" to turn reminders
+// off (default: 1m)
+func GetReminderInterval() time.Duration {
+	if v := os.Getenv("REMINDER_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return time.Minute
+}
+
+// reminderScheduler returns the scheduler with the notifiers every build
+// has: the web UI stream, and a webhook when REMINDER_WEBHOOK_URL is set
+func reminderScheduler(reminders store.ReminderStore, stream *remind.Broadcaster) *remind.Scheduler {
+	s := remind.NewScheduler(reminders, GetReminderLeadTimes(), stream)
+	if url := os.Getenv("REMINDER_WEBHOOK_URL"); url != "" {
+		s.Add(remind.NewWebhook(url))
+	}
+	return s
+}
+
+// AddReminderNotifier delivers reminders through n as well, such as the
+// desktop app's native notifications
+func (h *Handler) AddReminderNotifier(n remind.Notifier) {
+	h.scheduler.Add(n)
+}
+
+// RunReminders fires reminders every GetReminderInterval until stop is
+// closed
+func (h *Handler) RunReminders(stop <-chan struct{}) {
+	h.scheduler.Run(GetReminderInterval(), stop, func(err error) {
+		log.Printf("Error firing reminders: %v", err)
+	})
+}
+
+// reminderMinutes checks lead times and returns them sorted, longest first,
+// without duplicates. nil stays nil so the defaults apply.
+func reminderMinutes(minutes []int) ([]int, error) {
+	if minutes == nil {
+		return nil, nil
+	}
+	seen := map[int]bool{}
+	cleaned := []int{}
+	for _, m := range minutes {
+		if m < 0 || m > maxReminderMinutes {
+			return nil, errors.New("Reminder minutes must be between 0 and " + strconv.Itoa(maxReminderMinutes))
+		}
+		if !seen[m] {
+			seen[m] = true
+			cleaned = append(cleaned, m)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(cleaned)))
+	return cleaned, nil
+}
+
+// GetUpcomingReminders lists the reminders that fire within the given
+// duration (default 24h), earliest first
+func (h *Handler) GetUpcomingReminders(c *gin.Context) {
+	within := 24 * time.Hour
+	if v := c.Query("within"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid within, must be a duration such as 24h"})
+			return
+		}
+		within = d
+	}
+
+	now := time.Now()
+	reminders, err := h.scheduler.Upcoming(now, now.Add(within))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reminders)
+}
+
+// StreamReminders sends each reminder as it fires as a Server-Sent Event
+// named "reminder"
+func (h *Handler) StreamReminders(c *gin.Context) {
+	reminders, cancel := h.reminderStream.Subscribe()
+	defer cancel()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case r := <-reminders:
+			c.SSEvent("reminder", r)
+			return true
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// SetTodoReminders sets how many minutes before its due date a todo is
+// reminded; null minutes restore the default
+func (h *Handler) SetTodoReminders(c *gin.Context) {
+	var req models.SetRemindersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	minutes, err := reminderMinutes(req.Minutes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.reminders.SetMinutes(c.Param("id"), minutes); err != nil {
+		respondStoreError(c, err, "Todo not found")
+		return
+	}
+	h.GetTodo(c)
+}
+
+// SnoozeReminder postpones the reminder of a todo at one lead time, by the
+// given minutes (default 10) or until a given time
+func (h *Handler) SnoozeReminder(c *gin.Context) {
+	lead, err := strconv.Atoi(c.Param("lead"))
+	if err != nil || lead < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lead, must be the reminder's minutes before the due date"})
+		return
+	}
+	var req models.SnoozeReminderRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	now := time.Now()
+	until := now.Add(defaultSnooze)
+	switch {
+	case req.Until != nil:
+		parsed, err := time.Parse(time.RFC3339, *req.Until)
+		if err != nil || !parsed.After(now) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until, must be a future RFC 3339 time"})
+			return
+		}
+		until = parsed
+	case req.Minutes < 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Snooze minutes must be positive"})
+		return
+	case req.Minutes > 0:
+		until = now.Add(time.Duration(req.Minutes) * time.Minute)
+	}
+
+	if err := h.reminders.Snooze(c.Param("id"), lead, until, h.scheduler.Defaults()); err != nil {
+		if errors.Is(err, store.ErrReminderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Todo has no reminder " + strconv.Itoa(lead) + " minutes before its due date"})
+			return
+		}
+		respondStoreError(c, err, "Todo not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"todo_id":       c.Param("id"),
+		"lead_minutes":  lead,
+		"snoozed_until": until,
+	})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/search"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// savedQuery builds the query a saved search runs. param supplies the
+// paging parameters, limit and cursor; nil leaves them unset.
+func savedQuery(s *models.SavedSearch, param func(string) string) (search.Query, error) {
+	if s.Query == "" && s.Filters == (models.SearchFilters{}) {
+		return search.Query{}, errors.New("A saved search needs a query or filters")
+	}
+	params := map[string]string{
+		"type":          s.Filters.Type,
+		"account_id":    s.Filters.AccountID,
+		"tag":           s.Filters.Tag,
+		"template_type": s.Filters.TemplateType,
+		"from":          s.Filters.From,
+		"to":            s.Filters.To,
+		"archived":      s.Filters.Archived,
+		"deleted":       s.Filters.Deleted,
+	}
+	if param != nil {
+		params["limit"] = param("limit")
+		params["cursor"] = param("cursor")
+	}
+	return searchQuery(s.Query, func(name string) string { return params[name] })
+}
+
+// countSaved sets the number of results a saved search has right now
+func (h *Handler) countSaved(s *models.SavedSearch) {
+	q, err := savedQuery(s, nil)
+	if err != nil {
+		return
+	}
+	q.Limit = 1
+	page, err := search.Search(h.db, q)
+	if err != nil {
+		log.Printf("Error counting saved search %s: %v", s.ID, err)
+		return
+	}
+	s.Count = &page.Total
+}
+
+// GetSavedSearches lists the saved searches by name, with their current
+// result counts
+func (h *Handler) GetSavedSearches(c *gin.Context) {
+	searches, err := h.searches.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("counts") != "false" {
+		for i := range searches {
+			h.countSaved(&searches[i])
+		}
+	}
+	c.JSON(http.StatusOK, searches)
+}
+
+func (h *Handler) GetSavedSearch(c *gin.Context) {
+	saved, err := h.searches.Get(c.Param("id"))
+	if err != nil {
+		respondStoreError(c, err, "Saved search not found")
+		return
+	}
+	h.countSaved(saved)
+	c.JSON(http.StatusOK, saved)
+}
+
+func (h *Handler) CreateSavedSearch(c *gin.Context) {
+	var req models.CreateSavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved := &models.SavedSearch{Name: req.Name, Query: req.Query, Filters: req.Filters}
+	if _, err := savedQuery(saved, nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.searches.Create(saved); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Saved search already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.countSaved(saved)
+	c.JSON(http.StatusCreated, saved)
+}
+
+func (h *Handler) UpdateSavedSearch(c *gin.Context) {
+	id := c.Param("id")
+	var req models.UpdateSavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := h.searches.Get(id)
+	if err != nil {
+		respondStoreError(c, err, "Saved search not found")
+		return
+	}
+	if req.Name != nil && *req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+		return
+	}
+	if req.Query != nil {
+		saved.Query = *req.Query
+	}
+	if req.Filters != nil {
+		saved.Filters = *req.Filters
+	}
+	if _, err := savedQuery(saved, nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.searches.Update(id, store.SavedSearchUpdate{Name: req.Name, Query: req.Query, Filters: req.Filters})
+	if err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Saved search already exists"})
+			return
+		}
+		respondStoreError(c, err, "Saved search not found")
+		return
+	}
+
+	h.GetSavedSearch(c)
+}
+
+func (h *Handler) DeleteSavedSearch(c *gin.Context) {
+	if err := h.searches.Delete(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Saved search not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted"})
+}
+
+// RunSavedSearch returns a page of a saved search's results, paged with
+// limit and cursor like Search
+func (h *Handler) RunSavedSearch(c *gin.Context) {
+	saved, err := h.searches.Get(c.Param("id"))
+	if err != nil {
+		respondStoreError(c, err, "Saved search not found")
+		return
+	}
+	q, err := savedQuery(saved, c.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.respondSearch(c, q, false)
+}
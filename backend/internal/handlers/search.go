@@ -1,150 +1,197 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/embed"
+	"github.com/factory-sagar/notes-droid/backend/internal/search"
 	"github.com/gin-gonic/gin"
 )
 
+// GetEmbeddingProvider returns the name of the provider notes are embedded
+// with for semantic search. Set EMBEDDING_PROVIDER to customize (default:
+// hashed, which runs locally)
+func GetEmbeddingProvider() string {
+	if name := os.Getenv("EMBEDDING_PROVIDER"); name != "" {
+		return name
+	}
+	return embed.DefaultProvider
+}
+
+// embeddingProvider creates the configured provider, falling back to the
+// default when it can't be
+func embeddingProvider() embed.Provider {
+	p, err := embed.New(GetEmbeddingProvider())
+	if err != nil {
+		log.Printf("Error creating embedding provider: %v, using %s", err, embed.DefaultProvider)
+		p, _ = embed.New(embed.DefaultProvider)
+	}
+	return p
+}
+
+// Search ranks notes, accounts, todos, contacts and attachments against q.
+// The body is the page of results; the total is in X-Total-Count and the
+// cursor for the next page, when there is one, in X-Next-Cursor. With
+// mode=semantic it ranks notes by similarity to q instead.
 func (h *Handler) Search(c *gin.Context) {
-	q := c.Query("q")
-	if q == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
+	q, err := searchQuery(c.Query("q"), c.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	switch c.Query("mode") {
+	case "", "keyword":
+		h.respondSearch(c, q, false)
+	case "semantic":
+		if len(q.Terms) == 0 && len(q.Phrases) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Semantic search needs words to search for in 'q'"})
+			return
+		}
+		h.respondSearch(c, q, true)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode, must be 'keyword' or 'semantic'"})
+	}
+}
+
+// respondSearch runs q and writes the page of results
+func (h *Handler) respondSearch(c *gin.Context, q search.Query, semantic bool) {
+	var page *search.Page
+	var err error
+	if semantic {
+		page, err = search.Semantic(h.db, h.embedder, q)
+	} else {
+		page, err = search.Search(h.db, q)
+	}
+	if errors.Is(err, search.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	c.JSON(http.StatusOK, page.Results)
+}
+
+// searchQuery parses text and applies the filter parameters, as read by
+// param, on top of it. Errors describe the invalid query or parameter for
+// the client.
+func searchQuery(text string, param func(string) string) (search.Query, error) {
+	q, err := search.Parse(text)
+	if err != nil {
+		return q, errors.New("Invalid query: " + err.Error())
+	}
+	q.Cursor = param("cursor")
+	if v := param("account_id"); v != "" {
+		q.AccountID = v
+	}
+	if v := param("tag"); v != "" {
+		q.Tags = append(q.Tags, v)
+	}
+	if v := param("template_type"); v != "" {
+		q.TemplateType = v
+	}
 
-	results := []models.SearchResult{}
-	seen := make(map[string]bool) // Prevent duplicates
-
-	// Create fuzzy search term for FTS (prefix matching)
-	ftsQuery := q + "*"
-	likeQuery := "%" + q + "%"
-
-	// Search notes using FTS4 with prefix matching
-	noteRows, err := h.db.Query(`
-		SELECT n.id, n.title, n.account_id, COALESCE(a.name, '') as account_name,
-		       snippet(notes_fts, '<mark>', '</mark>', '...', -1, 64) as snippet
-		FROM notes_fts
-		JOIN notes n ON notes_fts.docid = n.rowid
-		LEFT JOIN accounts a ON n.account_id = a.id
-		WHERE notes_fts MATCH ?
-		LIMIT 20
-	`, ftsQuery)
-	if err == nil {
-		defer noteRows.Close()
-		for noteRows.Next() {
-			var id, title, accountID, accountName, snippet string
-			noteRows.Scan(&id, &title, &accountID, &accountName, &snippet)
-			if !seen["note:"+id] {
-				seen["note:"+id] = true
-				results = append(results, models.SearchResult{
-					Type:      "note",
-					ID:        id,
-					Title:     title,
-					Snippet:   snippet,
-					AccountID: accountID,
-				})
+	if types := param("type"); types != "" {
+		q.Types = nil
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			if !contains(search.Types, t) {
+				return q, errors.New("Invalid type, must be one of: " + strings.Join(search.Types, ", "))
 			}
+			q.Types = append(q.Types, t)
 		}
 	}
 
-	// Also search notes by participants (not in FTS)
-	participantRows, _ := h.db.Query(`
-		SELECT n.id, n.title, n.account_id, COALESCE(a.name, '') as account_name
-		FROM notes n
-		LEFT JOIN accounts a ON n.account_id = a.id
-		WHERE n.internal_participants LIKE ? OR n.external_participants LIKE ?
-		LIMIT 10
-	`, likeQuery, likeQuery)
-	if participantRows != nil {
-		defer participantRows.Close()
-		for participantRows.Next() {
-			var id, title, accountID, accountName string
-			participantRows.Scan(&id, &title, &accountID, &accountName)
-			if !seen["note:"+id] {
-				seen["note:"+id] = true
-				results = append(results, models.SearchResult{
-					Type:      "note",
-					ID:        id,
-					Title:     title,
-					Snippet:   "Match in participants",
-					AccountID: accountID,
-				})
-			}
+	var ok bool
+	if v := param("archived"); v != "" {
+		if q.Archived, ok = search.ParseVisibility(v); !ok {
+			return q, errors.New("Invalid archived, must be 'exclude', 'include' or 'only'")
+		}
+	}
+	if v := param("deleted"); v != "" {
+		if q.Deleted, ok = search.ParseVisibility(v); !ok {
+			return q, errors.New("Invalid deleted, must be 'exclude', 'include' or 'only'")
 		}
 	}
 
-	// Search accounts by name and owner
-	accountRows, err := h.db.Query(`
-		SELECT id, name, account_owner FROM accounts 
-		WHERE name LIKE ? OR account_owner LIKE ? 
-		LIMIT 10
-	`, likeQuery, likeQuery)
-	if err == nil {
-		defer accountRows.Close()
-		for accountRows.Next() {
-			var id, name string
-			var owner sql.NullString
-			accountRows.Scan(&id, &name, &owner)
-			if !seen["account:"+id] {
-				seen["account:"+id] = true
-				snippet := ""
-				if owner.Valid && owner.String != "" && strings.Contains(strings.ToLower(owner.String), strings.ToLower(q)) {
-					snippet = "Owner: " + owner.String
-				}
-				results = append(results, models.SearchResult{
-					Type:    "account",
-					ID:      id,
-					Title:   name,
-					Snippet: snippet,
-				})
+	for name, dest := range map[string]**time.Time{"from": &q.From, "to": &q.To} {
+		if v := param(name); v != "" {
+			t, err := search.ParseDate(v)
+			if err != nil {
+				return q, errors.New("Invalid " + name + " date, use YYYY-MM-DD, RFC3339 or a relative date like -30d")
 			}
+			*dest = &t
 		}
 	}
 
-	// Search todos by title and description
-	todoRows, err := h.db.Query(`
-		SELECT t.id, t.title, t.description, t.account_id, COALESCE(a.name, '') as account_name
-		FROM todos t
-		LEFT JOIN accounts a ON t.account_id = a.id
-		WHERE t.title LIKE ? OR t.description LIKE ?
-		LIMIT 10
-	`, likeQuery, likeQuery)
-	if err == nil {
-		defer todoRows.Close()
-		for todoRows.Next() {
-			var id, title, description string
-			var accountID sql.NullString
-			var accountName string
-			todoRows.Scan(&id, &title, &description, &accountID, &accountName)
-			if !seen["todo:"+id] {
-				seen["todo:"+id] = true
-				snippet := ""
-				if description != "" && strings.Contains(strings.ToLower(description), strings.ToLower(q)) {
-					if len(description) > 100 {
-						snippet = description[:100] + "..."
-					} else {
-						snippet = description
-					}
-				}
-				if accountName != "" {
-					if snippet != "" {
-						snippet += " | "
-					}
-					snippet += "Account: " + accountName
-				}
-				results = append(results, models.SearchResult{
-					Type:    "todo",
-					ID:      id,
-					Title:   title,
-					Snippet: snippet,
-				})
-			}
+	if v := param("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return q, errors.New("Invalid limit")
+		}
+		q.Limit = limit
+	}
+
+	if !q.HasCriteria() {
+		if len(q.Excluded) > 0 {
+			return q, errors.New("Invalid query: nothing to search for besides excluded words")
+		}
+		return q, errors.New("Query parameter 'q' is required")
+	}
+	return q, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
 		}
 	}
+	return false
+}
 
+// SimilarNotes lists the notes closest in topic to a note
+func (h *Handler) SimilarNotes(c *gin.Context) {
+	limit := 10
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(n, search.MaxLimit)
+	}
+
+	results, err := search.Similar(h.db, h.embedder, c.Param("id"), limit)
+	if errors.Is(err, search.ErrNoteNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, results)
 }
+
+// ReindexSearch rebuilds the search index from scratch
+func (h *Handler) ReindexSearch(c *gin.Context) {
+	counts, err := search.Reindex(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Search index rebuilt", "indexed": counts})
+}
@@ -1,26 +1,37 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+
 	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
+// respondTagError maps an error from creating, renaming or merging tags to
+// a response
+func respondTagError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, store.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+	case errors.Is(err, store.ErrInvalidTagName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag name, levels separated by / cannot be empty"})
+	case errors.Is(err, store.ErrTagCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A tag cannot be moved into itself or its children"})
+	default:
+		respondStoreError(c, err, "Tag not found")
+	}
+}
+
+// GetTags lists every tag by name, with the number of notes, todos and
+// accounts carrying each
 func (h *Handler) GetTags(c *gin.Context) {
-	rows, err := h.db.Query("SELECT id, name, color, created_at FROM tags ORDER BY name")
+	tags, err := h.tags.List()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	tags := []models.Tag{}
-	for rows.Next() {
-		var tag models.Tag
-		rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt)
-		tags = append(tags, tag)
-	}
 
 	c.JSON(http.StatusOK, tags)
 }
@@ -32,22 +43,17 @@ func (h *Handler) CreateTag(c *gin.Context) {
 		return
 	}
 
-	id := uuid.New().String()
 	color := req.Color
 	if color == "" {
 		color = "#6b7280"
 	}
 
-	_, err := h.db.Exec(
-		"INSERT INTO tags (id, name, color) VALUES (?, ?, ?)",
-		id, req.Name, color,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	tag := &models.Tag{Name: req.Name, Color: color}
+	if err := h.tags.Create(tag); err != nil {
+		respondTagError(c, err)
 		return
 	}
 
-	tag := models.Tag{ID: id, Name: req.Name, Color: color}
 	c.JSON(http.StatusCreated, tag)
 }
 
@@ -59,24 +65,45 @@ func (h *Handler) UpdateTag(c *gin.Context) {
 		return
 	}
 
-	if req.Name != nil {
-		h.db.Exec("UPDATE tags SET name = ? WHERE id = ?", *req.Name, id)
+	if err := h.tags.Update(id, store.TagUpdate{Name: req.Name, Color: req.Color}); err != nil {
+		respondTagError(c, err)
+		return
 	}
-	if req.Color != nil {
-		h.db.Exec("UPDATE tags SET color = ? WHERE id = ?", *req.Color, id)
+
+	tag, err := h.tags.Get(id)
+	if err != nil {
+		respondStoreError(c, err, "Tag not found")
+		return
 	}
 
-	var tag models.Tag
-	h.db.QueryRow("SELECT id, name, color, created_at FROM tags WHERE id = ?", id).
-		Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt)
+	c.JSON(http.StatusOK, tag)
+}
 
+// MergeTag moves a tag's notes, todos, accounts and children onto another
+// tag and deletes it
+func (h *Handler) MergeTag(c *gin.Context) {
+	var req models.MergeTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.tags.Merge(c.Param("id"), req.Into); err != nil {
+		respondTagError(c, err)
+		return
+	}
+
+	tag, err := h.tags.Get(req.Into)
+	if err != nil {
+		respondStoreError(c, err, "Tag not found")
+		return
+	}
 	c.JSON(http.StatusOK, tag)
 }
 
+// DeleteTag deletes a tag and its children
 func (h *Handler) DeleteTag(c *gin.Context) {
-	id := c.Param("id")
-	_, err := h.db.Exec("DELETE FROM tags WHERE id = ?", id)
-	if err != nil {
+	if err := h.tags.Delete(c.Param("id")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -84,11 +111,7 @@ func (h *Handler) DeleteTag(c *gin.Context) {
 }
 
 func (h *Handler) AddTagToNote(c *gin.Context) {
-	noteID := c.Param("id")
-	tagID := c.Param("tagId")
-
-	_, err := h.db.Exec("INSERT OR IGNORE INTO note_tags (note_id, tag_id) VALUES (?, ?)", noteID, tagID)
-	if err != nil {
+	if err := h.tags.AddToNote(c.Param("id"), c.Param("tagId")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -96,11 +119,7 @@ func (h *Handler) AddTagToNote(c *gin.Context) {
 }
 
 func (h *Handler) RemoveTagFromNote(c *gin.Context) {
-	noteID := c.Param("id")
-	tagID := c.Param("tagId")
-
-	_, err := h.db.Exec("DELETE FROM note_tags WHERE note_id = ? AND tag_id = ?", noteID, tagID)
-	if err != nil {
+	if err := h.tags.RemoveFromNote(c.Param("id"), c.Param("tagId")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -108,26 +127,62 @@ func (h *Handler) RemoveTagFromNote(c *gin.Context) {
 }
 
 func (h *Handler) GetNoteTags(c *gin.Context) {
-	noteID := c.Param("id")
-
-	rows, err := h.db.Query(`
-		SELECT t.id, t.name, t.color, t.created_at
-		FROM tags t
-		JOIN note_tags nt ON t.id = nt.tag_id
-		WHERE nt.note_id = ?
-		ORDER BY t.name
-	`, noteID)
+	tags, err := h.tags.ListForNote(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	tags := []models.Tag{}
-	for rows.Next() {
-		var tag models.Tag
-		rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt)
-		tags = append(tags, tag)
+	c.JSON(http.StatusOK, tags)
+}
+
+func (h *Handler) AddTagToTodo(c *gin.Context) {
+	if err := h.tags.AddToTodo(c.Param("id"), c.Param("tagId")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tag added to todo"})
+}
+
+func (h *Handler) RemoveTagFromTodo(c *gin.Context) {
+	if err := h.tags.RemoveFromTodo(c.Param("id"), c.Param("tagId")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tag removed from todo"})
+}
+
+func (h *Handler) GetTodoTags(c *gin.Context) {
+	tags, err := h.tags.ListForTodo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+func (h *Handler) AddTagToAccount(c *gin.Context) {
+	if err := h.tags.AddToAccount(c.Param("id"), c.Param("tagId")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tag added to account"})
+}
+
+func (h *Handler) RemoveTagFromAccount(c *gin.Context) {
+	if err := h.tags.RemoveFromAccount(c.Param("id"), c.Param("tagId")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tag removed from account"})
+}
+
+func (h *Handler) GetAccountTags(c *gin.Context) {
+	tags, err := h.tags.ListForAccount(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, tags)
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// maxEntryMinutes caps a time entry entered by hand at a day
+const maxEntryMinutes = 24 * 60
+
+// checkEstimate rejects negative effort estimates
+func checkEstimate(estimate *int) error {
+	if estimate != nil && *estimate < 0 {
+		return errors.New("Estimate minutes cannot be negative")
+	}
+	return nil
+}
+
+// StartTodoTimer starts timing work on a todo
+func (h *Handler) StartTodoTimer(c *gin.Context) {
+	entry, err := h.timesheet.Start(c.Param("id"), time.Now())
+	if err != nil {
+		if errors.Is(err, store.ErrTimerRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A timer is already running for this todo"})
+			return
+		}
+		respondStoreError(c, err, "Todo not found")
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// StopTodoTimer stops a todo's timer and returns the time entry it made
+func (h *Handler) StopTodoTimer(c *gin.Context) {
+	entry, err := h.timesheet.Stop(c.Param("id"), time.Now())
+	if err != nil {
+		if errors.Is(err, store.ErrTimerStopped) {
+			c.JSON(http.StatusConflict, gin.H{"error": "No timer is running for this todo"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// GetTodoTimeEntries lists the time logged on a todo, latest first
+func (h *Handler) GetTodoTimeEntries(c *gin.Context) {
+	if _, err := h.todos.Get(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Todo not found")
+		return
+	}
+	entries, err := h.timesheet.ListForTodo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// CreateTimeEntry logs time spent on a todo by hand
+func (h *Handler) CreateTimeEntry(c *gin.Context) {
+	var req models.CreateTimeEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Minutes <= 0 || req.Minutes > maxEntryMinutes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minutes must be between 1 and 1440"})
+		return
+	}
+
+	entry := &models.TimeEntry{
+		TodoID:    c.Param("id"),
+		StartedAt: time.Now().Add(-time.Duration(req.Minutes) * time.Minute),
+		Minutes:   req.Minutes,
+		Note:      req.Note,
+	}
+	if req.StartedAt != nil {
+		parsed, err := time.Parse(time.RFC3339, *req.StartedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid started_at format"})
+			return
+		}
+		entry.StartedAt = parsed
+	}
+
+	if err := h.timesheet.Add(entry); err != nil {
+		respondStoreError(c, err, "Todo not found")
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// DeleteTimeEntry removes a time entry, including a running timer
+func (h *Handler) DeleteTimeEntry(c *gin.Context) {
+	if err := h.timesheet.Delete(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Time entry not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Time entry deleted"})
+}
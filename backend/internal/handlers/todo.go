@@ -1,170 +1,143 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/recur"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
-func (h *Handler) GetTodos(c *gin.Context) {
-	status := c.Query("status")
-	query := `
-		SELECT t.id, t.title, t.description, t.status, t.priority, t.due_date, t.account_id, 
-		       COALESCE(a.name, '') as account_name, t.created_at, t.updated_at
-		FROM todos t
-		LEFT JOIN accounts a ON t.account_id = a.id
-		WHERE t.deleted_at IS NULL
-	`
-	args := []interface{}{}
-
-	if status != "" {
-		query += " AND t.status = ?"
-		args = append(args, status)
-	}
-	query += " ORDER BY t.created_at DESC"
-
-	rows, err := h.db.Query(query, args...)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// todoResponse is the API representation of a todo with its linked notes,
+// blockers and subtasks
+func todoResponse(t models.Todo) gin.H {
+	linkedNotes := []map[string]string{}
+	for _, n := range t.Notes {
+		linkedNotes = append(linkedNotes, map[string]string{"id": n.ID, "title": n.Title})
+	}
+	blockedBy := t.BlockedBy
+	if blockedBy == nil {
+		blockedBy = []models.TodoRef{}
+	}
+
+	todo := gin.H{
+		"id":               t.ID,
+		"title":            t.Title,
+		"description":      t.Description,
+		"status":           t.Status,
+		"priority":         t.Priority,
+		"due_date":         t.DueDate,
+		"account_id":       t.AccountID,
+		"account_name":     t.AccountName,
+		"version":          t.Version,
+		"created_at":       t.CreatedAt,
+		"updated_at":       t.UpdatedAt,
+		"linked_notes":     linkedNotes,
+		"parent_id":        t.ParentID,
+		"blocked":          t.Blocked,
+		"blocked_by":       blockedBy,
+		"reminder_minutes": t.Reminders,
+		"estimate_minutes": t.Estimate,
+		"tracked_minutes":  t.Tracked,
+		"timer_started_at": t.Timer,
+	}
+	if t.Progress != nil {
+		todo["progress"] = t.Progress
+	}
+	if t.Subtasks != nil {
+		todo["subtasks"] = t.Subtasks
+	}
+	addRecurrence(todo, t)
+	return todo
+}
+
+// addRecurrence adds the series fields of a todo that recurs or once did
+func addRecurrence(todo gin.H, t models.Todo) {
+	if t.SeriesID == "" {
 		return
 	}
-	defer rows.Close()
-
-	todos := []map[string]interface{}{}
-	var todoIDs []interface{}
-
-	for rows.Next() {
-		var t models.Todo
-		var accountName string
-		if err := rows.Scan(&t.ID, &t.Title, &t.Description, &t.Status, &t.Priority, &t.DueDate, &t.AccountID, &accountName, &t.CreatedAt, &t.UpdatedAt); err != nil {
-			continue
-		}
+	todo["recurrence"] = t.Recurrence
+	todo["series_id"] = t.SeriesID
+	todo["occurrence"] = t.Occurrence
+}
 
-		todos = append(todos, map[string]interface{}{
-			"id":           t.ID,
-			"title":        t.Title,
-			"description":  t.Description,
-			"status":       t.Status,
-			"priority":     t.Priority,
-			"due_date":     t.DueDate,
-			"account_id":   t.AccountID,
-			"account_name": accountName,
-			"created_at":   t.CreatedAt,
-			"updated_at":   t.UpdatedAt,
-			"linked_notes": []map[string]string{},
-		})
-		todoIDs = append(todoIDs, t.ID)
+// parseRecurrence checks a recurrence rule and returns it in canonical
+// form. An empty rule stays empty.
+func parseRecurrence(s string) (string, error) {
+	if strings.TrimSpace(s) == "" {
+		return "", nil
 	}
+	rule, err := recur.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("Invalid recurrence: %v", err)
+	}
+	return rule.String(), nil
+}
 
-	if len(todos) == 0 {
-		c.JSON(http.StatusOK, todos)
+// GetTodos lists todos not in the trash, filtered by status, account_id,
+// priority, pinned, parent_id, top_level, tags and a due_after/due_before
+// range
+func (h *Handler) GetTodos(c *gin.Context) {
+	o, err := listOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Batch fetch linked notes
-	placeholders := strings.Repeat("?,", len(todoIDs))
-	placeholders = placeholders[:len(placeholders)-1]
-
-	// Correctly cast todoIDs to []interface{} for Query
-	queryArgs := make([]interface{}, len(todoIDs))
-	for i, v := range todoIDs {
-		queryArgs[i] = v
+	f := store.TodoFilter{
+		Status:    c.Query("status"),
+		AccountID: c.Query("account_id"),
+		Priority:  c.Query("priority"),
+		ParentID:  c.Query("parent_id"),
 	}
-
-	notesQuery := `
-		SELECT nt.todo_id, n.id, n.title
-		FROM note_todos nt
-		JOIN notes n ON nt.note_id = n.id
-		WHERE nt.todo_id IN (` + placeholders + `)
-	`
-
-	noteRows, err := h.db.Query(notesQuery, queryArgs...)
+	if f.Pinned, err = boolParam(c, "pinned"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	topLevel, err := boolParam(c, "top_level")
 	if err != nil {
-		// Return todos even if linked notes fail
-		c.JSON(http.StatusOK, todos)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer noteRows.Close()
-
-	linkedNotesMap := make(map[string][]map[string]string)
-	for noteRows.Next() {
-		var todoID, noteID, noteTitle string
-		if err := noteRows.Scan(&todoID, &noteID, &noteTitle); err != nil {
-			continue
-		}
-		if _, ok := linkedNotesMap[todoID]; !ok {
-			linkedNotesMap[todoID] = []map[string]string{}
+	f.TopLevel = topLevel != nil && *topLevel
+	if f.Tags, f.MatchAny, err = tagsParam(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for name, dest := range map[string]**time.Time{"due_after": &f.DueAfter, "due_before": &f.DueBefore} {
+		if *dest, err = dateParam(c, name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		linkedNotesMap[todoID] = append(linkedNotesMap[todoID], map[string]string{"id": noteID, "title": noteTitle})
 	}
 
-	// Attach linked notes to todos
-	for i, todo := range todos {
-		id := todo["id"].(string)
-		if notes, ok := linkedNotesMap[id]; ok {
-			todos[i]["linked_notes"] = notes
-		}
+	list, info, err := h.todos.List(f, o)
+	if err != nil {
+		respondListError(c, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, todos)
+	todos := []gin.H{}
+	for _, t := range list {
+		todos = append(todos, todoResponse(t))
+	}
+
+	respondList(c, todos, info)
 }
 
 func (h *Handler) GetTodo(c *gin.Context) {
-	id := c.Param("id")
-	var t models.Todo
-	var accountName string
-	err := h.db.QueryRow(`
-		SELECT t.id, t.title, t.description, t.status, t.priority, t.due_date, t.account_id,
-		       COALESCE(a.name, '') as account_name, t.created_at, t.updated_at
-		FROM todos t
-		LEFT JOIN accounts a ON t.account_id = a.id
-		WHERE t.id = ?
-	`, id).Scan(&t.ID, &t.Title, &t.Description, &t.Status, &t.Priority, &t.DueDate, &t.AccountID, &accountName, &t.CreatedAt, &t.UpdatedAt)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
-		return
-	}
+	t, err := h.todos.Get(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStoreError(c, err, "Todo not found")
 		return
 	}
 
-	// Get linked notes
-	noteRows, _ := h.db.Query(`
-		SELECT n.id, n.title FROM notes n
-		JOIN note_todos nt ON n.id = nt.note_id
-		WHERE nt.todo_id = ?
-	`, id)
-
-	linkedNotes := []map[string]string{}
-	if noteRows != nil {
-		defer noteRows.Close()
-		for noteRows.Next() {
-			var noteID, noteTitle string
-			noteRows.Scan(&noteID, &noteTitle)
-			linkedNotes = append(linkedNotes, map[string]string{"id": noteID, "title": noteTitle})
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"id":           t.ID,
-		"title":        t.Title,
-		"description":  t.Description,
-		"status":       t.Status,
-		"priority":     t.Priority,
-		"due_date":     t.DueDate,
-		"account_id":   t.AccountID,
-		"account_name": accountName,
-		"created_at":   t.CreatedAt,
-		"updated_at":   t.UpdatedAt,
-		"linked_notes": linkedNotes,
-	})
+	setETag(c, t.Version)
+	c.JSON(http.StatusOK, todoResponse(*t))
 }
 
 func (h *Handler) CreateTodo(c *gin.Context) {
@@ -179,12 +152,6 @@ func (h *Handler) CreateTodo(c *gin.Context) {
 		return
 	}
 
-	id := uuid.New().String()
-	now := time.Now()
-
-	if req.Status == "" {
-		req.Status = "not_started"
-	}
 	if req.Priority == "" {
 		req.Priority = "medium"
 	}
@@ -199,39 +166,62 @@ func (h *Handler) CreateTodo(c *gin.Context) {
 		dueDate = &parsed
 	}
 
-	_, err := h.db.Exec(`
-		INSERT INTO todos (id, title, description, status, priority, due_date, account_id, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, id, req.Title, req.Description, req.Status, req.Priority, dueDate, req.AccountID, now, now)
-
+	recurrence, err := parseRecurrence(req.Recurrence)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Link to note if provided
-	if req.NoteID != nil {
-		h.db.Exec("INSERT INTO note_todos (note_id, todo_id) VALUES (?, ?)", *req.NoteID, id)
+	reminders, err := reminderMinutes(req.Reminders)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkEstimate(req.Estimate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Get account name if account_id was provided
-	var accountName string
-	if req.AccountID != nil {
-		h.db.QueryRow("SELECT name FROM accounts WHERE id = ?", *req.AccountID).Scan(&accountName)
+	t := &models.Todo{
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      req.Status,
+		Priority:    req.Priority,
+		DueDate:     dueDate,
+		AccountID:   req.AccountID,
+		Recurrence:  recurrence,
+		ParentID:    req.ParentID,
+		Reminders:   reminders,
+		Estimate:    req.Estimate,
+	}
+	if err := h.todos.Create(t, req.NoteID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrParentNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent todo not found"})
+		case errors.Is(err, store.ErrUnknownStatus):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown status"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"id":           id,
-		"title":        req.Title,
-		"description":  req.Description,
-		"status":       req.Status,
-		"priority":     req.Priority,
-		"due_date":     dueDate,
-		"account_id":   req.AccountID,
-		"account_name": accountName,
-		"created_at":   now,
-		"updated_at":   now,
-	})
+	todo := gin.H{
+		"id":               t.ID,
+		"title":            t.Title,
+		"description":      t.Description,
+		"status":           t.Status,
+		"priority":         t.Priority,
+		"due_date":         t.DueDate,
+		"account_id":       t.AccountID,
+		"account_name":     t.AccountName,
+		"parent_id":        t.ParentID,
+		"reminder_minutes": t.Reminders,
+		"estimate_minutes": t.Estimate,
+		"created_at":       t.CreatedAt,
+		"updated_at":       t.UpdatedAt,
+	}
+	addRecurrence(todo, *t)
+	c.JSON(http.StatusCreated, todo)
 }
 
 func (h *Handler) UpdateTodo(c *gin.Context) {
@@ -242,24 +232,19 @@ func (h *Handler) UpdateTodo(c *gin.Context) {
 		return
 	}
 
-	updates := []string{}
-	args := []interface{}{}
-
-	if req.Title != nil {
-		updates = append(updates, "title = ?")
-		args = append(args, *req.Title)
-	}
-	if req.Description != nil {
-		updates = append(updates, "description = ?")
-		args = append(args, *req.Description)
+	update := store.TodoUpdate{
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      req.Status,
+		Priority:    req.Priority,
+		AccountID:   req.AccountID,
+		Pinned:      req.Pinned,
+		ParentID:    req.ParentID,
+		Estimate:    req.Estimate,
 	}
-	if req.Status != nil {
-		updates = append(updates, "status = ?")
-		args = append(args, *req.Status)
-	}
-	if req.Priority != nil {
-		updates = append(updates, "priority = ?")
-		args = append(args, *req.Priority)
+	if err := checkEstimate(req.Estimate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 	if req.DueDate != nil {
 		parsed, err := time.Parse(time.RFC3339, *req.DueDate)
@@ -267,51 +252,88 @@ func (h *Handler) UpdateTodo(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due date format"})
 			return
 		}
-		updates = append(updates, "due_date = ?")
-		args = append(args, parsed)
+		update.DueDate = &parsed
 	}
-	if req.AccountID != nil {
-		updates = append(updates, "account_id = ?")
-		args = append(args, *req.AccountID)
+	if req.Recurrence != nil {
+		recurrence, err := parseRecurrence(*req.Recurrence)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		update.Recurrence = &recurrence
 	}
 
-	if len(updates) == 0 {
+	if update.IsEmpty() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
 		return
 	}
 
-	updates = append(updates, "updated_at = ?")
-	args = append(args, time.Now())
-	args = append(args, id)
+	expected, ok := expectedVersion(c, req.Version)
+	if !ok {
+		return
+	}
+	update.ExpectedVersion = expected
+
+	if err := h.todos.Update(id, update); err != nil {
+		var transitionErr *store.TransitionError
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			h.respondTodoConflict(c, id)
+		case errors.Is(err, store.ErrParentNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent todo not found"})
+		case errors.Is(err, store.ErrTodoCycle):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A todo cannot be moved under itself or its subtasks"})
+		case errors.Is(err, store.ErrUnknownStatus):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown status"})
+		case errors.As(err, &transitionErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Status cannot change from %s to %s", transitionErr.From, transitionErr.To)})
+		default:
+			respondStoreError(c, err, "Todo not found")
+		}
+		return
+	}
+	h.GetTodo(c)
+}
 
-	query := "UPDATE todos SET " + strings.Join(updates, ", ") + " WHERE id = ?"
-	result, err := h.db.Exec(query, args...)
+// GetTodoCompletions lists the completed occurrences of the series a
+// recurring todo belongs to, latest first
+func (h *Handler) GetTodoCompletions(c *gin.Context) {
+	t, err := h.todos.Get(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStoreError(c, err, "Todo not found")
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+	if t.SeriesID == "" {
+		c.JSON(http.StatusOK, []models.TodoCompletion{})
 		return
 	}
 
-	h.GetTodo(c)
+	completions, err := h.todos.Completions(t.SeriesID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, completions)
 }
 
-func (h *Handler) DeleteTodo(c *gin.Context) {
-	id := c.Param("id")
-	// Soft delete - set deleted_at timestamp
-	result, err := h.db.Exec("UPDATE todos SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL", id)
+// respondTodoConflict returns 409 with the server's copy of the todo
+func (h *Handler) respondTodoConflict(c *gin.Context, id string) {
+	t, err := h.todos.Get(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStoreError(c, err, "Todo not found")
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+	setETag(c, t.Version)
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "Todo was modified by someone else",
+		"current": todoResponse(*t),
+	})
+}
+
+func (h *Handler) DeleteTodo(c *gin.Context) {
+	if err := h.todos.Delete(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Todo not found")
 		return
 	}
 
@@ -319,74 +341,46 @@ func (h *Handler) DeleteTodo(c *gin.Context) {
 }
 
 func (h *Handler) RestoreTodo(c *gin.Context) {
-	id := c.Param("id")
-	result, err := h.db.Exec("UPDATE todos SET deleted_at = NULL WHERE id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+	if err := h.todos.Restore(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Todo not found")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Todo restored"})
 }
 
 func (h *Handler) PermanentDeleteTodo(c *gin.Context) {
-	id := c.Param("id")
-	result, err := h.db.Exec("DELETE FROM todos WHERE id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+	if err := h.todos.PermanentDelete(c.Param("id")); err != nil {
+		respondStoreError(c, err, "Todo not found")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Todo permanently deleted"})
 }
 
 func (h *Handler) GetDeletedTodos(c *gin.Context) {
-	rows, err := h.db.Query(`
-		SELECT t.id, t.title, t.description, t.status, t.priority, t.due_date, 
-			   t.account_id, COALESCE(a.name, '') as account_name, t.created_at, t.updated_at, t.deleted_at
-		FROM todos t
-		LEFT JOIN accounts a ON t.account_id = a.id
-		WHERE t.deleted_at IS NOT NULL
-		ORDER BY t.deleted_at DESC
-	`)
+	list, err := h.todos.ListDeleted()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	todos := []map[string]interface{}{}
-	for rows.Next() {
-		var id, title, description, status, priority, accountName string
-		var dueDate, accountID, deletedAt sql.NullString
-		var createdAt, updatedAt time.Time
-		if err := rows.Scan(&id, &title, &description, &status, &priority, &dueDate, &accountID, &accountName, &createdAt, &updatedAt, &deletedAt); err != nil {
-			continue
-		}
-		todo := map[string]interface{}{
-			"id":           id,
-			"title":        title,
-			"description":  description,
-			"status":       status,
-			"priority":     priority,
-			"account_name": accountName,
-			"created_at":   createdAt,
-			"updated_at":   updatedAt,
-			"deleted_at":   deletedAt.String,
+	todos := []gin.H{}
+	for _, t := range list {
+		todo := gin.H{
+			"id":           t.ID,
+			"title":        t.Title,
+			"description":  t.Description,
+			"status":       t.Status,
+			"priority":     t.Priority,
+			"account_name": t.AccountName,
+			"created_at":   t.CreatedAt,
+			"updated_at":   t.UpdatedAt,
+			"deleted_at":   t.DeletedAt,
 		}
-		if dueDate.Valid {
-			todo["due_date"] = dueDate.String
+		if t.DueDate != nil {
+			todo["due_date"] = t.DueDate
 		}
-		if accountID.Valid {
-			todo["account_id"] = accountID.String
+		if t.AccountID != nil {
+			todo["account_id"] = *t.AccountID
 		}
 		todos = append(todos, todo)
 	}
@@ -394,11 +388,7 @@ func (h *Handler) GetDeletedTodos(c *gin.Context) {
 }
 
 func (h *Handler) LinkTodoToNote(c *gin.Context) {
-	todoID := c.Param("id")
-	noteID := c.Param("noteId")
-
-	_, err := h.db.Exec("INSERT OR IGNORE INTO note_todos (note_id, todo_id) VALUES (?, ?)", noteID, todoID)
-	if err != nil {
+	if err := h.todos.LinkNote(c.Param("id"), c.Param("noteId")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -407,11 +397,7 @@ func (h *Handler) LinkTodoToNote(c *gin.Context) {
 }
 
 func (h *Handler) UnlinkTodoFromNote(c *gin.Context) {
-	todoID := c.Param("id")
-	noteID := c.Param("noteId")
-
-	_, err := h.db.Exec("DELETE FROM note_todos WHERE note_id = ? AND todo_id = ?", noteID, todoID)
-	if err != nil {
+	if err := h.todos.UnlinkNote(c.Param("id"), c.Param("noteId")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -419,34 +405,47 @@ func (h *Handler) UnlinkTodoFromNote(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Todo unlinked from note"})
 }
 
-func (h *Handler) ToggleTodoPin(c *gin.Context) {
-	id := c.Param("id")
-
-	var pinned int
-	err := h.db.QueryRow("SELECT pinned FROM todos WHERE id = ?", id).Scan(&pinned)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+// AddTodoBlocker marks a todo as blocked until another one is completed
+func (h *Handler) AddTodoBlocker(c *gin.Context) {
+	err := h.todos.AddBlocker(c.Param("id"), c.Param("blockerId"))
+	if errors.Is(err, store.ErrTodoCycle) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A todo cannot be blocked by itself or by a todo it blocks"})
 		return
 	}
+	if err != nil {
+		respondStoreError(c, err, "Todo not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Blocker added"})
+}
 
-	newPinned := 1
-	if pinned == 1 {
-		newPinned = 0
+func (h *Handler) RemoveTodoBlocker(c *gin.Context) {
+	if err := h.todos.RemoveBlocker(c.Param("id"), c.Param("blockerId")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	h.db.Exec("UPDATE todos SET pinned = ?, updated_at = ? WHERE id = ?", newPinned, time.Now(), id)
+	c.JSON(http.StatusOK, gin.H{"message": "Blocker removed"})
+}
+
+func (h *Handler) ToggleTodoPin(c *gin.Context) {
+	pinned, err := h.todos.TogglePin(c.Param("id"))
+	if err != nil {
+		respondStoreError(c, err, "Todo not found")
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"pinned": newPinned == 1})
+	c.JSON(http.StatusOK, gin.H{"pinned": pinned})
 }
 
 // EmptyTodosTrash permanently deletes all soft-deleted todos
 func (h *Handler) EmptyTodosTrash(c *gin.Context) {
-	result, err := h.db.Exec(`DELETE FROM todos WHERE deleted_at IS NOT NULL`)
+	count, err := h.todos.EmptyTrash()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	rows, _ := result.RowsAffected()
-	c.JSON(http.StatusOK, gin.H{"message": "Trash emptied", "count": rows})
+	c.JSON(http.StatusOK, gin.H{"message": "Trash emptied", "count": count})
 }
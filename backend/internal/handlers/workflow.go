@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// GetWorkflow returns the todo statuses in board order and the transitions
+// allowed between them
+func (h *Handler) GetWorkflow(c *gin.Context) {
+	w, err := h.workflow.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+// UpdateWorkflow replaces the workflow. Leaving out transitions allows
+// every move between the statuses.
+func (h *Handler) UpdateWorkflow(c *gin.Context) {
+	var w models.Workflow
+	if err := c.ShouldBindJSON(&w); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.workflow.Replace(w); err != nil {
+		var workflowErr *store.WorkflowError
+		if errors.As(err, &workflowErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.GetWorkflow(c)
+}
+
+// GetTodoHistory lists a todo's status changes, oldest first
+func (h *Handler) GetTodoHistory(c *gin.Context) {
+	history, err := h.workflow.History(c.Param("id"))
+	if err != nil {
+		respondStoreError(c, err, "Todo not found")
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// GetStatusAnalytics reports lead and cycle time and how long todos stay in
+// each status, optionally for one account_id and between from and to
+func (h *Handler) GetStatusAnalytics(c *gin.Context) {
+	f := store.StatusFilter{AccountID: c.Query("account_id")}
+	var err error
+	for name, dest := range map[string]**time.Time{"from": &f.From, "to": &f.To} {
+		if *dest, err = dateParam(c, name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	report, err := h.workflow.Report(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,489 @@
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// rawInline lists the marks with no Markdown syntax. They are written as
+// inline HTML, which Parse lets through for these tags only.
+var rawInline = map[string]bool{"u": true, "mark": true, "sub": true, "sup": true}
+
+// blockTags are the elements that start a new Markdown block
+var blockTags = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "blockquote": true, "pre": true, "hr": true, "table": true,
+	"div": true, "section": true, "article": true,
+}
+
+var (
+	whitespace   = regexp.MustCompile(`[ \t\r\n]+`)
+	entityLike   = regexp.MustCompile(`^&(#[0-9]+|#[xX][0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+	orderedStart = regexp.MustCompile(`^([0-9]{1,9})([.)])`)
+)
+
+// FromHTML converts editor HTML to GitHub-flavored Markdown that Parse turns
+// back into equivalent HTML
+func FromHTML(s string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(s), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var c converter
+	out := c.joinBlocks(c.blocks(nodes))
+	if out == "" {
+		return "", nil
+	}
+	return out + "\n", nil
+}
+
+// converter carries the context that changes how inline content is written
+type converter struct {
+	inTable bool
+}
+
+// block is one rendered Markdown block; list blocks remember their marker so
+// two adjacent lists are not merged into one when parsed back
+type block struct {
+	text   string
+	marker string
+}
+
+func (c *converter) joinBlocks(blocks []block) string {
+	var sb strings.Builder
+	for i, b := range blocks {
+		if i > 0 {
+			sb.WriteString("\n\n")
+			if b.marker != "" && b.marker == blocks[i-1].marker {
+				sb.WriteString("<!-- -->\n\n")
+			}
+		}
+		sb.WriteString(b.text)
+	}
+	return sb.String()
+}
+
+// blocks renders a sequence of sibling nodes, gathering runs of inline
+// content into paragraphs
+func (c *converter) blocks(nodes []*html.Node) []block {
+	var out []block
+	var run []*html.Node
+	flush := func() {
+		if text := paragraph(c.inlines(run)); text != "" {
+			out = append(out, block{text: text})
+		}
+		run = nil
+	}
+
+	for _, n := range nodes {
+		if n.Type != html.ElementNode || !blockTags[n.Data] {
+			run = append(run, n)
+			continue
+		}
+		flush()
+		out = append(out, c.block(n)...)
+	}
+	flush()
+	return out
+}
+
+func (c *converter) block(n *html.Node) []block {
+	switch n.Data {
+	case "p":
+		if text := paragraph(c.inlines(children(n))); text != "" {
+			return []block{{text: text}}
+		}
+		return nil
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return []block{{text: heading(int(n.Data[1]-'0'), c.inlines(children(n)))}}
+	case "ul", "ol":
+		return c.list(n)
+	case "blockquote":
+		inner := c.joinBlocks(c.blocks(children(n)))
+		return []block{{text: prefixLines(inner, "> ", ">")}}
+	case "pre":
+		return []block{{text: codeBlock(n)}}
+	case "hr":
+		return []block{{text: "---"}}
+	case "table":
+		return []block{{text: c.table(n)}}
+	}
+	return c.blocks(children(n))
+}
+
+func (c *converter) list(n *html.Node) []block {
+	ordered := n.Data == "ol"
+	task := attr(n, "data-type") == "taskList"
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil && ordered {
+		number = start
+	}
+
+	var items []string
+	marker := "-"
+	for _, li := range children(n) {
+		if li.Type != html.ElementNode || li.Data != "li" {
+			continue
+		}
+
+		bullet := "- "
+		if ordered {
+			bullet = strconv.Itoa(number) + ". "
+			number++
+		}
+		prefix := bullet
+		if task || attr(li, "data-type") == "taskItem" {
+			if attr(li, "data-checked") == "true" {
+				prefix += "[x] "
+			} else {
+				prefix += "[ ] "
+			}
+		}
+
+		body := c.listItem(li)
+		items = append(items, prefix+indentLines(body, strings.Repeat(" ", len(bullet))))
+	}
+	if ordered {
+		marker = "."
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return []block{{text: strings.Join(items, "\n"), marker: marker}}
+}
+
+// listItem renders the content of an <li>. Paragraphs are separated by a
+// blank line; a nested list follows its paragraph directly.
+func (c *converter) listItem(li *html.Node) string {
+	var nodes []*html.Node
+	for _, child := range children(li) {
+		// TipTap renders task items as <label><input></label><div>content</div>
+		if child.Type == html.ElementNode && (child.Data == "label" || child.Data == "input") {
+			continue
+		}
+		nodes = append(nodes, child)
+	}
+
+	blocks := c.blocks(nodes)
+	var sb strings.Builder
+	for i, b := range blocks {
+		if i > 0 {
+			if b.marker != "" && blocks[i-1].marker == "" {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(b.text)
+	}
+	return sb.String()
+}
+
+func (c *converter) table(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for _, child := range children(n) {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "thead", "tbody", "tfoot":
+				walk(child)
+			case "tr":
+				var cells []string
+				for _, cell := range children(child) {
+					if cell.Type == html.ElementNode && (cell.Data == "th" || cell.Data == "td") {
+						cells = append(cells, c.tableCell(cell))
+					}
+				}
+				rows = append(rows, cells)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	var sb strings.Builder
+	writeRow := func(cells []string) {
+		sb.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			sb.WriteString(" " + cell + " |")
+		}
+		sb.WriteString("\n")
+	}
+
+	// GFM tables always have a header row; the first row serves as one
+	writeRow(rows[0])
+	sb.WriteString("|")
+	for i := 0; i < columns; i++ {
+		sb.WriteString(" --- |")
+	}
+	sb.WriteString("\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// tableCell flattens a cell to a single line, since GFM cells cannot span
+// lines. Paragraph breaks become <br>.
+func (c *converter) tableCell(cell *html.Node) string {
+	c.inTable = true
+	defer func() { c.inTable = false }()
+
+	var parts []string
+	for _, b := range c.blocks(children(cell)) {
+		parts = append(parts, strings.ReplaceAll(b.text, "\n", " "))
+	}
+	return strings.ReplaceAll(strings.Join(parts, "<br>"), "|", `\|`)
+}
+
+func (c *converter) inlines(nodes []*html.Node) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		sb.WriteString(c.inline(n))
+	}
+	return sb.String()
+}
+
+func (c *converter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeText(whitespace.ReplaceAllString(n.Data, " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.Data {
+	case "strong", "b":
+		return wrap("**", c.inlines(children(n)))
+	case "em", "i":
+		return wrap("*", c.inlines(children(n)))
+	case "s", "del", "strike":
+		return wrap("~~", c.inlines(children(n)))
+	case "code":
+		return codeSpan(textContent(n))
+	case "a":
+		text := c.inlines(children(n))
+		href := attr(n, "href")
+		if href == "" {
+			return text
+		}
+		return "[" + text + "](" + destination(href) + linkTitle(attr(n, "title")) + ")"
+	case "img":
+		return "![" + escapeText(attr(n, "alt")) + "](" + destination(attr(n, "src")) + linkTitle(attr(n, "title")) + ")"
+	case "br":
+		if c.inTable {
+			return "<br>"
+		}
+		return "\\\n"
+	case "input", "label", "script", "style":
+		return ""
+	}
+
+	if rawInline[n.Data] {
+		return "<" + n.Data + ">" + c.inlines(children(n)) + "</" + n.Data + ">"
+	}
+	return c.inlines(children(n))
+}
+
+// wrap surrounds content with an emphasis delimiter. Delimiters next to
+// whitespace do not count as emphasis, so surrounding spaces move outside.
+func wrap(delim, content string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return content
+	}
+	lead := content[:strings.Index(content, trimmed)]
+	trail := content[len(lead)+len(trimmed):]
+	return lead + delim + trimmed + delim + trail
+}
+
+func codeSpan(code string) string {
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") ||
+		(strings.HasPrefix(code, " ") && strings.HasSuffix(code, " ") && strings.TrimSpace(code) != "") {
+		code = " " + code + " "
+	}
+	return fence + code + fence
+}
+
+func codeBlock(pre *html.Node) string {
+	lang := ""
+	code := textContent(pre)
+	for _, child := range children(pre) {
+		if child.Type == html.ElementNode && child.Data == "code" {
+			for _, class := range strings.Fields(attr(child, "class")) {
+				if strings.HasPrefix(class, "language-") {
+					lang = strings.TrimPrefix(class, "language-")
+				}
+			}
+		}
+	}
+
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimSuffix(code, "\n") + "\n" + fence
+}
+
+// destination formats a link target, using the <...> form when the URL has
+// characters that would end a bare destination
+func destination(url string) string {
+	if strings.ContainsAny(url, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(url) + ">"
+	}
+	return url
+}
+
+func linkTitle(title string) string {
+	if title == "" {
+		return ""
+	}
+	return ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(title) + `"`
+}
+
+// escapeText backslash-escapes characters that Markdown would otherwise
+// read as syntax
+func escapeText(s string) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\', '`', '*', '[', ']', '<', '>', '~':
+			sb.WriteByte('\\')
+		case '_':
+			before, _ := utf8.DecodeLastRuneInString(s[:i])
+			after, _ := utf8.DecodeRuneInString(s[i+1:])
+			if !(isWordRune(before) && isWordRune(after)) {
+				sb.WriteByte('\\')
+			}
+		case '&':
+			if entityLike.MatchString(s[i:]) {
+				sb.WriteByte('\\')
+			}
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// paragraph trims rendered inline content and escapes the start of each of
+// its lines, since a line after a hard break could otherwise open a heading,
+// list or thematic break
+func paragraph(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\\\n")
+	for i, line := range lines {
+		lines[i] = escapeBlockStart(strings.TrimLeft(line, " "))
+	}
+	return strings.Join(lines, "\\\n")
+}
+
+func heading(level int, text string) string {
+	text = strings.TrimSpace(text)
+	// A trailing # would be read as a closing sequence and dropped
+	if strings.HasSuffix(text, "#") {
+		text = text[:len(text)-1] + `\#`
+	}
+	return strings.Repeat("#", level) + " " + text
+}
+
+// escapeBlockStart escapes a leading character that would turn a line into
+// a heading, list item or thematic break
+func escapeBlockStart(text string) string {
+	if text == "" {
+		return text
+	}
+	if m := orderedStart.FindStringSubmatch(text); m != nil {
+		return m[1] + `\` + text[len(m[1]):]
+	}
+	switch text[0] {
+	case '#', '-', '+', '=':
+		return `\` + text
+	}
+	return text
+}
+
+// indentLines indents every line after the first, leaving blank lines empty
+func indentLines(s, indent string) string {
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func prefixLines(s, prefix, blank string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = blank
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func children(n *html.Node) []*html.Node {
+	var out []*html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		out = append(out, child)
+	}
+	return out
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
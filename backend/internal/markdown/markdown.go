@@ -0,0 +1,211 @@
+// Package markdown converts Markdown documents into the HTML the TipTap
+// editor stores. It understands CommonMark plus the GFM extensions (tables,
+// task lists, strikethrough, autolinks) and an optional YAML front matter
+// block carrying note metadata.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter is the metadata a document may declare between --- fences
+type FrontMatter struct {
+	Title                string     `yaml:"title,omitempty"`
+	Account              string     `yaml:"account,omitempty"`
+	AccountID            string     `yaml:"account_id,omitempty"`
+	MeetingDate          string     `yaml:"meeting_date,omitempty"`
+	Participants         StringList `yaml:"participants,omitempty"`
+	InternalParticipants StringList `yaml:"internal_participants,omitempty"`
+	ExternalParticipants StringList `yaml:"external_participants,omitempty"`
+	Tags                 StringList `yaml:"tags,omitempty"`
+	Todos                []Todo     `yaml:"todos,omitempty"`
+}
+
+// Todo is a todo linked to the note
+type Todo struct {
+	ID       string `yaml:"id,omitempty"`
+	Title    string `yaml:"title"`
+	Status   string `yaml:"status,omitempty"`
+	Priority string `yaml:"priority,omitempty"`
+	DueDate  string `yaml:"due_date,omitempty"`
+}
+
+// StringList accepts either a YAML sequence or a comma-separated string
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = splitList(node.Value)
+		return nil
+	}
+	var items []string
+	if err := node.Decode(&items); err != nil {
+		return err
+	}
+	*l = nil
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Document is a parsed Markdown file
+type Document struct {
+	FrontMatter FrontMatter
+	// Title comes from the front matter, falling back to a leading # heading.
+	// It is empty when the document declares neither.
+	Title string
+	HTML  string
+}
+
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&tiptapRenderer{}, 100)),
+	),
+)
+
+// Parse reads front matter and converts the body to TipTap HTML. A leading
+// level-one heading is taken as the title and left out of the body, since
+// the note title is shown separately.
+func Parse(src []byte) (*Document, error) {
+	src = bytes.TrimPrefix(src, []byte("\xef\xbb\xbf"))
+
+	var doc Document
+	header, body := SplitFrontMatter(src)
+	if header != nil {
+		if err := yaml.Unmarshal(header, &doc.FrontMatter); err != nil {
+			return nil, fmt.Errorf("invalid front matter: %w", err)
+		}
+	}
+	doc.Title = strings.TrimSpace(doc.FrontMatter.Title)
+
+	root := md.Parser().Parse(text.NewReader(body))
+	if h, ok := root.FirstChild().(*ast.Heading); ok && h.Level == 1 {
+		heading := strings.TrimSpace(plainText(h, body))
+		if doc.Title == "" {
+			doc.Title = heading
+		}
+		if heading == doc.Title {
+			root.RemoveChild(root, h)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, body, root); err != nil {
+		return nil, err
+	}
+	doc.HTML = strings.TrimSpace(buf.String())
+	return &doc, nil
+}
+
+// Render writes a note as Markdown: front matter, the title as a level-one
+// heading, then the body converted from HTML. Parse reads it back into the
+// same metadata and equivalent HTML.
+func Render(fm FrontMatter, body string) ([]byte, error) {
+	content, err := FromHTML(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(fm); err != nil {
+		return nil, err
+	}
+	enc.Close()
+	buf.WriteString("---\n\n")
+
+	if fm.Title != "" {
+		buf.WriteString(heading(1, escapeText(fm.Title)) + "\n\n")
+	}
+	buf.WriteString(content)
+	return buf.Bytes(), nil
+}
+
+// ToHTML converts Markdown to TipTap HTML without any front matter handling
+func ToHTML(src []byte) (string, error) {
+	var buf bytes.Buffer
+	if err := md.Convert(src, &buf); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// SplitFrontMatter separates a leading --- fenced YAML block from the body.
+// header is nil when the document has no front matter.
+func SplitFrontMatter(src []byte) (header, body []byte) {
+	normalized := bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(normalized, []byte("---\n")) {
+		return nil, src
+	}
+
+	rest := normalized[len("---\n"):]
+	for offset := 0; offset < len(rest); {
+		end := bytes.IndexByte(rest[offset:], '\n')
+		var line []byte
+		if end < 0 {
+			line = rest[offset:]
+			end = len(rest) - offset
+		} else {
+			line = rest[offset : offset+end]
+			end++
+		}
+		if trimmed := bytes.TrimRight(line, " \t"); string(trimmed) == "---" || string(trimmed) == "..." {
+			return rest[:offset], rest[offset+end:]
+		}
+		offset += end
+	}
+	return nil, src
+}
+
+// plainText concatenates the text content of an inline subtree
+func plainText(n ast.Node, source []byte) string {
+	var sb strings.Builder
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := child.(type) {
+		case *ast.Text:
+			value := t.Segment.Value(source)
+			if _, inCode := t.Parent().(*ast.CodeSpan); !inCode {
+				value = util.UnescapePunctuations(value)
+				value = util.ResolveNumericReferences(value)
+				value = util.ResolveEntityNames(value)
+			}
+			sb.Write(value)
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return sb.String()
+}
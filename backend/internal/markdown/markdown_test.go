@@ -0,0 +1,93 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	doc, err := Parse([]byte("---\ntitle: Weekly sync\nmeeting_date: 2024-01-15\nparticipants:\n  - ana@example.com\ntags: roadmap, q1\n---\n# Weekly sync\n\nAgenda\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Weekly sync", doc.Title)
+	assert.Equal(t, "2024-01-15", doc.FrontMatter.MeetingDate)
+	assert.Equal(t, StringList{"ana@example.com"}, doc.FrontMatter.Participants)
+	assert.Equal(t, StringList{"roadmap", "q1"}, doc.FrontMatter.Tags)
+	// The heading repeats the title, so it is not kept in the body
+	assert.Equal(t, "<p>Agenda</p>", doc.HTML)
+}
+
+func TestParseTitleFromHeading(t *testing.T) {
+	doc, err := Parse([]byte("# Kickoff *notes*\n\n## Goals\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "Kickoff notes", doc.Title)
+	assert.Equal(t, "<h2>Goals</h2>", doc.HTML)
+
+	_, err = Parse([]byte("---\ntitle: [unterminated\n---\n"))
+	assert.Error(t, err)
+}
+
+func TestToHTMLLists(t *testing.T) {
+	html, err := ToHTML([]byte("- [x] Done\n- [ ] Open\n\n1. One\n   - Nested\n"))
+	require.NoError(t, err)
+	assert.Equal(t, `<ul data-type="taskList">
+<li data-type="taskItem" data-checked="true"><p>Done</p></li>
+<li data-type="taskItem" data-checked="false"><p>Open</p></li>
+</ul>
+<ol>
+<li><p>One</p><ul>
+<li><p>Nested</p></li>
+</ul>
+</li>
+</ol>`, html)
+
+	// Lists mixing plain and task items keep the checkbox as text
+	html, err = ToHTML([]byte("- plain\n- [x] checked\n"))
+	require.NoError(t, err)
+	assert.Contains(t, html, "<li><p>[x] checked</p></li>")
+}
+
+func TestToHTMLGFM(t *testing.T) {
+	html, err := ToHTML([]byte("| a | b |\n|---|---|\n| 1 | 2 |\n\n```go\nx := 1\n```\n\n~~old~~ <script>x</script>\n"))
+	require.NoError(t, err)
+	assert.Contains(t, html, "<th>a</th>")
+	assert.Contains(t, html, `<pre><code class="language-go">x := 1`)
+	assert.Contains(t, html, "<del>old</del>")
+	assert.NotContains(t, html, "<script>")
+}
+
+var betweenTags = regexp.MustCompile(`>\s+<`)
+
+func TestRoundTrip(t *testing.T) {
+	cases := []string{
+		`<h2>Agenda</h2><p>Hello <strong>bold</strong> <em>it</em> <code>x</code> <a href="https://example.com/a_(b)">link</a> R&amp;D 5 * 3 [b] &lt;div&gt;</p>`,
+		`<ul data-type="taskList"><li data-type="taskItem" data-checked="true"><p>done</p></li><li data-type="taskItem" data-checked="false"><p>open</p><ul><li><p>nested</p></li></ul></li></ul><ul><li><p>plain</p></li></ul>`,
+		`<ol start="3"><li><p>three</p></li><li><p>four</p><p>more</p></li></ol><p>1. not a list</p><p># not a heading</p>`,
+		`<blockquote><p>quote</p></blockquote><pre><code class="language-go">x := "` + "```" + `"</code></pre><hr><p>line<br>- not an item</p>`,
+		`<table><thead><tr><th>A</th><th>B|C</th></tr></thead><tbody><tr><td>1</td><td>2</td></tr></tbody></table>`,
+		`<p>Some <u>underline</u> and x<sup>2</sup></p>`,
+	}
+
+	for _, html := range cases {
+		md, err := Render(FrontMatter{Title: "Notes #1"}, html)
+		require.NoError(t, err)
+
+		doc, err := Parse(md)
+		require.NoError(t, err)
+		assert.Equal(t, "Notes #1", doc.Title)
+
+		want := betweenTags.ReplaceAllString(html, "><")
+		got := betweenTags.ReplaceAllString(strings.NewReplacer("\n</code>", "</code>", "<br>\n", "<br>").Replace(doc.HTML), "><")
+		assert.Equal(t, want, strings.ReplaceAll(got, "&quot;", `"`), string(md))
+	}
+}
+
+func TestFromHTMLTipTapTaskItem(t *testing.T) {
+	md, err := FromHTML(`<ul data-type="taskList"><li data-type="taskItem" data-checked="true"><label><input type="checkbox" checked="checked"><span></span></label><div><p>Ship it</p></div></li></ul>`)
+	require.NoError(t, err)
+	assert.Equal(t, "- [x] Ship it\n", md)
+}
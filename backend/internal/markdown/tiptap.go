@@ -0,0 +1,142 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// allowedRawHTML matches the inline tags FromHTML writes for marks that
+// Markdown cannot express. Any other raw HTML is dropped.
+var allowedRawHTML = regexp.MustCompile(`^(?i)</?(u|mark|sub|sup|br)\s*/?>$`)
+
+// tiptapRenderer overrides goldmark's list output to match what TipTap
+// produces itself: list items always wrap their text in <p>, and GFM task
+// lists become TipTap taskList/taskItem nodes instead of bare checkboxes.
+type tiptapRenderer struct{}
+
+func (r *tiptapRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindList, r.renderList)
+	reg.Register(ast.KindListItem, r.renderListItem)
+	reg.Register(ast.KindTextBlock, r.renderTextBlock)
+	reg.Register(east.KindTaskCheckBox, r.renderTaskCheckBox)
+	reg.Register(ast.KindRawHTML, r.renderRawHTML)
+	reg.Register(ast.KindHTMLBlock, r.renderHTMLBlock)
+}
+
+func (r *tiptapRenderer) renderList(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.List)
+	tag := "ul"
+	if n.IsOrdered() {
+		tag = "ol"
+	}
+
+	if !entering {
+		fmt.Fprintf(w, "</%s>\n", tag)
+		return ast.WalkContinue, nil
+	}
+
+	fmt.Fprintf(w, "<%s", tag)
+	if n.IsOrdered() && n.Start != 1 {
+		fmt.Fprintf(w, ` start="%d"`, n.Start)
+	}
+	if isTaskList(n) {
+		w.WriteString(` data-type="taskList"`)
+	}
+	w.WriteString(">\n")
+	return ast.WalkContinue, nil
+}
+
+func (r *tiptapRenderer) renderListItem(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		w.WriteString("</li>\n")
+		return ast.WalkContinue, nil
+	}
+
+	if list, ok := node.Parent().(*ast.List); ok && isTaskList(list) {
+		fmt.Fprintf(w, `<li data-type="taskItem" data-checked="%t">`, taskCheckBox(node).IsChecked)
+	} else {
+		w.WriteString("<li>")
+	}
+	return ast.WalkContinue, nil
+}
+
+// renderTextBlock handles the paragraphs of tight list items, which goldmark
+// would otherwise emit as bare text
+func (r *tiptapRenderer) renderTextBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.WriteString("<p>")
+	} else {
+		w.WriteString("</p>")
+	}
+	return ast.WalkContinue, nil
+}
+
+// renderTaskCheckBox drops the checkbox inside task lists, where the state is
+// carried by data-checked. A checkbox in a list that mixes plain and task
+// items is kept as literal text so nothing is lost.
+func (r *tiptapRenderer) renderTaskCheckBox(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	if list, ok := node.Parent().Parent().Parent().(*ast.List); ok && isTaskList(list) {
+		return ast.WalkContinue, nil
+	}
+	if node.(*east.TaskCheckBox).IsChecked {
+		w.WriteString("[x] ")
+	} else {
+		w.WriteString("[ ] ")
+	}
+	return ast.WalkContinue, nil
+}
+
+// isTaskList reports whether every item of a bullet list starts with a
+// checkbox. TipTap has no ordered task list, so numbered lists never qualify.
+func isTaskList(list *ast.List) bool {
+	if list.IsOrdered() || list.FirstChild() == nil {
+		return false
+	}
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		if taskCheckBox(item) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func taskCheckBox(item ast.Node) *east.TaskCheckBox {
+	block := item.FirstChild()
+	if block == nil {
+		return nil
+	}
+	box, _ := block.FirstChild().(*east.TaskCheckBox)
+	return box
+}
+
+func (r *tiptapRenderer) renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+
+	n := node.(*ast.RawHTML)
+	var raw []byte
+	for i := 0; i < n.Segments.Len(); i++ {
+		segment := n.Segments.At(i)
+		raw = append(raw, segment.Value(source)...)
+	}
+	if allowedRawHTML.Match(raw) {
+		w.Write(raw)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// renderHTMLBlock drops block-level raw HTML, including the comments FromHTML
+// uses to keep adjacent lists apart
+func (r *tiptapRenderer) renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	return ast.WalkSkipChildren, nil
+}
@@ -6,13 +6,14 @@ import (
 
 // Account represents a customer account
 type Account struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	AccountOwner string    `json:"account_owner"` // Sales rep
-	Budget       *float64  `json:"budget,omitempty"`
-	EstEngineers *int      `json:"est_engineers,omitempty"` // Estimated POC size
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	AccountOwner string     `json:"account_owner"` // Sales rep
+	Budget       *float64   `json:"budget,omitempty"`
+	EstEngineers *int       `json:"est_engineers,omitempty"` // Estimated POC size
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
 }
 
 // Note represents a meeting note
@@ -20,6 +21,7 @@ type Note struct {
 	ID                   string       `json:"id"`
 	Title                string       `json:"title"`
 	AccountID            string       `json:"account_id"`
+	AccountName          string       `json:"account_name,omitempty"` // Populated from join
 	Account              *Account     `json:"account,omitempty"`
 	TemplateType         string       `json:"template_type"` // "initial" or "followup"
 	InternalParticipants []string     `json:"internal_participants"`
@@ -30,27 +32,172 @@ type Note struct {
 	Pinned               bool         `json:"pinned"`
 	Archived             bool         `json:"archived"`
 	SortOrder            int          `json:"sort_order"`
+	Version              int          `json:"version"` // Incremented on every update
 	CreatedAt            time.Time    `json:"created_at"`
 	UpdatedAt            time.Time    `json:"updated_at"`
+	DeletedAt            *time.Time   `json:"deleted_at,omitempty"`
 	Todos                []Todo       `json:"todos,omitempty"`
 	Tags                 []Tag        `json:"tags,omitempty"`
 	Attachments          []Attachment `json:"attachments,omitempty"`
 }
 
+// NoteRevision is a saved version of a note's title and content
+type NoteRevision struct {
+	NoteID    string    `json:"note_id"`
+	Rev       int       `json:"rev"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content,omitempty"`
+	Size      int       `json:"size"` // Content length in bytes
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // Todo represents a task/follow-up item
 type Todo struct {
+	ID          string        `json:"id"`
+	Title       string        `json:"title"`
+	Description string        `json:"description,omitempty"`
+	Status      string        `json:"status"`             // A TodoStatus key; the workflow's blocked status while blocked
+	Priority    string        `json:"priority,omitempty"` // "low", "medium", "high"
+	DueDate     *time.Time    `json:"due_date,omitempty"`
+	AccountID   *string       `json:"account_id,omitempty"`   // Optional account tag
+	AccountName string        `json:"account_name,omitempty"` // Populated from join
+	Pinned      bool          `json:"pinned"`
+	Recurrence  string        `json:"recurrence,omitempty"`       // RRULE such as "FREQ=WEEKLY;BYDAY=MO"
+	SeriesID    string        `json:"series_id,omitempty"`        // Shared by every occurrence of a recurring todo
+	Occurrence  int           `json:"occurrence,omitempty"`       // Position in the series, from 1
+	ParentID    *string       `json:"parent_id,omitempty"`        // Set on subtasks
+	Blocked     bool          `json:"blocked"`                    // An open todo in BlockedBy holds this one up
+	Reminders   []int         `json:"reminder_minutes"`           // Minutes before the due date; nil for the default, empty for none
+	Estimate    *int          `json:"estimate_minutes,omitempty"` // Expected effort
+	Tracked     int           `json:"tracked_minutes"`            // Sum of the finished time entries
+	Timer       *time.Time    `json:"timer_started_at,omitempty"` // Start of the running timer
+	Version     int           `json:"version"`                    // Incremented on every update
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	DeletedAt   *time.Time    `json:"deleted_at,omitempty"`
+	Notes       []Note        `json:"notes,omitempty"`      // Linked notes
+	BlockedBy   []TodoRef     `json:"blocked_by,omitempty"` // Todos that must be completed first
+	Subtasks    []TodoRef     `json:"subtasks,omitempty"`   // Direct subtasks, only filled by Get
+	Progress    *TodoProgress `json:"progress,omitempty"`   // Set on todos with subtasks
+}
+
+// TodoRef is a short reference to a related todo
+type TodoRef struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+}
+
+// TodoProgress rolls up the subtasks of a todo at every depth
+type TodoProgress struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Percent   int `json:"percent"`
+}
+
+// TodoStatus is a status of the todo workflow and a column of the board
+type TodoStatus struct {
+	Key      string `json:"key"`
+	Label    string `json:"label"`
+	Category string `json:"category"` // "open", "active", "blocked" or "done"
+	Color    string `json:"color,omitempty"`
+}
+
+// Workflow is the todo statuses in board order and the changes allowed
+// between them
+type Workflow struct {
+	Statuses    []TodoStatus        `json:"statuses"`
+	Transitions map[string][]string `json:"transitions"` // Statuses each one can change to; null allows every change
+}
+
+// StatusChange records a todo moving from one status to another
+type StatusChange struct {
+	ID         string    `json:"id"`
+	TodoID     string    `json:"todo_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ChangedAt  time.Time `json:"changed_at"`
+	Minutes    int       `json:"minutes_in_from_status"` // Since the previous change, or since the todo was created
+}
+
+// StatusReport measures how todos move through the workflow
+type StatusReport struct {
+	Completed int           `json:"completed"`  // Todos that reached a done status in the range
+	LeadTime  DurationStats `json:"lead_time"`  // From creation to done
+	CycleTime DurationStats `json:"cycle_time"` // From leaving the open statuses to done
+	Statuses  []StatusTime  `json:"statuses"`
+}
+
+// DurationStats summarizes a set of durations
+type DurationStats struct {
+	AverageHours float64 `json:"average_hours"`
+	MedianHours  float64 `json:"median_hours"`
+}
+
+// StatusTime is how long todos stayed in a status
+type StatusTime struct {
+	Status       string  `json:"status"`
+	Label        string  `json:"label"`
+	Category     string  `json:"category"`
+	Current      int     `json:"current"` // Todos in the status now
+	Periods      int     `json:"periods"` // Times a todo entered it in the range
+	TotalHours   float64 `json:"total_hours"`
+	AverageHours float64 `json:"average_hours"`
+}
+
+// TimeEntry is time spent on a todo, from its timer or entered by hand
+type TimeEntry struct {
+	ID        string     `json:"id"`
+	TodoID    string     `json:"todo_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"` // Nil while the timer runs
+	Minutes   int        `json:"minutes"`
+	Note      string     `json:"note,omitempty"`
+	Source    string     `json:"source"` // "timer" or "manual"
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TimeReport rolls up finished time entries per account and per week
+type TimeReport struct {
+	TotalMinutes int           `json:"total_minutes"`
+	ByAccount    []AccountTime `json:"by_account"`
+	ByWeek       []WeekTime    `json:"by_week"`
+}
+
+// AccountTime is the time spent on an account's todos
+type AccountTime struct {
+	AccountID   *string `json:"account_id"` // Nil for todos without an account
+	AccountName string  `json:"account_name"`
+	Minutes     int     `json:"minutes"`
+	Todos       int     `json:"todos"` // Todos with time logged
+}
+
+// WeekTime is the time spent in a week starting on Monday
+type WeekTime struct {
+	WeekStart string        `json:"week_start"` // YYYY-MM-DD
+	Minutes   int           `json:"minutes"`
+	Accounts  []AccountTime `json:"accounts"`
+}
+
+// Reminder is the reminder of a todo at one lead time before its due date
+type Reminder struct {
+	TodoID      string    `json:"todo_id"`
+	Title       string    `json:"title"`
+	AccountName string    `json:"account_name,omitempty"`
+	DueDate     time.Time `json:"due_date"`
+	LeadMinutes int       `json:"lead_minutes"`
+	RemindAt    time.Time `json:"remind_at"`
+	Snoozed     bool      `json:"snoozed"` // RemindAt was set by a snooze
+}
+
+// TodoCompletion records one completed occurrence of a recurring todo
+type TodoCompletion struct {
 	ID          string     `json:"id"`
-	Title       string     `json:"title"`
-	Description string     `json:"description,omitempty"`
-	Status      string     `json:"status"`             // "not_started", "in_progress", "stuck", "completed"
-	Priority    string     `json:"priority,omitempty"` // "low", "medium", "high"
+	SeriesID    string     `json:"series_id"`
+	TodoID      *string    `json:"todo_id"` // Null once the occurrence is permanently deleted
+	Occurrence  int        `json:"occurrence"`
 	DueDate     *time.Time `json:"due_date,omitempty"`
-	AccountID   *string    `json:"account_id,omitempty"`   // Optional account tag
-	AccountName string     `json:"account_name,omitempty"` // Populated from join
-	Pinned      bool       `json:"pinned"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	Notes       []Note     `json:"notes,omitempty"` // Linked notes
+	CompletedAt time.Time  `json:"completed_at"`
 }
 
 // Activity represents an activity log entry
@@ -73,6 +220,10 @@ type Attachment struct {
 	OriginalName string    `json:"original_name"` // User's filename
 	MimeType     string    `json:"mime_type"`
 	Size         int64     `json:"size"`
+	PageCount    int       `json:"page_count,omitempty"` // PDFs and Word documents
+	Width        int       `json:"width,omitempty"`      // Images
+	Height       int       `json:"height,omitempty"`
+	TextPreview  string    `json:"text_preview,omitempty"` // Start of the extracted text
 	CreatedAt    time.Time `json:"created_at"`
 }
 
@@ -84,14 +235,26 @@ type Participant struct {
 	IsInternal bool   `json:"is_internal"`
 }
 
-// Tag represents a tag for notes
+// Tag represents a tag for notes, todos and accounts. Names are paths such
+// as "customer/poc", nested under the tag named by everything before the
+// last slash.
 type Tag struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
+	Parent    string    `json:"parent,omitempty"`
 	Color     string    `json:"color"`
+	Usage     *TagUsage `json:"usage,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// TagUsage counts the live notes, todos and accounts carrying a tag itself,
+// not counting its children
+type TagUsage struct {
+	Notes    int `json:"notes"`
+	Todos    int `json:"todos"`
+	Accounts int `json:"accounts"`
+}
+
 // CreateTagRequest for creating a tag
 type CreateTagRequest struct {
 	Name  string `json:"name" binding:"required"`
@@ -104,6 +267,11 @@ type UpdateTagRequest struct {
 	Color *string `json:"color"`
 }
 
+// MergeTagRequest for merging a tag into another
+type MergeTagRequest struct {
+	Into string `json:"into" binding:"required"`
+}
+
 // CreateAccountRequest for creating an account
 type CreateAccountRequest struct {
 	Name         string   `json:"name" binding:"required"`
@@ -145,6 +313,14 @@ type UpdateNoteRequest struct {
 	Pinned               *bool    `json:"pinned"`
 	Archived             *bool    `json:"archived"`
 	SortOrder            *int     `json:"sort_order"`
+	Version              *int     `json:"version"` // Optional: reject the update if the note has changed since
+}
+
+// BulkNotesRequest for making one change to many notes
+type BulkNotesRequest struct {
+	NoteIDs []string               `json:"note_ids" binding:"required"`
+	Action  string                 `json:"action" binding:"required"` // "set_account", "add_tag", "remove_tag", "archive", "unarchive", "pin", "unpin", "delete", "restore"
+	Value   map[string]interface{} `json:"value"`
 }
 
 // CreateTodoRequest for creating a todo
@@ -154,8 +330,12 @@ type CreateTodoRequest struct {
 	Status      string  `json:"status"`
 	Priority    string  `json:"priority"`
 	DueDate     *string `json:"due_date"`
-	NoteID      *string `json:"note_id"`    // Optional: link to a note on creation
-	AccountID   *string `json:"account_id"` // Optional: tag with account
+	NoteID      *string `json:"note_id"`          // Optional: link to a note on creation
+	AccountID   *string `json:"account_id"`       // Optional: tag with account
+	Recurrence  string  `json:"recurrence"`       // Optional: RRULE to repeat the todo on
+	ParentID    *string `json:"parent_id"`        // Optional: make it a subtask
+	Reminders   []int   `json:"reminder_minutes"` // Optional: minutes before the due date to remind
+	Estimate    *int    `json:"estimate_minutes"` // Optional: expected effort
 }
 
 // UpdateTodoRequest for updating a todo
@@ -167,6 +347,42 @@ type UpdateTodoRequest struct {
 	DueDate     *string `json:"due_date"`
 	AccountID   *string `json:"account_id"`
 	Pinned      *bool   `json:"pinned"`
+	Recurrence  *string `json:"recurrence"`       // Empty stops the todo recurring
+	ParentID    *string `json:"parent_id"`        // Empty makes a subtask top level
+	Estimate    *int    `json:"estimate_minutes"` // 0 clears the estimate
+	Version     *int    `json:"version"`          // Optional: reject the update if the todo has changed since
+}
+
+// BulkTodosRequest for making one change to many todos
+type BulkTodosRequest struct {
+	TodoIDs []string               `json:"todo_ids" binding:"required"`
+	Action  string                 `json:"action" binding:"required"` // "set_status", "set_priority", "set_due_date", "set_account", "delete"
+	Value   map[string]interface{} `json:"value"`
+}
+
+// BulkResult is the outcome of a bulk change for one item
+type BulkResult struct {
+	ID    string `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// CreateTimeEntryRequest logs time spent on a todo by hand
+type CreateTimeEntryRequest struct {
+	Minutes   int     `json:"minutes" binding:"required"`
+	StartedAt *string `json:"started_at"` // Optional: defaults to minutes before now
+	Note      string  `json:"note"`
+}
+
+// SetRemindersRequest sets the lead times of a todo's reminders
+type SetRemindersRequest struct {
+	Minutes []int `json:"minutes"` // Null or absent for the default
+}
+
+// SnoozeReminderRequest postpones a reminder by Minutes or until Until
+type SnoozeReminderRequest struct {
+	Minutes int     `json:"minutes"`
+	Until   *string `json:"until"`
 }
 
 // Analytics response
@@ -196,12 +412,137 @@ type IncompleteField struct {
 
 // SearchResult represents a search result
 type SearchResult struct {
-	Type        string `json:"type"` // "note", "account", "todo"
+	Type        string `json:"type"` // "note", "account", "todo", "contact", "attachment"
 	ID          string `json:"id"`
 	Title       string `json:"title"`
 	Snippet     string `json:"snippet,omitempty"`
 	AccountID   string `json:"account_id,omitempty"`
 	AccountName string `json:"account_name,omitempty"`
+	// Date is a note's meeting date, a todo's due date, or when the result
+	// was created when it has neither
+	Date      time.Time `json:"date"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Score     float64   `json:"score"`
+	NoteID    string    `json:"note_id,omitempty"` // The note an attachment belongs to
+}
+
+// SavedSearch is a search kept under a name, listed as a smart folder
+type SavedSearch struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Query     string        `json:"query"`
+	Filters   SearchFilters `json:"filters"`
+	Count     *int          `json:"count,omitempty"` // Current number of results, when listed
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// SearchFilters are the filter parameters of a search, as GET /search takes
+// them
+type SearchFilters struct {
+	Type         string `json:"type,omitempty"` // Comma separated
+	AccountID    string `json:"account_id,omitempty"`
+	Tag          string `json:"tag,omitempty"`
+	TemplateType string `json:"template_type,omitempty"`
+	From         string `json:"from,omitempty"`
+	To           string `json:"to,omitempty"`
+	Archived     string `json:"archived,omitempty"`
+	Deleted      string `json:"deleted,omitempty"`
+}
+
+// CreateSavedSearchRequest for saving a search
+type CreateSavedSearchRequest struct {
+	Name    string        `json:"name" binding:"required"`
+	Query   string        `json:"query"`
+	Filters SearchFilters `json:"filters"`
+}
+
+// UpdateSavedSearchRequest for changing a saved search
+type UpdateSavedSearchRequest struct {
+	Name    *string        `json:"name"`
+	Query   *string        `json:"query"`
+	Filters *SearchFilters `json:"filters"`
+}
+
+// Contact represents a person seen in meetings
+type Contact struct {
+	ID                   string     `json:"id"`
+	Email                string     `json:"email"`
+	Name                 string     `json:"name"`
+	Company              string     `json:"company"`
+	Domain               string     `json:"domain"`
+	IsInternal           bool       `json:"is_internal"`
+	AccountID            *string    `json:"account_id,omitempty"`
+	AccountName          string     `json:"account_name,omitempty"`
+	SuggestedAccountID   *string    `json:"suggested_account_id,omitempty"`
+	SuggestedAccountName string     `json:"suggested_account_name,omitempty"`
+	SuggestionConfirmed  bool       `json:"suggestion_confirmed"`
+	Source               string     `json:"source"`
+	FirstSeen            time.Time  `json:"first_seen"`
+	LastSeen             time.Time  `json:"last_seen"`
+	MeetingCount         int        `json:"meeting_count"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+	DeletedAt            *time.Time `json:"-"`
+}
+
+// CreateContactRequest for creating a contact manually
+type CreateContactRequest struct {
+	Email   string `json:"email" binding:"required,email"`
+	Name    string `json:"name"`
+	Company string `json:"company"`
+	Source  string `json:"source"`
+}
+
+// UpdateContactRequest for updating a contact
+type UpdateContactRequest struct {
+	Name      *string `json:"name"`
+	Company   *string `json:"company"`
+	AccountID *string `json:"account_id"` // Empty string unlinks
+}
+
+// BulkContactsRequest for bulk actions on contacts
+type BulkContactsRequest struct {
+	ContactIDs []string               `json:"contact_ids" binding:"required"`
+	Action     string                 `json:"action" binding:"required"` // "delete", "set_internal", "set_account"
+	Value      map[string]interface{} `json:"value"`
+}
+
+// ContactNote is a note a contact participated in
+type ContactNote struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	AccountID   *string    `json:"account_id,omitempty"`
+	AccountName string     `json:"account_name,omitempty"`
+	MeetingDate *time.Time `json:"meeting_date,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ContactStats summarizes the contact list
+type ContactStats struct {
+	TotalContacts      int `json:"total_contacts"`
+	InternalContacts   int `json:"internal_contacts"`
+	ExternalContacts   int `json:"external_contacts"`
+	LinkedContacts     int `json:"linked_contacts"`
+	PendingSuggestions int `json:"pending_suggestions"`
+}
+
+// AccountRef is a lightweight reference to an account
+type AccountRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// DomainGroup represents contacts grouped by domain with suggestions
+type DomainGroup struct {
+	Domain            string      `json:"domain"`
+	ContactCount      int         `json:"contact_count"`
+	ContactIDs        []string    `json:"contact_ids"`
+	IsInternal        bool        `json:"is_internal"`
+	LinkedAccountID   *string     `json:"linked_account_id,omitempty"`
+	LinkedAccountName string      `json:"linked_account_name,omitempty"`
+	SuggestedAccount  *AccountRef `json:"suggested_account,omitempty"`
+	Contacts          []Contact   `json:"contacts,omitempty"`
 }
 
 // CreateActivityRequest for logging activities
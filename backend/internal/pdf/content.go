@@ -0,0 +1,488 @@
+package pdf
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	bodySize = 10.5
+	monoSize = 9.0
+	// listIndent is how far list item content sits from its marker
+	listIndent = 6.0
+)
+
+var headingSizes = map[string]float64{"h1": 16, "h2": 14, "h3": 12.5, "h4": 11.5, "h5": 11, "h6": 10.5}
+
+var blockTags = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "blockquote": true, "pre": true, "hr": true, "table": true,
+	"div": true, "section": true, "article": true,
+}
+
+var whitespace = regexp.MustCompile(`[ \t\r\n]+`)
+
+// textStyle is the font state for a run of inline text
+type textStyle struct {
+	bold, italic, underline, strike, mono bool
+	size                                  float64
+	gray                                  bool
+	link                                  string
+}
+
+func (s textStyle) apply(p *fpdf.Fpdf) {
+	family := fontFamily
+	if s.mono {
+		family = monoFamily
+	}
+	var flags strings.Builder
+	if s.bold {
+		flags.WriteString("B")
+	}
+	if s.italic {
+		flags.WriteString("I")
+	}
+	if s.underline || s.link != "" {
+		flags.WriteString("U")
+	}
+	if s.strike {
+		flags.WriteString("S")
+	}
+	p.SetFont(family, flags.String(), s.size)
+
+	switch {
+	case s.link != "":
+		p.SetTextColor(37, 99, 235)
+	case s.gray:
+		p.SetTextColor(100, 100, 100)
+	default:
+		p.SetTextColor(30, 30, 30)
+	}
+}
+
+// content prints note HTML, mapping TipTap's block and inline elements onto
+// fpdf text runs
+func (r *renderer) content(s string) {
+	nodes, err := html.ParseFragment(strings.NewReader(s), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		nodes = []*html.Node{{Type: html.TextNode, Data: s}}
+	}
+
+	r.style = textStyle{size: bodySize}
+	r.blocks(nodes)
+}
+
+func (r *renderer) blocks(nodes []*html.Node) {
+	var run []*html.Node
+	flush := func() {
+		if hasContent(run) {
+			r.paragraph(run)
+		}
+		run = nil
+	}
+
+	for _, n := range nodes {
+		if n.Type != html.ElementNode || !blockTags[n.Data] {
+			run = append(run, n)
+			continue
+		}
+		flush()
+		r.block(n)
+	}
+	flush()
+}
+
+func (r *renderer) block(n *html.Node) {
+	switch n.Data {
+	case "p":
+		r.paragraph(children(n))
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		r.heading(n)
+	case "ul", "ol":
+		r.list(n)
+	case "blockquote":
+		r.quote(n)
+	case "pre":
+		r.code(n)
+	case "hr":
+		r.startBlock()
+		r.rule()
+	case "table":
+		r.table(n)
+	default:
+		r.blocks(children(n))
+	}
+}
+
+// startBlock moves to the left edge of the current indentation, unless a
+// list marker was just printed and the block continues on its line
+func (r *renderer) startBlock() {
+	r.pdf.SetLeftMargin(margin + r.indent)
+	if r.afterMarker {
+		r.afterMarker = false
+		return
+	}
+	r.pdf.SetX(margin + r.indent)
+}
+
+func (r *renderer) paragraph(nodes []*html.Node) {
+	r.startBlock()
+	r.style.apply(r.pdf)
+	r.atLineStart = true
+	r.inlines(nodes)
+	r.pdf.Ln(lineHeight)
+	r.pdf.Ln(1.5)
+}
+
+func (r *renderer) heading(n *html.Node) {
+	saved := r.style
+	r.style.bold = true
+	r.style.size = headingSizes[n.Data]
+
+	r.pdf.Ln(2)
+	r.startBlock()
+	r.style.apply(r.pdf)
+	r.atLineStart = true
+	r.inlines(children(n))
+	r.pdf.Ln(r.style.size * 0.45)
+	r.pdf.Ln(1.5)
+	r.style = saved
+}
+
+func (r *renderer) list(n *html.Node) {
+	p := r.pdf
+	ordered := n.Data == "ol"
+	task := attr(n, "data-type") == "taskList"
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil && ordered {
+		number = start
+	}
+
+	for _, li := range children(n) {
+		if li.Type != html.ElementNode || li.Data != "li" {
+			continue
+		}
+
+		r.startBlock()
+		r.style.apply(p)
+		x, y := p.GetX(), p.GetY()
+		switch {
+		case task || attr(li, "data-type") == "taskItem":
+			r.checkbox(x, y, attr(li, "data-checked") == "true")
+		case ordered:
+			p.CellFormat(listIndent, lineHeight, strconv.Itoa(number)+".", "", 0, "L", false, 0, "")
+			number++
+		default:
+			p.CellFormat(listIndent, lineHeight, r.tr("•"), "", 0, "L", false, 0, "")
+		}
+		p.SetXY(x+listIndent, y)
+
+		var content []*html.Node
+		for _, child := range children(li) {
+			if child.Type == html.ElementNode && (child.Data == "label" || child.Data == "input") {
+				continue
+			}
+			content = append(content, child)
+		}
+
+		r.indent += listIndent
+		r.afterMarker = true
+		r.blocks(content)
+		if r.afterMarker {
+			// Empty item: still give it a line
+			r.afterMarker = false
+			p.Ln(lineHeight)
+		}
+		r.indent -= listIndent
+	}
+	p.SetLeftMargin(margin + r.indent)
+}
+
+func (r *renderer) checkbox(x, y float64, checked bool) {
+	p := r.pdf
+	const size = 3.2
+	top := y + (lineHeight-size)/2
+	p.SetDrawColor(120, 120, 120)
+	p.SetLineWidth(0.25)
+	p.Rect(x+0.3, top, size, size, "D")
+	if checked {
+		p.SetLineWidth(0.45)
+		p.Line(x+0.9, top+1.7, x+1.7, top+2.6)
+		p.Line(x+1.7, top+2.6, x+3.1, top+0.6)
+	}
+}
+
+func (r *renderer) quote(n *html.Node) {
+	p := r.pdf
+	startY, page := p.GetY(), p.PageNo()
+	saved := r.style
+	r.style.gray = true
+
+	r.indent += 5
+	r.blocks(children(n))
+	r.indent -= 5
+	r.style = saved
+
+	if p.PageNo() == page {
+		x := margin + r.indent + 1.5
+		p.SetDrawColor(200, 200, 200)
+		p.SetLineWidth(0.8)
+		p.Line(x, startY, x, p.GetY()-1.5)
+	}
+	p.SetLeftMargin(margin + r.indent)
+}
+
+func (r *renderer) code(n *html.Node) {
+	p := r.pdf
+	text := strings.TrimRight(strings.ReplaceAll(textContent(n), "\t", "    "), "\n")
+
+	r.startBlock()
+	p.SetFont(monoFamily, "", monoSize)
+	p.SetTextColor(40, 40, 40)
+	p.SetFillColor(245, 245, 245)
+	p.MultiCell(r.width(), 4.5, r.tr(text), "", "L", true)
+	p.Ln(2)
+}
+
+func (r *renderer) table(n *html.Node) {
+	var header []string
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for _, child := range children(n) {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "thead", "tbody", "tfoot":
+				walk(child)
+			case "tr":
+				var cells []string
+				isHeader := true
+				for _, cell := range children(child) {
+					if cell.Type == html.ElementNode && (cell.Data == "th" || cell.Data == "td") {
+						cells = append(cells, strings.TrimSpace(whitespace.ReplaceAllString(textContent(cell), " ")))
+						isHeader = isHeader && cell.Data == "th"
+					}
+				}
+				if isHeader && header == nil && len(rows) == 0 {
+					header = cells
+				} else {
+					rows = append(rows, cells)
+				}
+			}
+		}
+	}
+	walk(n)
+
+	columns := len(header)
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	if columns == 0 {
+		return
+	}
+
+	r.startBlock()
+	r.grid(header, rows, columnWidths(columns))
+}
+
+func columnWidths(columns int) []float64 {
+	widths := make([]float64, columns)
+	for i := range widths {
+		widths[i] = 1 / float64(columns)
+	}
+	return widths
+}
+
+func (r *renderer) inlines(nodes []*html.Node) {
+	for _, n := range nodes {
+		r.inline(n)
+	}
+}
+
+func (r *renderer) inline(n *html.Node) {
+	p := r.pdf
+	if n.Type == html.TextNode {
+		text := whitespace.ReplaceAllString(n.Data, " ")
+		if r.atLineStart {
+			text = strings.TrimLeft(text, " ")
+		}
+		if text == "" {
+			return
+		}
+		r.write(text)
+		return
+	}
+	if n.Type != html.ElementNode {
+		return
+	}
+
+	saved := r.style
+	defer func() {
+		r.style = saved
+		r.style.apply(p)
+	}()
+
+	switch n.Data {
+	case "strong", "b":
+		r.style.bold = true
+	case "em", "i":
+		r.style.italic = true
+	case "u":
+		r.style.underline = true
+	case "s", "del", "strike":
+		r.style.strike = true
+	case "code":
+		r.style.mono = true
+		r.style.size = monoSize
+	case "a":
+		r.style.link = attr(n, "href")
+	case "br":
+		p.Ln(lineHeight)
+		r.atLineStart = true
+		return
+	case "img":
+		r.image(n)
+		return
+	case "input", "label", "script", "style":
+		return
+	}
+	r.style.apply(p)
+	r.inlines(children(n))
+}
+
+func (r *renderer) write(text string) {
+	height := lineHeight
+	if r.style.size > bodySize {
+		height = r.style.size * 0.5
+	}
+	if r.style.link != "" {
+		r.pdf.WriteLinkString(height, r.tr(text), r.style.link)
+	} else {
+		r.pdf.Write(height, r.tr(text))
+	}
+	r.atLineStart = false
+}
+
+// image embeds a local PNG, JPEG or GIF on its own line, scaled to fit the
+// text width. Anything else is printed as its alt text.
+func (r *renderer) image(n *html.Node) {
+	p := r.pdf
+	path := ""
+	if r.imagePath != nil {
+		path = r.imagePath(attr(n, "src"))
+	}
+	format, width, height := probeImage(path)
+	if format == "" {
+		saved := r.style
+		r.style.italic = true
+		r.style.apply(p)
+		alt := attr(n, "alt")
+		if alt == "" {
+			alt = "image"
+		}
+		r.write("[" + alt + "]")
+		r.style = saved
+		return
+	}
+
+	if !r.atLineStart {
+		p.Ln(lineHeight)
+	}
+	// Treat pixels as 96 dpi, and never wider than the text column
+	w := float64(width) * 25.4 / 96
+	if w > r.width() {
+		w = r.width()
+	}
+	h := w * float64(height) / float64(width)
+
+	_, pageHeight := p.GetPageSize()
+	if p.GetY()+h > pageHeight-margin {
+		p.AddPage()
+	}
+	y := p.GetY()
+	p.ImageOptions(path, margin+r.indent, y, w, h, false, fpdf.ImageOptions{ImageType: format}, 0, "")
+	p.SetXY(margin+r.indent, y+h+1)
+	r.atLineStart = true
+}
+
+// probeImage returns the fpdf image type and pixel size of a file, or an
+// empty type when it is missing or not a format fpdf can embed. Checking
+// first matters because a bad image puts fpdf into a permanent error state.
+func probeImage(path string) (string, int, int) {
+	if path == "" {
+		return "", 0, 0
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, 0
+	}
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 {
+		return "", 0, 0
+	}
+	switch format {
+	case "png", "jpeg", "gif":
+		return format, cfg.Width, cfg.Height
+	}
+	return "", 0, 0
+}
+
+func hasContent(nodes []*html.Node) bool {
+	for _, n := range nodes {
+		if n.Type == html.ElementNode && (n.Data == "img" || n.Data == "br") {
+			return true
+		}
+		if strings.TrimSpace(textContent(n)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func children(n *html.Node) []*html.Node {
+	var out []*html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		out = append(out, child)
+	}
+	return out
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
@@ -0,0 +1,304 @@
+// Package pdf renders notes as printable PDF documents: a title block with
+// account and meeting details, the rich-text content and tables of linked
+// todos and attachments.
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/go-pdf/fpdf"
+)
+
+// Page layout, in millimetres
+const (
+	margin     = 18.0
+	lineHeight = 5.5
+	fontFamily = "Helvetica"
+	monoFamily = "Courier"
+)
+
+// Note is what gets printed for a note
+type Note struct {
+	Note        *models.Note
+	Tags        []models.Tag
+	Attachments []models.Attachment
+	// Minimal prints only the title, account name and content
+	Minimal bool
+	// ImagePath maps an <img> src to a local file. Images it cannot map are
+	// printed as their alt text.
+	ImagePath func(src string) string
+}
+
+// Render writes the note as a PDF
+func Render(w io.Writer, doc Note) error {
+	r := newRenderer(doc.ImagePath)
+	n := doc.Note
+
+	r.titleBlock(n, doc.Minimal)
+	if !doc.Minimal {
+		r.details(n, doc.Tags)
+	}
+
+	r.section("Notes")
+	r.content(n.Content)
+
+	if !doc.Minimal {
+		r.todos(n.Todos)
+		r.attachments(doc.Attachments)
+	}
+
+	return r.pdf.Output(w)
+}
+
+func newRenderer(imagePath func(string) string) *renderer {
+	p := fpdf.New("P", "mm", "A4", "")
+	p.SetMargins(margin, margin, margin)
+	p.SetAutoPageBreak(true, margin)
+	p.AliasNbPages("")
+
+	r := &renderer{pdf: p, tr: p.UnicodeTranslatorFromDescriptor(""), imagePath: imagePath}
+	generated := time.Now().Format("Jan 2, 2006")
+	p.SetFooterFunc(func() {
+		p.SetY(-12)
+		p.SetFont(fontFamily, "", 8)
+		p.SetTextColor(140, 140, 140)
+		p.CellFormat(0, 5, "Exported "+generated, "", 0, "L", false, 0, "")
+		p.CellFormat(0, 5, fmt.Sprintf("Page %d of {nb}", p.PageNo()), "", 0, "R", false, 0, "")
+	})
+	p.AddPage()
+	return r
+}
+
+// renderer wraps fpdf with the current indentation and text style
+type renderer struct {
+	pdf       *fpdf.Fpdf
+	tr        func(string) string
+	imagePath func(string) string
+
+	indent      float64
+	style       textStyle
+	afterMarker bool
+	atLineStart bool
+}
+
+func (r *renderer) width() float64 {
+	pageWidth, _ := r.pdf.GetPageSize()
+	return pageWidth - 2*margin - r.indent
+}
+
+func (r *renderer) titleBlock(n *models.Note, minimal bool) {
+	p := r.pdf
+	p.SetFont(fontFamily, "B", 20)
+	p.SetTextColor(20, 20, 20)
+	p.MultiCell(0, 9, r.tr(n.Title), "", "L", false)
+
+	var subtitle []string
+	if n.AccountName != "" {
+		subtitle = append(subtitle, n.AccountName)
+	}
+	if n.MeetingDate != nil && !minimal {
+		subtitle = append(subtitle, n.MeetingDate.Format("Monday, January 2, 2006 15:04"))
+	}
+	if len(subtitle) > 0 {
+		p.SetFont(fontFamily, "", 11)
+		p.SetTextColor(100, 100, 100)
+		p.MultiCell(0, 6, r.tr(strings.Join(subtitle, "  |  ")), "", "L", false)
+	}
+	p.Ln(2)
+	r.rule()
+}
+
+func (r *renderer) details(n *models.Note, tags []models.Tag) {
+	var rows [][2]string
+	if a := n.Account; a != nil {
+		if a.AccountOwner != "" {
+			rows = append(rows, [2]string{"Account owner", a.AccountOwner})
+		}
+		if a.Budget != nil {
+			rows = append(rows, [2]string{"Budget", formatMoney(*a.Budget)})
+		}
+		if a.EstEngineers != nil {
+			rows = append(rows, [2]string{"Est. engineers", fmt.Sprint(*a.EstEngineers)})
+		}
+	}
+	if len(n.InternalParticipants) > 0 {
+		rows = append(rows, [2]string{"Internal", strings.Join(n.InternalParticipants, ", ")})
+	}
+	if len(n.ExternalParticipants) > 0 {
+		rows = append(rows, [2]string{"External", strings.Join(n.ExternalParticipants, ", ")})
+	}
+	if len(tags) > 0 {
+		names := make([]string, len(tags))
+		for i, t := range tags {
+			names[i] = t.Name
+		}
+		rows = append(rows, [2]string{"Tags", strings.Join(names, ", ")})
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	r.section("Details")
+	p := r.pdf
+	for _, row := range rows {
+		p.SetFont(fontFamily, "B", 10)
+		p.SetTextColor(90, 90, 90)
+		p.CellFormat(35, lineHeight, r.tr(row[0]), "", 0, "L", false, 0, "")
+		p.SetFont(fontFamily, "", 10)
+		p.SetTextColor(30, 30, 30)
+		p.MultiCell(0, lineHeight, r.tr(row[1]), "", "L", false)
+	}
+}
+
+func (r *renderer) todos(todos []models.Todo) {
+	if len(todos) == 0 {
+		return
+	}
+
+	r.section("Linked Todos")
+	rows := [][]string{}
+	for _, t := range todos {
+		due := ""
+		if t.DueDate != nil {
+			due = t.DueDate.Format("Jan 2, 2006")
+		}
+		rows = append(rows, []string{t.Title, humanize(t.Status), humanize(t.Priority), due})
+	}
+	r.grid([]string{"Todo", "Status", "Priority", "Due"}, rows, []float64{0.52, 0.18, 0.14, 0.16})
+}
+
+func (r *renderer) attachments(attachments []models.Attachment) {
+	if len(attachments) == 0 {
+		return
+	}
+
+	r.section("Attachments")
+	rows := [][]string{}
+	for _, a := range attachments {
+		rows = append(rows, []string{a.OriginalName, a.MimeType, formatSize(a.Size)})
+	}
+	r.grid([]string{"File", "Type", "Size"}, rows, []float64{0.6, 0.25, 0.15})
+}
+
+// section starts a titled block with a rule underneath
+func (r *renderer) section(title string) {
+	p := r.pdf
+	p.Ln(4)
+	p.SetFont(fontFamily, "B", 13)
+	p.SetTextColor(20, 20, 20)
+	p.CellFormat(0, 7, r.tr(title), "", 1, "L", false, 0, "")
+	r.rule()
+	p.Ln(1)
+}
+
+func (r *renderer) rule() {
+	p := r.pdf
+	pageWidth, _ := p.GetPageSize()
+	p.SetDrawColor(210, 210, 210)
+	p.SetLineWidth(0.3)
+	p.Line(margin+r.indent, p.GetY(), pageWidth-margin, p.GetY())
+	p.Ln(2)
+}
+
+// grid draws a table with an optional shaded header row. widths are
+// fractions of the available width; cells wrap and rows break across pages
+// as a unit.
+func (r *renderer) grid(header []string, rows [][]string, widths []float64) {
+	p := r.pdf
+	total := r.width()
+	cols := make([]float64, len(widths))
+	for i, fraction := range widths {
+		cols[i] = fraction * total
+	}
+
+	p.SetDrawColor(200, 200, 200)
+	p.SetLineWidth(0.2)
+	if header != nil {
+		r.gridRow(header, cols, true)
+	}
+	for _, row := range rows {
+		r.gridRow(row, cols, false)
+	}
+	p.Ln(2)
+}
+
+func (r *renderer) gridRow(cells []string, cols []float64, header bool) {
+	p := r.pdf
+	const cellLine = 5.0
+	style := ""
+	if header {
+		style = "B"
+	}
+	p.SetFont(fontFamily, style, 9.5)
+
+	lines := 1
+	for i, cell := range cells {
+		if i >= len(cols) {
+			break
+		}
+		if n := len(p.SplitText(r.tr(cell), cols[i]-2)); n > lines {
+			lines = n
+		}
+	}
+	height := float64(lines)*cellLine + 2
+
+	_, pageHeight := p.GetPageSize()
+	if p.GetY()+height > pageHeight-margin {
+		p.AddPage()
+	}
+
+	x, y := margin+r.indent, p.GetY()
+	for i, width := range cols {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		if header {
+			p.SetFillColor(243, 244, 246)
+			p.Rect(x, y, width, height, "FD")
+			p.SetTextColor(60, 60, 60)
+		} else {
+			p.Rect(x, y, width, height, "D")
+			p.SetTextColor(30, 30, 30)
+		}
+		p.SetXY(x+1, y+1)
+		p.MultiCell(width-2, cellLine, r.tr(cell), "", "L", false)
+		x += width
+	}
+	p.SetXY(margin+r.indent, y+height)
+}
+
+func formatMoney(amount float64) string {
+	whole := fmt.Sprintf("%.0f", amount)
+	var sb strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 && whole[i-1] != '-' {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(digit)
+	}
+	return "$" + sb.String()
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.0f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// humanize turns a status like "in_progress" into "In progress"
+func humanize(s string) string {
+	if s == "" {
+		return ""
+	}
+	s = strings.ReplaceAll(s, "_", " ")
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,68 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderNote(t *testing.T) {
+	dir := t.TempDir()
+	imagePath := filepath.Join(dir, "chart.png")
+	f, err := os.Create(imagePath)
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(f, image.NewRGBA(image.Rect(0, 0, 40, 20))))
+	f.Close()
+
+	budget := 250000.0
+	meeting := time.Date(2024, 5, 2, 16, 0, 0, 0, time.UTC)
+	due := meeting.AddDate(0, 0, 7)
+	note := &models.Note{
+		Title:                "Pricing review – Q2",
+		AccountName:          "Globex",
+		Account:              &models.Account{AccountOwner: "Dana", Budget: &budget},
+		MeetingDate:          &meeting,
+		InternalParticipants: []string{"me@company.com"},
+		ExternalParticipants: []string{"cfo@globex.com"},
+		Content: `<h2>Agenda</h2><p>Intro with <strong>bold</strong>, <em>italic</em>, <code>code</code> and a <a href="https://example.com">link</a>.</p>` +
+			`<ul data-type="taskList"><li data-type="taskItem" data-checked="true"><p>Send deck</p></li><li data-type="taskItem" data-checked="false"><p>Follow up</p></li></ul>` +
+			`<ol><li><p>One</p><ul><li><p>Nested</p></li></ul></li></ol><blockquote><p>Quote</p></blockquote>` +
+			`<pre><code>x := 1</code></pre><hr><table><tr><th>A</th><th>B</th></tr><tr><td>1</td><td>2</td></tr></table>` +
+			`<img src="/uploads/chart.png" alt="chart"><img src="https://example.com/remote.png" alt="remote">`,
+		Todos: []models.Todo{{Title: "Draft quote", Status: "in_progress", Priority: "high", DueDate: &due}},
+	}
+
+	var buf bytes.Buffer
+	err = Render(&buf, Note{
+		Note:        note,
+		Tags:        []models.Tag{{Name: "pricing"}},
+		Attachments: []models.Attachment{{OriginalName: "deck.pdf", MimeType: "application/pdf", Size: 204800}},
+		ImagePath: func(src string) string {
+			return filepath.Join(dir, strings.TrimPrefix(src, "/uploads/"))
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+	// The embedded PNG shows up as an image XObject
+	assert.Contains(t, buf.String(), "/Subtype /Image")
+
+	buf.Reset()
+	require.NoError(t, Render(&buf, Note{Note: note, Minimal: true}))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+}
+
+func TestFormatting(t *testing.T) {
+	assert.Equal(t, "$1,250,000", formatMoney(1250000))
+	assert.Equal(t, "$950", formatMoney(950))
+	assert.Equal(t, "1.5 MB", formatSize(3<<19))
+	assert.Equal(t, "In progress", humanize("in_progress"))
+}
@@ -0,0 +1,311 @@
+// Package recur reads the subset of iCalendar recurrence rules (RFC 5545
+// RRULE) that recurring todos use: daily, weekly and monthly frequencies
+// with an interval, weekdays, and an end date or occurrence count.
+//
+//	FREQ=WEEKLY;BYDAY=MO,TH
+//	FREQ=MONTHLY;INTERVAL=3;BYDAY=-1FR;COUNT=4
+//	FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;UNTIL=20261231
+package recur
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Freq is how often a rule repeats
+type Freq string
+
+const (
+	Daily   Freq = "DAILY"
+	Weekly  Freq = "WEEKLY"
+	Monthly Freq = "MONTHLY"
+)
+
+// Day is a weekday in BYDAY. N picks the Nth such day of the month, counting
+// from the end when negative; 0 means every one.
+type Day struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// Rule is a parsed recurrence rule. The first occurrence is the todo's due
+// date, which also sets the time of day and, without BYDAY, the weekday or
+// day of the month.
+type Rule struct {
+	Freq     Freq
+	Interval int
+	ByDay    []Day
+	// Until is the last moment an occurrence can fall on
+	Until *time.Time
+	// Count is the number of occurrences, 0 for no limit
+	Count int
+}
+
+// maxPeriods bounds the search for the next occurrence, so that a rule that
+// can never match, such as the 5th Monday every 12 months, ends
+const maxPeriods = 1000
+
+var dayNames = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;BYDAY=MO". An "RRULE:" prefix is
+// allowed. UNTIL takes a date (YYYYMMDD, the end of that day in local time)
+// or a UTC time (YYYYMMDDTHHMMSSZ).
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("empty rule")
+	}
+
+	r := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%q is not NAME=VALUE", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s given twice", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			switch f := Freq(value); f {
+			case Daily, Weekly, Monthly:
+				r.Freq = f
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q, expected DAILY, WEEKLY or MONTHLY", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("INTERVAL must be a positive number")
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("COUNT must be a positive number")
+			}
+			r.Count = n
+		case "UNTIL":
+			t, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = &t
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				d, err := parseDay(v)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, d)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported part %s", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, errors.New("use either COUNT or UNTIL, not both")
+	}
+	if r.Freq != Monthly {
+		for _, d := range r.ByDay {
+			if d.N != 0 {
+				return nil, errors.New("numbered BYDAY days such as 1MO need FREQ=MONTHLY")
+			}
+		}
+	}
+	return r, nil
+}
+
+func parseUntil(v string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102", v, time.Local); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("UNTIL %q must be YYYYMMDD or YYYYMMDDTHHMMSSZ", v)
+}
+
+func parseDay(v string) (Day, error) {
+	v = strings.TrimSpace(v)
+	if len(v) < 2 {
+		return Day{}, fmt.Errorf("invalid BYDAY %q", v)
+	}
+	weekday, ok := dayNames[v[len(v)-2:]]
+	if !ok {
+		return Day{}, fmt.Errorf("invalid BYDAY %q", v)
+	}
+	d := Day{Weekday: weekday}
+	if prefix := v[:len(v)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return Day{}, fmt.Errorf("invalid BYDAY %q", v)
+		}
+		d.N = n
+	}
+	return d, nil
+}
+
+// String formats the rule in canonical form
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = d.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+func (d Day) String() string {
+	name := strings.ToUpper(d.Weekday.String()[:2])
+	if d.N != 0 {
+		return strconv.Itoa(d.N) + name
+	}
+	return name
+}
+
+// Next returns the occurrence after prev, which is occurrence n counting
+// from 1. It returns false once the rule has run its count or passed its end.
+func (r *Rule) Next(prev time.Time, n int) (time.Time, bool) {
+	if r.Count > 0 && n >= r.Count {
+		return time.Time{}, false
+	}
+	var next time.Time
+	var ok bool
+	switch r.Freq {
+	case Daily:
+		next, ok = r.nextDaily(prev)
+	case Weekly:
+		next, ok = r.nextWeekly(prev)
+	case Monthly:
+		next, ok = r.nextMonthly(prev)
+	}
+	if !ok || (r.Until != nil && next.After(*r.Until)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func (r *Rule) matches(weekday time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) nextDaily(prev time.Time) (time.Time, bool) {
+	for i := 1; i <= maxPeriods; i++ {
+		if t := prev.AddDate(0, 0, i*r.Interval); r.matches(t.Weekday()) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// nextWeekly looks for the next listed weekday in prev's week, then in the
+// weeks the interval lands on. Weeks start on Monday.
+func (r *Rule) nextWeekly(prev time.Time) (time.Time, bool) {
+	weekdays := []time.Weekday{prev.Weekday()}
+	if len(r.ByDay) > 0 {
+		weekdays = weekdays[:0]
+		for _, d := range r.ByDay {
+			weekdays = append(weekdays, d.Weekday)
+		}
+	}
+	offsets := make([]int, len(weekdays))
+	for i, w := range weekdays {
+		offsets[i] = (int(w) + 6) % 7
+	}
+	sort.Ints(offsets)
+
+	start := prev.AddDate(0, 0, -((int(prev.Weekday()) + 6) % 7))
+	for week := 0; week <= maxPeriods; week += r.Interval {
+		for _, off := range offsets {
+			if t := start.AddDate(0, 0, week*7+off); t.After(prev) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// nextMonthly keeps prev's day of the month, skipping months too short for
+// it, or with BYDAY looks for the listed weekdays in prev's month and then
+// in the months the interval lands on
+func (r *Rule) nextMonthly(prev time.Time) (time.Time, bool) {
+	y, m, day := prev.Date()
+	h, min, sec := prev.Clock()
+	for month := 0; month <= maxPeriods; month += r.Interval {
+		first := time.Date(y, m+time.Month(month), 1, h, min, sec, prev.Nanosecond(), prev.Location())
+		if len(r.ByDay) == 0 {
+			if t := first.AddDate(0, 0, day-1); t.Month() == first.Month() && t.After(prev) {
+				return t, true
+			}
+			continue
+		}
+		for _, t := range r.monthDays(first) {
+			if t.After(prev) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// monthDays lists the days of first's month that BYDAY picks, in order
+func (r *Rule) monthDays(first time.Time) []time.Time {
+	var all []time.Time
+	for t := first; t.Month() == first.Month(); t = t.AddDate(0, 0, 1) {
+		all = append(all, t)
+	}
+
+	var days []time.Time
+	for _, t := range all {
+		for _, d := range r.ByDay {
+			if d.Weekday != t.Weekday() {
+				continue
+			}
+			// Position of t among the same weekdays, from the start and end
+			nth := (t.Day()-1)/7 + 1
+			fromEnd := -((len(all)-t.Day())/7 + 1)
+			if d.N == 0 || d.N == nth || d.N == fromEnd {
+				days = append(days, t)
+				break
+			}
+		}
+	}
+	return days
+}
@@ -0,0 +1,79 @@
+package recur
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse("RRULE:freq=weekly; interval=2; byday=MO,th; count=5")
+	require.NoError(t, err)
+	assert.Equal(t, Weekly, r.Freq)
+	assert.Equal(t, 2, r.Interval)
+	assert.Equal(t, []Day{{Weekday: time.Monday}, {Weekday: time.Thursday}}, r.ByDay)
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=5", r.String())
+
+	r, err = Parse("FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20261231T170000Z")
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20261231T170000Z", r.String())
+
+	for _, bad := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20260101",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=15",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;UNTIL=tomorrow",
+	} {
+		_, err := Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestNext(t *testing.T) {
+	at := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 9, 30, 0, 0, time.UTC) }
+	// 2026-03-02 is a Monday
+	tests := []struct {
+		rule string
+		from time.Time
+		want []time.Time
+	}{
+		{"FREQ=DAILY;INTERVAL=2", at(2026, 3, 2), []time.Time{at(2026, 3, 4), at(2026, 3, 6), at(2026, 3, 8)}},
+		{"FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", at(2026, 3, 5), []time.Time{at(2026, 3, 6), at(2026, 3, 9), at(2026, 3, 10)}},
+		{"FREQ=WEEKLY", at(2026, 3, 4), []time.Time{at(2026, 3, 11), at(2026, 3, 18)}},
+		{"FREQ=WEEKLY;BYDAY=MO,TH", at(2026, 3, 2), []time.Time{at(2026, 3, 5), at(2026, 3, 9), at(2026, 3, 12)}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,SU", at(2026, 3, 3), []time.Time{at(2026, 3, 8), at(2026, 3, 17), at(2026, 3, 22)}},
+		{"FREQ=MONTHLY", at(2026, 1, 31), []time.Time{at(2026, 3, 31), at(2026, 5, 31), at(2026, 7, 31)}},
+		{"FREQ=MONTHLY;INTERVAL=3", at(2026, 1, 15), []time.Time{at(2026, 4, 15), at(2026, 7, 15)}},
+		{"FREQ=MONTHLY;BYDAY=1MO", at(2026, 3, 2), []time.Time{at(2026, 4, 6), at(2026, 5, 4)}},
+		{"FREQ=MONTHLY;BYDAY=-1FR", at(2026, 3, 27), []time.Time{at(2026, 4, 24), at(2026, 5, 29)}},
+		{"FREQ=WEEKLY;COUNT=3", at(2026, 3, 2), []time.Time{at(2026, 3, 9), at(2026, 3, 16)}},
+		{"FREQ=WEEKLY;UNTIL=20260316", at(2026, 3, 2), []time.Time{at(2026, 3, 9), at(2026, 3, 16)}},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.rule)
+		require.NoError(t, err, tt.rule)
+
+		var got []time.Time
+		prev := tt.from
+		for n := 1; n <= len(tt.want)+1; n++ {
+			next, ok := r.Next(prev, n)
+			if !ok {
+				break
+			}
+			got = append(got, next)
+			prev = next
+			if len(got) == len(tt.want) && r.Count == 0 && r.Until == nil {
+				break
+			}
+		}
+		assert.Equal(t, tt.want, got, tt.rule)
+	}
+}
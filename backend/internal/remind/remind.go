@@ -0,0 +1,185 @@
+// Package remind fires todo reminders. A Scheduler polls for reminders that
+// have come due and hands each one to every registered Notifier: a webhook,
+// the Server-Sent Events stream of the web UI, or, in the desktop app, a
+// native notification.
+package remind
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+)
+
+// Notifier delivers a reminder to the user
+type Notifier interface {
+	Notify(r models.Reminder) error
+}
+
+// NotifierFunc adapts a function to a Notifier
+type NotifierFunc func(r models.Reminder) error
+
+func (f NotifierFunc) Notify(r models.Reminder) error { return f(r) }
+
+// Source finds reminders and records the ones that fired. store.ReminderStore
+// satisfies it.
+type Source interface {
+	Pending(now, until time.Time, defaults []int) ([]models.Reminder, error)
+	MarkFired(todoID string, leadMinutes int, at time.Time) error
+}
+
+// Scheduler fires reminders as they come due
+type Scheduler struct {
+	source Source
+	// defaults are the lead times, in minutes, of todos without their own
+	defaults []int
+
+	mu        sync.RWMutex
+	notifiers []Notifier
+}
+
+// NewScheduler returns a Scheduler reading from source
+func NewScheduler(source Source, defaults []int, notifiers ...Notifier) *Scheduler {
+	return &Scheduler{source: source, defaults: defaults, notifiers: notifiers}
+}
+
+// Defaults returns the lead times of todos without their own
+func (s *Scheduler) Defaults() []int {
+	return s.defaults
+}
+
+// Add registers another notifier
+func (s *Scheduler) Add(n Notifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifiers = append(s.notifiers, n)
+}
+
+// Upcoming returns the reminders that will fire by until, earliest first
+func (s *Scheduler) Upcoming(now, until time.Time) ([]models.Reminder, error) {
+	return s.source.Pending(now, until, s.defaults)
+}
+
+// Fire notifies every reminder due at now and marks it fired. A reminder is
+// marked fired even if a notifier fails, so that a broken webhook does not
+// repeat it every tick; the failures are passed to onError.
+func (s *Scheduler) Fire(now time.Time, onError func(error)) (int, error) {
+	due, err := s.source.Pending(now, now, s.defaults)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	notifiers := append([]Notifier{}, s.notifiers...)
+	s.mu.RUnlock()
+
+	for _, r := range due {
+		for _, n := range notifiers {
+			if err := n.Notify(r); err != nil && onError != nil {
+				onError(fmt.Errorf("reminder for todo %s: %w", r.TodoID, err))
+			}
+		}
+		if err := s.source.MarkFired(r.TodoID, r.LeadMinutes, now); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// Run fires due reminders every interval until stop is closed
+func (s *Scheduler) Run(interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Fire(time.Now(), onError); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Webhook posts each reminder as JSON to a URL
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook returns a Webhook posting to url with a short timeout
+func NewWebhook(url string) *Webhook {
+	return &Webhook{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *Webhook) Notify(r models.Reminder) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	resp, err := w.Client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// subscriberBuffer is how many reminders a slow subscriber can fall behind
+// before it misses some
+const subscriberBuffer = 16
+
+// Broadcaster passes reminders on to every current subscriber, such as the
+// open Server-Sent Events connections
+type Broadcaster struct {
+	mu   sync.Mutex
+	subs map[chan models.Reminder]struct{}
+}
+
+// NewBroadcaster returns a Broadcaster without subscribers
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subs: map[chan models.Reminder]struct{}{}}
+}
+
+// Subscribe returns a channel of reminders and a function that ends the
+// subscription
+func (b *Broadcaster) Subscribe() (<-chan models.Reminder, func()) {
+	ch := make(chan models.Reminder, subscriberBuffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Notify never blocks; a subscriber whose buffer is full misses the reminder
+func (b *Broadcaster) Notify(r models.Reminder) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package remind
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource holds reminders in memory
+type fakeSource struct {
+	reminders []models.Reminder
+	fired     map[string]time.Time
+}
+
+func (f *fakeSource) Pending(now, until time.Time, defaults []int) ([]models.Reminder, error) {
+	var due []models.Reminder
+	for _, r := range f.reminders {
+		if _, ok := f.fired[r.TodoID]; !ok && !r.RemindAt.After(until) {
+			due = append(due, r)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakeSource) MarkFired(todoID string, leadMinutes int, at time.Time) error {
+	f.fired[todoID] = at
+	return nil
+}
+
+func TestSchedulerFire(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	source := &fakeSource{
+		reminders: []models.Reminder{
+			{TodoID: "due", RemindAt: now.Add(-time.Minute)},
+			{TodoID: "later", RemindAt: now.Add(time.Hour)},
+		},
+		fired: map[string]time.Time{},
+	}
+
+	var got []string
+	s := NewScheduler(source, []int{60}, NotifierFunc(func(r models.Reminder) error {
+		got = append(got, r.TodoID)
+		return nil
+	}))
+	var errs []error
+	s.Add(NotifierFunc(func(r models.Reminder) error { return errors.New("offline") }))
+
+	n, err := s.Fire(now, func(err error) { errs = append(errs, err) })
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"due"}, got)
+	assert.Len(t, errs, 1, "a failing notifier is reported")
+	assert.Equal(t, now, source.fired["due"], "marked fired despite the failure")
+
+	n, err = s.Fire(now, nil)
+	require.NoError(t, err)
+	assert.Zero(t, n, "fires once")
+
+	upcoming, err := s.Upcoming(now, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, upcoming, 1)
+}
+
+func TestWebhook(t *testing.T) {
+	var got models.Reminder
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.TodoID == "fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(srv.URL)
+	require.NoError(t, w.Notify(models.Reminder{TodoID: "todo-1", Title: "Call Acme", LeadMinutes: 15}))
+	assert.Equal(t, "Call Acme", got.Title)
+	assert.Equal(t, 15, got.LeadMinutes)
+	assert.Error(t, w.Notify(models.Reminder{TodoID: "fail"}))
+}
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster()
+	ch, cancel := b.Subscribe()
+
+	require.NoError(t, b.Notify(models.Reminder{TodoID: "todo-1"}))
+	assert.Equal(t, "todo-1", (<-ch).TodoID)
+
+	// A full subscriber does not hold up the others
+	for i := 0; i < subscriberBuffer+5; i++ {
+		b.Notify(models.Reminder{})
+	}
+	assert.Len(t, ch, subscriberBuffer)
+
+	cancel()
+	cancel()
+	assert.Empty(t, b.subs)
+}
@@ -0,0 +1,288 @@
+package search
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// The index holds one document per note, todo, account, contact and
+// attachment in search_index, with search_docs mapping its rowid to the row.
+// Triggers queue every row a change affects in search_queue, and Sync
+// rebuilds the queued documents, so the text can be prepared in Go: note
+// content is stripped of markup, notes also carry their tags, attachments
+// and the names of their participants, and attachments carry the text
+// extracted from their files.
+
+// syncMu keeps two syncs from claiming the queue at once
+var syncMu sync.Mutex
+
+// indexed is the text a document is searched by
+type indexed struct {
+	title, body, extra string
+}
+
+// loaders read the text of a document, reporting false when its row is gone
+var loaders = map[string]func(tx *sql.Tx, id string) (indexed, bool, error){
+	TypeNote:       loadNote,
+	TypeTodo:       loadTodo,
+	TypeAccount:    loadAccount,
+	TypeContact:    loadContact,
+	TypeAttachment: loadAttachment,
+}
+
+// Sync indexes the rows queued since the last sync
+func Sync(db *sql.DB) error {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+
+	var pending bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM search_queue)").Scan(&pending); err != nil || !pending {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Claiming the queue is the first write, so the transaction holds the
+	// write lock before any row is read and nothing can change under it
+	rows, err := tx.Query("DELETE FROM search_queue RETURNING type, id")
+	if err != nil {
+		return err
+	}
+	type key struct{ typ, id string }
+	var keys []key
+	for rows.Next() {
+		var k key
+		if err := rows.Scan(&k.typ, &k.id); err != nil {
+			rows.Close()
+			return err
+		}
+		keys = append(keys, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := index(tx, k.typ, k.id); err != nil {
+			return fmt.Errorf("indexing %s %s: %w", k.typ, k.id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Reindex rebuilds every document and returns how many there are of each
+// type
+func Reindex(db *sql.DB) (map[string]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		`DELETE FROM search_index`,
+		`DELETE FROM search_docs`,
+		`INSERT OR IGNORE INTO search_queue (type, id)
+			SELECT 'note', id FROM notes UNION ALL
+			SELECT 'todo', id FROM todos UNION ALL
+			SELECT 'account', id FROM accounts UNION ALL
+			SELECT 'contact', id FROM contacts UNION ALL
+			SELECT 'attachment', id FROM attachments`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if err := Sync(db); err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, typ := range Types {
+		counts[typ] = 0
+	}
+	rows, err := db.Query("SELECT type, COUNT(*) FROM search_docs GROUP BY type")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var typ string
+		var n int
+		if err := rows.Scan(&typ, &n); err != nil {
+			return nil, err
+		}
+		counts[typ] = n
+	}
+	return counts, rows.Err()
+}
+
+// index rebuilds the document for one row, or removes it if the row is gone
+func index(tx *sql.Tx, typ, id string) error {
+	load, ok := loaders[typ]
+	if !ok {
+		return fmt.Errorf("unknown type %q", typ)
+	}
+	doc, live, err := load(tx, id)
+	if err != nil {
+		return err
+	}
+
+	var rowid int64
+	err = tx.QueryRow("SELECT rowid FROM search_docs WHERE type = ? AND id = ?", typ, id).Scan(&rowid)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	// A note's embedding is computed from its title and body, so it is
+	// dropped when they change and SyncEmbeddings makes a new one
+	if typ == TypeNote {
+		var title, body string
+		if exists {
+			if err := tx.QueryRow("SELECT title, body FROM search_index WHERE rowid = ?", rowid).Scan(&title, &body); err != nil {
+				return err
+			}
+		}
+		if !exists || !live || title != doc.title || body != doc.body {
+			if _, err := tx.Exec("DELETE FROM note_embeddings WHERE note_id = ?", id); err != nil {
+				return err
+			}
+		}
+	}
+	if exists {
+		if _, err := tx.Exec("DELETE FROM search_index WHERE rowid = ?", rowid); err != nil {
+			return err
+		}
+	}
+
+	if !live {
+		if exists {
+			_, err = tx.Exec("DELETE FROM search_docs WHERE rowid = ?", rowid)
+		}
+		return err
+	}
+	if !exists {
+		res, err := tx.Exec("INSERT INTO search_docs (type, id) VALUES (?, ?)", typ, id)
+		if err != nil {
+			return err
+		}
+		if rowid, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("INSERT INTO search_index (rowid, title, body, extra) VALUES (?, ?, ?, ?)",
+		rowid, doc.title, doc.body, doc.extra)
+	return err
+}
+
+func loadNote(tx *sql.Tx, id string) (indexed, bool, error) {
+	var title string
+	var content, internal, external sql.NullString
+	err := tx.QueryRow(`SELECT title, content, internal_participants, external_participants FROM notes WHERE id = ?`, id).
+		Scan(&title, &content, &internal, &external)
+	if errors.Is(err, sql.ErrNoRows) {
+		return indexed{}, false, nil
+	}
+	if err != nil {
+		return indexed{}, false, err
+	}
+
+	participants := append(participantList(internal.String), participantList(external.String)...)
+	names, err := column(tx, `SELECT name FROM contacts WHERE deleted_at IS NULL AND name != '' AND instr(?, LOWER(email)) > 0`,
+		strings.ToLower(strings.Join(participants, " ")))
+	if err != nil {
+		return indexed{}, false, err
+	}
+	tags, err := column(tx, `SELECT t.name FROM note_tags nt JOIN tags t ON t.id = nt.tag_id WHERE nt.note_id = ? ORDER BY t.name`, id)
+	if err != nil {
+		return indexed{}, false, err
+	}
+	attachments, err := column(tx, `SELECT original_name FROM attachments WHERE note_id = ? ORDER BY created_at`, id)
+	if err != nil {
+		return indexed{}, false, err
+	}
+
+	extra := append(append(append(participants, names...), tags...), attachments...)
+	return indexed{title, plainText(content.String), strings.Join(extra, " ")}, true, nil
+}
+
+func loadTodo(tx *sql.Tx, id string) (indexed, bool, error) {
+	var d indexed
+	var description sql.NullString
+	err := tx.QueryRow(`SELECT title, description FROM todos WHERE id = ?`, id).Scan(&d.title, &description)
+	d.body = description.String
+	return found(d, err)
+}
+
+func loadAccount(tx *sql.Tx, id string) (indexed, bool, error) {
+	var d indexed
+	var owner sql.NullString
+	err := tx.QueryRow(`SELECT name, account_owner FROM accounts WHERE id = ?`, id).Scan(&d.title, &owner)
+	d.extra = owner.String
+	return found(d, err)
+}
+
+func loadContact(tx *sql.Tx, id string) (indexed, bool, error) {
+	var name, email, company sql.NullString
+	err := tx.QueryRow(`SELECT name, email, company FROM contacts WHERE id = ?`, id).Scan(&name, &email, &company)
+	d := indexed{title: name.String, extra: strings.TrimSpace(email.String + " " + company.String)}
+	if d.title == "" {
+		d.title = email.String
+	}
+	return found(d, err)
+}
+
+// loadAttachment indexes an attachment by its name and the text extracted
+// from it
+func loadAttachment(tx *sql.Tx, id string) (indexed, bool, error) {
+	var d indexed
+	var text sql.NullString
+	err := tx.QueryRow(`SELECT original_name, text FROM attachments WHERE id = ?`, id).Scan(&d.title, &text)
+	d.body = text.String
+	return found(d, err)
+}
+
+func found(d indexed, err error) (indexed, bool, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return indexed{}, false, nil
+	}
+	return d, err == nil, err
+}
+
+// column returns the first column of every row
+func column(tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// participantList reads a participants column, which holds a JSON array
+func participantList(s string) []string {
+	var list []string
+	if err := json.Unmarshal([]byte(s), &list); err != nil && s != "" {
+		return []string{s}
+	}
+	return list
+}
@@ -0,0 +1,65 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	database := setupDB(t)
+	now := time.Now()
+	exec(t, database, `INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-1', 'Acme', ?, ?)`, now, now)
+	exec(t, database, `INSERT INTO notes (id, title, account_id, content, external_participants, created_at, updated_at)
+		VALUES ('note-1', 'Kickoff', 'acc-1', '<p class="lead">Agenda <strong>review</strong></p>', '["jane@acme.com"]', ?, ?)`, now, now)
+	exec(t, database, `INSERT INTO contacts (id, email, name, company, domain, account_id) VALUES ('con-1', 'jane@acme.com', 'Jane Marlowe', 'Acme', 'acme.com', 'acc-1')`)
+	exec(t, database, `INSERT INTO tags (id, name) VALUES ('tag-1', 'Discovery')`)
+	exec(t, database, `INSERT INTO note_tags (note_id, tag_id) VALUES ('note-1', 'tag-1')`)
+	exec(t, database, `INSERT INTO attachments (id, note_id, filename, original_name, mime_type, size, text, created_at)
+		VALUES ('att-1', 'note-1', 'att-1_roadmap.pdf', 'roadmap.pdf', 'application/pdf', 10, 'Phase two covers migration', ?)`, now)
+	exec(t, database, `INSERT INTO todos (id, title, description, created_at, updated_at) VALUES ('todo-1', 'Send deck', 'Include pricing appendix', ?, ?)`, now, now)
+
+	search := func(s string) []string {
+		q, err := Parse(s)
+		require.NoError(t, err, s)
+		page, err := Search(database, q)
+		require.NoError(t, err, s)
+		return ids(page)
+	}
+
+	assert.Empty(t, search("strong"), "markup is not indexed")
+	assert.Empty(t, search("lead"))
+	assert.Equal(t, []string{"note:note-1"}, search("agenda"))
+	assert.Equal(t, []string{"note:note-1"}, search("discovery"), "tag names")
+	assert.ElementsMatch(t, []string{"note:note-1", "attachment:att-1"}, search("roadmap"), "attachment names")
+	assert.Equal(t, []string{"attachment:att-1"}, search("migration"), "attachment text")
+	assert.Equal(t, []string{"attachment:att-1"}, search("migration tag:discovery account:acme"), "attachments take their note's filters")
+	assert.Empty(t, search("migration status:open"))
+	assert.ElementsMatch(t, []string{"note:note-1", "contact:con-1"}, search("marlowe type:note,contact"), "participant names")
+	assert.Equal(t, []string{"todo:todo-1"}, search("appendix"))
+
+	// Changes reach the index through the triggers
+	exec(t, database, `UPDATE tags SET name = 'Qualification' WHERE id = 'tag-1'`)
+	exec(t, database, `UPDATE contacts SET name = 'Jane Ashby' WHERE id = 'con-1'`)
+	exec(t, database, `DELETE FROM todos WHERE id = 'todo-1'`)
+	assert.Empty(t, search("discovery"))
+	assert.Equal(t, []string{"note:note-1"}, search("qualification"))
+	assert.Equal(t, []string{"note:note-1"}, search("ashby type:note"))
+	assert.Empty(t, search("appendix"))
+	exec(t, database, `UPDATE notes SET deleted_at = ? WHERE id = 'note-1'`, now)
+	assert.Empty(t, search("migration"), "attachments of deleted notes")
+	exec(t, database, `UPDATE notes SET deleted_at = NULL WHERE id = 'note-1'`)
+	assert.Equal(t, []string{"attachment:att-1"}, search("migration"))
+
+	var queued int
+	require.NoError(t, database.QueryRow("SELECT COUNT(*) FROM search_queue").Scan(&queued))
+	assert.Zero(t, queued)
+
+	exec(t, database, `DELETE FROM search_index`)
+	counts, err := Reindex(database)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{TypeNote: 1, TypeAccount: 1, TypeTodo: 0, TypeContact: 1, TypeAttachment: 1}, counts)
+	assert.Equal(t, []string{"note:note-1"}, search("qualification"))
+}
@@ -0,0 +1,268 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Phrase is a run of consecutive words. With Prefix the last word also
+// matches longer words, as an unquoted word does.
+type Phrase struct {
+	Words  []string
+	Prefix bool
+}
+
+// in reports whether the phrase appears in the tokens
+func (ph Phrase) in(tokens []token) bool {
+	n := len(ph.Words)
+	for i := 0; i+n <= len(tokens); i++ {
+		found := true
+		for j, w := range ph.Words {
+			t := tokens[i+j].word
+			if t != w && !(ph.Prefix && j == n-1 && strings.HasPrefix(t, w)) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// Operators lists the name:value operators Parse understands
+var Operators = []string{"account", "tag", "participant", "status", "type", "template", "before", "after", "is"}
+
+// repeatable operators may be given more than once
+var repeatable = map[string]bool{"tag": true, "is": true}
+
+// SyntaxError describes a malformed query
+type SyntaxError struct {
+	// Pos is the character the problem was found at, from 1
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at character %d", e.Msg, e.Pos)
+}
+
+// Parse reads a query such as
+//
+//	account:acme tag:poc participant:jane@x.com before:2026-01-01 "exact phrase" -excluded
+//
+// Unquoted words match as word prefixes and quoted phrases as consecutive
+// whole words. A leading '-' excludes a word or phrase. Operators set the
+// filters; values with spaces can be quoted, as in account:"Acme Corp".
+// Anything else that is not a letter or digit only separates words, so no
+// input reaches the index or the database as syntax.
+func Parse(s string) (Query, error) {
+	p := &parser{s: s, seen: map[string]bool{}}
+	p.q.Archived, p.q.Deleted = Exclude, Exclude
+	for {
+		for p.pos < len(p.s) && isSpace(p.s[p.pos:]) {
+			_, size := utf8.DecodeRuneInString(p.s[p.pos:])
+			p.pos += size
+		}
+		if p.pos >= len(p.s) {
+			return p.q, nil
+		}
+		if err := p.clause(); err != nil {
+			return Query{}, err
+		}
+	}
+}
+
+type parser struct {
+	s    string
+	pos  int
+	q    Query
+	seen map[string]bool
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: utf8.RuneCountInString(p.s[:pos]) + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// clause reads one word, phrase or operator
+func (p *parser) clause() error {
+	start := p.pos
+	negate := p.s[p.pos] == '-'
+	if negate {
+		p.pos++
+	}
+
+	if p.pos < len(p.s) && p.s[p.pos] == '"' {
+		text, err := p.quoted()
+		if err != nil {
+			return err
+		}
+		p.add(Phrase{Words: Terms(text)}, negate)
+		return nil
+	}
+
+	if name, ok := p.operator(); ok {
+		if negate {
+			return p.errorf(start, "%s: can't be negated", name)
+		}
+		if p.seen[name] && !repeatable[name] {
+			return p.errorf(start, "%s: can only be used once", name)
+		}
+		p.seen[name] = true
+		value, err := p.value()
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(value) == "" {
+			return p.errorf(start, "%s: needs a value", name)
+		}
+		return p.apply(name, strings.TrimSpace(value), start)
+	}
+
+	from := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] != '"' && !isSpace(p.s[p.pos:]) {
+		p.pos++
+	}
+	p.add(Phrase{Words: Terms(p.s[from:p.pos]), Prefix: true}, negate)
+	return nil
+}
+
+// add records a word or phrase. A single unquoted word is a term; one
+// that splits into several words, like an email address, is a phrase.
+func (p *parser) add(ph Phrase, negate bool) {
+	switch {
+	case len(ph.Words) == 0:
+	case negate:
+		p.q.Excluded = append(p.q.Excluded, ph)
+	case len(ph.Words) == 1 && ph.Prefix:
+		p.q.Terms = append(p.q.Terms, ph.Words[0])
+	default:
+		p.q.Phrases = append(p.q.Phrases, ph)
+	}
+}
+
+// operator reads one of Operators followed by ':', if that is what comes
+// next. Any other name, as in https://acme.com or re:, is ordinary words.
+func (p *parser) operator() (string, bool) {
+	end := p.pos
+	for end < len(p.s) && (p.s[end] >= 'a' && p.s[end] <= 'z' || p.s[end] >= 'A' && p.s[end] <= 'Z') {
+		end++
+	}
+	if end >= len(p.s) || p.s[end] != ':' {
+		return "", false
+	}
+	name := strings.ToLower(p.s[p.pos:end])
+	if !contains(Operators, name) {
+		return "", false
+	}
+	p.pos = end + 1
+	return name, true
+}
+
+func (p *parser) value() (string, error) {
+	if p.pos < len(p.s) && p.s[p.pos] == '"' {
+		return p.quoted()
+	}
+	from := p.pos
+	for p.pos < len(p.s) && !isSpace(p.s[p.pos:]) {
+		p.pos++
+	}
+	return p.s[from:p.pos], nil
+}
+
+// quoted reads the text between a pair of double quotes
+func (p *parser) quoted() (string, error) {
+	end := strings.IndexByte(p.s[p.pos+1:], '"')
+	if end < 0 {
+		return "", p.errorf(p.pos, "unterminated quote")
+	}
+	text := p.s[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return text, nil
+}
+
+// apply sets the filter for an operator
+func (p *parser) apply(name, value string, pos int) error {
+	f := &p.q.Filters
+	switch name {
+	case "account":
+		f.Account = value
+	case "tag":
+		f.Tags = append(f.Tags, value)
+	case "participant":
+		f.Participant = value
+	case "status":
+		f.Status = strings.ToLower(value)
+	case "template":
+		f.TemplateType = value
+	case "type":
+		for _, t := range strings.Split(strings.ToLower(value), ",") {
+			if !contains(Types, t) {
+				return p.errorf(pos, "type: must be one of %s", strings.Join(Types, ", "))
+			}
+			f.Types = append(f.Types, t)
+		}
+	case "before", "after":
+		t, err := ParseDate(value)
+		if err != nil {
+			return p.errorf(pos, "%s: needs a date like 2026-01-31 or -30d", name)
+		}
+		if name == "before" {
+			f.To = &t
+		} else {
+			f.From = &t
+		}
+	case "is":
+		switch strings.ToLower(value) {
+		case "archived":
+			f.Archived = Only
+		case "deleted":
+			f.Deleted = Only
+		default:
+			return p.errorf(pos, "is: must be archived or deleted")
+		}
+	}
+	return nil
+}
+
+// relativeDate is a count of days, weeks, months or years before today
+var relativeDate = regexp.MustCompile(`^-(\d{1,4})([dwmy])$`)
+
+// ParseDate reads a date as YYYY-MM-DD, in local time, or RFC3339. "today"
+// and relative dates such as -30d, -2w, -6m and -1y are midnight local time
+// on that day, so that a saved query keeps covering the same span.
+func ParseDate(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if strings.EqualFold(v, "today") {
+		return today, nil
+	}
+	if m := relativeDate.FindStringSubmatch(strings.ToLower(v)); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "d":
+			return today.AddDate(0, 0, -n), nil
+		case "w":
+			return today.AddDate(0, 0, -7*n), nil
+		case "m":
+			return today.AddDate(0, -n, 0), nil
+		default:
+			return today.AddDate(-n, 0, 0), nil
+		}
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
+
+func isSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
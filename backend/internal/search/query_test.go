@@ -0,0 +1,123 @@
+package search
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`account:acme tag:poc tag:"Q3 Push" participant:jane@x.com before:2026-01-01 status:Stuck "Exact phrase" -excluded -"not this" deploy OR`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"deploy", "or"}, q.Terms)
+	assert.Equal(t, []Phrase{{Words: []string{"exact", "phrase"}}}, q.Phrases)
+	assert.Equal(t, []Phrase{{Words: []string{"excluded"}, Prefix: true}, {Words: []string{"not", "this"}}}, q.Excluded)
+	assert.Equal(t, "acme", q.Account)
+	assert.Equal(t, []string{"poc", "Q3 Push"}, q.Tags)
+	assert.Equal(t, "jane@x.com", q.Participant)
+	assert.Equal(t, "stuck", q.Status)
+	require.NotNil(t, q.To)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local), *q.To)
+	assert.Equal(t, Exclude, q.Archived)
+
+	q, err = Parse(`type:note,todo is:archived after:2026-02-01T10:00:00Z template:followup e-mail`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{TypeNote, TypeTodo}, q.Types)
+	assert.Equal(t, Only, q.Archived)
+	assert.Equal(t, "followup", q.TemplateType)
+	assert.Equal(t, []Phrase{{Words: []string{"e", "mail"}, Prefix: true}}, q.Phrases)
+
+	// Only the known operators are operators; other names before a colon
+	// are ordinary words
+	q, err = Parse(`https://acme.com/pricing re: pricing todo: color:red`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"re", "pricing", "todo"}, q.Terms)
+	assert.Equal(t, []Phrase{
+		{Words: []string{"https", "acme", "com", "pricing"}, Prefix: true},
+		{Words: []string{"color", "red"}, Prefix: true},
+	}, q.Phrases)
+	assert.True(t, q.Filters.IsEmpty())
+
+	q, err = Parse(`  - * ( ) `)
+	require.NoError(t, err)
+	assert.False(t, q.HasCriteria())
+}
+
+func TestParseErrors(t *testing.T) {
+	for query, msg := range map[string]string{
+		`"unterminated`:       "unterminated quote at character 1",
+		`tag:"open`:           "unterminated quote at character 5",
+		`tag:`:                "tag: needs a value at character 1",
+		`foo -tag:x`:          "tag: can't be negated at character 5",
+		`before:yesterday`:    "before: needs a date",
+		`type:email`:          "type: must be one of note, account, todo",
+		`is:done`:             "is: must be archived or deleted",
+		`status:a status:b`:   "status: can only be used once at character 10",
+		`ünïcode "still open`: "unterminated quote at character 9",
+	} {
+		_, err := Parse(query)
+		var syntax *SyntaxError
+		require.True(t, errors.As(err, &syntax), query)
+		assert.Contains(t, err.Error(), msg, query)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	for v, want := range map[string]time.Time{
+		"2026-01-31":           time.Date(2026, 1, 31, 0, 0, 0, 0, time.Local),
+		"2026-01-31T10:00:00Z": time.Date(2026, 1, 31, 10, 0, 0, 0, time.UTC),
+		"today":                today,
+		"-30d":                 today.AddDate(0, 0, -30),
+		"-2w":                  today.AddDate(0, 0, -14),
+		"-6M":                  today.AddDate(0, -6, 0),
+		"-1y":                  today.AddDate(-1, 0, 0),
+	} {
+		got, err := ParseDate(v)
+		require.NoError(t, err, v)
+		assert.True(t, want.Equal(got), "%s: got %s", v, got)
+	}
+	for _, v := range []string{"30d", "-d", "-3h", "yesterday"} {
+		_, err := ParseDate(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestSearchQueryLanguage(t *testing.T) {
+	database := setupDB(t)
+	now := time.Now()
+	exec(t, database, `INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-1', 'Acme Corp', ?, ?)`, now, now)
+	exec(t, database, `INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-2', 'Initech', ?, ?)`, now, now)
+	addNote(t, database, "exact", "Pilot review", "<p>The rollout <em>plan</em> was approved.</p>", now)
+	addNote(t, database, "apart", "Pilot plan", "<p>A plan for the rollout.</p>", now)
+	addNote(t, database, "other", "Pilot kickoff", "<p>Rollout plan, draft.</p>", now)
+	exec(t, database, `UPDATE notes SET account_id = 'acc-2', external_participants = 'jane_doe@x.com' WHERE id = 'other'`)
+	exec(t, database, `INSERT INTO todos (id, title, status, account_id, created_at, updated_at) VALUES ('todo-1', 'Pilot rollout plan', 'stuck', 'acc-1', ?, ?)`, now, now)
+	exec(t, database, `INSERT INTO todos (id, title, status, account_id, created_at, updated_at) VALUES ('todo-2', 'Pilot rollout plan', 'in_progress', 'acc-1', ?, ?)`, now, now)
+	exec(t, database, `INSERT INTO todos (id, title, status, account_id, created_at, updated_at) VALUES ('todo-3', 'Pilot signoff', 'not_started', 'acc-1', ?, ?)`, now, now)
+	exec(t, database, `INSERT INTO todo_dependencies (todo_id, blocked_by) VALUES ('todo-3', 'todo-2')`)
+
+	search := func(s string) []string {
+		q, err := Parse(s)
+		require.NoError(t, err, s)
+		page, err := Search(database, q)
+		require.NoError(t, err, s)
+		return ids(page)
+	}
+
+	assert.ElementsMatch(t, []string{"note:exact", "note:other", "todo:todo-1", "todo:todo-2"}, search(`"rollout plan"`))
+	assert.ElementsMatch(t, []string{"note:exact", "todo:todo-1", "todo:todo-2"}, search(`"rollout plan" -draft`))
+	assert.Equal(t, []string{"note:other"}, search(`pilot account:initech`))
+	assert.ElementsMatch(t, []string{"note:exact", "note:apart", "todo:todo-1", "todo:todo-2", "todo:todo-3"}, search(`pilot account:"acme corp"`))
+	assert.Equal(t, []string{"note:other"}, search(`participant:jane_doe`))
+	assert.Empty(t, search(`participant:jane%`), "LIKE wildcards are taken literally")
+	assert.ElementsMatch(t, []string{"todo:todo-1", "todo:todo-3"}, search(`pilot status:stuck`), "a todo with an open blocker is stuck")
+	assert.Empty(t, search(`signoff status:not_started`))
+	assert.Empty(t, search(`"plan rollout"`))
+	assert.Empty(t, search(`pilot -"rollout plan" -apart -review -plan -signoff`))
+}
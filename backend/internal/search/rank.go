@@ -0,0 +1,233 @@
+package search
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Field weights: a term in the title counts for more than one in the text
+const (
+	titleWeight = 3.0
+	bodyWeight  = 1.0
+	extraWeight = 1.0
+)
+
+// Recency boost: a result changed just now scores up to recencyWeight more
+// than an old one, halving every recencyHalfLife
+const (
+	recencyWeight   = 0.5
+	recencyHalfLife = 30 * 24 * time.Hour
+)
+
+// excerptBefore and excerptAfter size the text shown around a match
+const (
+	excerptBefore = 60
+	excerptAfter  = 140
+)
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Terms splits a query into lowercase words
+func Terms(q string) []string {
+	terms := []string{}
+	for _, tok := range tokenize(q) {
+		terms = append(terms, tok.word)
+	}
+	return terms
+}
+
+// token is a word and its byte offsets in the text it came from
+type token struct {
+	word       string
+	start, end int
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		}
+		if !word && start >= 0 {
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// plainText strips HTML markup from note content
+func plainText(content string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(tagPattern.ReplaceAllString(content, " "))), " ")
+}
+
+// field is a document field as weighted words
+type field struct {
+	tokens []token
+	weight float64
+}
+
+func (f field) count(term string) float64 {
+	n := 0
+	for _, tok := range f.tokens {
+		if strings.HasPrefix(tok.word, term) {
+			n++
+		}
+	}
+	return float64(n) * f.weight
+}
+
+// rank scores documents with BM25 over their weighted fields, multiplies by
+// the recency boost, and drops documents where a term only matched inside
+// markup or in the middle of a word, where a phrase is missing, or where an
+// excluded phrase appears
+func rank(s source, docs []*document, q Query, idf []float64, asOf time.Time) []scored {
+	terms := q.words()
+	fields := make([][]field, len(docs))
+	lengths := make([]float64, len(docs))
+	var total float64
+	for i, d := range docs {
+		fields[i] = []field{
+			{tokenize(d.title), titleWeight},
+			{tokenize(d.body), bodyWeight},
+			{tokenize(d.extra), extraWeight},
+		}
+		for _, f := range fields[i] {
+			lengths[i] += float64(len(f.tokens)) * f.weight
+		}
+		total += lengths[i]
+	}
+	avg := total / float64(len(docs))
+
+	results := []scored{}
+	for i, d := range docs {
+		score := 1.0
+		if len(terms) > 0 {
+			score = 0
+		}
+		matched := true
+		for j, term := range terms {
+			var tf float64
+			for _, f := range fields[i] {
+				tf += f.count(term)
+			}
+			if tf == 0 {
+				matched = false
+				break
+			}
+			norm := 1 - b
+			if avg > 0 {
+				norm += b * lengths[i] / avg
+			}
+			score += idf[j] * tf * (k1 + 1) / (tf + k1*norm)
+		}
+		for _, ph := range q.Phrases {
+			matched = matched && anyField(fields[i], ph)
+		}
+		for _, ph := range q.Excluded {
+			matched = matched && !anyField(fields[i], ph)
+		}
+		if !matched {
+			continue
+		}
+
+		r := scored{d.result(s.typ)}
+		r.Score = score * recencyBoost(d.updatedAt.Time, asOf)
+		r.Snippet = s.snippet(d, terms)
+		results = append(results, r)
+	}
+	return results
+}
+
+func anyField(fields []field, ph Phrase) bool {
+	for _, f := range fields {
+		if ph.in(f.tokens) {
+			return true
+		}
+	}
+	return false
+}
+
+func idfWeight(total, df int) float64 {
+	return math.Log(1 + (float64(total-df)+0.5)/(float64(df)+0.5))
+}
+
+func recencyBoost(updated, asOf time.Time) float64 {
+	if updated.IsZero() {
+		return 1
+	}
+	age := max(asOf.Sub(updated), 0)
+	return 1 + recencyWeight*math.Pow(0.5, float64(age)/float64(recencyHalfLife))
+}
+
+func matchesAny(text string, terms []string) bool {
+	for _, tok := range tokenize(text) {
+		for _, term := range terms {
+			if strings.HasPrefix(tok.word, term) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// excerpt returns the text around the first match with every match wrapped
+// in <mark>, HTML escaped. Without terms it returns the start of the text.
+func excerpt(text string, terms []string) string {
+	tokens := tokenize(text)
+	first := -1
+	for i, tok := range tokens {
+		if matchesAny(tok.word, terms) {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		if len(terms) > 0 || len(tokens) == 0 {
+			return ""
+		}
+		first = 0
+	}
+
+	from, to := first, first
+	for from > 0 && tokens[first].start-tokens[from-1].start <= excerptBefore {
+		from--
+	}
+	for to < len(tokens)-1 && tokens[to+1].end-tokens[first].start <= excerptAfter {
+		to++
+	}
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("...")
+	}
+	pos := tokens[from].start
+	for _, tok := range tokens[from : to+1] {
+		sb.WriteString(html.EscapeString(text[pos:tok.start]))
+		word := html.EscapeString(text[tok.start:tok.end])
+		if len(terms) > 0 && matchesAny(tok.word, terms) {
+			word = "<mark>" + word + "</mark>"
+		}
+		sb.WriteString(word)
+		pos = tok.end
+	}
+	if to < len(tokens)-1 {
+		sb.WriteString("...")
+	}
+	return sb.String()
+}
@@ -0,0 +1,248 @@
+// Package search finds and ranks notes, accounts, todos and contacts for the
+// search endpoint. Candidates come from the full-text index, then every
+// match is scored with BM25 over its title and text, boosted by how recently
+// it changed, and returned a page at a time.
+package search
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+)
+
+// Result types
+const (
+	TypeNote       = "note"
+	TypeAccount    = "account"
+	TypeTodo       = "todo"
+	TypeContact    = "contact"
+	TypeAttachment = "attachment"
+)
+
+// Types lists every result type
+var Types = []string{TypeNote, TypeAccount, TypeTodo, TypeContact, TypeAttachment}
+
+// Visibility says whether archived or deleted rows are searched
+type Visibility string
+
+const (
+	Exclude Visibility = "exclude"
+	Include Visibility = "include"
+	Only    Visibility = "only"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by Search
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Filters narrow the rows that are searched. Tags, Participant and
+// TemplateType only apply to notes and their attachments and Status only to
+// todos, so setting them leaves the other types out.
+type Filters struct {
+	Types     []string
+	AccountID string
+	// Account matches an account id or part of its name
+	Account string
+	// Tags must all be on the note
+	Tags         []string
+	Participant  string
+	Status       string
+	From, To     *time.Time
+	TemplateType string
+	Archived     Visibility
+	Deleted      Visibility
+}
+
+// IsEmpty reports whether the filters would let every row through, apart
+// from archived and deleted ones
+func (f Filters) IsEmpty() bool {
+	return len(f.Types) == 0 && f.AccountID == "" && f.Account == "" && len(f.Tags) == 0 && f.Participant == "" &&
+		f.Status == "" && f.From == nil && f.To == nil && f.TemplateType == "" && f.Archived == Exclude && f.Deleted == Exclude
+}
+
+// Query is a search request
+type Query struct {
+	// Terms must all match, as word prefixes
+	Terms []string
+	// Phrases must all appear
+	Phrases []Phrase
+	// Excluded phrases must not appear
+	Excluded []Phrase
+	Filters
+	Limit  int
+	Cursor string
+}
+
+// HasCriteria reports whether the query asks for anything beyond leaving
+// results out
+func (q Query) HasCriteria() bool {
+	return len(q.Terms) > 0 || len(q.Phrases) > 0 || !q.Filters.IsEmpty()
+}
+
+// words lists the terms and the words of the phrases, which are what
+// results are ranked and highlighted by
+func (q Query) words() []string {
+	words := append([]string{}, q.Terms...)
+	for _, ph := range q.Phrases {
+		words = append(words, ph.Words...)
+	}
+	return words
+}
+
+// Page is one page of ranked results
+type Page struct {
+	Results []models.SearchResult
+	// Total counts every result across all pages
+	Total      int
+	NextCursor string
+}
+
+// cursor marks the last result of a page. Recency depends on the time, so
+// it also pins the time the first page was scored at.
+type cursor struct {
+	Score float64 `json:"s"`
+	Key   string  `json:"k"`
+	AsOf  int64   `json:"t"`
+}
+
+// Search brings the index up to date and runs the query
+func Search(db *sql.DB, q Query) (*Page, error) {
+	if err := Sync(db); err != nil {
+		return nil, err
+	}
+	after, asOf, err := q.paging()
+	if err != nil {
+		return nil, err
+	}
+
+	var all []scored
+	for _, src := range sources {
+		if !wanted(src.typ, q.Filters) {
+			continue
+		}
+		results, err := src.search(db, q, asOf)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, results...)
+	}
+	return paginate(all, q.Limit, after, asOf), nil
+}
+
+// paging decodes the cursor and caps the limit. asOf is when the first page
+// was scored.
+func (q *Query) paging() (after *cursor, asOf time.Time, err error) {
+	asOf = time.Now()
+	if q.Cursor != "" {
+		if after, err = decodeCursor(q.Cursor); err != nil {
+			return nil, asOf, err
+		}
+		asOf = time.Unix(0, after.AsOf)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	return after, asOf, nil
+}
+
+// paginate orders the results and returns the page following after
+func paginate(all []scored, limit int, after *cursor, asOf time.Time) *Page {
+	sort.Slice(all, func(i, j int) bool { return all[i].before(all[j]) })
+
+	page := &Page{Results: []models.SearchResult{}, Total: len(all)}
+	start := 0
+	if after != nil {
+		start = sort.Search(len(all), func(i int) bool {
+			return all[i].Score < after.Score || (all[i].Score == after.Score && all[i].key() > after.Key)
+		})
+	}
+	end := min(start+limit, len(all))
+	for _, r := range all[start:end] {
+		page.Results = append(page.Results, r.SearchResult)
+	}
+	if end < len(all) {
+		last := all[end-1]
+		page.NextCursor = encodeCursor(cursor{Score: last.Score, Key: last.key(), AsOf: asOf.UnixNano()})
+	}
+	return page
+}
+
+// wanted reports whether results of typ can pass the filters
+func wanted(typ string, f Filters) bool {
+	if len(f.Types) > 0 && !contains(f.Types, typ) {
+		return false
+	}
+	if typ != TypeNote && typ != TypeAttachment && (len(f.Tags) > 0 || f.Participant != "" || f.TemplateType != "" || f.Archived == Only) {
+		return false
+	}
+	if typ != TypeTodo && f.Status != "" {
+		return false
+	}
+	return true
+}
+
+// scored is a ranked result
+type scored struct {
+	models.SearchResult
+}
+
+func (s scored) key() string { return s.Type + ":" + s.ID }
+
+// before orders by score, then by type and id so that pages are stable
+func (s scored) before(o scored) bool {
+	if s.Score != o.Score {
+		return s.Score > o.Score
+	}
+	return s.key() < o.key()
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Key == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// ParseVisibility reads an archived or deleted filter value, defaulting to
+// Exclude
+func ParseVisibility(s string) (Visibility, bool) {
+	switch v := Visibility(strings.ToLower(s)); v {
+	case "":
+		return Exclude, true
+	case Exclude, Include, Only:
+		return v, true
+	}
+	return "", false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
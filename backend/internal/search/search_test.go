@@ -0,0 +1,150 @@
+package search
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupDB(t *testing.T) *sql.DB {
+	database, err := db.Initialize(filepath.Join(t.TempDir(), "notes.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	require.NoError(t, db.Migrate(database))
+	return database
+}
+
+func exec(t *testing.T, database *sql.DB, query string, args ...interface{}) {
+	_, err := database.Exec(query, args...)
+	require.NoError(t, err, query)
+}
+
+func addNote(t *testing.T, database *sql.DB, id, title, content string, updated time.Time) {
+	exec(t, database, `INSERT INTO notes (id, title, account_id, content, created_at, updated_at) VALUES (?, ?, 'acc-1', ?, ?, ?)`,
+		id, title, content, updated, updated)
+}
+
+func ids(page *Page) []string {
+	out := []string{}
+	for _, r := range page.Results {
+		out = append(out, r.Type+":"+r.ID)
+	}
+	return out
+}
+
+func TestSearchRanking(t *testing.T) {
+	database := setupDB(t)
+	now := time.Now()
+	exec(t, database, `INSERT INTO accounts (id, name, account_owner, created_at, updated_at) VALUES ('acc-1', 'Kubernetes Inc', 'Kim', ?, ?)`, now, now)
+	addNote(t, database, "title", "Kubernetes rollout", "<p>Plan for the cluster.</p>", now.Add(-90*24*time.Hour))
+	addNote(t, database, "body", "Weekly sync", "<p>We talked about <strong>kubernetes</strong> once.</p>", now.Add(-90*24*time.Hour))
+	addNote(t, database, "markup", "Styling", `<p class="kubernetes">Nothing relevant</p>`, now)
+	addNote(t, database, "deleted", "Kubernetes trash", "", now)
+	exec(t, database, `UPDATE notes SET deleted_at = ? WHERE id = 'deleted'`, now)
+	exec(t, database, `INSERT INTO todos (id, title, description, account_id, created_at, updated_at) VALUES ('todo-1', 'Upgrade kubernetes', '', 'acc-1', ?, ?)`, now, now)
+
+	page, err := Search(database, Query{Terms: Terms("kube"), Filters: Filters{Archived: Exclude, Deleted: Exclude}})
+	require.NoError(t, err)
+	assert.Equal(t, 4, page.Total)
+	assert.ElementsMatch(t, []string{"note:title", "note:body", "account:acc-1", "todo:todo-1"}, ids(page))
+	assert.NotContains(t, ids(page), "note:markup", "matches inside markup are dropped")
+
+	positions := map[string]int{}
+	for i, id := range ids(page) {
+		positions[id] = i
+	}
+	assert.Less(t, positions["note:title"], positions["note:body"], "a title match outranks a body match")
+
+	for _, r := range page.Results {
+		if r.ID == "body" {
+			assert.Contains(t, r.Snippet, "<mark>kubernetes</mark>")
+		}
+	}
+
+	t.Run("Recency", func(t *testing.T) {
+		addNote(t, database, "fresh", "Weekly sync", "<p>We talked about <strong>kubernetes</strong> once.</p>", now)
+		page, err := Search(database, Query{Terms: Terms("kubernetes"), Filters: Filters{Types: []string{TypeNote}, Archived: Exclude, Deleted: Exclude}})
+		require.NoError(t, err)
+		require.Len(t, page.Results, 3)
+		assert.Equal(t, []string{"note:fresh", "note:body"}, ids(page)[1:], "the same text ranks higher when it changed recently")
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		page, err := Search(database, Query{Terms: Terms("kubernetes"), Filters: Filters{Types: []string{TypeNote}, Archived: Exclude, Deleted: Only}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"note:deleted"}, ids(page))
+	})
+}
+
+func TestSearchFiltersAndPagination(t *testing.T) {
+	database := setupDB(t)
+	now := time.Now()
+	exec(t, database, `INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-1', 'Acme', ?, ?)`, now, now)
+	exec(t, database, `INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-2', 'Globex', ?, ?)`, now, now)
+	for i, id := range []string{"n1", "n2", "n3", "n4", "n5"} {
+		addNote(t, database, id, "Demo "+id, "<p>demo</p>", now.Add(-time.Duration(i)*time.Hour))
+	}
+	exec(t, database, `UPDATE notes SET account_id = 'acc-2', template_type = 'followup' WHERE id = 'n5'`)
+	exec(t, database, `UPDATE notes SET archived = 1 WHERE id = 'n4'`)
+	exec(t, database, `INSERT INTO tags (id, name) VALUES ('tag-1', 'POC')`)
+	exec(t, database, `INSERT INTO note_tags (note_id, tag_id) VALUES ('n2', 'tag-1')`)
+	exec(t, database, `UPDATE notes SET meeting_date = ? WHERE id = 'n3'`, now.Add(-72*time.Hour))
+
+	base := Filters{Archived: Exclude, Deleted: Exclude}
+	search := func(f Filters) []string {
+		page, err := Search(database, Query{Terms: Terms("demo"), Filters: f})
+		require.NoError(t, err)
+		return ids(page)
+	}
+
+	f := base
+	f.AccountID = "acc-2"
+	assert.Equal(t, []string{"note:n5"}, search(f))
+
+	f = base
+	f.Tags = []string{"poc"}
+	assert.Equal(t, []string{"note:n2"}, search(f))
+
+	f = base
+	f.TemplateType = "followup"
+	assert.Equal(t, []string{"note:n5"}, search(f))
+
+	f = base
+	f.Archived = Only
+	assert.Equal(t, []string{"note:n4"}, search(f))
+
+	f = base
+	from, to := now.Add(-96*time.Hour), now.Add(-48*time.Hour)
+	f.From, f.To = &from, &to
+	assert.Equal(t, []string{"note:n3"}, search(f))
+
+	// Walk every page with a limit of two
+	var walked []string
+	cursor := ""
+	for {
+		page, err := Search(database, Query{Terms: Terms("demo"), Filters: base, Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		assert.Equal(t, 4, page.Total)
+		walked = append(walked, ids(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, search(base), walked)
+
+	_, err := Search(database, Query{Terms: Terms("demo"), Filters: base, Cursor: "garbage"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestExcerpt(t *testing.T) {
+	text := "The quick brown fox & the lazy dog"
+	assert.Equal(t, "The quick brown <mark>fox</mark> &amp; the lazy dog", excerpt(text, []string{"fo"}))
+	assert.Equal(t, "", excerpt(text, []string{"cat"}))
+	assert.Equal(t, []string{"jane", "x", "com"}, Terms("Jane@X.com"))
+}
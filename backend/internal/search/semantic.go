@@ -0,0 +1,262 @@
+package search
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/embed"
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+)
+
+// Semantic search ranks notes by the cosine similarity of their embedding to
+// the query's, so that notes on the same topic are found whatever words they
+// use. Embeddings are computed from a note's title and body as indexed and
+// kept in note_embeddings. index drops an embedding when that text changes,
+// and SyncEmbeddings computes the missing ones.
+
+// MinSimilarity is the lowest cosine similarity a semantic result can have
+const MinSimilarity = 0.1
+
+// embedBatch is how many notes are sent to the provider at once
+const embedBatch = 64
+
+// ErrNoteNotFound is returned by Similar for a note that does not exist or
+// is deleted
+var ErrNoteNotFound = errors.New("note not found")
+
+// embedMu keeps two syncs from embedding the same notes
+var embedMu sync.Mutex
+
+// SyncEmbeddings brings the index up to date and embeds the notes that have
+// no embedding from p. Embeddings are computed before the write transaction
+// that stores them, so a search only takes the write lock when there is
+// something to store.
+func SyncEmbeddings(db *sql.DB, p embed.Provider) error {
+	if err := Sync(db); err != nil {
+		return err
+	}
+	embedMu.Lock()
+	defer embedMu.Unlock()
+
+	// Vectors from another provider can't be compared with p's
+	var stale bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM note_embeddings WHERE provider != ?)", p.Name()).Scan(&stale); err != nil {
+		return err
+	}
+	if stale {
+		if _, err := db.Exec("DELETE FROM note_embeddings WHERE provider != ?", p.Name()); err != nil {
+			return err
+		}
+	}
+
+	rows, err := db.Query(`
+		SELECT d.id, si.title, si.body
+		FROM search_docs d
+		JOIN search_index si ON si.rowid = d.rowid
+		LEFT JOIN note_embeddings e ON e.note_id = d.id
+		WHERE d.type = 'note' AND e.note_id IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	var ids, titles, bodies []string
+	for rows.Next() {
+		var id, title, body string
+		if err := rows.Scan(&id, &title, &body); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		titles = append(titles, title)
+		bodies = append(bodies, body)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	vectors := make([][]float32, 0, len(ids))
+	for start := 0; start < len(ids); start += embedBatch {
+		end := min(start+embedBatch, len(ids))
+		texts := make([]string, 0, end-start)
+		for i := start; i < end; i++ {
+			texts = append(texts, titles[i]+"\n"+bodies[i])
+		}
+		batch, err := p.Embed(texts)
+		if err != nil {
+			return err
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A note edited while it was being embedded keeps no vector; the next
+	// sync embeds its new text
+	for i, v := range vectors {
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO note_embeddings (note_id, provider, vector)
+			SELECT d.id, ?, ?
+			FROM search_docs d
+			JOIN search_index si ON si.rowid = d.rowid
+			WHERE d.type = 'note' AND d.id = ? AND si.title = ? AND si.body = ?
+		`, p.Name(), embed.Encode(v), ids[i], titles[i], bodies[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Semantic ranks the notes that pass the query's filters by how close they
+// are to the query's words. Phrases are not required to appear, but
+// excluded words are still left out.
+func Semantic(db *sql.DB, p embed.Provider, q Query) (*Page, error) {
+	if err := SyncEmbeddings(db, p); err != nil {
+		return nil, err
+	}
+	after, asOf, err := q.paging()
+	if err != nil {
+		return nil, err
+	}
+	if !wanted(TypeNote, q.Filters) {
+		return paginate(nil, q.Limit, after, asOf), nil
+	}
+
+	terms := q.words()
+	query, err := p.Embed([]string{strings.Join(terms, " ")})
+	if err != nil {
+		return nil, err
+	}
+	notes, err := noteSource()
+	if err != nil {
+		return nil, err
+	}
+	conds, args := noteConds(q.Filters)
+	docs, err := notes.documents(db, q, conds, args)
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := noteVectors(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []scored
+	for _, d := range docs {
+		score := embed.Cosine(query[0], vectors[d.id])
+		if score < MinSimilarity || excluded(d, q.Excluded) {
+			continue
+		}
+		r := scored{d.result(TypeNote)}
+		r.Score = score
+		if r.Snippet = excerpt(d.body, terms); r.Snippet == "" {
+			r.Snippet = excerpt(d.body, nil)
+		}
+		all = append(all, r)
+	}
+	return paginate(all, q.Limit, after, asOf), nil
+}
+
+// Similar returns up to limit notes closest to the given one, most similar
+// first. Archived and deleted notes are left out.
+func Similar(db *sql.DB, p embed.Provider, noteID string, limit int) ([]models.SearchResult, error) {
+	var deleted sql.NullTime
+	err := db.QueryRow("SELECT deleted_at FROM notes WHERE id = ?", noteID).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) || deleted.Valid {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := SyncEmbeddings(db, p); err != nil {
+		return nil, err
+	}
+
+	notes, err := noteSource()
+	if err != nil {
+		return nil, err
+	}
+	conds, args := noteConds(Filters{})
+	docs, err := notes.documents(db, Query{}, conds, args)
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := noteVectors(db)
+	if err != nil {
+		return nil, err
+	}
+	target := vectors[noteID]
+
+	var all []scored
+	for _, d := range docs {
+		if d.id == noteID {
+			continue
+		}
+		score := embed.Cosine(target, vectors[d.id])
+		if score < MinSimilarity {
+			continue
+		}
+		r := scored{d.result(TypeNote)}
+		r.Score = score
+		r.Snippet = excerpt(d.body, nil)
+		all = append(all, r)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].before(all[j]) })
+
+	results := []models.SearchResult{}
+	for _, r := range all[:min(limit, len(all))] {
+		results = append(results, r.SearchResult)
+	}
+	return results, nil
+}
+
+// noteSource returns the source notes are searched with
+func noteSource() (source, error) {
+	for _, s := range sources {
+		if s.typ == TypeNote {
+			return s, nil
+		}
+	}
+	return source{}, errors.New("no note source")
+}
+
+func noteVectors(db *sql.DB) (map[string][]float32, error) {
+	rows, err := db.Query("SELECT note_id, vector FROM note_embeddings")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	vectors := map[string][]float32{}
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		vectors[id] = embed.Decode(data)
+	}
+	return vectors, rows.Err()
+}
+
+// excluded reports whether any of the phrases appears in the document
+func excluded(d *document, phrases []Phrase) bool {
+	fields := [][]token{tokenize(d.title), tokenize(d.body), tokenize(d.extra)}
+	for _, ph := range phrases {
+		for _, f := range fields {
+			if ph.in(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
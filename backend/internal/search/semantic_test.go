@@ -0,0 +1,60 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/embed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemantic(t *testing.T) {
+	database := setupDB(t)
+	now := time.Now()
+	exec(t, database, `INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-1', 'Acme', ?, ?)`, now, now)
+	addNote(t, database, "k8s-1", "Cluster migration", "<p>Moving the Kubernetes clusters to the new platform.</p>", now)
+	addNote(t, database, "k8s-2", "Platform sync", "<p>Kubernetes cluster upgrade and migration timeline.</p>", now)
+	addNote(t, database, "pricing", "Renewal", "<p>Discount negotiation for the annual renewal.</p>", now)
+	p := embed.NewHashed(embed.DefaultDims)
+
+	similar, err := Similar(database, p, "k8s-1", 5)
+	require.NoError(t, err)
+	if assert.Len(t, similar, 1) {
+		assert.Equal(t, "k8s-2", similar[0].ID)
+		assert.Greater(t, similar[0].Score, MinSimilarity)
+	}
+	_, err = Similar(database, p, "missing", 5)
+	assert.ErrorIs(t, err, ErrNoteNotFound)
+
+	semantic := func(s string) []string {
+		q, err := Parse(s)
+		require.NoError(t, err, s)
+		page, err := Semantic(database, p, q)
+		require.NoError(t, err, s)
+		return ids(page)
+	}
+	assert.ElementsMatch(t, []string{"note:k8s-1", "note:k8s-2"}, semantic("migrating clusters"))
+	assert.Equal(t, []string{"note:k8s-1"}, semantic("migrating clusters -upgrade"))
+	assert.Equal(t, []string{"note:pricing"}, semantic("renewal discounts"))
+	assert.Empty(t, semantic("renewal status:open"), "todos only")
+
+	var embedded int
+	require.NoError(t, database.QueryRow("SELECT COUNT(*) FROM note_embeddings").Scan(&embedded))
+	assert.Equal(t, 3, embedded)
+
+	// Editing a note replaces its embedding; other changes keep it
+	exec(t, database, `UPDATE notes SET content = '<p>Annual renewal pricing and discount terms.</p>' WHERE id = 'k8s-1'`)
+	exec(t, database, `UPDATE notes SET pinned = 1 WHERE id = 'pricing'`)
+	require.NoError(t, Sync(database))
+	require.NoError(t, database.QueryRow("SELECT COUNT(*) FROM note_embeddings").Scan(&embedded))
+	assert.Equal(t, 2, embedded)
+	assert.ElementsMatch(t, []string{"note:k8s-1", "note:pricing"}, semantic("renewal discounts"))
+
+	// Vectors from another provider are replaced
+	_, err = Similar(database, embed.NewHashed(64), "pricing", 5)
+	require.NoError(t, err)
+	var provider string
+	require.NoError(t, database.QueryRow("SELECT DISTINCT provider FROM note_embeddings").Scan(&provider))
+	assert.Equal(t, "hashed-64", provider)
+}
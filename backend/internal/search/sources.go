@@ -0,0 +1,279 @@
+package search
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/store"
+)
+
+// source is one searchable table
+type source struct {
+	typ string
+	// from joins the table to accounts, as a, and to its document in the
+	// index, as d in search_docs and si in search_index
+	from string
+	// columns selects id, account id, account name, date, created_at,
+	// updated_at and the id of the note the row belongs to, in that order
+	columns string
+	// filter turns the filters into conditions on the row
+	filter func(f Filters) ([]string, []interface{})
+	// snippet describes where the terms matched
+	snippet func(d *document, terms []string) string
+}
+
+// document is a row being ranked, with its indexed text
+type document struct {
+	id, title, body, extra string
+	accountID              sql.NullString
+	accountName            string
+	date                   sql.NullTime
+	createdAt, updatedAt   sql.NullTime
+	noteID                 sql.NullString
+}
+
+// indexJoin joins a table, aliased as alias, to its index document
+func indexJoin(typ, alias string) string {
+	return fmt.Sprintf(` JOIN search_docs d ON d.type = '%s' AND d.id = %s.id JOIN search_index si ON si.rowid = d.rowid`, typ, alias)
+}
+
+var sources = []source{
+	{
+		typ:     TypeNote,
+		from:    `notes n LEFT JOIN accounts a ON n.account_id = a.id` + indexJoin(TypeNote, "n"),
+		columns: `n.id, n.account_id, COALESCE(a.name, ''), n.meeting_date, n.created_at, n.updated_at, NULL`,
+		filter:  noteConds,
+		snippet: func(d *document, terms []string) string {
+			if s := excerpt(d.body, terms); s != "" {
+				return s
+			}
+			return excerpt(d.extra, terms)
+		},
+	},
+	{
+		typ:     TypeAccount,
+		from:    `accounts a` + indexJoin(TypeAccount, "a"),
+		columns: `a.id, NULL, '', NULL, a.created_at, a.updated_at, NULL`,
+		filter: func(f Filters) ([]string, []interface{}) {
+			conds, args := accountConds("a.id", f)
+			return append(conds, visibility("a.deleted_at IS NOT NULL", f.Deleted)), args
+		},
+		snippet: func(d *document, terms []string) string {
+			if d.extra != "" && matchesAny(d.extra, terms) {
+				return "Owner: " + html.EscapeString(d.extra)
+			}
+			return ""
+		},
+	},
+	{
+		typ:     TypeTodo,
+		from:    `todos t LEFT JOIN accounts a ON t.account_id = a.id` + indexJoin(TypeTodo, "t"),
+		columns: `t.id, t.account_id, COALESCE(a.name, ''), t.due_date, t.created_at, t.updated_at, NULL`,
+		filter: func(f Filters) ([]string, []interface{}) {
+			conds, args := accountConds("t.account_id", f)
+			conds = append(conds, visibility("t.deleted_at IS NOT NULL", f.Deleted))
+			if f.Status != "" {
+				// The status as the todo API reports it, so a todo with an
+				// open blocker is found by the blocked status
+				conds = append(conds, "LOWER("+store.TodoStatus+") = ?")
+				args = append(args, f.Status)
+			}
+			return conds, args
+		},
+		snippet: func(d *document, terms []string) string {
+			parts := []string{}
+			if s := excerpt(d.body, terms); s != "" {
+				parts = append(parts, s)
+			}
+			if d.accountName != "" {
+				parts = append(parts, "Account: "+html.EscapeString(d.accountName))
+			}
+			return strings.Join(parts, " | ")
+		},
+	},
+	{
+		typ:     TypeContact,
+		from:    `contacts c LEFT JOIN accounts a ON c.account_id = a.id` + indexJoin(TypeContact, "c"),
+		columns: `c.id, c.account_id, COALESCE(a.name, ''), c.last_seen, c.created_at, c.updated_at, NULL`,
+		filter: func(f Filters) ([]string, []interface{}) {
+			conds, args := accountConds("c.account_id", f)
+			return append(conds, visibility("c.deleted_at IS NOT NULL", f.Deleted)), args
+		},
+		snippet: func(d *document, terms []string) string {
+			if s := excerpt(d.extra, terms); s != "" {
+				return s
+			}
+			return html.EscapeString(d.extra)
+		},
+	},
+	{
+		// Attachments are found by their extracted text and take the
+		// account and filters of their note
+		typ: TypeAttachment,
+		from: `attachments att JOIN notes n ON n.id = att.note_id LEFT JOIN accounts a ON n.account_id = a.id` +
+			indexJoin(TypeAttachment, "att"),
+		columns: `att.id, n.account_id, COALESCE(a.name, ''), NULL, att.created_at, att.created_at, att.note_id`,
+		filter:  noteConds,
+		snippet: func(d *document, terms []string) string {
+			return excerpt(d.body, terms)
+		},
+	},
+}
+
+// noteConds applies the filters to notes, joined as n
+func noteConds(f Filters) ([]string, []interface{}) {
+	conds, args := accountConds("n.account_id", f)
+	conds = append(conds, visibility("n.deleted_at IS NOT NULL", f.Deleted), visibility("COALESCE(n.archived, 0) = 1", f.Archived))
+	for _, tag := range f.Tags {
+		// A tag also matches its children, so tag:customer finds customer/poc
+		conds = append(conds, `EXISTS (SELECT 1 FROM note_tags nt JOIN tags tg ON tg.id = nt.tag_id
+			WHERE nt.note_id = n.id AND (LOWER(tg.name) = LOWER(?)
+				OR LOWER(substr(tg.name, 1, length(?) + 1)) = LOWER(?) || '/'))`)
+		args = append(args, tag, tag, tag)
+	}
+	if f.Participant != "" {
+		like := likePattern(f.Participant)
+		conds = append(conds, `(n.internal_participants LIKE ? ESCAPE '\' OR n.external_participants LIKE ? ESCAPE '\')`)
+		args = append(args, like, like)
+	}
+	if f.TemplateType != "" {
+		conds = append(conds, "n.template_type = ?")
+		args = append(args, f.TemplateType)
+	}
+	return conds, args
+}
+
+// visibility turns an archived or deleted filter into a condition, given
+// the condition that is true for archived or deleted rows
+func visibility(cond string, v Visibility) string {
+	switch v {
+	case Include:
+		return "1 = 1"
+	case Only:
+		return cond
+	}
+	return "NOT (" + cond + ")"
+}
+
+// accountConds narrows rows by account, given the column holding the
+// account id. Every source joins accounts as a.
+func accountConds(col string, f Filters) ([]string, []interface{}) {
+	conds, args := []string{}, []interface{}{}
+	if f.AccountID != "" {
+		conds = append(conds, col+" = ?")
+		args = append(args, f.AccountID)
+	}
+	if f.Account != "" {
+		conds = append(conds, `(a.id = ? OR a.name LIKE ? ESCAPE '\')`)
+		args = append(args, f.Account, likePattern(f.Account))
+	}
+	return conds, args
+}
+
+// likePattern matches s anywhere, taking LIKE wildcards in s literally
+func likePattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s) + "%"
+}
+
+// matchTerm is the condition for a document containing a word that starts
+// with term. Terms only hold letters and digits, and are lowercase so they
+// can't be read as operators, which keeps the MATCH valid for both FTS4 and
+// FTS5.
+func matchTerm(term string) (string, interface{}) {
+	return `d.rowid IN (SELECT rowid FROM search_index WHERE search_index MATCH ?)`, term + "*"
+}
+
+// search loads the rows that can match the query and ranks them. Every word
+// must match, phrase words included; rank checks the phrases themselves.
+func (s source) search(db *sql.DB, q Query, asOf time.Time) ([]scored, error) {
+	terms := q.words()
+	conds, args := s.filter(q.Filters)
+	for _, term := range terms {
+		cond, arg := matchTerm(term)
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+
+	docs, err := s.documents(db, q, conds, args)
+	if err != nil || len(docs) == 0 {
+		return nil, err
+	}
+
+	idf, err := s.idf(db, terms)
+	if err != nil {
+		return nil, err
+	}
+	return rank(s, docs, q, idf, asOf), nil
+}
+
+// documents loads the rows meeting conds that fall in the query's date range
+func (s source) documents(db *sql.DB, q Query, conds []string, args []interface{}) ([]*document, error) {
+	rows, err := db.Query("SELECT si.title, si.body, si.extra, "+s.columns+" FROM "+s.from+" WHERE "+strings.Join(conds, " AND "), args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching %ss: %w", s.typ, err)
+	}
+	defer rows.Close()
+	var docs []*document
+	for rows.Next() {
+		d := &document{}
+		if err := rows.Scan(&d.title, &d.body, &d.extra, &d.id, &d.accountID, &d.accountName, &d.date, &d.createdAt, &d.updatedAt, &d.noteID); err != nil {
+			return nil, err
+		}
+		if inRange(d.when(), q.From, q.To) {
+			docs = append(docs, d)
+		}
+	}
+	return docs, rows.Err()
+}
+
+// idf weighs each term by how rare it is among the documents of this type
+func (s source) idf(db *sql.DB, terms []string) ([]float64, error) {
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM search_docs d WHERE d.type = ?", s.typ).Scan(&total); err != nil {
+		return nil, err
+	}
+	weights := make([]float64, len(terms))
+	for i, term := range terms {
+		cond, arg := matchTerm(term)
+		var df int
+		if err := db.QueryRow("SELECT COUNT(*) FROM search_docs d WHERE d.type = ? AND "+cond, s.typ, arg).Scan(&df); err != nil {
+			return nil, err
+		}
+		weights[i] = idfWeight(total, df)
+	}
+	return weights, nil
+}
+
+func (d *document) when() time.Time {
+	if d.date.Valid {
+		return d.date.Time
+	}
+	return d.createdAt.Time
+}
+
+func (d *document) result(typ string) models.SearchResult {
+	return models.SearchResult{
+		Type:        typ,
+		ID:          d.id,
+		Title:       d.title,
+		AccountID:   d.accountID.String,
+		AccountName: d.accountName,
+		Date:        d.when(),
+		UpdatedAt:   d.updatedAt.Time,
+		NoteID:      d.noteID.String,
+	}
+}
+
+func inRange(t time.Time, from, to *time.Time) bool {
+	if from != nil && t.Before(*from) {
+		return false
+	}
+	if to != nil && !t.Before(*to) {
+		return false
+	}
+	return true
+}
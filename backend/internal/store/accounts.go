@@ -0,0 +1,214 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// AccountStore persists customer accounts
+type AccountStore interface {
+	// List returns a page of the accounts not in the trash that match f,
+	// ordered by name unless o sorts them otherwise
+	List(f AccountFilter, o ListOptions) ([]models.Account, PageInfo, error)
+	ListDeleted() ([]models.Account, error)
+	Get(id string) (*models.Account, error)
+	// GetByName returns the account with exactly this name, including ones
+	// in the trash
+	GetByName(name string) (*models.Account, error)
+	// FindByNameLike returns the first live account whose lowercased name
+	// contains fragment
+	FindByNameLike(fragment string) (*models.Account, error)
+	// Create inserts an account, assigning an ID and timestamps when unset
+	Create(a *models.Account) error
+	Update(id string, u AccountUpdate) error
+	Delete(id string) error
+	Restore(id string) error
+	PermanentDelete(id string) error
+	EmptyTrash() (int64, error)
+}
+
+// AccountUpdate holds the fields to change on an account; nil fields are left alone
+type AccountUpdate struct {
+	Name         *string
+	AccountOwner *string
+	Budget       *float64
+	EstEngineers *int
+}
+
+// IsEmpty reports whether the update changes nothing
+func (u AccountUpdate) IsEmpty() bool {
+	return u.Name == nil && u.AccountOwner == nil && u.Budget == nil && u.EstEngineers == nil
+}
+
+// AccountFilter narrows List results; zero fields match every account
+type AccountFilter struct {
+	Owner string // account owner, case-insensitive
+	// Tags are matched as for notes
+	Tags     []string
+	MatchAny bool
+}
+
+var accountSorts = sortKeys{
+	keys: map[string]sortKey{
+		"name":       {expr: "name"},
+		"created_at": {expr: "created_at", desc: true},
+		"updated_at": {expr: "updated_at", desc: true},
+		"budget":     {expr: "COALESCE(budget, 0)", desc: true},
+	},
+	fallback: "name",
+}
+
+type sqliteAccountStore struct {
+	db *sql.DB
+}
+
+const accountSelect = `
+	SELECT id, name, account_owner, budget, est_engineers, created_at, updated_at, deleted_at
+	FROM accounts`
+
+func scanAccount(row scanner) (*models.Account, error) {
+	var a models.Account
+	var accountOwner sql.NullString
+	var deletedAt sql.NullTime
+	if err := row.Scan(&a.ID, &a.Name, &accountOwner, &a.Budget, &a.EstEngineers, &a.CreatedAt, &a.UpdatedAt, &deletedAt); err != nil {
+		return nil, err
+	}
+	a.AccountOwner = accountOwner.String
+	a.DeletedAt = nullTimePtr(deletedAt)
+	return &a, nil
+}
+
+func (s *sqliteAccountStore) queryAccounts(query string, args ...interface{}) ([]models.Account, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []models.Account{}
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *a)
+	}
+	return accounts, rows.Err()
+}
+
+func (s *sqliteAccountStore) List(f AccountFilter, o ListOptions) ([]models.Account, PageInfo, error) {
+	q := newListQuery("accounts", "id", []string{"deleted_at IS NULL"}, nil)
+	if f.Owner != "" {
+		q.where("LOWER(account_owner) = LOWER(?)", f.Owner)
+	}
+	if len(f.Tags) > 0 {
+		cond, args := tagCond(accountTags, "accounts.id", f.Tags, f.MatchAny)
+		q.where(cond, args...)
+	}
+	if err := q.sortBy(accountSorts, o); err != nil {
+		return nil, PageInfo{}, err
+	}
+	return listPage(s.db, q, accountSelect, o, s.queryAccounts, func(a models.Account) string { return a.ID })
+}
+
+func (s *sqliteAccountStore) ListDeleted() ([]models.Account, error) {
+	return s.queryAccounts(accountSelect + ` WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC`)
+}
+
+func (s *sqliteAccountStore) Get(id string) (*models.Account, error) {
+	a, err := scanAccount(s.db.QueryRow(accountSelect+` WHERE id = ? AND deleted_at IS NULL`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+func (s *sqliteAccountStore) GetByName(name string) (*models.Account, error) {
+	a, err := scanAccount(s.db.QueryRow(accountSelect+` WHERE name = ? LIMIT 1`, name))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+func (s *sqliteAccountStore) FindByNameLike(fragment string) (*models.Account, error) {
+	a, err := scanAccount(s.db.QueryRow(accountSelect+`
+		WHERE LOWER(name) LIKE ? AND deleted_at IS NULL
+		LIMIT 1
+	`, "%"+strings.ToLower(fragment)+"%"))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+func (s *sqliteAccountStore) Create(a *models.Account) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	if a.UpdatedAt.IsZero() {
+		a.UpdatedAt = a.CreatedAt
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO accounts (id, name, account_owner, budget, est_engineers, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, a.ID, a.Name, a.AccountOwner, a.Budget, a.EstEngineers, a.CreatedAt, a.UpdatedAt)
+	return err
+}
+
+func (s *sqliteAccountStore) Update(id string, u AccountUpdate) error {
+	updates := []string{}
+	args := []interface{}{}
+
+	if u.Name != nil {
+		updates = append(updates, "name = ?")
+		args = append(args, *u.Name)
+	}
+	if u.AccountOwner != nil {
+		updates = append(updates, "account_owner = ?")
+		args = append(args, *u.AccountOwner)
+	}
+	if u.Budget != nil {
+		updates = append(updates, "budget = ?")
+		args = append(args, *u.Budget)
+	}
+	if u.EstEngineers != nil {
+		updates = append(updates, "est_engineers = ?")
+		args = append(args, *u.EstEngineers)
+	}
+
+	updates = append(updates, "updated_at = ?")
+	args = append(args, time.Now())
+	args = append(args, id)
+
+	return expectAffected(s.db.Exec("UPDATE accounts SET "+strings.Join(updates, ", ")+" WHERE id = ?", args...))
+}
+
+func (s *sqliteAccountStore) Delete(id string) error {
+	// Soft delete
+	return expectAffected(s.db.Exec("UPDATE accounts SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL", id))
+}
+
+func (s *sqliteAccountStore) Restore(id string) error {
+	return expectAffected(s.db.Exec("UPDATE accounts SET deleted_at = NULL WHERE id = ?", id))
+}
+
+func (s *sqliteAccountStore) PermanentDelete(id string) error {
+	return expectAffected(s.db.Exec("DELETE FROM accounts WHERE id = ?", id))
+}
+
+func (s *sqliteAccountStore) EmptyTrash() (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM accounts WHERE deleted_at IS NOT NULL`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+)
+
+// AttachmentStore records the text and metadata extracted from attachment
+// files
+type AttachmentStore interface {
+	// Unextracted returns the attachments that have not been through
+	// extraction yet, with their ID, filenames and MIME type
+	Unextracted() ([]models.Attachment, error)
+	// SetExtracted stores what was extracted from an attachment and marks
+	// it as extracted. Zero values are stored as unknown, so a file nothing
+	// could be read from is not tried again.
+	SetExtracted(id string, e AttachmentExtract) error
+}
+
+// AttachmentExtract is what extraction read from an attachment's file
+type AttachmentExtract struct {
+	Text   string
+	Pages  int
+	Width  int
+	Height int
+}
+
+type sqliteAttachmentStore struct {
+	db *sql.DB
+}
+
+func (s *sqliteAttachmentStore) Unextracted() ([]models.Attachment, error) {
+	rows, err := s.db.Query(`SELECT id, filename, original_name, COALESCE(mime_type, '') FROM attachments WHERE extracted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(&a.ID, &a.Filename, &a.OriginalName, &a.MimeType); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func (s *sqliteAttachmentStore) SetExtracted(id string, e AttachmentExtract) error {
+	return expectAffected(s.db.Exec(`
+		UPDATE attachments
+		SET text = NULLIF(?, ''), page_count = NULLIF(?, 0), width = NULLIF(?, 0), height = NULLIF(?, 0), extracted_at = ?
+		WHERE id = ?
+	`, e.Text, e.Pages, e.Width, e.Height, time.Now(), id))
+}
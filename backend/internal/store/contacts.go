@@ -0,0 +1,446 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// ContactStore persists contacts and their account links
+type ContactStore interface {
+	// List returns a page of the contacts not in the trash that match f,
+	// most recently seen first unless o sorts them otherwise
+	List(f ContactFilter, o ListOptions) ([]models.Contact, PageInfo, error)
+	ListByDomain(domain string) ([]models.Contact, error)
+	ListDeleted() ([]models.Contact, error)
+	Get(id string) (*models.Contact, error)
+	GetByEmail(email string) (*models.Contact, error)
+	// Create inserts a contact, assigning an ID when unset. Returns
+	// ErrDuplicate if the email is already known.
+	Create(c *models.Contact) error
+	Update(id string, u ContactUpdate) error
+	// RecordSighting bumps the meeting count and last seen time, filling in
+	// the name if the contact has none yet
+	RecordSighting(id, name string) error
+	Delete(id string) error
+	Restore(id string) error
+	PermanentDelete(id string) error
+	EmptyTrash() (int64, error)
+	SoftDeleteMany(ids []string) (int64, error)
+	DeleteMany(ids []string) error
+	SetInternal(id string, internal bool) error
+	SetInternalMany(ids []string, internal bool) error
+	SetAccountMany(ids []string, accountID string) error
+	// SetSuggestedAccount records a suggestion for contacts not yet linked
+	SetSuggestedAccount(contactID, accountID string) error
+	// ResolveSuggestion accepts or rejects the suggested account
+	ResolveSuggestion(id string, confirm bool) error
+	LinkToAccount(contactID, accountID string) error
+	// LinkDomain links every external contact on a domain to an account
+	LinkDomain(domain, accountID string) (int64, error)
+	// Notes returns notes that list email as a participant
+	Notes(email string) ([]models.ContactNote, error)
+	Stats() (*models.ContactStats, error)
+	// DomainGroups groups external contacts by email domain
+	DomainGroups(unlinkedOnly bool) ([]models.DomainGroup, error)
+	// TopAccountForDomain returns the live account holding the most contacts
+	// from domain
+	TopAccountForDomain(domain string) (*models.AccountRef, error)
+}
+
+// ContactFilter narrows List results
+type ContactFilter struct {
+	Filter    string // "internal", "external", "unlinked", "suggestions"
+	AccountID string
+}
+
+// ContactUpdate holds the fields to change on a contact; nil fields are left
+// alone and an empty AccountID unlinks the contact
+type ContactUpdate struct {
+	Name      *string
+	Company   *string
+	AccountID *string
+}
+
+var contactSorts = sortKeys{
+	keys: map[string]sortKey{
+		"last_seen":     {expr: "COALESCE(c.last_seen, '')", desc: true},
+		"name":          {expr: "LOWER(COALESCE(c.name, ''))"},
+		"email":         {expr: "c.email"},
+		"meeting_count": {expr: "COALESCE(c.meeting_count, 0)", desc: true},
+		"created_at":    {expr: "c.created_at", desc: true},
+	},
+	fallback: "last_seen",
+}
+
+type sqliteContactStore struct {
+	db *sql.DB
+}
+
+const contactFrom = `contacts c
+	LEFT JOIN accounts a ON c.account_id = a.id
+	LEFT JOIN accounts sa ON c.suggested_account_id = sa.id`
+
+const contactSelect = `
+	SELECT c.id, c.email, COALESCE(c.name, ''), COALESCE(c.company, ''), c.domain, c.is_internal,
+	       c.account_id, a.name, c.suggested_account_id, sa.name,
+	       c.suggestion_confirmed, COALESCE(c.source, ''), c.first_seen, c.last_seen,
+	       c.meeting_count, c.created_at, c.updated_at, c.deleted_at
+	FROM ` + contactFrom
+
+func scanContact(row scanner) (*models.Contact, error) {
+	var contact models.Contact
+	var accountID, accountName, suggestedAccountID, suggestedAccountName sql.NullString
+	var deletedAt sql.NullTime
+
+	if err := row.Scan(
+		&contact.ID, &contact.Email, &contact.Name, &contact.Company, &contact.Domain,
+		&contact.IsInternal, &accountID, &accountName, &suggestedAccountID, &suggestedAccountName,
+		&contact.SuggestionConfirmed, &contact.Source, &contact.FirstSeen, &contact.LastSeen,
+		&contact.MeetingCount, &contact.CreatedAt, &contact.UpdatedAt, &deletedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if accountID.Valid {
+		contact.AccountID = &accountID.String
+		contact.AccountName = accountName.String
+	}
+	if suggestedAccountID.Valid {
+		contact.SuggestedAccountID = &suggestedAccountID.String
+		contact.SuggestedAccountName = suggestedAccountName.String
+	}
+	contact.DeletedAt = nullTimePtr(deletedAt)
+	return &contact, nil
+}
+
+func (s *sqliteContactStore) queryContacts(query string, args ...interface{}) ([]models.Contact, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []models.Contact{}
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, *contact)
+	}
+	return contacts, rows.Err()
+}
+
+func (s *sqliteContactStore) List(f ContactFilter, o ListOptions) ([]models.Contact, PageInfo, error) {
+	q := newListQuery(contactFrom, "c.id", []string{"c.deleted_at IS NULL"}, nil)
+	switch f.Filter {
+	case "internal":
+		q.where("c.is_internal = 1")
+	case "external":
+		q.where("c.is_internal = 0")
+	case "unlinked":
+		q.where("c.account_id IS NULL AND c.is_internal = 0")
+	case "suggestions":
+		q.where("c.suggested_account_id IS NOT NULL AND c.suggestion_confirmed = 0")
+	}
+	if f.AccountID != "" {
+		q.where("c.account_id = ?", f.AccountID)
+	}
+	if err := q.sortBy(contactSorts, o); err != nil {
+		return nil, PageInfo{}, err
+	}
+	return listPage(s.db, q, contactSelect, o, s.queryContacts, func(c models.Contact) string { return c.ID })
+}
+
+func (s *sqliteContactStore) ListByDomain(domain string) ([]models.Contact, error) {
+	return s.queryContacts(contactSelect+` WHERE c.domain = ? ORDER BY c.name ASC`, domain)
+}
+
+func (s *sqliteContactStore) ListDeleted() ([]models.Contact, error) {
+	return s.queryContacts(contactSelect + ` WHERE c.deleted_at IS NOT NULL ORDER BY c.deleted_at DESC`)
+}
+
+func (s *sqliteContactStore) Get(id string) (*models.Contact, error) {
+	contact, err := scanContact(s.db.QueryRow(contactSelect+` WHERE c.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return contact, err
+}
+
+func (s *sqliteContactStore) GetByEmail(email string) (*models.Contact, error) {
+	contact, err := scanContact(s.db.QueryRow(contactSelect+` WHERE c.email = ?`, email))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return contact, err
+}
+
+func (s *sqliteContactStore) Create(c *models.Contact) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO contacts (id, email, name, company, domain, is_internal, source, meeting_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, c.ID, c.Email, c.Name, c.Company, c.Domain, c.IsInternal, c.Source, c.MeetingCount)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *sqliteContactStore) Update(id string, u ContactUpdate) error {
+	if u.Name != nil {
+		if _, err := s.db.Exec(`UPDATE contacts SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, *u.Name, id); err != nil {
+			return err
+		}
+	}
+	if u.Company != nil {
+		if _, err := s.db.Exec(`UPDATE contacts SET company = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, *u.Company, id); err != nil {
+			return err
+		}
+	}
+	if u.AccountID != nil {
+		var accountID interface{}
+		if *u.AccountID != "" {
+			accountID = *u.AccountID
+		}
+		if _, err := s.db.Exec(`UPDATE contacts SET account_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, accountID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteContactStore) RecordSighting(id, name string) error {
+	_, err := s.db.Exec(`
+		UPDATE contacts
+		SET name = CASE WHEN name = '' AND ? != '' THEN ? ELSE name END,
+		    last_seen = CURRENT_TIMESTAMP,
+		    meeting_count = meeting_count + 1,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, name, name, id)
+	return err
+}
+
+func (s *sqliteContactStore) Delete(id string) error {
+	return expectAffected(s.db.Exec(`UPDATE contacts SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`, id))
+}
+
+func (s *sqliteContactStore) Restore(id string) error {
+	return expectAffected(s.db.Exec(`UPDATE contacts SET deleted_at = NULL WHERE id = ?`, id))
+}
+
+func (s *sqliteContactStore) PermanentDelete(id string) error {
+	return expectAffected(s.db.Exec(`DELETE FROM contacts WHERE id = ?`, id))
+}
+
+func (s *sqliteContactStore) EmptyTrash() (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM contacts WHERE deleted_at IS NOT NULL`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteContactStore) SoftDeleteMany(ids []string) (int64, error) {
+	in, args := placeholders(ids)
+	result, err := s.db.Exec(`UPDATE contacts SET deleted_at = CURRENT_TIMESTAMP WHERE id IN (`+in+`) AND deleted_at IS NULL`, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteContactStore) DeleteMany(ids []string) error {
+	in, args := placeholders(ids)
+	_, err := s.db.Exec("DELETE FROM contacts WHERE id IN ("+in+")", args...)
+	return err
+}
+
+func (s *sqliteContactStore) SetInternal(id string, internal bool) error {
+	return expectAffected(s.db.Exec(`UPDATE contacts SET is_internal = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, internal, id))
+}
+
+func (s *sqliteContactStore) SetInternalMany(ids []string, internal bool) error {
+	in, args := placeholders(ids)
+	_, err := s.db.Exec("UPDATE contacts SET is_internal = ?, updated_at = CURRENT_TIMESTAMP WHERE id IN ("+in+")",
+		append([]interface{}{internal}, args...)...)
+	return err
+}
+
+func (s *sqliteContactStore) SetAccountMany(ids []string, accountID string) error {
+	in, args := placeholders(ids)
+	_, err := s.db.Exec("UPDATE contacts SET account_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id IN ("+in+")",
+		append([]interface{}{accountID}, args...)...)
+	return err
+}
+
+func (s *sqliteContactStore) SetSuggestedAccount(contactID, accountID string) error {
+	_, err := s.db.Exec(`
+		UPDATE contacts
+		SET suggested_account_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND account_id IS NULL
+	`, accountID, contactID)
+	return err
+}
+
+func (s *sqliteContactStore) ResolveSuggestion(id string, confirm bool) error {
+	if confirm {
+		// Move suggested_account_id to account_id
+		_, err := s.db.Exec(`
+			UPDATE contacts
+			SET account_id = suggested_account_id,
+			    suggestion_confirmed = 1,
+			    updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, id)
+		return err
+	}
+
+	// Clear the suggestion
+	_, err := s.db.Exec(`
+		UPDATE contacts
+		SET suggested_account_id = NULL,
+		    suggestion_confirmed = 1,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, id)
+	return err
+}
+
+func (s *sqliteContactStore) LinkToAccount(contactID, accountID string) error {
+	_, err := s.db.Exec(`
+		UPDATE contacts
+		SET account_id = ?, suggestion_confirmed = 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, accountID, contactID)
+	return err
+}
+
+func (s *sqliteContactStore) LinkDomain(domain, accountID string) (int64, error) {
+	result, err := s.db.Exec(`
+		UPDATE contacts
+		SET account_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE domain = ? AND is_internal = 0
+	`, accountID, domain)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteContactStore) Notes(email string) ([]models.ContactNote, error) {
+	// Find notes where this email appears in participants
+	rows, err := s.db.Query(`
+		SELECT n.id, n.title, n.account_id, a.name, n.meeting_date, n.created_at
+		FROM notes n
+		LEFT JOIN accounts a ON n.account_id = a.id
+		WHERE n.deleted_at IS NULL
+		  AND (n.internal_participants LIKE ? OR n.external_participants LIKE ?)
+		ORDER BY COALESCE(n.meeting_date, n.created_at) DESC
+		LIMIT 50
+	`, "%"+email+"%", "%"+email+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []models.ContactNote{}
+	for rows.Next() {
+		var note models.ContactNote
+		var accountID, accountName sql.NullString
+		var meetingDate sql.NullTime
+
+		if err := rows.Scan(&note.ID, &note.Title, &accountID, &accountName, &meetingDate, &note.CreatedAt); err != nil {
+			return nil, err
+		}
+		if accountID.Valid {
+			note.AccountID = &accountID.String
+			note.AccountName = accountName.String
+		}
+		note.MeetingDate = nullTimePtr(meetingDate)
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+func (s *sqliteContactStore) Stats() (*models.ContactStats, error) {
+	var stats models.ContactStats
+	err := s.db.QueryRow(`
+		SELECT COUNT(*),
+		       COALESCE(SUM(CASE WHEN is_internal = 1 THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN is_internal = 0 THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN account_id IS NOT NULL THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN suggested_account_id IS NOT NULL AND suggestion_confirmed = 0 THEN 1 ELSE 0 END), 0)
+		FROM contacts
+	`).Scan(&stats.TotalContacts, &stats.InternalContacts, &stats.ExternalContacts,
+		&stats.LinkedContacts, &stats.PendingSuggestions)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (s *sqliteContactStore) DomainGroups(unlinkedOnly bool) ([]models.DomainGroup, error) {
+	inner := `
+		SELECT c.domain, COUNT(*) as count, c.is_internal,
+		       GROUP_CONCAT(c.id) as contact_ids,
+		       MAX(c.account_id) as account_id
+		FROM contacts c
+		WHERE c.is_internal = 0`
+	if unlinkedOnly {
+		inner += " AND c.account_id IS NULL"
+	}
+	inner += " GROUP BY c.domain"
+
+	rows, err := s.db.Query(`
+		SELECT g.domain, g.count, g.is_internal, g.contact_ids, g.account_id, COALESCE(a.name, '')
+		FROM (` + inner + `) g
+		LEFT JOIN accounts a ON a.id = g.account_id
+		ORDER BY g.count DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.DomainGroup{}
+	for rows.Next() {
+		var group models.DomainGroup
+		var contactIDs string
+		var accountID sql.NullString
+		if err := rows.Scan(&group.Domain, &group.ContactCount, &group.IsInternal, &contactIDs, &accountID, &group.LinkedAccountName); err != nil {
+			return nil, err
+		}
+		group.ContactIDs = strings.Split(contactIDs, ",")
+		group.LinkedAccountID = nullStringPtr(accountID)
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+func (s *sqliteContactStore) TopAccountForDomain(domain string) (*models.AccountRef, error) {
+	var ref models.AccountRef
+	err := s.db.QueryRow(`
+		SELECT a.id, a.name FROM accounts a
+		INNER JOIN contacts c ON c.account_id = a.id
+		WHERE c.domain = ? AND a.deleted_at IS NULL
+		GROUP BY a.id
+		ORDER BY COUNT(*) DESC
+		LIMIT 1
+	`, domain).Scan(&ref.ID, &ref.Name)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ref, nil
+}
@@ -0,0 +1,278 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by the
+// same list with the same sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// MaxLimit is the largest page a list returns
+const MaxLimit = 500
+
+// ListOptions pages and sorts a list. The zero value returns every row in
+// the list's default order.
+type ListOptions struct {
+	// Limit is the page size, at most MaxLimit; 0 returns every row
+	Limit int
+	// Cursor is PageInfo.NextCursor from the previous page
+	Cursor string
+	// Sort names the field to order by, empty for the list's default
+	Sort string
+	// Order is "asc" or "desc", empty for the sort's default
+	Order string
+}
+
+// PageInfo describes the rows around a page
+type PageInfo struct {
+	// Total counts every row matching the filters, across all pages
+	Total int
+	// NextCursor fetches the following page, empty on the last one
+	NextCursor string
+}
+
+// InvalidSortError is returned for a sort field or order a list does not
+// support. Option is "sort" or "order".
+type InvalidSortError struct {
+	Option  string
+	Value   string
+	Allowed []string
+}
+
+func (e *InvalidSortError) Error() string {
+	return fmt.Sprintf("invalid %s %q, must be one of: %s", e.Option, e.Value, strings.Join(e.Allowed, ", "))
+}
+
+// sortKey is a field a list can be ordered by. expr may be NULL; as in
+// SQLite's own ordering, NULLs come first ascending and last descending.
+type sortKey struct {
+	expr string
+	desc bool
+}
+
+// sortKeys maps each field a list can be ordered by to its column, and names
+// the default one
+type sortKeys struct {
+	keys     map[string]sortKey
+	fallback string
+}
+
+func (s sortKeys) names() []string {
+	names := make([]string, 0, len(s.keys))
+	for name := range s.keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// listQuery builds the page of a filtered list. from is the FROM clause and
+// id the column that breaks ties between rows that sort the same.
+type listQuery struct {
+	from  string
+	id    string
+	conds []string
+	args  []interface{}
+
+	name string
+	key  sortKey
+}
+
+func newListQuery(from, id string, conds []string, args []interface{}) *listQuery {
+	return &listQuery{from: from, id: id, conds: conds, args: args}
+}
+
+// where adds a condition on the rows
+func (q *listQuery) where(cond string, args ...interface{}) {
+	q.conds = append(q.conds, cond)
+	q.args = append(q.args, args...)
+}
+
+// sortBy picks the order from the options
+func (q *listQuery) sortBy(keys sortKeys, o ListOptions) error {
+	q.name = o.Sort
+	if q.name == "" {
+		q.name = keys.fallback
+	}
+	key, ok := keys.keys[q.name]
+	if !ok {
+		return &InvalidSortError{Option: "sort", Value: o.Sort, Allowed: keys.names()}
+	}
+	switch strings.ToLower(o.Order) {
+	case "":
+	case "asc":
+		key.desc = false
+	case "desc":
+		key.desc = true
+	default:
+		return &InvalidSortError{Option: "order", Value: o.Order, Allowed: []string{"asc", "desc"}}
+	}
+	q.key = key
+	return nil
+}
+
+func (q *listQuery) whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// count returns how many rows match the filters
+func (q *listQuery) count(db *sql.DB) (int, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM "+q.from+q.whereClause(q.conds), q.args...).Scan(&n)
+	return n, err
+}
+
+// page returns the query for the page after the cursor, with one row more
+// than the limit so the caller can tell whether another page follows. sel
+// is the SELECT clause through FROM.
+func (q *listQuery) page(sel string, o ListOptions) (string, []interface{}, error) {
+	conds := append([]string{}, q.conds...)
+	args := append([]interface{}{}, q.args...)
+	dir, op := "ASC", ">"
+	if q.key.desc {
+		dir, op = "DESC", "<"
+	}
+
+	if o.Cursor != "" {
+		c, err := decodeListCursor(o.Cursor)
+		if err != nil || c.Sort != q.sortName() {
+			return "", nil, ErrInvalidCursor
+		}
+		v, err := c.value()
+		if err != nil {
+			return "", nil, ErrInvalidCursor
+		}
+		cond, condArgs := q.after(op, v, c.ID)
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+
+	query := sel + q.whereClause(conds) +
+		fmt.Sprintf(" ORDER BY %s %s, %s %s", q.key.expr, dir, q.id, dir)
+	if o.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, o.Limit+1)
+	}
+	return query, args, nil
+}
+
+// after is the condition for the rows that follow the one with sort value v
+// and id. A NULL v sorts before every value ascending and after every value
+// descending.
+func (q *listQuery) after(op string, v interface{}, id string) (string, []interface{}) {
+	expr := q.key.expr
+	switch {
+	case v == nil && q.key.desc:
+		return fmt.Sprintf("(%s IS NULL AND %s %s ?)", expr, q.id, op), []interface{}{id}
+	case v == nil:
+		return fmt.Sprintf("(%s IS NOT NULL OR %s %s ?)", expr, q.id, op), []interface{}{id}
+	case q.key.desc:
+		return fmt.Sprintf("(%s %s ? OR %s IS NULL OR (%s = ? AND %s %s ?))", expr, op, expr, expr, q.id, op),
+			[]interface{}{v, v, id}
+	}
+	return fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", expr, op, expr, q.id, op), []interface{}{v, v, id}
+}
+
+// cursor returns the cursor for the page that follows the row with id
+func (q *listQuery) cursor(db *sql.DB, id string) (string, error) {
+	c := listCursor{Sort: q.sortName(), ID: id}
+	var value sql.NullString
+	err := db.QueryRow("SELECT typeof("+q.key.expr+"), CAST("+q.key.expr+" AS TEXT) FROM "+q.from+" WHERE "+q.id+" = ?", id).
+		Scan(&c.Type, &value)
+	if err != nil {
+		return "", err
+	}
+	c.Value = value.String
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func (q *listQuery) sortName() string {
+	if q.key.desc {
+		return q.name + ":desc"
+	}
+	return q.name + ":asc"
+}
+
+// listCursor holds the sort value and id of the last row of a page. The
+// value is kept as text with its SQLite type so that it compares exactly as
+// the column does; a NULL value has type "null".
+type listCursor struct {
+	Sort  string `json:"s"`
+	Type  string `json:"t"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func decodeListCursor(s string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (c *listCursor) value() (interface{}, error) {
+	switch c.Type {
+	case "integer":
+		return strconv.ParseInt(c.Value, 10, 64)
+	case "real":
+		return strconv.ParseFloat(c.Value, 64)
+	case "text":
+		return c.Value, nil
+	case "null":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected cursor type %q", c.Type)
+}
+
+// listPage runs a list query and returns the page the options ask for.
+// scan runs the query and id returns a row's id.
+func listPage[T any](db *sql.DB, q *listQuery, sel string, o ListOptions,
+	scan func(query string, args ...interface{}) ([]T, error), id func(T) string) ([]T, PageInfo, error) {
+	if o.Limit > MaxLimit {
+		o.Limit = MaxLimit
+	}
+	var info PageInfo
+	var err error
+	if info.Total, err = q.count(db); err != nil {
+		return nil, info, err
+	}
+	query, args, err := q.page(sel, o)
+	if err != nil {
+		return nil, info, err
+	}
+	rows, err := scan(query, args...)
+	if err != nil {
+		return nil, info, err
+	}
+	if o.Limit > 0 && len(rows) > o.Limit {
+		rows = rows[:o.Limit]
+		if info.NextCursor, err = q.cursor(db, id(rows[o.Limit-1])); err != nil {
+			return nil, info, err
+		}
+	}
+	return rows, info, nil
+}
+
+// utcTime formats t for comparison with julianday, which reads any of the
+// formats timestamps are stored in
+func utcTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.000")
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/tasks"
+)
+
+// TaskSync is what saving a note does to the todos of its task items: the
+// todos to create, linked to the note, the existing todos to link, such as
+// those of items pasted from another note, and the statuses to move todos
+// to so that they match their items' checkboxes
+type TaskSync struct {
+	Create []*models.Todo
+	Link   []string
+	Status map[string]string
+}
+
+// TaskResult reports what a TaskSync did. Moved counts the todos whose
+// status changed. Skipped holds, by todo ID, the *TransitionError of each
+// move the workflow does not allow; the items of those todos are saved with
+// their checkbox matching the todo instead.
+type TaskResult struct {
+	Moved   int
+	Skipped map[string]error
+}
+
+// checkTaskMoves returns the moves the workflow allows. Items whose move is
+// not allowed are reset in content to match their todo, and todos that no
+// longer exist are left out.
+func checkTaskMoves(tx *sql.Tx, content *string, moves map[string]string, result *TaskResult) (map[string]string, error) {
+	allowed := map[string]string{}
+	reset := map[string]bool{}
+	for id, status := range moves {
+		var current string
+		var done bool
+		err := tx.QueryRow(`
+			SELECT COALESCE(status, ''), COALESCE(status, '') IN `+doneStatuses+` FROM todos WHERE id = ? AND deleted_at IS NULL
+		`, id).Scan(&current, &done)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = checkTransition(tx, current, status)
+		var transitionErr *TransitionError
+		if errors.As(err, &transitionErr) {
+			result.Skipped[id] = err
+			reset[id] = done
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		allowed[id] = status
+	}
+
+	if len(reset) > 0 {
+		items := tasks.Parse(*content)
+		for i := range items {
+			if done, ok := reset[items[i].TodoID]; ok {
+				items[i].Checked = done
+			}
+		}
+		*content = tasks.Update(*content, items)
+	}
+	return allowed, nil
+}
+
+// applyTasks creates the todos of new task items, links the existing ones
+// to the note, and makes the moves checkTaskMoves allowed
+func applyTasks(tx *sql.Tx, noteID string, sync TaskSync, moves map[string]string, result *TaskResult) error {
+	for _, t := range sync.Create {
+		if err := createTodo(tx, t, &noteID); err != nil {
+			return err
+		}
+	}
+	for _, id := range sync.Link {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO note_todos (note_id, todo_id) SELECT ?, id FROM todos WHERE id = ?", noteID, id); err != nil {
+			return err
+		}
+	}
+	for id, status := range moves {
+		s := status
+		if err := updateTodo(tx, id, TodoUpdate{Status: &s}); err != nil {
+			return err
+		}
+		result.Moved++
+	}
+	return nil
+}
+
+// syncTaskChecks ticks or unticks the task items of a todo in its linked
+// notes to match whether it is done. The notes' versions move on so that
+// stale copies are caught, but the checkbox is not recorded as a revision.
+func syncTaskChecks(tx *sql.Tx, todoID string, done bool) error {
+	rows, err := tx.Query(`
+		SELECT n.id, COALESCE(n.content, '')
+		FROM notes n
+		JOIN note_todos nt ON nt.note_id = n.id
+		WHERE nt.todo_id = ?
+	`, todoID)
+	if err != nil {
+		return err
+	}
+	contents := map[string]string{}
+	for rows.Next() {
+		var id, content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		contents[id] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, content := range contents {
+		items := tasks.Parse(content)
+		changed := false
+		for i := range items {
+			if items[i].TodoID == todoID && items[i].Checked != done {
+				items[i].Checked = done
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE notes SET content = ?, version = version + 1 WHERE id = ?",
+			tasks.Update(content, items), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,566 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// NoteStore persists meeting notes
+type NoteStore interface {
+	// List returns a page of the notes not in the trash that match f, newest
+	// first unless o sorts them otherwise
+	List(f NoteFilter, o ListOptions) ([]models.Note, PageInfo, error)
+	ListByAccount(accountID string) ([]models.Note, error)
+	ListArchived() ([]models.Note, error)
+	ListDeleted() ([]models.Note, error)
+	// Get returns a note with its account details and linked todos
+	Get(id string) (*models.Note, error)
+	// Create inserts a note, assigning an ID and timestamps when unset, and
+	// records its first revision
+	Create(n *models.Note) error
+	// CreateWithTasks creates a note and applies sync to the todos of its
+	// task items in the same transaction. Items whose move the workflow does
+	// not allow are reset in n.Content.
+	CreateWithTasks(n *models.Note, sync TaskSync) (*TaskResult, error)
+	// Update applies u, recording a revision when the title or content changes
+	Update(id string, u NoteUpdate) error
+	// UpdateWithTasks updates a note as Update does and applies sync to the
+	// todos of its task items in the same transaction. Items whose move the
+	// workflow does not allow are reset in *u.Content.
+	UpdateWithTasks(id string, u NoteUpdate, sync TaskSync) (*TaskResult, error)
+	Delete(id string) error
+	Restore(id string) error
+	PermanentDelete(id string) error
+	EmptyTrash() (int64, error)
+	TogglePin(id string) (bool, error)
+	ToggleArchive(id string) (bool, error)
+	Reorder(accountID string, noteIDs []string) error
+	// Bulk makes the same change to every note in ids in one transaction.
+	// Notes in the trash can only be deleted again, which changes nothing,
+	// or restored; other changes to them fail with ErrTrashed. If any note
+	// cannot be changed it returns a *BulkError and changes none.
+	Bulk(ids []string, op NoteBulkOp) error
+}
+
+// NoteBulkOp is the change Bulk makes to each note: Delete, Restore, a tag
+// to add or remove, or else Update
+type NoteBulkOp struct {
+	Delete  bool
+	Restore bool
+	// AddTag and RemoveTag are tag IDs
+	AddTag    string
+	RemoveTag string
+	// Update is applied as by Update; ExpectedVersion is ignored
+	Update NoteUpdate
+}
+
+// NoteUpdate holds the fields to change on a note; nil fields are left alone
+type NoteUpdate struct {
+	Title                *string
+	AccountID            *string
+	TemplateType         *string
+	InternalParticipants []string
+	ExternalParticipants []string
+	Content              *string
+	MeetingID            *string
+	MeetingDate          *time.Time
+	Pinned               *bool
+	Archived             *bool
+	SortOrder            *int
+	// ExpectedVersion, when set, makes the update fail with ErrConflict
+	// unless the note is still at this version
+	ExpectedVersion *int
+}
+
+// IsEmpty reports whether the update changes nothing
+func (u NoteUpdate) IsEmpty() bool {
+	return u.Title == nil && u.AccountID == nil && u.TemplateType == nil &&
+		u.InternalParticipants == nil && u.ExternalParticipants == nil &&
+		u.Content == nil && u.MeetingID == nil && u.MeetingDate == nil &&
+		u.Pinned == nil && u.Archived == nil && u.SortOrder == nil
+}
+
+// NoteFilter narrows List results; zero fields match every note
+type NoteFilter struct {
+	AccountID string
+	// Tags are matched case-insensitively along with their children. Every
+	// tag must be on the note unless MatchAny is set.
+	Tags         []string
+	MatchAny     bool
+	TemplateType string
+	Pinned       *bool
+	Archived     *bool
+	// From and To bound the meeting date, or the creation date of notes
+	// without one; To is exclusive
+	From *time.Time
+	To   *time.Time
+	// OmitContent leaves Content empty, for lists that don't return it
+	OmitContent bool
+}
+
+var noteSorts = sortKeys{
+	keys: map[string]sortKey{
+		"created_at":   {expr: "n.created_at", desc: true},
+		"updated_at":   {expr: "n.updated_at", desc: true},
+		"meeting_date": {expr: "COALESCE(n.meeting_date, n.created_at)", desc: true},
+		"title":        {expr: "LOWER(n.title)"},
+		"sort_order":   {expr: "COALESCE(n.sort_order, 0)"},
+	},
+	fallback: "created_at",
+}
+
+type sqliteNoteStore struct {
+	db *sql.DB
+}
+
+const noteFrom = `notes n
+	LEFT JOIN accounts a ON n.account_id = a.id`
+
+const noteSelect = `
+	SELECT n.id, n.title, n.account_id, COALESCE(n.template_type, ''),
+	       COALESCE(n.internal_participants, '[]'), COALESCE(n.external_participants, '[]'),
+	       COALESCE(n.content, ''), n.meeting_id, n.meeting_date,
+	       COALESCE(n.pinned, 0), COALESCE(n.archived, 0), COALESCE(n.sort_order, 0), n.version,
+	       n.created_at, n.updated_at, n.deleted_at, COALESCE(a.name, '')
+	FROM ` + noteFrom
+
+// noteListSelect is noteSelect without the content, which is most of a
+// note's size
+var noteListSelect = strings.Replace(noteSelect, "COALESCE(n.content, '')", "''", 1)
+
+func scanNote(row scanner) (*models.Note, error) {
+	var n models.Note
+	var accountID sql.NullString
+	var internalJSON, externalJSON string
+	var meetingDate, deletedAt sql.NullTime
+
+	if err := row.Scan(&n.ID, &n.Title, &accountID, &n.TemplateType, &internalJSON, &externalJSON,
+		&n.Content, &n.MeetingID, &meetingDate, &n.Pinned, &n.Archived, &n.SortOrder, &n.Version,
+		&n.CreatedAt, &n.UpdatedAt, &deletedAt, &n.AccountName); err != nil {
+		return nil, err
+	}
+
+	n.AccountID = accountID.String
+	n.MeetingDate = nullTimePtr(meetingDate)
+	n.DeletedAt = nullTimePtr(deletedAt)
+	if err := json.Unmarshal([]byte(internalJSON), &n.InternalParticipants); err != nil {
+		log.Printf("Error unmarshalling internal participants for note %s: %v", n.ID, err)
+	}
+	if err := json.Unmarshal([]byte(externalJSON), &n.ExternalParticipants); err != nil {
+		log.Printf("Error unmarshalling external participants for note %s: %v", n.ID, err)
+	}
+	return &n, nil
+}
+
+func (s *sqliteNoteStore) queryNotes(query string, args ...interface{}) ([]models.Note, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []models.Note{}
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, *n)
+	}
+	return notes, rows.Err()
+}
+
+func (s *sqliteNoteStore) List(f NoteFilter, o ListOptions) ([]models.Note, PageInfo, error) {
+	q := newListQuery(noteFrom, "n.id", []string{"n.deleted_at IS NULL"}, nil)
+	if f.AccountID != "" {
+		q.where("n.account_id = ?", f.AccountID)
+	}
+	if len(f.Tags) > 0 {
+		cond, args := tagCond(noteTags, "n.id", f.Tags, f.MatchAny)
+		q.where(cond, args...)
+	}
+	if f.TemplateType != "" {
+		q.where("n.template_type = ?", f.TemplateType)
+	}
+	if f.Pinned != nil {
+		q.where("COALESCE(n.pinned, 0) = ?", *f.Pinned)
+	}
+	if f.Archived != nil {
+		q.where("COALESCE(n.archived, 0) = ?", *f.Archived)
+	}
+	if f.From != nil {
+		q.where("julianday(COALESCE(n.meeting_date, n.created_at)) >= julianday(?)", utcTime(*f.From))
+	}
+	if f.To != nil {
+		q.where("julianday(COALESCE(n.meeting_date, n.created_at)) < julianday(?)", utcTime(*f.To))
+	}
+	if err := q.sortBy(noteSorts, o); err != nil {
+		return nil, PageInfo{}, err
+	}
+	sel := noteSelect
+	if f.OmitContent {
+		sel = noteListSelect
+	}
+	return listPage(s.db, q, sel, o, s.queryNotes, func(n models.Note) string { return n.ID })
+}
+
+func (s *sqliteNoteStore) ListByAccount(accountID string) ([]models.Note, error) {
+	return s.queryNotes(noteSelect+` WHERE n.account_id = ? AND n.deleted_at IS NULL ORDER BY n.created_at DESC`, accountID)
+}
+
+func (s *sqliteNoteStore) ListArchived() ([]models.Note, error) {
+	return s.queryNotes(noteSelect + ` WHERE n.archived = 1 ORDER BY n.updated_at DESC`)
+}
+
+func (s *sqliteNoteStore) ListDeleted() ([]models.Note, error) {
+	return s.queryNotes(noteSelect + ` WHERE n.deleted_at IS NOT NULL ORDER BY n.deleted_at DESC`)
+}
+
+func (s *sqliteNoteStore) Get(id string) (*models.Note, error) {
+	n, err := scanNote(s.db.QueryRow(noteSelect+` WHERE n.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Account details for exports
+	var a models.Account
+	var accountOwner sql.NullString
+	var createdAt, updatedAt sql.NullTime
+	err = s.db.QueryRow(`
+		SELECT id, name, account_owner, budget, est_engineers, created_at, updated_at
+		FROM accounts WHERE id = ?
+	`, n.AccountID).Scan(&a.ID, &a.Name, &accountOwner, &a.Budget, &a.EstEngineers, &createdAt, &updatedAt)
+	if err == nil {
+		a.AccountOwner = accountOwner.String
+		a.CreatedAt = createdAt.Time
+		a.UpdatedAt = updatedAt.Time
+		n.Account = &a
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// Linked todos
+	todoRows, err := s.db.Query(`
+		SELECT t.id, t.title, COALESCE(t.description, ''), COALESCE(t.status, ''), COALESCE(t.priority, ''),
+		       t.due_date, t.created_at, t.updated_at
+		FROM todos t
+		JOIN note_todos nt ON t.id = nt.todo_id
+		WHERE nt.note_id = ?
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer todoRows.Close()
+	for todoRows.Next() {
+		var todo models.Todo
+		var dueDate sql.NullTime
+		if err := todoRows.Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Status, &todo.Priority,
+			&dueDate, &todo.CreatedAt, &todo.UpdatedAt); err != nil {
+			return nil, err
+		}
+		todo.DueDate = nullTimePtr(dueDate)
+		n.Todos = append(n.Todos, todo)
+	}
+
+	return n, todoRows.Err()
+}
+
+func (s *sqliteNoteStore) Create(n *models.Note) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createNote(tx, n); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteNoteStore) CreateWithTasks(n *models.Note, sync TaskSync) (*TaskResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &TaskResult{Skipped: map[string]error{}}
+	allowed, err := checkTaskMoves(tx, &n.Content, sync.Status, result)
+	if err != nil {
+		return nil, err
+	}
+	if err := createNote(tx, n); err != nil {
+		return nil, err
+	}
+	if err := applyTasks(tx, n.ID, sync, allowed, result); err != nil {
+		return nil, err
+	}
+	return result, tx.Commit()
+}
+
+func createNote(tx *sql.Tx, n *models.Note) error {
+	if n.ID == "" {
+		n.ID = uuid.New().String()
+	}
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	if n.UpdatedAt.IsZero() {
+		n.UpdatedAt = n.CreatedAt
+	}
+	n.Version = 1
+
+	internalJSON, _ := json.Marshal(n.InternalParticipants)
+	externalJSON, _ := json.Marshal(n.ExternalParticipants)
+
+	_, err := tx.Exec(`
+		INSERT INTO notes (id, title, account_id, template_type, internal_participants, external_participants, content, meeting_id, meeting_date, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, n.ID, n.Title, n.AccountID, n.TemplateType, string(internalJSON), string(externalJSON), n.Content,
+		n.MeetingID, n.MeetingDate, n.CreatedAt, n.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return recordRevision(tx, n.ID, n.Title, n.Content)
+}
+
+func (s *sqliteNoteStore) Update(id string, u NoteUpdate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateNote(tx, id, u); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteNoteStore) UpdateWithTasks(id string, u NoteUpdate, sync TaskSync) (*TaskResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &TaskResult{Skipped: map[string]error{}}
+	allowed := map[string]string{}
+	if u.Content != nil {
+		if allowed, err = checkTaskMoves(tx, u.Content, sync.Status, result); err != nil {
+			return nil, err
+		}
+	}
+	if err := updateNote(tx, id, u); err != nil {
+		return nil, err
+	}
+	if err := applyTasks(tx, id, sync, allowed, result); err != nil {
+		return nil, err
+	}
+	return result, tx.Commit()
+}
+
+func updateNote(tx *sql.Tx, id string, u NoteUpdate) error {
+	updates := []string{}
+	args := []interface{}{}
+
+	if u.Title != nil {
+		updates = append(updates, "title = ?")
+		args = append(args, *u.Title)
+	}
+	if u.AccountID != nil {
+		updates = append(updates, "account_id = ?")
+		args = append(args, *u.AccountID)
+	}
+	if u.TemplateType != nil {
+		updates = append(updates, "template_type = ?")
+		args = append(args, *u.TemplateType)
+	}
+	if u.InternalParticipants != nil {
+		internalJSON, _ := json.Marshal(u.InternalParticipants)
+		updates = append(updates, "internal_participants = ?")
+		args = append(args, string(internalJSON))
+	}
+	if u.ExternalParticipants != nil {
+		externalJSON, _ := json.Marshal(u.ExternalParticipants)
+		updates = append(updates, "external_participants = ?")
+		args = append(args, string(externalJSON))
+	}
+	if u.Content != nil {
+		updates = append(updates, "content = ?")
+		args = append(args, *u.Content)
+	}
+	if u.MeetingID != nil {
+		updates = append(updates, "meeting_id = ?")
+		args = append(args, *u.MeetingID)
+	}
+	if u.MeetingDate != nil {
+		updates = append(updates, "meeting_date = ?")
+		args = append(args, *u.MeetingDate)
+	}
+	if u.Pinned != nil {
+		updates = append(updates, "pinned = ?")
+		args = append(args, *u.Pinned)
+	}
+	if u.Archived != nil {
+		updates = append(updates, "archived = ?")
+		args = append(args, *u.Archived)
+	}
+	if u.SortOrder != nil {
+		updates = append(updates, "sort_order = ?")
+		args = append(args, *u.SortOrder)
+	}
+
+	updates = append(updates, "updated_at = ?")
+	args = append(args, time.Now())
+	args = append(args, id)
+
+	var oldTitle, oldContent string
+	var version int
+	err := tx.QueryRow("SELECT title, COALESCE(content, ''), version FROM notes WHERE id = ?", id).
+		Scan(&oldTitle, &oldContent, &version)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if u.ExpectedVersion != nil && *u.ExpectedVersion != version {
+		return ErrConflict
+	}
+
+	// Matching on the version read above keeps a concurrent writer from
+	// slipping in between the check and the write
+	args = append(args, version)
+	if err := expectAffected(tx.Exec("UPDATE notes SET "+strings.Join(updates, ", ")+", version = version + 1 WHERE id = ? AND version = ?", args...)); err != nil {
+		if err == ErrNotFound {
+			return ErrConflict
+		}
+		return err
+	}
+
+	newTitle, newContent := oldTitle, oldContent
+	if u.Title != nil {
+		newTitle = *u.Title
+	}
+	if u.Content != nil {
+		newContent = *u.Content
+	}
+	if newTitle != oldTitle || newContent != oldContent {
+		// Notes written before history was kept have no revisions yet;
+		// save what is being overwritten so it can still be restored.
+		var count int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM note_revisions WHERE note_id = ?", id).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			if err := recordRevision(tx, id, oldTitle, oldContent); err != nil {
+				return err
+			}
+		}
+		if err := recordRevision(tx, id, newTitle, newContent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteNoteStore) Delete(id string) error {
+	// Soft delete - set deleted_at timestamp
+	return expectAffected(s.db.Exec("UPDATE notes SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ? AND deleted_at IS NULL", id))
+}
+
+func (s *sqliteNoteStore) Restore(id string) error {
+	return expectAffected(s.db.Exec("UPDATE notes SET deleted_at = NULL, version = version + 1 WHERE id = ?", id))
+}
+
+func (s *sqliteNoteStore) PermanentDelete(id string) error {
+	return expectAffected(s.db.Exec("DELETE FROM notes WHERE id = ?", id))
+}
+
+func (s *sqliteNoteStore) EmptyTrash() (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM notes WHERE deleted_at IS NOT NULL`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteNoteStore) TogglePin(id string) (bool, error) {
+	return s.toggle(id, "pinned")
+}
+
+func (s *sqliteNoteStore) ToggleArchive(id string) (bool, error) {
+	return s.toggle(id, "archived")
+}
+
+// toggle flips a 0/1 flag column and returns the new value
+func (s *sqliteNoteStore) toggle(id, column string) (bool, error) {
+	var current int
+	err := s.db.QueryRow("SELECT "+column+" FROM notes WHERE id = ?", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	next := current != 1
+	if _, err := s.db.Exec("UPDATE notes SET "+column+" = ?, updated_at = ?, version = version + 1 WHERE id = ?", next, time.Now(), id); err != nil {
+		return false, err
+	}
+	return next, nil
+}
+
+func (s *sqliteNoteStore) Reorder(accountID string, noteIDs []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, noteID := range noteIDs {
+		if _, err := tx.Exec("UPDATE notes SET sort_order = ? WHERE id = ? AND account_id = ?", i, noteID, accountID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteNoteStore) Bulk(ids []string, op NoteBulkOp) error {
+	op.Update.ExpectedVersion = nil
+	return bulk(s.db, ids, func(tx *sql.Tx, id string) error {
+		inTrash, err := trashed(tx, "notes", id)
+		if err != nil {
+			return err
+		}
+		switch {
+		case op.Delete:
+			if inTrash {
+				return nil
+			}
+			_, err = tx.Exec("UPDATE notes SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ?", id)
+		case op.Restore:
+			_, err = tx.Exec("UPDATE notes SET deleted_at = NULL, version = version + 1 WHERE id = ?", id)
+		case inTrash:
+			return ErrTrashed
+		case op.AddTag != "":
+			_, err = tx.Exec("INSERT OR IGNORE INTO note_tags (note_id, tag_id) VALUES (?, ?)", id, op.AddTag)
+		case op.RemoveTag != "":
+			_, err = tx.Exec("DELETE FROM note_tags WHERE note_id = ? AND tag_id = ?", id, op.RemoveTag)
+		default:
+			err = updateNote(tx, id, op.Update)
+		}
+		return err
+	})
+}
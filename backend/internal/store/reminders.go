@@ -0,0 +1,200 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+)
+
+// ReminderStore finds the reminders of open todos and records which have
+// fired or been snoozed
+type ReminderStore interface {
+	// Pending returns the reminders that have not fired and are due by
+	// until, earliest first. defaults are the lead times, in minutes, of
+	// todos without their own. A reminder that was never snoozed is left out
+	// once its todo has been past due for longer than MissedReminderGrace at
+	// now, so that starting the app late does not replay a backlog.
+	Pending(now, until time.Time, defaults []int) ([]models.Reminder, error)
+	// SetMinutes sets the lead times of a todo's reminders; nil restores
+	// the defaults
+	SetMinutes(todoID string, minutes []int) error
+	MarkFired(todoID string, leadMinutes int, at time.Time) error
+	// Snooze moves a reminder to until, even if it already fired. defaults
+	// are as for Pending; a lead time the todo is not reminded at returns
+	// ErrReminderNotFound.
+	Snooze(todoID string, leadMinutes int, until time.Time, defaults []int) error
+}
+
+// ErrReminderNotFound is returned for a lead time a todo has no reminder at
+var ErrReminderNotFound = errors.New("reminder not found")
+
+// MissedReminderGrace is how long after its todo's due date an unfired
+// reminder still fires, so that one due at the due time itself, or missed
+// while the app was closed for a moment, is not lost
+const MissedReminderGrace = time.Hour
+
+type sqliteReminderStore struct {
+	db *sql.DB
+}
+
+// reminderMinutes encodes lead times for the reminder_minutes column
+func reminderMinutes(minutes []int) (*string, error) {
+	if minutes == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(minutes)
+	if err != nil {
+		return nil, err
+	}
+	s := string(data)
+	return &s, nil
+}
+
+type reminderState struct {
+	firedAt      *time.Time
+	snoozedUntil *time.Time
+}
+
+func (s *sqliteReminderStore) Pending(now, until time.Time, defaults []int) ([]models.Reminder, error) {
+	todos, err := s.openTodos()
+	if err != nil {
+		return nil, err
+	}
+	states, err := s.states()
+	if err != nil {
+		return nil, err
+	}
+
+	reminders := []models.Reminder{}
+	for _, t := range todos {
+		leads := t.Reminders
+		if leads == nil {
+			leads = defaults
+		}
+		for _, lead := range leads {
+			r := models.Reminder{
+				TodoID:      t.ID,
+				Title:       t.Title,
+				AccountName: t.AccountName,
+				DueDate:     *t.DueDate,
+				LeadMinutes: lead,
+				RemindAt:    t.DueDate.Add(-time.Duration(lead) * time.Minute),
+			}
+			st := states[reminderKey{t.ID, lead}]
+			switch {
+			case st.snoozedUntil != nil:
+				r.RemindAt, r.Snoozed = *st.snoozedUntil, true
+			case st.firedAt != nil, !t.DueDate.After(now.Add(-MissedReminderGrace)):
+				continue
+			}
+			if !r.RemindAt.After(until) {
+				reminders = append(reminders, r)
+			}
+		}
+	}
+	sort.SliceStable(reminders, func(i, j int) bool { return reminders[i].RemindAt.Before(reminders[j].RemindAt) })
+	return reminders, nil
+}
+
+// openTodos returns the todos that are not done or in the trash and have a
+// due date
+func (s *sqliteReminderStore) openTodos() ([]models.Todo, error) {
+	rows, err := s.db.Query(`
+		SELECT t.id, t.title, COALESCE(a.name, ''), t.due_date, t.reminder_minutes
+		FROM todos t
+		LEFT JOIN accounts a ON t.account_id = a.id
+		WHERE t.deleted_at IS NULL AND t.due_date IS NOT NULL AND COALESCE(t.status, '') NOT IN ` + doneStatuses + `
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []models.Todo{}
+	for rows.Next() {
+		var t models.Todo
+		var due time.Time
+		var reminders sql.NullString
+		if err := rows.Scan(&t.ID, &t.Title, &t.AccountName, &due, &reminders); err != nil {
+			return nil, err
+		}
+		t.DueDate = &due
+		if reminders.Valid {
+			if err := json.Unmarshal([]byte(reminders.String), &t.Reminders); err != nil {
+				return nil, err
+			}
+		}
+		todos = append(todos, t)
+	}
+	return todos, rows.Err()
+}
+
+type reminderKey struct {
+	todoID string
+	lead   int
+}
+
+func (s *sqliteReminderStore) states() (map[reminderKey]reminderState, error) {
+	rows, err := s.db.Query(`SELECT todo_id, lead_minutes, fired_at, snoozed_until FROM todo_reminders`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := map[reminderKey]reminderState{}
+	for rows.Next() {
+		var k reminderKey
+		var firedAt, snoozedUntil sql.NullTime
+		if err := rows.Scan(&k.todoID, &k.lead, &firedAt, &snoozedUntil); err != nil {
+			return nil, err
+		}
+		states[k] = reminderState{firedAt: nullTimePtr(firedAt), snoozedUntil: nullTimePtr(snoozedUntil)}
+	}
+	return states, rows.Err()
+}
+
+func (s *sqliteReminderStore) SetMinutes(todoID string, minutes []int) error {
+	value, err := reminderMinutes(minutes)
+	if err != nil {
+		return err
+	}
+	return expectAffected(s.db.Exec("UPDATE todos SET reminder_minutes = ?, version = version + 1 WHERE id = ?", value, todoID))
+}
+
+func (s *sqliteReminderStore) MarkFired(todoID string, leadMinutes int, at time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO todo_reminders (todo_id, lead_minutes, fired_at) VALUES (?, ?, ?)
+		ON CONFLICT (todo_id, lead_minutes) DO UPDATE SET fired_at = excluded.fired_at, snoozed_until = NULL
+	`, todoID, leadMinutes, at)
+	return err
+}
+
+func (s *sqliteReminderStore) Snooze(todoID string, leadMinutes int, until time.Time, defaults []int) error {
+	var reminders sql.NullString
+	err := s.db.QueryRow("SELECT reminder_minutes FROM todos WHERE id = ? AND deleted_at IS NULL", todoID).Scan(&reminders)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	leads := defaults
+	if reminders.Valid {
+		if err := json.Unmarshal([]byte(reminders.String), &leads); err != nil {
+			return err
+		}
+	}
+	if !slices.Contains(leads, leadMinutes) {
+		return ErrReminderNotFound
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO todo_reminders (todo_id, lead_minutes, snoozed_until) VALUES (?, ?, ?)
+		ON CONFLICT (todo_id, lead_minutes) DO UPDATE SET snoozed_until = excluded.snoozed_until
+	`, todoID, leadMinutes, until)
+	return err
+}
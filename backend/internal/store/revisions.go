@@ -0,0 +1,93 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+)
+
+// RevisionStore reads and prunes the version history kept for each note.
+// Revisions are written by NoteStore whenever a title or content changes.
+type RevisionStore interface {
+	// List returns a note's revisions newest first, without content
+	List(noteID string) ([]models.NoteRevision, error)
+	Get(noteID string, rev int) (*models.NoteRevision, error)
+	// Prune thins revisions created before cutoff down to the last one of
+	// each day. An empty noteID prunes every note.
+	Prune(noteID string, cutoff time.Time) (int64, error)
+}
+
+type sqliteRevisionStore struct {
+	db *sql.DB
+}
+
+func (s *sqliteRevisionStore) List(noteID string) ([]models.NoteRevision, error) {
+	rows, err := s.db.Query(`
+		SELECT note_id, rev, title, LENGTH(COALESCE(content, '')), created_at
+		FROM note_revisions
+		WHERE note_id = ?
+		ORDER BY rev DESC
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.NoteRevision{}
+	for rows.Next() {
+		var r models.NoteRevision
+		if err := rows.Scan(&r.NoteID, &r.Rev, &r.Title, &r.Size, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *sqliteRevisionStore) Get(noteID string, rev int) (*models.NoteRevision, error) {
+	var r models.NoteRevision
+	err := s.db.QueryRow(`
+		SELECT note_id, rev, title, COALESCE(content, ''), created_at
+		FROM note_revisions
+		WHERE note_id = ? AND rev = ?
+	`, noteID, rev).Scan(&r.NoteID, &r.Rev, &r.Title, &r.Content, &r.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Size = len(r.Content)
+	return &r, nil
+}
+
+func (s *sqliteRevisionStore) Prune(noteID string, cutoff time.Time) (int64, error) {
+	// created_at is written by CURRENT_TIMESTAMP, so compare in UTC text form.
+	// A revision survives if it is the newest one of its day; the note's
+	// latest revision is always the newest of its day.
+	result, err := s.db.Exec(`
+		DELETE FROM note_revisions
+		WHERE created_at < ?
+		  AND (? = '' OR note_id = ?)
+		  AND EXISTS (
+			SELECT 1 FROM note_revisions later
+			WHERE later.note_id = note_revisions.note_id
+			  AND date(later.created_at) = date(note_revisions.created_at)
+			  AND later.rev > note_revisions.rev
+		  )
+	`, cutoff.UTC().Format("2006-01-02 15:04:05"), noteID, noteID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// recordRevision appends a revision with the next number for the note
+func recordRevision(tx *sql.Tx, noteID, title, content string) error {
+	_, err := tx.Exec(`
+		INSERT INTO note_revisions (note_id, rev, title, content)
+		SELECT ?, COALESCE(MAX(rev), 0) + 1, ?, ? FROM note_revisions WHERE note_id = ?
+	`, noteID, title, content, noteID)
+	return err
+}
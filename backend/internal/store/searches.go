@@ -0,0 +1,122 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// SavedSearchStore persists named searches. Names are unique, ignoring case.
+type SavedSearchStore interface {
+	List() ([]models.SavedSearch, error)
+	Get(id string) (*models.SavedSearch, error)
+	// Create inserts a saved search, assigning an ID when unset
+	Create(s *models.SavedSearch) error
+	Update(id string, u SavedSearchUpdate) error
+	Delete(id string) error
+}
+
+// SavedSearchUpdate holds the fields to change on a saved search; nil fields
+// are left alone
+type SavedSearchUpdate struct {
+	Name    *string
+	Query   *string
+	Filters *models.SearchFilters
+}
+
+type sqliteSavedSearchStore struct {
+	db *sql.DB
+}
+
+func scanSavedSearch(row scanner) (*models.SavedSearch, error) {
+	var s models.SavedSearch
+	var filters string
+	if err := row.Scan(&s.ID, &s.Name, &s.Query, &filters, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(filters), &s.Filters); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *sqliteSavedSearchStore) List() ([]models.SavedSearch, error) {
+	rows, err := s.db.Query("SELECT id, name, query, filters, created_at, updated_at FROM saved_searches ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []models.SavedSearch{}
+	for rows.Next() {
+		saved, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, *saved)
+	}
+	return searches, rows.Err()
+}
+
+func (s *sqliteSavedSearchStore) Get(id string) (*models.SavedSearch, error) {
+	saved, err := scanSavedSearch(s.db.QueryRow("SELECT id, name, query, filters, created_at, updated_at FROM saved_searches WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return saved, err
+}
+
+func (s *sqliteSavedSearchStore) Create(saved *models.SavedSearch) error {
+	if saved.ID == "" {
+		saved.ID = uuid.New().String()
+	}
+	now := time.Now()
+	saved.CreatedAt, saved.UpdatedAt = now, now
+
+	filters, err := json.Marshal(saved.Filters)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO saved_searches (id, name, query, filters, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, saved.ID, saved.Name, saved.Query, string(filters), now, now)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *sqliteSavedSearchStore) Update(id string, u SavedSearchUpdate) error {
+	saved, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if u.Name != nil {
+		saved.Name = *u.Name
+	}
+	if u.Query != nil {
+		saved.Query = *u.Query
+	}
+	if u.Filters != nil {
+		saved.Filters = *u.Filters
+	}
+
+	filters, err := json.Marshal(saved.Filters)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("UPDATE saved_searches SET name = ?, query = ?, filters = ?, updated_at = ? WHERE id = ?",
+		saved.Name, saved.Query, string(filters), time.Now(), id)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *sqliteSavedSearchStore) Delete(id string) error {
+	return expectAffected(s.db.Exec("DELETE FROM saved_searches WHERE id = ?", id))
+}
@@ -0,0 +1,148 @@
+// Package store is the persistence layer between the HTTP handlers and the
+// database. Each entity has a small interface so handlers can be tested with
+// fakes and the backing store can be swapped out.
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when a unique constraint would be violated
+	ErrDuplicate = errors.New("already exists")
+	// ErrConflict is returned when an update expected a version of the row
+	// that has since been replaced
+	ErrConflict = errors.New("version conflict")
+	// ErrTrashed is returned by bulk changes other than delete and restore
+	// for an item in the trash
+	ErrTrashed = errors.New("in the trash")
+)
+
+// BulkError is returned by a bulk change when some items could not be
+// changed, in which case none were. Items maps each failed ID to its error.
+type BulkError struct {
+	Items map[string]error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d items could not be changed", len(e.Items))
+}
+
+// bulk calls fn for each of ids, skipping repeats, in one transaction that
+// is committed only if every call succeeds
+func bulk(db *sql.DB, ids []string, fn func(tx *sql.Tx, id string) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	seen := map[string]bool{}
+	failed := map[string]error{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if err := fn(tx, id); err != nil {
+			failed[id] = err
+		}
+	}
+	if len(failed) > 0 {
+		return &BulkError{Items: failed}
+	}
+	return tx.Commit()
+}
+
+// trashed returns whether the row of table with id is in the trash, or
+// ErrNotFound when there is none
+func trashed(tx *sql.Tx, table, id string) (bool, error) {
+	var deleted bool
+	err := tx.QueryRow("SELECT deleted_at IS NOT NULL FROM "+table+" WHERE id = ?", id).Scan(&deleted)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	return deleted, err
+}
+
+// Store groups the entity stores used by the handlers
+type Store struct {
+	Notes       NoteStore
+	Todos       TodoStore
+	Accounts    AccountStore
+	Contacts    ContactStore
+	Tags        TagStore
+	Revisions   RevisionStore
+	Searches    SavedSearchStore
+	Reminders   ReminderStore
+	Time        TimeStore
+	Workflow    WorkflowStore
+	Attachments AttachmentStore
+}
+
+// NewSQLite returns a Store backed by the given SQLite database
+func NewSQLite(db *sql.DB) *Store {
+	return &Store{
+		Notes:       &sqliteNoteStore{db: db},
+		Todos:       &sqliteTodoStore{db: db},
+		Accounts:    &sqliteAccountStore{db: db},
+		Contacts:    &sqliteContactStore{db: db},
+		Tags:        &sqliteTagStore{db: db},
+		Revisions:   &sqliteRevisionStore{db: db},
+		Searches:    &sqliteSavedSearchStore{db: db},
+		Reminders:   &sqliteReminderStore{db: db},
+		Time:        &sqliteTimeStore{db: db},
+		Workflow:    &sqliteWorkflowStore{db: db},
+		Attachments: &sqliteAttachmentStore{db: db},
+	}
+}
+
+// scanner is satisfied by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// placeholders returns "?,?,?" and the matching args for an IN clause
+func placeholders(ids []string) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","), args
+}
+
+// expectAffected maps a zero-row result to ErrNotFound
+func expectAffected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE")
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
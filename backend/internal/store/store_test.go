@@ -0,0 +1,998 @@
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/db"
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/remind"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupStore(t *testing.T) (*Store, *sql.DB) {
+	database, err := db.Initialize(filepath.Join(t.TempDir(), "notes.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	require.NoError(t, db.Migrate(database))
+	return NewSQLite(database), database
+}
+
+func TestNoteStoreLifecycle(t *testing.T) {
+	s, _ := setupStore(t)
+
+	account := &models.Account{Name: "Acme"}
+	require.NoError(t, s.Accounts.Create(account))
+
+	note := &models.Note{
+		Title:                "Kickoff",
+		AccountID:            account.ID,
+		TemplateType:         "initial",
+		ExternalParticipants: []string{"jane@acme.com"},
+	}
+	require.NoError(t, s.Notes.Create(note))
+
+	todo := &models.Todo{Title: "Send deck", Status: "not_started", Priority: "high"}
+	require.NoError(t, s.Todos.Create(todo, &note.ID))
+
+	got, err := s.Notes.Get(note.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", got.AccountName)
+	assert.Equal(t, []string{"jane@acme.com"}, got.ExternalParticipants)
+	require.Len(t, got.Todos, 1)
+	assert.Equal(t, "Send deck", got.Todos[0].Title)
+
+	title := "Kickoff v2"
+	require.NoError(t, s.Notes.Update(note.ID, NoteUpdate{Title: &title}))
+	assert.ErrorIs(t, s.Notes.Update("missing", NoteUpdate{Title: &title}), ErrNotFound)
+
+	require.NoError(t, s.Notes.Delete(note.ID))
+	assert.ErrorIs(t, s.Notes.Delete(note.ID), ErrNotFound)
+	live, _, err := s.Notes.List(NoteFilter{}, ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, live)
+
+	require.NoError(t, s.Notes.Restore(note.ID))
+	pinned, err := s.Notes.TogglePin(note.ID)
+	require.NoError(t, err)
+	assert.True(t, pinned)
+}
+
+func TestTodoStoreLinkedNotes(t *testing.T) {
+	s, _ := setupStore(t)
+
+	account := &models.Account{Name: "Acme"}
+	require.NoError(t, s.Accounts.Create(account))
+	note := &models.Note{Title: "Kickoff", AccountID: account.ID}
+	require.NoError(t, s.Notes.Create(note))
+
+	todo := &models.Todo{Title: "Follow up", Status: "not_started", AccountID: &account.ID}
+	require.NoError(t, s.Todos.Create(todo, nil))
+	assert.Equal(t, "Acme", todo.AccountName)
+	require.NoError(t, s.Todos.LinkNote(todo.ID, note.ID))
+
+	todos, _, err := s.Todos.List(TodoFilter{Status: "not_started"}, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	require.Len(t, todos[0].Notes, 1)
+	assert.Equal(t, "Kickoff", todos[0].Notes[0].Title)
+
+	todos, _, err = s.Todos.List(TodoFilter{Status: "completed"}, ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, todos)
+}
+
+func TestContactAndTagDuplicates(t *testing.T) {
+	s, _ := setupStore(t)
+
+	contact := &models.Contact{Email: "jane@acme.com", Domain: "acme.com", Source: "manual"}
+	require.NoError(t, s.Contacts.Create(contact))
+	assert.ErrorIs(t, s.Contacts.Create(&models.Contact{Email: "jane@acme.com", Domain: "acme.com"}), ErrDuplicate)
+
+	require.NoError(t, s.Contacts.RecordSighting(contact.ID, "Jane"))
+	got, err := s.Contacts.GetByEmail("jane@acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Jane", got.Name)
+	assert.Equal(t, 1, got.MeetingCount)
+
+	require.NoError(t, s.Tags.Create(&models.Tag{Name: "poc", Color: "#fff"}))
+	assert.ErrorIs(t, s.Tags.Create(&models.Tag{Name: "poc"}), ErrDuplicate)
+}
+
+func TestRevisionPrune(t *testing.T) {
+	s, database := setupStore(t)
+
+	account := &models.Account{Name: "Acme"}
+	require.NoError(t, s.Accounts.Create(account))
+	note := &models.Note{Title: "Kickoff", AccountID: account.ID, Content: "v1"}
+	require.NoError(t, s.Notes.Create(note))
+	for _, content := range []string{"v2", "v3", "v4"} {
+		c := content
+		require.NoError(t, s.Notes.Update(note.ID, NoteUpdate{Content: &c}))
+	}
+
+	// Unchanged content does not add a revision
+	same := "v4"
+	require.NoError(t, s.Notes.Update(note.ID, NoteUpdate{Content: &same}))
+	revisions, err := s.Revisions.List(note.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 4)
+
+	// Age revisions 1-3 onto two old days; 4 stays recent
+	_, err = database.Exec(`UPDATE note_revisions SET created_at = CASE rev
+		WHEN 1 THEN '2026-01-01 09:00:00' WHEN 2 THEN '2026-01-01 17:00:00'
+		WHEN 3 THEN '2026-01-02 09:00:00' ELSE created_at END WHERE note_id = ?`, note.ID)
+	require.NoError(t, err)
+
+	pruned, err := s.Revisions.Prune("", time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+
+	_, err = s.Revisions.Get(note.ID, 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+	rev, err := s.Revisions.Get(note.ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", rev.Content)
+}
+
+func TestSavedSearchStore(t *testing.T) {
+	s, _ := setupStore(t)
+
+	saved := &models.SavedSearch{Name: "Stuck todos", Query: "status:stuck", Filters: models.SearchFilters{Type: "todo"}}
+	require.NoError(t, s.Searches.Create(saved))
+	assert.ErrorIs(t, s.Searches.Create(&models.SavedSearch{Name: "stuck TODOS"}), ErrDuplicate)
+
+	name, filters := "Stuck", models.SearchFilters{AccountID: "acc-1"}
+	require.NoError(t, s.Searches.Update(saved.ID, SavedSearchUpdate{Name: &name, Filters: &filters}))
+	got, err := s.Searches.Get(saved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Stuck", got.Name)
+	assert.Equal(t, "status:stuck", got.Query)
+	assert.Equal(t, filters, got.Filters)
+
+	list, err := s.Searches.List()
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	require.NoError(t, s.Searches.Delete(saved.ID))
+	assert.ErrorIs(t, s.Searches.Delete(saved.ID), ErrNotFound)
+	_, err = s.Searches.Get(saved.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Searches.Update(saved.ID, SavedSearchUpdate{Name: &name}), ErrNotFound)
+}
+
+func TestListPaging(t *testing.T) {
+	s, database := setupStore(t)
+
+	account := &models.Account{Name: "Acme"}
+	require.NoError(t, s.Accounts.Create(account))
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	var ids []string
+	for i, title := range []string{"delta", "Alpha", "charlie", "bravo", "echo"} {
+		// Two notes share each creation time, so ties fall back to the id
+		n := &models.Note{Title: title, AccountID: account.ID, CreatedAt: base.Add(time.Duration(i/2) * time.Hour)}
+		require.NoError(t, s.Notes.Create(n))
+		ids = append(ids, n.ID)
+	}
+
+	var seen []string
+	o := ListOptions{Limit: 2}
+	for {
+		page, info, err := s.Notes.List(NoteFilter{}, o)
+		require.NoError(t, err)
+		assert.Equal(t, 5, info.Total)
+		for _, n := range page {
+			seen = append(seen, n.ID)
+		}
+		if info.NextCursor == "" {
+			break
+		}
+		o.Cursor = info.NextCursor
+	}
+	all, _, err := s.Notes.List(NoteFilter{}, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, seen, 5)
+	for i, n := range all {
+		assert.Equal(t, n.ID, seen[i])
+	}
+	assert.Equal(t, ids[4], seen[0], "newest first")
+
+	titles := func(f NoteFilter, o ListOptions) []string {
+		notes, _, err := s.Notes.List(f, o)
+		require.NoError(t, err)
+		var out []string
+		for _, n := range notes {
+			out = append(out, n.Title)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"Alpha", "bravo", "charlie", "delta", "echo"}, titles(NoteFilter{}, ListOptions{Sort: "title"}))
+	assert.Equal(t, []string{"echo", "delta"}, titles(NoteFilter{}, ListOptions{Sort: "title", Order: "desc", Limit: 2}))
+
+	// Filters
+	meeting := time.Date(2026, 1, 15, 10, 0, 0, 0, time.FixedZone("PST", -8*3600))
+	pinned := true
+	require.NoError(t, s.Notes.Update(ids[0], NoteUpdate{Pinned: &pinned, MeetingDate: &meeting}))
+	assert.Equal(t, []string{"delta"}, titles(NoteFilter{Pinned: &pinned}, ListOptions{}))
+	from, to := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"delta"}, titles(NoteFilter{From: &from, To: &to}, ListOptions{}))
+	assert.Len(t, titles(NoteFilter{From: &to}, ListOptions{}), 4, "meeting date wins over creation date")
+	_, err = database.Exec(`INSERT INTO tags (id, name, color) VALUES ('t1', 'POC', '#fff')`)
+	require.NoError(t, err)
+	require.NoError(t, s.Tags.AddToNote(ids[2], "t1"))
+	assert.Equal(t, []string{"charlie"}, titles(NoteFilter{Tags: []string{"poc"}}, ListOptions{}))
+
+	// Bad options
+	_, _, err = s.Notes.List(NoteFilter{}, ListOptions{Sort: "content"})
+	var sortErr *InvalidSortError
+	require.ErrorAs(t, err, &sortErr)
+	assert.Contains(t, sortErr.Allowed, "title")
+	_, _, err = s.Notes.List(NoteFilter{}, ListOptions{Order: "sideways"})
+	assert.ErrorAs(t, err, &sortErr)
+	_, _, err = s.Notes.List(NoteFilter{}, ListOptions{Cursor: "nope"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, info, err := s.Notes.List(NoteFilter{}, ListOptions{Limit: 1})
+	require.NoError(t, err)
+	_, _, err = s.Notes.List(NoteFilter{}, ListOptions{Limit: 1, Sort: "title", Cursor: info.NextCursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor, "cursor from another sort")
+
+}
+
+func TestListPagingNullSortValues(t *testing.T) {
+	_, database := setupStore(t)
+	_, err := database.Exec(`
+		CREATE TABLE items (id TEXT PRIMARY KEY, v INTEGER);
+		INSERT INTO items VALUES ('a', 2), ('b', NULL), ('c', 1), ('d', NULL), ('e', 2);
+	`)
+	require.NoError(t, err)
+	keys := sortKeys{keys: map[string]sortKey{"v": {expr: "v"}}, fallback: "v"}
+	scan := func(query string, args ...interface{}) ([]string, error) {
+		rows, err := database.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, rows.Err()
+	}
+
+	for order, want := range map[string][]string{
+		"asc":  {"b", "d", "c", "a", "e"},
+		"desc": {"e", "a", "c", "d", "b"},
+	} {
+		var seen []string
+		o := ListOptions{Limit: 1, Order: order}
+		for {
+			q := newListQuery("items", "id", nil, nil)
+			require.NoError(t, q.sortBy(keys, o))
+			page, info, err := listPage(database, q, "SELECT id FROM items", o, scan, func(id string) string { return id })
+			require.NoError(t, err, order)
+			seen = append(seen, page...)
+			if info.NextCursor == "" {
+				break
+			}
+			o.Cursor = info.NextCursor
+		}
+		assert.Equal(t, want, seen, order)
+	}
+}
+
+func TestTodoListFilters(t *testing.T) {
+	s, _ := setupStore(t)
+
+	due := func(day int) *time.Time {
+		d := time.Date(2026, 5, day, 12, 0, 0, 0, time.UTC)
+		return &d
+	}
+	for _, todo := range []*models.Todo{
+		{Title: "Low", Status: "not_started", Priority: "low", DueDate: due(1)},
+		{Title: "High", Status: "not_started", Priority: "high", DueDate: due(10)},
+		{Title: "Medium", Status: "in_progress", Priority: "medium"},
+	} {
+		require.NoError(t, s.Todos.Create(todo, nil))
+	}
+
+	titles := func(f TodoFilter, o ListOptions) []string {
+		todos, _, err := s.Todos.List(f, o)
+		require.NoError(t, err)
+		var out []string
+		for _, t := range todos {
+			out = append(out, t.Title)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"High", "Medium", "Low"}, titles(TodoFilter{}, ListOptions{Sort: "priority"}))
+	assert.Equal(t, []string{"Low", "High", "Medium"}, titles(TodoFilter{}, ListOptions{Sort: "due_date"}))
+	assert.Equal(t, []string{"Low"}, titles(TodoFilter{DueBefore: due(5)}, ListOptions{}))
+	assert.Equal(t, []string{"High"}, titles(TodoFilter{DueAfter: due(5)}, ListOptions{}))
+	assert.Equal(t, []string{"High"}, titles(TodoFilter{Priority: "high", Status: "not_started"}, ListOptions{}))
+
+	// Paging by a sort with a default value keeps every todo
+	todos, info, err := s.Todos.List(TodoFilter{}, ListOptions{Sort: "due_date", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+	rest, _, err := s.Todos.List(TodoFilter{}, ListOptions{Sort: "due_date", Limit: 2, Cursor: info.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	assert.Equal(t, "Medium", rest[0].Title)
+}
+
+func TestTagHierarchy(t *testing.T) {
+	s, _ := setupStore(t)
+
+	account := &models.Account{Name: "Acme"}
+	require.NoError(t, s.Accounts.Create(account))
+	note := &models.Note{Title: "Kickoff", AccountID: account.ID}
+	require.NoError(t, s.Notes.Create(note))
+	todo := &models.Todo{Title: "Follow up", Status: "not_started"}
+	require.NoError(t, s.Todos.Create(todo, nil))
+
+	poc := &models.Tag{Name: " customer / poc ", Color: "#fff"}
+	require.NoError(t, s.Tags.Create(poc))
+	assert.Equal(t, "customer/poc", poc.Name)
+	assert.Equal(t, "customer", poc.Parent)
+	customer, err := s.Tags.GetByName("customer")
+	require.NoError(t, err, "parent created")
+	assert.ErrorIs(t, s.Tags.Create(&models.Tag{Name: "customer//poc"}), ErrInvalidTagName)
+
+	require.NoError(t, s.Tags.AddToNote(note.ID, poc.ID))
+	require.NoError(t, s.Tags.AddToTodo(todo.ID, poc.ID))
+	require.NoError(t, s.Tags.AddToAccount(account.ID, customer.ID))
+
+	// Filtering by a parent finds its children
+	notes, _, err := s.Notes.List(NoteFilter{Tags: []string{"Customer"}}, ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, notes, 1)
+	todos, _, err := s.Todos.List(TodoFilter{Tags: []string{"customer/poc"}}, ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, todos, 1)
+	accounts, _, err := s.Accounts.List(AccountFilter{Tags: []string{"customer/poc"}}, ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, accounts, "children do not match their parent")
+	renewal := &models.Tag{Name: "renewal"}
+	require.NoError(t, s.Tags.Create(renewal))
+	notes, _, err = s.Notes.List(NoteFilter{Tags: []string{"customer", "renewal"}}, ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, notes)
+	notes, _, err = s.Notes.List(NoteFilter{Tags: []string{"customer", "renewal"}, MatchAny: true}, ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, notes, 1)
+
+	// Renaming takes the children along
+	name := "client"
+	require.NoError(t, s.Tags.Update(customer.ID, TagUpdate{Name: &name}))
+	got, err := s.Tags.Get(poc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "client/poc", got.Name)
+	name = "client/poc/deep"
+	assert.ErrorIs(t, s.Tags.Update(customer.ID, TagUpdate{Name: &name}), ErrTagCycle)
+	name = "renewal"
+	assert.ErrorIs(t, s.Tags.Update(customer.ID, TagUpdate{Name: &name}), ErrDuplicate)
+
+	// Merging moves every association and drops the tag
+	require.NoError(t, s.Tags.AddToNote(note.ID, renewal.ID))
+	require.NoError(t, s.Tags.Merge(poc.ID, renewal.ID))
+	_, err = s.Tags.Get(poc.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	tags, err := s.Tags.ListForNote(note.ID)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "renewal", tags[0].Name)
+	tags, err = s.Tags.ListForTodo(todo.ID)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "renewal", tags[0].Name)
+
+	all, err := s.Tags.List()
+	require.NoError(t, err)
+	usage := map[string]models.TagUsage{}
+	for _, tag := range all {
+		usage[tag.Name] = *tag.Usage
+	}
+	assert.Equal(t, map[string]models.TagUsage{
+		"client":  {Accounts: 1},
+		"renewal": {Notes: 1, Todos: 1},
+	}, usage)
+
+	// Deleting a tag deletes its children
+	sub := &models.Tag{Name: "client/beta"}
+	require.NoError(t, s.Tags.Create(sub))
+	require.NoError(t, s.Tags.Delete(customer.ID))
+	_, err = s.Tags.Get(sub.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Names are unique regardless of case, and children file under their
+	// parent as it is spelled
+	partner := &models.Tag{Name: "partner"}
+	require.NoError(t, s.Tags.Create(partner))
+	assert.ErrorIs(t, s.Tags.Create(&models.Tag{Name: "Partner"}), ErrDuplicate)
+	got, err = s.Tags.GetByName("PARTNER")
+	require.NoError(t, err)
+	assert.Equal(t, partner.ID, got.ID)
+	reseller := &models.Tag{Name: "Partner/Reseller"}
+	require.NoError(t, s.Tags.Create(reseller))
+	assert.Equal(t, "partner/Reseller", reseller.Name)
+	name = "Partner"
+	require.NoError(t, s.Tags.Update(partner.ID, TagUpdate{Name: &name}))
+	got, err = s.Tags.Get(reseller.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Partner/Reseller", got.Name, "changing the case respells the children")
+	name = "PARTNER/reseller/emea"
+	assert.ErrorIs(t, s.Tags.Update(partner.ID, TagUpdate{Name: &name}), ErrTagCycle)
+	name = "Renewal"
+	assert.ErrorIs(t, s.Tags.Update(partner.ID, TagUpdate{Name: &name}), ErrDuplicate)
+	name = "RENEWAL/partner"
+	require.NoError(t, s.Tags.Update(partner.ID, TagUpdate{Name: &name}))
+	got, err = s.Tags.Get(reseller.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "renewal/partner/Reseller", got.Name)
+}
+
+func TestRecurringTodo(t *testing.T) {
+	s, _ := setupStore(t)
+
+	account := &models.Account{Name: "Acme"}
+	require.NoError(t, s.Accounts.Create(account))
+	note := &models.Note{Title: "Weekly sync", AccountID: account.ID}
+	require.NoError(t, s.Notes.Create(note))
+	tag := &models.Tag{Name: "check-in"}
+	require.NoError(t, s.Tags.Create(tag))
+
+	// 2026-03-02 is a Monday
+	due := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	todo := &models.Todo{Title: "Check in", Status: "not_started", DueDate: &due, Recurrence: "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3"}
+	require.NoError(t, s.Todos.Create(todo, &note.ID))
+	require.NoError(t, s.Tags.AddToTodo(todo.ID, tag.ID))
+	require.NotEmpty(t, todo.SeriesID)
+
+	occurrences := func() []models.Todo {
+		todos, _, err := s.Todos.List(TodoFilter{}, ListOptions{Sort: "due_date"})
+		require.NoError(t, err)
+		return todos
+	}
+	setStatus := func(id, status string) {
+		require.NoError(t, s.Todos.Update(id, TodoUpdate{Status: &status}))
+	}
+	complete := func(id string) { setStatus(id, "completed") }
+
+	complete(todo.ID)
+	todos := occurrences()
+	require.Len(t, todos, 2)
+	next := todos[1]
+	assert.Equal(t, "not_started", next.Status)
+	assert.Equal(t, 2, next.Occurrence)
+	assert.Equal(t, todo.SeriesID, next.SeriesID)
+	assert.True(t, next.DueDate.Equal(time.Date(2026, 3, 5, 10, 0, 0, 0, time.UTC)), next.DueDate)
+	require.Len(t, next.Notes, 1, "keeps linked notes")
+	tags, err := s.Tags.ListForTodo(next.ID)
+	require.NoError(t, err)
+	assert.Len(t, tags, 1, "keeps tags")
+
+	// Completing again after reopening does not spawn a second copy
+	setStatus(todo.ID, "in_progress")
+	complete(todo.ID)
+	assert.Len(t, occurrences(), 2)
+
+	complete(next.ID)
+	todos = occurrences()
+	require.Len(t, todos, 3)
+	complete(todos[2].ID)
+	assert.Len(t, occurrences(), 3, "COUNT=3 ends the series")
+
+	history, err := s.Todos.Completions(todo.SeriesID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, 3, history[0].Occurrence)
+	assert.Equal(t, 1, history[2].Occurrence)
+
+	// A todo without a rule records nothing
+	plain := &models.Todo{Title: "Once", Status: "not_started"}
+	require.NoError(t, s.Todos.Create(plain, nil))
+	complete(plain.ID)
+	assert.Len(t, occurrences(), 4)
+	assert.Empty(t, plain.SeriesID)
+}
+
+func TestTodoSubtasksAndBlockers(t *testing.T) {
+	s, _ := setupStore(t)
+
+	create := func(title string, parentID *string) *models.Todo {
+		todo := &models.Todo{Title: title, Status: "not_started", ParentID: parentID}
+		require.NoError(t, s.Todos.Create(todo, nil))
+		return todo
+	}
+	complete := func(id string) {
+		status := "completed"
+		require.NoError(t, s.Todos.Update(id, TodoUpdate{Status: &status}))
+	}
+
+	poc := create("Set up POC environment", nil)
+	vpc := create("Create VPC", &poc.ID)
+	create("Grant access", &poc.ID)
+	create("Peer VPC", &vpc.ID)
+	missing := "missing"
+	assert.ErrorIs(t, s.Todos.Create(&models.Todo{Title: "Orphan", ParentID: &missing}, nil), ErrParentNotFound)
+
+	complete(vpc.ID)
+	got, err := s.Todos.Get(poc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &models.TodoProgress{Total: 3, Completed: 1, Percent: 33}, got.Progress)
+	assert.Len(t, got.Subtasks, 2)
+
+	// A todo cannot move under itself or its subtasks
+	assert.ErrorIs(t, s.Todos.Update(poc.ID, TodoUpdate{ParentID: &vpc.ID}), ErrTodoCycle)
+	assert.ErrorIs(t, s.Todos.Update(poc.ID, TodoUpdate{ParentID: &poc.ID}), ErrTodoCycle)
+
+	top, _, err := s.Todos.List(TodoFilter{TopLevel: true}, ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, top, 1)
+
+	// An open blocker makes a todo stuck until it is completed
+	review := create("Security review", nil)
+	require.NoError(t, s.Todos.AddBlocker(poc.ID, review.ID))
+	got, err = s.Todos.Get(poc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "stuck", got.Status)
+	assert.True(t, got.Blocked)
+	require.Len(t, got.BlockedBy, 1)
+	assert.Equal(t, review.ID, got.BlockedBy[0].ID)
+
+	stuck, _, err := s.Todos.List(TodoFilter{Status: "stuck"}, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, stuck, 1)
+	assert.Equal(t, poc.ID, stuck[0].ID)
+
+	// Dependencies cannot loop back
+	assert.ErrorIs(t, s.Todos.AddBlocker(review.ID, poc.ID), ErrTodoCycle)
+	assert.ErrorIs(t, s.Todos.AddBlocker(review.ID, review.ID), ErrTodoCycle)
+	assert.ErrorIs(t, s.Todos.AddBlocker(review.ID, "missing"), ErrNotFound)
+
+	complete(review.ID)
+	got, err = s.Todos.Get(poc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "not_started", got.Status)
+	assert.False(t, got.Blocked)
+
+	require.NoError(t, s.Todos.RemoveBlocker(poc.ID, review.ID))
+	got, err = s.Todos.Get(poc.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.BlockedBy)
+}
+
+func TestTodoReminders(t *testing.T) {
+	s, _ := setupStore(t)
+
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	create := func(title string, due time.Time, minutes []int) *models.Todo {
+		todo := &models.Todo{Title: title, Status: "not_started", DueDate: &due, Reminders: minutes}
+		require.NoError(t, s.Todos.Create(todo, nil))
+		return todo
+	}
+	pending := func(until time.Time) []models.Reminder {
+		reminders, err := s.Reminders.Pending(now, until, []int{60})
+		require.NoError(t, err)
+		return reminders
+	}
+
+	call := create("Call Acme", now.Add(30*time.Minute), nil)
+	demo := create("Demo", now.Add(3*time.Hour), []int{120, 15})
+	create("Missed", now.Add(-2*time.Hour), nil)
+
+	reminders := pending(now.Add(4 * time.Hour))
+	require.Len(t, reminders, 3, "past due todos are skipped")
+	assert.Equal(t, call.ID, reminders[0].TodoID)
+	assert.Equal(t, 60, reminders[0].LeadMinutes, "uses the default lead")
+	assert.Equal(t, now.Add(-30*time.Minute), reminders[0].RemindAt)
+	assert.Equal(t, demo.ID, reminders[1].TodoID)
+	assert.Equal(t, 120, reminders[1].LeadMinutes)
+	assert.Equal(t, 15, reminders[2].LeadMinutes)
+	assert.Len(t, pending(now), 1, "only the overdue reminder is due now")
+
+	fetched, err := s.Todos.Get(demo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{120, 15}, fetched.Reminders)
+
+	require.NoError(t, s.Reminders.MarkFired(call.ID, 60, now))
+	assert.Empty(t, pending(now), "fires once")
+
+	// Snoozing brings a fired reminder back
+	require.NoError(t, s.Reminders.Snooze(call.ID, 60, now.Add(10*time.Minute), []int{60}))
+	reminders = pending(now.Add(10 * time.Minute))
+	require.Len(t, reminders, 1)
+	assert.True(t, reminders[0].Snoozed)
+	assert.Equal(t, now.Add(10*time.Minute), reminders[0].RemindAt)
+	assert.ErrorIs(t, s.Reminders.Snooze("missing", 60, now, []int{60}), ErrNotFound)
+	assert.ErrorIs(t, s.Reminders.Snooze(call.ID, 45, now, []int{60}), ErrReminderNotFound)
+	assert.ErrorIs(t, s.Reminders.Snooze(demo.ID, 60, now, []int{60}), ErrReminderNotFound, "the demo has its own lead times")
+
+	// Moving the due date rearms the reminders
+	later := now.Add(2 * time.Hour)
+	require.NoError(t, s.Todos.Update(call.ID, TodoUpdate{DueDate: &later}))
+	reminders = pending(later)
+	require.Len(t, reminders, 2)
+	assert.Equal(t, call.ID, reminders[0].TodoID)
+	assert.Equal(t, now.Add(time.Hour), reminders[0].RemindAt)
+	assert.False(t, reminders[0].Snoozed)
+
+	require.NoError(t, s.Reminders.SetMinutes(demo.ID, nil))
+	require.NoError(t, s.Reminders.SetMinutes(call.ID, []int{}))
+	reminders = pending(later)
+	require.Len(t, reminders, 1, "the demo is back to the default and the call has none")
+	assert.Equal(t, demo.ID, reminders[0].TodoID)
+	assert.ErrorIs(t, s.Reminders.SetMinutes("missing", nil), ErrNotFound)
+
+	completed := "completed"
+	require.NoError(t, s.Todos.Update(demo.ID, TodoUpdate{Status: &completed}))
+	assert.Empty(t, pending(later), "completed todos are not reminded")
+}
+
+func TestReminderAtDueTime(t *testing.T) {
+	s, _ := setupStore(t)
+
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	due := now.Add(time.Minute)
+	todo := &models.Todo{Title: "Call Acme", Status: "not_started", DueDate: &due, Reminders: []int{0}}
+	require.NoError(t, s.Todos.Create(todo, nil))
+
+	var fired []models.Reminder
+	scheduler := remind.NewScheduler(s.Reminders, []int{60}, remind.NotifierFunc(func(r models.Reminder) error {
+		fired = append(fired, r)
+		return nil
+	}))
+	n, err := scheduler.Fire(now, nil)
+	require.NoError(t, err)
+	assert.Zero(t, n, "not due yet")
+
+	// The first tick at or after the due date fires it, once
+	for _, at := range []time.Time{due, due.Add(time.Minute)} {
+		_, err = scheduler.Fire(at, nil)
+		require.NoError(t, err)
+	}
+	if assert.Len(t, fired, 1) {
+		assert.Equal(t, 0, fired[0].LeadMinutes)
+		assert.Equal(t, todo.ID, fired[0].TodoID)
+	}
+}
+
+func TestTimeTracking(t *testing.T) {
+	s, _ := setupStore(t)
+
+	acme := &models.Account{Name: "Acme"}
+	require.NoError(t, s.Accounts.Create(acme))
+	globex := &models.Account{Name: "Globex"}
+	require.NoError(t, s.Accounts.Create(globex))
+
+	estimate := 240
+	poc := &models.Todo{Title: "Set up POC", Status: "in_progress", AccountID: &acme.ID, Estimate: &estimate}
+	require.NoError(t, s.Todos.Create(poc, nil))
+	demo := &models.Todo{Title: "Demo", Status: "not_started", AccountID: &globex.ID}
+	require.NoError(t, s.Todos.Create(demo, nil))
+
+	// 2026-03-02 is a Monday
+	monday := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	_, err := s.Time.Start(poc.ID, monday)
+	require.NoError(t, err)
+	_, err = s.Time.Start(poc.ID, monday)
+	assert.ErrorIs(t, err, ErrTimerRunning)
+	_, err = s.Time.Start("missing", monday)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	running, err := s.Todos.Get(poc.ID)
+	require.NoError(t, err)
+	require.NotNil(t, running.Timer)
+	assert.True(t, running.Timer.Equal(monday))
+	assert.Equal(t, 240, *running.Estimate)
+
+	entry, err := s.Time.Stop(poc.ID, monday.Add(90*time.Minute+20*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 90, entry.Minutes)
+	_, err = s.Time.Stop(poc.ID, monday)
+	assert.ErrorIs(t, err, ErrTimerStopped)
+
+	require.NoError(t, s.Time.Add(&models.TimeEntry{TodoID: poc.ID, StartedAt: monday.AddDate(0, 0, 7), Minutes: 30, Note: "Follow-up"}))
+	require.NoError(t, s.Time.Add(&models.TimeEntry{TodoID: demo.ID, StartedAt: monday.AddDate(0, 0, 8), Minutes: 45}))
+
+	// Completing a todo stops its timer
+	_, err = s.Time.Start(poc.ID, time.Now().Add(-10*time.Minute))
+	require.NoError(t, err)
+	completed := "completed"
+	require.NoError(t, s.Todos.Update(poc.ID, TodoUpdate{Status: &completed}))
+	done, err := s.Todos.Get(poc.ID)
+	require.NoError(t, err)
+	assert.Nil(t, done.Timer)
+	assert.Equal(t, 130, done.Tracked)
+
+	entries, err := s.Time.ListForTodo(poc.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "timer", entries[0].Source)
+	assert.Equal(t, "manual", entries[1].Source)
+	assert.Equal(t, "Follow-up", entries[1].Note)
+
+	from, to := monday, monday.AddDate(0, 0, 14)
+	report, err := s.Time.Report(TimeFilter{From: &from, To: &to})
+	require.NoError(t, err)
+	assert.Equal(t, 165, report.TotalMinutes)
+	require.Len(t, report.ByAccount, 2)
+	assert.Equal(t, "Acme", report.ByAccount[0].AccountName)
+	assert.Equal(t, 120, report.ByAccount[0].Minutes)
+	assert.Equal(t, 1, report.ByAccount[0].Todos)
+	require.Len(t, report.ByWeek, 2)
+	assert.Equal(t, "2026-03-02", report.ByWeek[0].WeekStart)
+	assert.Equal(t, 90, report.ByWeek[0].Minutes)
+	assert.Equal(t, "2026-03-09", report.ByWeek[1].WeekStart)
+	assert.Len(t, report.ByWeek[1].Accounts, 2)
+
+	report, err = s.Time.Report(TimeFilter{AccountID: globex.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 45, report.TotalMinutes)
+
+	require.NoError(t, s.Time.Delete(entries[1].ID))
+	assert.ErrorIs(t, s.Time.Delete(entries[1].ID), ErrNotFound)
+	require.NoError(t, s.Todos.Delete(demo.ID))
+	report, err = s.Time.Report(TimeFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 100, report.TotalMinutes, "leaves out deleted entries and todos in the trash")
+}
+
+func TestStatusWorkflow(t *testing.T) {
+	s, database := setupStore(t)
+
+	w, err := s.Workflow.Get()
+	require.NoError(t, err)
+	require.Len(t, w.Statuses, 4)
+	assert.Equal(t, "not_started", w.Statuses[0].Key)
+	assert.Equal(t, []string{"not_started", "in_progress", "stuck"}, w.Transitions["completed"])
+
+	todo := &models.Todo{Title: "Run POC"}
+	require.NoError(t, s.Todos.Create(todo, nil))
+	assert.Equal(t, "not_started", todo.Status, "starts in the first open status")
+	assert.ErrorIs(t, s.Todos.Create(&models.Todo{Title: "Odd", Status: "someday"}, nil), ErrUnknownStatus)
+
+	require.NoError(t, s.Workflow.Replace(models.Workflow{
+		Statuses: []models.TodoStatus{
+			{Key: "not_started", Label: "Backlog", Category: "open"},
+			{Key: "in_progress", Label: "Doing", Category: "active"},
+			{Key: "review", Label: "Review", Category: "active", Color: "#8b5cf6"},
+			{Key: "waiting", Label: "Waiting", Category: "blocked"},
+			{Key: "completed", Label: "Done", Category: "done"},
+		},
+		Transitions: map[string][]string{
+			"not_started": {"in_progress"},
+			"in_progress": {"review", "waiting"},
+			"waiting":     {"in_progress"},
+			"review":      {"in_progress", "completed"},
+			"completed":   {"in_progress"},
+		},
+	}))
+	w, err = s.Workflow.Get()
+	require.NoError(t, err)
+	assert.Len(t, w.Statuses, 5)
+	assert.Equal(t, "Backlog", w.Statuses[0].Label)
+	assert.Equal(t, []string{"in_progress", "completed"}, w.Transitions["review"])
+
+	setStatus := func(status string) error {
+		return s.Todos.Update(todo.ID, TodoUpdate{Status: &status})
+	}
+	var transitionErr *TransitionError
+	require.ErrorAs(t, setStatus("completed"), &transitionErr)
+	assert.Equal(t, "not_started", transitionErr.From)
+	assert.ErrorIs(t, setStatus("stuck"), ErrUnknownStatus, "stuck left the workflow")
+	for _, status := range []string{"in_progress", "review", "completed"} {
+		require.NoError(t, setStatus(status))
+	}
+	require.NoError(t, setStatus("completed"), "keeping the status is not a change")
+
+	// Spread the changes out: created at 9:00, started at 10:00, in review
+	// at 12:00 and done at 13:00
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	_, err = database.Exec("UPDATE todos SET created_at = ? WHERE id = ?", start, todo.ID)
+	require.NoError(t, err)
+	for status, hours := range map[string]int{"in_progress": 1, "review": 3, "completed": 4} {
+		_, err = database.Exec("UPDATE todo_status_history SET changed_at = ? WHERE to_status = ?", start.Add(time.Duration(hours)*time.Hour), status)
+		require.NoError(t, err)
+	}
+
+	history, err := s.Workflow.History(todo.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "not_started", history[0].FromStatus)
+	assert.Equal(t, 60, history[0].Minutes)
+	assert.Equal(t, 120, history[1].Minutes)
+
+	report, err := s.Workflow.Report(StatusFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Completed)
+	assert.Equal(t, 4.0, report.LeadTime.AverageHours)
+	assert.Equal(t, 3.0, report.CycleTime.MedianHours)
+	require.Len(t, report.Statuses, 5)
+	assert.Equal(t, 1.0, report.Statuses[0].TotalHours)
+	assert.Equal(t, 2.0, report.Statuses[1].AverageHours)
+	assert.Equal(t, 1, report.Statuses[4].Current)
+
+	from := start.Add(24 * time.Hour)
+	report, err = s.Workflow.Report(StatusFilter{From: &from})
+	require.NoError(t, err)
+	assert.Zero(t, report.Completed)
+
+	// A blocked todo shows the workflow's blocked status
+	blocker := &models.Todo{Title: "Get access"}
+	require.NoError(t, s.Todos.Create(blocker, nil))
+	blocked := &models.Todo{Title: "Deploy"}
+	require.NoError(t, s.Todos.Create(blocked, nil))
+	require.NoError(t, s.Todos.AddBlocker(blocked.ID, blocker.ID))
+	got, err := s.Todos.Get(blocked.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "waiting", got.Status)
+
+	var workflowErr *WorkflowError
+	assert.ErrorAs(t, s.Workflow.Replace(models.Workflow{Statuses: []models.TodoStatus{
+		{Key: "not_started", Label: "To do", Category: "open"},
+	}}), &workflowErr, "needs a done status")
+	err = s.Workflow.Replace(models.Workflow{Statuses: []models.TodoStatus{
+		{Key: "not_started", Label: "To do", Category: "open"},
+		{Key: "done", Label: "Done", Category: "done"},
+	}})
+	require.ErrorAs(t, err, &workflowErr)
+	assert.Contains(t, workflowErr.Reason, `"completed" is still used by 1 todos`)
+}
+
+func TestBulkChanges(t *testing.T) {
+	s, _ := setupStore(t)
+
+	account := &models.Account{Name: "Acme"}
+	require.NoError(t, s.Accounts.Create(account))
+	tag := &models.Tag{Name: "poc"}
+	require.NoError(t, s.Tags.Create(tag))
+	var noteIDs []string
+	for _, title := range []string{"Kickoff", "Demo"} {
+		note := &models.Note{Title: title, AccountID: account.ID}
+		require.NoError(t, s.Notes.Create(note))
+		noteIDs = append(noteIDs, note.ID)
+	}
+
+	archived := true
+	require.NoError(t, s.Notes.Bulk(noteIDs, NoteBulkOp{Update: NoteUpdate{Archived: &archived}}))
+	require.NoError(t, s.Notes.Bulk(noteIDs, NoteBulkOp{AddTag: tag.ID}))
+	for _, id := range noteIDs {
+		got, err := s.Notes.Get(id)
+		require.NoError(t, err)
+		assert.True(t, got.Archived)
+		assert.Equal(t, 2, got.Version)
+		tags, err := s.Tags.ListForNote(id)
+		require.NoError(t, err)
+		assert.Len(t, tags, 1)
+	}
+
+	// One missing note stops the others from being deleted
+	var bulkErr *BulkError
+	require.ErrorAs(t, s.Notes.Bulk(append(noteIDs, "missing"), NoteBulkOp{Delete: true}), &bulkErr)
+	assert.Len(t, bulkErr.Items, 1)
+	assert.ErrorIs(t, bulkErr.Items["missing"], ErrNotFound)
+	deleted, err := s.Notes.ListDeleted()
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+
+	// Deleting again is a no-op, but nothing else touches the trash
+	require.NoError(t, s.Notes.Delete(noteIDs[0]))
+	require.NoError(t, s.Notes.Bulk(noteIDs, NoteBulkOp{Delete: true}))
+	deleted, err = s.Notes.ListDeleted()
+	require.NoError(t, err)
+	assert.Len(t, deleted, 2)
+	require.NoError(t, s.Notes.Restore(noteIDs[1]))
+	pinned := true
+	for _, op := range []NoteBulkOp{{Update: NoteUpdate{Pinned: &pinned}}, {RemoveTag: tag.ID}} {
+		require.ErrorAs(t, s.Notes.Bulk(noteIDs, op), &bulkErr)
+		assert.Len(t, bulkErr.Items, 1)
+		assert.ErrorIs(t, bulkErr.Items[noteIDs[0]], ErrTrashed)
+	}
+	require.NoError(t, s.Notes.Bulk(noteIDs, NoteBulkOp{Restore: true}))
+
+	running := &models.Todo{Title: "Run POC"}
+	require.NoError(t, s.Todos.Create(running, nil))
+	_, err = s.Time.Start(running.ID, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	other := &models.Todo{Title: "Send deck"}
+	require.NoError(t, s.Todos.Create(other, nil))
+	todoIDs := []string{running.ID, other.ID, running.ID}
+
+	completed := "completed"
+	require.NoError(t, s.Todos.Bulk(todoIDs, TodoBulkOp{Update: TodoUpdate{Status: &completed}}))
+	got, err := s.Todos.Get(running.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", got.Status)
+	assert.Nil(t, got.Timer, "completing stops the timer")
+	history, err := s.Workflow.History(running.ID)
+	require.NoError(t, err)
+	assert.Len(t, history, 1, "the repeated ID is changed once")
+
+	require.NoError(t, s.Todos.Delete(other.ID))
+	high := "high"
+	require.ErrorAs(t, s.Todos.Bulk(todoIDs, TodoBulkOp{Update: TodoUpdate{Priority: &high}}), &bulkErr)
+	assert.ErrorIs(t, bulkErr.Items[other.ID], ErrTrashed)
+	require.NoError(t, s.Todos.Bulk(todoIDs, TodoBulkOp{Delete: true}), "deleting a todo in the trash is a no-op")
+	require.NoError(t, s.Todos.Restore(running.ID))
+
+	// The workflow has to allow the change for every todo
+	require.NoError(t, s.Workflow.Replace(models.Workflow{
+		Statuses: []models.TodoStatus{
+			{Key: "not_started", Label: "To do", Category: "open"},
+			{Key: "in_progress", Label: "Doing", Category: "active"},
+			{Key: "completed", Label: "Done", Category: "done"},
+		},
+		Transitions: map[string][]string{
+			"not_started": {"in_progress"},
+			"in_progress": {"completed"},
+			"completed":   {"in_progress"},
+		},
+	}))
+	started := &models.Todo{Title: "Book room"}
+	require.NoError(t, s.Todos.Create(started, nil))
+	inProgress := "in_progress"
+	require.NoError(t, s.Todos.Bulk([]string{started.ID}, TodoBulkOp{Update: TodoUpdate{Status: &inProgress}}))
+	fresh := &models.Todo{Title: "Write recap"}
+	require.NoError(t, s.Todos.Create(fresh, nil))
+	err = s.Todos.Bulk([]string{started.ID, fresh.ID}, TodoBulkOp{Update: TodoUpdate{Status: &completed}})
+	require.ErrorAs(t, err, &bulkErr)
+	assert.Len(t, bulkErr.Items, 1)
+	var transitionErr *TransitionError
+	assert.ErrorAs(t, bulkErr.Items[fresh.ID], &transitionErr)
+	got, err = s.Todos.Get(started.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "in_progress", got.Status, "rolled back")
+}
+
+func TestNoteTaskSync(t *testing.T) {
+	s, _ := setupStore(t)
+
+	account := &models.Account{Name: "Acme"}
+	require.NoError(t, s.Accounts.Create(account))
+
+	// A todo that cannot be created keeps the note from being saved
+	missing := "missing"
+	note := &models.Note{Title: "Kickoff", AccountID: account.ID, Content: "<p>Agenda</p>"}
+	_, err := s.Notes.CreateWithTasks(note, TaskSync{Create: []*models.Todo{{Title: "Orphan", ParentID: &missing}}})
+	assert.ErrorIs(t, err, ErrParentNotFound)
+	_, err = s.Notes.Get(note.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	todo := &models.Todo{ID: "todo-1", Title: "Send deck", Status: "completed"}
+	content := `<ul data-type="taskList"><li data-type="taskItem" data-checked="true" data-todo-id="todo-1"><p>Send deck</p></li></ul>`
+	note = &models.Note{Title: "Kickoff", AccountID: account.ID, Content: content}
+	result, err := s.Notes.CreateWithTasks(note, TaskSync{Create: []*models.Todo{todo}})
+	require.NoError(t, err)
+	assert.Empty(t, result.Skipped)
+
+	// Unticking an item the workflow won't reopen keeps it ticked
+	require.NoError(t, s.Workflow.Replace(models.Workflow{
+		Statuses: []models.TodoStatus{
+			{Key: "not_started", Label: "To do", Category: "open"},
+			{Key: "completed", Label: "Done", Category: "done"},
+		},
+		Transitions: map[string][]string{"not_started": {"completed"}},
+	}))
+	unticked := strings.Replace(content, `data-checked="true"`, `data-checked="false"`, 1)
+	result, err = s.Notes.UpdateWithTasks(note.ID, NoteUpdate{Content: &unticked},
+		TaskSync{Status: map[string]string{"todo-1": "not_started"}})
+	require.NoError(t, err)
+	assert.Zero(t, result.Moved)
+	var transitionErr *TransitionError
+	assert.ErrorAs(t, result.Skipped["todo-1"], &transitionErr)
+	saved, err := s.Notes.Get(note.ID)
+	require.NoError(t, err)
+	assert.Equal(t, content, saved.Content)
+	got, err := s.Todos.Get("todo-1")
+	require.NoError(t, err)
+	assert.Equal(t, "completed", got.Status)
+}
@@ -0,0 +1,427 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidTagName is returned for a tag name that is empty or has an
+	// empty level, such as "customer//poc"
+	ErrInvalidTagName = errors.New("invalid tag name")
+	// ErrTagCycle is returned when a tag would be renamed or merged into
+	// itself or one of its children
+	ErrTagCycle = errors.New("tag cannot move under itself")
+)
+
+// TagStore persists tags and their assignment to notes, todos and accounts.
+// Names are paths: a tag is created with any missing parents, and renaming,
+// merging or deleting a tag carries its children along.
+type TagStore interface {
+	// List returns every tag by name with its usage counts
+	List() ([]models.Tag, error)
+	Get(id string) (*models.Tag, error)
+	GetByName(name string) (*models.Tag, error)
+	// Create inserts a tag and its missing parents, assigning an ID when unset
+	Create(t *models.Tag) error
+	// Update applies u. A renamed tag takes its children with it, and fails
+	// with ErrDuplicate if any new name is taken.
+	Update(id string, u TagUpdate) error
+	// Delete removes a tag and its children
+	Delete(id string) error
+	// Merge moves a tag's notes, todos, accounts and children onto the tag
+	// into, then deletes it. Children whose new name is taken are merged too.
+	Merge(id, into string) error
+	ListForNote(noteID string) ([]models.Tag, error)
+	AddToNote(noteID, tagID string) error
+	RemoveFromNote(noteID, tagID string) error
+	ListForTodo(todoID string) ([]models.Tag, error)
+	AddToTodo(todoID, tagID string) error
+	RemoveFromTodo(todoID, tagID string) error
+	ListForAccount(accountID string) ([]models.Tag, error)
+	AddToAccount(accountID, tagID string) error
+	RemoveFromAccount(accountID, tagID string) error
+}
+
+// TagUpdate holds the fields to change on a tag; nil fields are left alone
+type TagUpdate struct {
+	Name  *string
+	Color *string
+}
+
+// CleanTagName trims the spaces around each level of a tag name
+func CleanTagName(name string) (string, error) {
+	levels := strings.Split(name, "/")
+	for i, level := range levels {
+		levels[i] = strings.TrimSpace(level)
+		if levels[i] == "" {
+			return "", ErrInvalidTagName
+		}
+	}
+	return strings.Join(levels, "/"), nil
+}
+
+// tagParent returns the name of the tag a tag is nested under
+func tagParent(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// tagLink is a table assigning tags to one kind of row
+type tagLink struct {
+	table  string
+	column string
+}
+
+var (
+	noteTags    = tagLink{"note_tags", "note_id"}
+	todoTags    = tagLink{"todo_tags", "todo_id"}
+	accountTags = tagLink{"account_tags", "account_id"}
+	tagLinks    = []tagLink{noteTags, todoTags, accountTags}
+)
+
+// tagCond matches rows carrying the tags or their children. idCol holds the
+// row's id; with any, one tag is enough, otherwise every tag is needed.
+func tagCond(l tagLink, idCol string, tags []string, any bool) (string, []interface{}) {
+	conds, args := []string{}, []interface{}{}
+	for _, tag := range tags {
+		conds = append(conds, `EXISTS (SELECT 1 FROM `+l.table+` lt JOIN tags tg ON tg.id = lt.tag_id
+			WHERE lt.`+l.column+` = `+idCol+` AND (LOWER(tg.name) = LOWER(?)
+				OR LOWER(substr(tg.name, 1, length(?) + 1)) = LOWER(?) || '/'))`)
+		args = append(args, tag, tag, tag)
+	}
+	op := " AND "
+	if any {
+		op = " OR "
+	}
+	return "(" + strings.Join(conds, op) + ")", args
+}
+
+type sqliteTagStore struct {
+	db *sql.DB
+}
+
+func (s *sqliteTagStore) queryTags(query string, args ...interface{}) ([]models.Tag, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tag.Parent = tagParent(tag.Name)
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *sqliteTagStore) List() ([]models.Tag, error) {
+	rows, err := s.db.Query(`
+		SELECT t.id, t.name, t.color, t.created_at,
+		       (SELECT COUNT(*) FROM note_tags nt JOIN notes n ON n.id = nt.note_id
+		        WHERE nt.tag_id = t.id AND n.deleted_at IS NULL),
+		       (SELECT COUNT(*) FROM todo_tags tt JOIN todos td ON td.id = tt.todo_id
+		        WHERE tt.tag_id = t.id AND td.deleted_at IS NULL),
+		       (SELECT COUNT(*) FROM account_tags at JOIN accounts a ON a.id = at.account_id
+		        WHERE at.tag_id = t.id AND a.deleted_at IS NULL)
+		FROM tags t
+		ORDER BY t.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		var usage models.TagUsage
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt,
+			&usage.Notes, &usage.Todos, &usage.Accounts); err != nil {
+			return nil, err
+		}
+		tag.Parent = tagParent(tag.Name)
+		tag.Usage = &usage
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *sqliteTagStore) Get(id string) (*models.Tag, error) {
+	return s.getBy("id = ?", id)
+}
+
+func (s *sqliteTagStore) GetByName(name string) (*models.Tag, error) {
+	name, err := CleanTagName(name)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return s.getBy("name = ? COLLATE NOCASE", name)
+}
+
+func (s *sqliteTagStore) getBy(cond, value string) (*models.Tag, error) {
+	var tag models.Tag
+	err := s.db.QueryRow("SELECT id, name, color, created_at FROM tags WHERE "+cond, value).
+		Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	tag.Parent = tagParent(tag.Name)
+	return &tag, nil
+}
+
+func (s *sqliteTagStore) Create(t *models.Tag) error {
+	name, err := CleanTagName(t.Name)
+	if err != nil {
+		return err
+	}
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if name, err = ensureParents(tx, name); err != nil {
+		return err
+	}
+	t.Name = name
+	t.Parent = tagParent(name)
+	_, err = tx.Exec("INSERT INTO tags (id, name, color, created_at) VALUES (?, ?, ?, ?)", t.ID, t.Name, t.Color, t.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ensureParents creates the missing tags above name. Names are unique
+// regardless of case, so it returns name with the parents that already
+// exist spelled as they are stored: "Customer/poc" files under "customer".
+func ensureParents(tx *sql.Tx, name string) (string, error) {
+	levels := strings.Split(name, "/")
+	for i := 1; i < len(levels); i++ {
+		parent := strings.Join(levels[:i], "/")
+		var existing string
+		err := tx.QueryRow("SELECT name FROM tags WHERE name = ? COLLATE NOCASE", parent).Scan(&existing)
+		if err == sql.ErrNoRows {
+			existing = parent
+			_, err = tx.Exec("INSERT INTO tags (id, name, created_at) VALUES (?, ?, ?)", uuid.New().String(), parent, time.Now())
+		}
+		if err != nil {
+			return "", err
+		}
+		copy(levels, strings.Split(existing, "/"))
+	}
+	return strings.Join(levels, "/"), nil
+}
+
+func (s *sqliteTagStore) Update(id string, u TagUpdate) error {
+	tag, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if u.Name != nil {
+		name, err := CleanTagName(*u.Name)
+		if err != nil {
+			return err
+		}
+		if name != tag.Name {
+			if err := moveTags(tx, tag.Name, name, false); err != nil {
+				return err
+			}
+		}
+	}
+	if u.Color != nil {
+		if _, err := tx.Exec("UPDATE tags SET color = ? WHERE id = ?", *u.Color, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteTagStore) Merge(id, into string) error {
+	tag, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	target, err := s.Get(into)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := moveTags(tx, tag.Name, target.Name, true); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// moveTags renames the tag from, and its children, to sit at to. A tag whose
+// new name is taken, in any case, is merged into the tag holding it when
+// merge is set, and is ErrDuplicate otherwise. Changing only the case of a
+// name respells its children too.
+func moveTags(tx *sql.Tx, from, to string, merge bool) error {
+	if to == from || len(to) > len(from) && strings.EqualFold(to[:len(from)+1], from+"/") {
+		return ErrTagCycle
+	}
+	to, err := ensureParents(tx, to)
+	if err != nil {
+		return err
+	}
+	if to == from {
+		return nil
+	}
+
+	rows, err := tx.Query(`SELECT id, name FROM tags
+		WHERE name = ? COLLATE NOCASE OR LOWER(substr(name, 1, length(?) + 1)) = LOWER(?) || '/' ORDER BY name`,
+		from, from, from)
+	if err != nil {
+		return err
+	}
+	type move struct{ id, name string }
+	var moves []move
+	for rows.Next() {
+		var m move
+		if err := rows.Scan(&m.id, &m.name); err != nil {
+			rows.Close()
+			return err
+		}
+		m.name = to + m.name[len(from):]
+		moves = append(moves, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range moves {
+		var existing string
+		err := tx.QueryRow("SELECT id FROM tags WHERE name = ? COLLATE NOCASE AND id != ?", m.name, m.id).Scan(&existing)
+		if err == sql.ErrNoRows {
+			if _, err := tx.Exec("UPDATE tags SET name = ? WHERE id = ?", m.name, m.id); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !merge {
+			return ErrDuplicate
+		}
+		for _, l := range tagLinks {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO `+l.table+` (`+l.column+`, tag_id, created_at)
+				SELECT `+l.column+`, ?, created_at FROM `+l.table+` WHERE tag_id = ?`, existing, m.id); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM tags WHERE id = ?", m.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteTagStore) Delete(id string) error {
+	tag, err := s.Get(id)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("DELETE FROM tags WHERE id = ? OR LOWER(substr(name, 1, length(?) + 1)) = LOWER(?) || '/'", id, tag.Name, tag.Name)
+	return err
+}
+
+func (s *sqliteTagStore) listFor(l tagLink, id string) ([]models.Tag, error) {
+	return s.queryTags(`
+		SELECT t.id, t.name, t.color, t.created_at
+		FROM tags t
+		JOIN `+l.table+` lt ON t.id = lt.tag_id
+		WHERE lt.`+l.column+` = ?
+		ORDER BY t.name
+	`, id)
+}
+
+func (s *sqliteTagStore) addTo(l tagLink, id, tagID string) error {
+	_, err := s.db.Exec("INSERT OR IGNORE INTO "+l.table+" ("+l.column+", tag_id) VALUES (?, ?)", id, tagID)
+	return err
+}
+
+func (s *sqliteTagStore) removeFrom(l tagLink, id, tagID string) error {
+	_, err := s.db.Exec("DELETE FROM "+l.table+" WHERE "+l.column+" = ? AND tag_id = ?", id, tagID)
+	return err
+}
+
+func (s *sqliteTagStore) ListForNote(noteID string) ([]models.Tag, error) {
+	return s.listFor(noteTags, noteID)
+}
+
+func (s *sqliteTagStore) AddToNote(noteID, tagID string) error {
+	return s.addTo(noteTags, noteID, tagID)
+}
+
+func (s *sqliteTagStore) RemoveFromNote(noteID, tagID string) error {
+	return s.removeFrom(noteTags, noteID, tagID)
+}
+
+func (s *sqliteTagStore) ListForTodo(todoID string) ([]models.Tag, error) {
+	return s.listFor(todoTags, todoID)
+}
+
+func (s *sqliteTagStore) AddToTodo(todoID, tagID string) error {
+	return s.addTo(todoTags, todoID, tagID)
+}
+
+func (s *sqliteTagStore) RemoveFromTodo(todoID, tagID string) error {
+	return s.removeFrom(todoTags, todoID, tagID)
+}
+
+func (s *sqliteTagStore) ListForAccount(accountID string) ([]models.Tag, error) {
+	return s.listFor(accountTags, accountID)
+}
+
+func (s *sqliteTagStore) AddToAccount(accountID, tagID string) error {
+	return s.addTo(accountTags, accountID, tagID)
+}
+
+func (s *sqliteTagStore) RemoveFromAccount(accountID, tagID string) error {
+	return s.removeFrom(accountTags, accountID, tagID)
+}
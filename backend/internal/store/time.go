@@ -0,0 +1,294 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTimerRunning is returned when starting a timer on a todo whose
+	// timer already runs
+	ErrTimerRunning = errors.New("timer already running")
+	// ErrTimerStopped is returned when stopping a timer that does not run
+	ErrTimerStopped = errors.New("no timer running")
+)
+
+// TimeStore records the time spent on todos
+type TimeStore interface {
+	// Start starts a timer on a todo that is not in the trash
+	Start(todoID string, at time.Time) (*models.TimeEntry, error)
+	// Stop stops the todo's timer and returns the finished entry
+	Stop(todoID string, at time.Time) (*models.TimeEntry, error)
+	// Add records time entered by hand; EndedAt is set from Minutes
+	Add(e *models.TimeEntry) error
+	// ListForTodo returns a todo's entries, latest first
+	ListForTodo(todoID string) ([]models.TimeEntry, error)
+	Delete(id string) error
+	// Report rolls up the finished entries of todos not in the trash
+	Report(f TimeFilter) (*models.TimeReport, error)
+}
+
+// TimeFilter narrows a time report; zero fields match every entry
+type TimeFilter struct {
+	AccountID string
+	// From and To bound when entries started, To exclusively
+	From *time.Time
+	To   *time.Time
+}
+
+type sqliteTimeStore struct {
+	db *sql.DB
+}
+
+// entryMinutes rounds the length of an entry to whole minutes
+func entryMinutes(start, end time.Time) int {
+	return int(end.Sub(start).Round(time.Minute) / time.Minute)
+}
+
+const timeEntrySelect = `
+	SELECT id, todo_id, started_at, ended_at, COALESCE(minutes, 0), note, source, created_at
+	FROM time_entries`
+
+func scanTimeEntry(row scanner) (*models.TimeEntry, error) {
+	var e models.TimeEntry
+	var endedAt sql.NullTime
+	if err := row.Scan(&e.ID, &e.TodoID, &e.StartedAt, &endedAt, &e.Minutes, &e.Note, &e.Source, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	e.EndedAt = nullTimePtr(endedAt)
+	return &e, nil
+}
+
+func (s *sqliteTimeStore) Start(todoID string, at time.Time) (*models.TimeEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := liveTodo(tx, todoID); err != nil {
+		return nil, err
+	}
+	var running int
+	err = tx.QueryRow("SELECT 1 FROM time_entries WHERE todo_id = ? AND ended_at IS NULL", todoID).Scan(&running)
+	if err == nil {
+		return nil, ErrTimerRunning
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	e := &models.TimeEntry{ID: uuid.New().String(), TodoID: todoID, StartedAt: at, Source: "timer", CreatedAt: at}
+	_, err = tx.Exec(`
+		INSERT INTO time_entries (id, todo_id, started_at, source, created_at) VALUES (?, ?, ?, ?, ?)
+	`, e.ID, e.TodoID, e.StartedAt, e.Source, e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return e, tx.Commit()
+}
+
+func (s *sqliteTimeStore) Stop(todoID string, at time.Time) (*models.TimeEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id, err := stopTimer(tx, todoID, at)
+	if err != nil {
+		return nil, err
+	}
+	e, err := scanTimeEntry(tx.QueryRow(timeEntrySelect+" WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	return e, tx.Commit()
+}
+
+// stopTimer ends the running timer of a todo and returns the entry's id
+func stopTimer(tx *sql.Tx, todoID string, at time.Time) (string, error) {
+	var id string
+	var startedAt time.Time
+	err := tx.QueryRow("SELECT id, started_at FROM time_entries WHERE todo_id = ? AND ended_at IS NULL", todoID).Scan(&id, &startedAt)
+	if err == sql.ErrNoRows {
+		return "", ErrTimerStopped
+	}
+	if err != nil {
+		return "", err
+	}
+	if at.Before(startedAt) {
+		at = startedAt
+	}
+	_, err = tx.Exec("UPDATE time_entries SET ended_at = ?, minutes = ? WHERE id = ?", at, entryMinutes(startedAt, at), id)
+	return id, err
+}
+
+func (s *sqliteTimeStore) Add(e *models.TimeEntry) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	e.Source = "manual"
+	ended := e.StartedAt.Add(time.Duration(e.Minutes) * time.Minute)
+	e.EndedAt = &ended
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := liveTodo(tx, e.TodoID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO time_entries (id, todo_id, started_at, ended_at, minutes, note, source, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.ID, e.TodoID, e.StartedAt, e.EndedAt, e.Minutes, e.Note, e.Source, e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteTimeStore) ListForTodo(todoID string) ([]models.TimeEntry, error) {
+	rows, err := s.db.Query(timeEntrySelect+" WHERE todo_id = ? ORDER BY started_at DESC", todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.TimeEntry{}
+	for rows.Next() {
+		e, err := scanTimeEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *sqliteTimeStore) Delete(id string) error {
+	return expectAffected(s.db.Exec("DELETE FROM time_entries WHERE id = ?", id))
+}
+
+// weekStart returns the Monday that starts t's week, in local time
+func weekStart(t time.Time) time.Time {
+	t = t.Local()
+	sinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-sinceMonday, 0, 0, 0, 0, time.Local)
+}
+
+// accountTimes adds up minutes per account and keeps track of the todos
+type accountTimes struct {
+	totals map[string]*models.AccountTime
+	todos  map[string]map[string]bool
+}
+
+func newAccountTimes() *accountTimes {
+	return &accountTimes{totals: map[string]*models.AccountTime{}, todos: map[string]map[string]bool{}}
+}
+
+func (a *accountTimes) add(accountID *string, accountName, todoID string, minutes int) {
+	key := ""
+	if accountID != nil {
+		key = *accountID
+	}
+	total, ok := a.totals[key]
+	if !ok {
+		total = &models.AccountTime{AccountID: accountID, AccountName: accountName}
+		a.totals[key] = total
+		a.todos[key] = map[string]bool{}
+	}
+	total.Minutes += minutes
+	a.todos[key][todoID] = true
+}
+
+// list returns the totals, most time first
+func (a *accountTimes) list() []models.AccountTime {
+	list := []models.AccountTime{}
+	for key, total := range a.totals {
+		total.Todos = len(a.todos[key])
+		list = append(list, *total)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Minutes != list[j].Minutes {
+			return list[i].Minutes > list[j].Minutes
+		}
+		return list[i].AccountName < list[j].AccountName
+	})
+	return list
+}
+
+func (s *sqliteTimeStore) Report(f TimeFilter) (*models.TimeReport, error) {
+	query := `
+		SELECT te.todo_id, te.started_at, te.minutes, t.account_id, COALESCE(a.name, '')
+		FROM time_entries te
+		JOIN todos t ON t.id = te.todo_id
+		LEFT JOIN accounts a ON a.id = t.account_id
+		WHERE te.minutes IS NOT NULL AND t.deleted_at IS NULL`
+	args := []interface{}{}
+	if f.AccountID != "" {
+		query += " AND t.account_id = ?"
+		args = append(args, f.AccountID)
+	}
+	if f.From != nil {
+		query += " AND julianday(te.started_at) >= julianday(?)"
+		args = append(args, utcTime(*f.From))
+	}
+	if f.To != nil {
+		query += " AND julianday(te.started_at) < julianday(?)"
+		args = append(args, utcTime(*f.To))
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &models.TimeReport{}
+	byAccount := newAccountTimes()
+	byWeek := map[string]*accountTimes{}
+	for rows.Next() {
+		var todoID, accountName string
+		var startedAt time.Time
+		var minutes int
+		var accountID sql.NullString
+		if err := rows.Scan(&todoID, &startedAt, &minutes, &accountID, &accountName); err != nil {
+			return nil, err
+		}
+		report.TotalMinutes += minutes
+		byAccount.add(nullStringPtr(accountID), accountName, todoID, minutes)
+
+		week := weekStart(startedAt).Format("2006-01-02")
+		if byWeek[week] == nil {
+			byWeek[week] = newAccountTimes()
+		}
+		byWeek[week].add(nullStringPtr(accountID), accountName, todoID, minutes)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report.ByAccount = byAccount.list()
+	report.ByWeek = []models.WeekTime{}
+	for week, accounts := range byWeek {
+		w := models.WeekTime{WeekStart: week, Accounts: accounts.list()}
+		for _, a := range w.Accounts {
+			w.Minutes += a.Minutes
+		}
+		report.ByWeek = append(report.ByWeek, w)
+	}
+	sort.Slice(report.ByWeek, func(i, j int) bool { return report.ByWeek[i].WeekStart < report.ByWeek[j].WeekStart })
+	return report, nil
+}
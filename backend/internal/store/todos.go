@@ -0,0 +1,911 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/factory-sagar/notes-droid/backend/internal/recur"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTodoCycle is returned when a todo would become its own subtask or
+	// would be blocked, directly or not, by itself
+	ErrTodoCycle = errors.New("todo would depend on itself")
+	// ErrParentNotFound is returned when a todo is put under one that does
+	// not exist or is in the trash
+	ErrParentNotFound = errors.New("parent todo not found")
+)
+
+// TodoStore persists todos, their subtasks and dependencies, and their
+// links to notes
+type TodoStore interface {
+	// List returns a page of the todos not in the trash that match f, with
+	// their linked notes, newest first unless o sorts them otherwise
+	List(f TodoFilter, o ListOptions) ([]models.Todo, PageInfo, error)
+	// ListByAccount returns an account's todos not in the trash with their
+	// linked notes
+	ListByAccount(accountID string) ([]models.Todo, error)
+	ListDeleted() ([]models.Todo, error)
+	// Get returns a todo with its linked notes, blockers and subtasks
+	Get(id string) (*models.Todo, error)
+	// Create inserts a todo, assigning an ID and timestamps when unset, and
+	// links it to noteID if given. Without a status it starts in the
+	// workflow's first open status.
+	Create(t *models.Todo, noteID *string) error
+	// Update changes a todo. A status change must be allowed by the
+	// workflow and is recorded in the todo's history. Completing an
+	// occurrence of a recurring todo records the completion and creates the
+	// next occurrence. Moving a todo under itself or its subtasks returns
+	// ErrTodoCycle.
+	Update(id string, u TodoUpdate) error
+	Delete(id string) error
+	Restore(id string) error
+	PermanentDelete(id string) error
+	EmptyTrash() (int64, error)
+	TogglePin(id string) (bool, error)
+	LinkNote(todoID, noteID string) error
+	UnlinkNote(todoID, noteID string) error
+	// AddBlocker marks todoID as blocked until blockerID is completed. It
+	// returns ErrNotFound when either todo is missing or in the trash, and
+	// ErrTodoCycle when blockerID already waits on todoID.
+	AddBlocker(todoID, blockerID string) error
+	RemoveBlocker(todoID, blockerID string) error
+	// Completions returns the completed occurrences of a series, latest
+	// first
+	Completions(seriesID string) ([]models.TodoCompletion, error)
+	// Bulk makes the same change to every todo in ids in one transaction,
+	// with the same checks and side effects as one at a time. Todos in the
+	// trash can only be deleted again, which changes nothing; other changes
+	// to them fail with ErrTrashed. If any todo cannot be changed it returns
+	// a *BulkError and changes none.
+	Bulk(ids []string, op TodoBulkOp) error
+}
+
+// TodoBulkOp is the change Bulk makes to each todo: Delete, or else Update
+type TodoBulkOp struct {
+	Delete bool
+	// Update is applied as by Update; ExpectedVersion is ignored
+	Update TodoUpdate
+}
+
+// TodoUpdate holds the fields to change on a todo; nil fields are left alone
+type TodoUpdate struct {
+	Title       *string
+	Description *string
+	Status      *string
+	Priority    *string
+	DueDate     *time.Time
+	AccountID   *string
+	Pinned      *bool
+	// Recurrence is a rule as formatted by recur.Rule.String, or empty to
+	// stop the todo recurring
+	Recurrence *string
+	// ParentID moves the todo under another one, or to the top level when
+	// empty
+	ParentID *string
+	// Estimate is the expected effort in minutes, or 0 to clear it
+	Estimate *int
+	// ExpectedVersion, when set, makes the update fail with ErrConflict
+	// unless the todo is still at this version
+	ExpectedVersion *int
+}
+
+// IsEmpty reports whether the update changes nothing
+func (u TodoUpdate) IsEmpty() bool {
+	return u.Title == nil && u.Description == nil && u.Status == nil && u.Priority == nil &&
+		u.DueDate == nil && u.AccountID == nil && u.Pinned == nil &&
+		u.Recurrence == nil && u.ParentID == nil && u.Estimate == nil
+}
+
+// TodoFilter narrows List results; zero fields match every todo
+type TodoFilter struct {
+	// Status matches the status as returned, so "stuck" includes blocked
+	// todos
+	Status    string
+	AccountID string
+	Priority  string
+	Pinned    *bool
+	// ParentID lists the subtasks of a todo; TopLevel leaves subtasks out
+	ParentID string
+	TopLevel bool
+	// Tags are matched as for notes
+	Tags     []string
+	MatchAny bool
+	// DueAfter and DueBefore bound the due date, DueBefore exclusively.
+	// Todos without a due date never match either.
+	DueAfter  *time.Time
+	DueBefore *time.Time
+}
+
+var todoSorts = sortKeys{
+	keys: map[string]sortKey{
+		"created_at": {expr: "t.created_at", desc: true},
+		"updated_at": {expr: "t.updated_at", desc: true},
+		// Todos without a due date come after the rest
+		"due_date": {expr: "COALESCE(t.due_date, '9999-12-31')"},
+		"title":    {expr: "LOWER(t.title)"},
+		"priority": {expr: "CASE t.priority WHEN 'high' THEN 0 WHEN 'medium' THEN 1 WHEN 'low' THEN 2 ELSE 3 END"},
+		"status":   {expr: TodoStatus},
+	},
+	fallback: "created_at",
+}
+
+type sqliteTodoStore struct {
+	db *sql.DB
+}
+
+const todoFrom = `todos t
+	LEFT JOIN accounts a ON t.account_id = a.id`
+
+// todoBlocked is true for a todo with a blocker that is not done
+const todoBlocked = `EXISTS (
+	SELECT 1 FROM todo_dependencies dep
+	JOIN todos blocker ON blocker.id = dep.blocked_by
+	WHERE dep.todo_id = t.id AND blocker.deleted_at IS NULL AND COALESCE(blocker.status, '') NOT IN ` + doneStatuses + `)`
+
+// TodoStatus is the status of todo t as returned: the workflow's first
+// blocked status while it is blocked, whatever status it was given
+const TodoStatus = `CASE WHEN COALESCE(t.status, '') NOT IN ` + doneStatuses + ` AND ` + todoBlocked + ` THEN COALESCE(
+		(SELECT key FROM todo_statuses WHERE category = 'blocked' ORDER BY position LIMIT 1), t.status)
+	ELSE COALESCE(t.status, '') END`
+
+const todoSelect = `
+	SELECT t.id, t.title, COALESCE(t.description, ''), ` + TodoStatus + `, COALESCE(t.priority, ''),
+	       t.due_date, t.account_id, COALESCE(a.name, ''), COALESCE(t.pinned, 0),
+	       COALESCE(t.recurrence, ''), COALESCE(t.series_id, ''), COALESCE(t.occurrence, 0),
+	       t.parent_id, ` + todoBlocked + `, t.reminder_minutes, t.estimate_minutes,
+	       (SELECT COALESCE(SUM(te.minutes), 0) FROM time_entries te WHERE te.todo_id = t.id),
+	       t.version, t.created_at, t.updated_at, t.deleted_at
+	FROM ` + todoFrom
+
+func scanTodo(row scanner) (*models.Todo, error) {
+	var t models.Todo
+	var dueDate, deletedAt sql.NullTime
+	var accountID, parentID, reminders sql.NullString
+	var estimate sql.NullInt64
+
+	if err := row.Scan(&t.ID, &t.Title, &t.Description, &t.Status, &t.Priority, &dueDate, &accountID,
+		&t.AccountName, &t.Pinned, &t.Recurrence, &t.SeriesID, &t.Occurrence, &parentID, &t.Blocked,
+		&reminders, &estimate, &t.Tracked, &t.Version, &t.CreatedAt, &t.UpdatedAt, &deletedAt); err != nil {
+		return nil, err
+	}
+
+	t.DueDate = nullTimePtr(dueDate)
+	t.AccountID = nullStringPtr(accountID)
+	t.ParentID = nullStringPtr(parentID)
+	if reminders.Valid {
+		if err := json.Unmarshal([]byte(reminders.String), &t.Reminders); err != nil {
+			return nil, err
+		}
+	}
+	if estimate.Valid {
+		minutes := int(estimate.Int64)
+		t.Estimate = &minutes
+	}
+	t.DeletedAt = nullTimePtr(deletedAt)
+	return &t, nil
+}
+
+func (s *sqliteTodoStore) queryTodos(query string, args ...interface{}) ([]models.Todo, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []models.Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, *t)
+	}
+	return todos, rows.Err()
+}
+
+func (s *sqliteTodoStore) List(f TodoFilter, o ListOptions) ([]models.Todo, PageInfo, error) {
+	q := newListQuery(todoFrom, "t.id", []string{"t.deleted_at IS NULL"}, nil)
+	if f.Status != "" {
+		q.where(TodoStatus+" = ?", f.Status)
+	}
+	if f.AccountID != "" {
+		q.where("t.account_id = ?", f.AccountID)
+	}
+	if f.Priority != "" {
+		q.where("t.priority = ?", f.Priority)
+	}
+	if f.Pinned != nil {
+		q.where("COALESCE(t.pinned, 0) = ?", *f.Pinned)
+	}
+	if f.ParentID != "" {
+		q.where("t.parent_id = ?", f.ParentID)
+	}
+	if f.TopLevel {
+		q.where("t.parent_id IS NULL")
+	}
+	if len(f.Tags) > 0 {
+		cond, args := tagCond(todoTags, "t.id", f.Tags, f.MatchAny)
+		q.where(cond, args...)
+	}
+	if f.DueAfter != nil {
+		q.where("julianday(t.due_date) >= julianday(?)", utcTime(*f.DueAfter))
+	}
+	if f.DueBefore != nil {
+		q.where("julianday(t.due_date) < julianday(?)", utcTime(*f.DueBefore))
+	}
+	if err := q.sortBy(todoSorts, o); err != nil {
+		return nil, PageInfo{}, err
+	}
+	return listPage(s.db, q, todoSelect, o, s.queryWithLinks, func(t models.Todo) string { return t.ID })
+}
+
+func (s *sqliteTodoStore) ListByAccount(accountID string) ([]models.Todo, error) {
+	return s.queryWithLinks(todoSelect+` WHERE t.account_id = ? AND t.deleted_at IS NULL ORDER BY t.created_at DESC`, accountID)
+}
+
+// queryWithLinks runs a todo query and batch fetches each todo's linked
+// notes, blockers, subtask progress and running timer
+func (s *sqliteTodoStore) queryWithLinks(query string, args ...interface{}) ([]models.Todo, error) {
+	todos, err := s.queryTodos(query, args...)
+	if err != nil || len(todos) == 0 {
+		return todos, err
+	}
+	return todos, s.loadLinks(todos)
+}
+
+func (s *sqliteTodoStore) loadLinks(todos []models.Todo) error {
+	ids := make([]string, len(todos))
+	for i, t := range todos {
+		ids[i] = t.ID
+	}
+	linked, err := s.linkedNotes(ids)
+	if err != nil {
+		return err
+	}
+	blockers, err := s.blockers(ids)
+	if err != nil {
+		return err
+	}
+	progress, err := s.progress(ids)
+	if err != nil {
+		return err
+	}
+	timers, err := s.timers(ids)
+	if err != nil {
+		return err
+	}
+	for i := range todos {
+		todos[i].Notes = linked[todos[i].ID]
+		todos[i].BlockedBy = blockers[todos[i].ID]
+		todos[i].Progress = progress[todos[i].ID]
+		todos[i].Timer = timers[todos[i].ID]
+	}
+	return nil
+}
+
+// todoRefs runs a query for a key and the id, title and status of a todo,
+// and groups the todos by key
+func (s *sqliteTodoStore) todoRefs(query string, args ...interface{}) (map[string][]models.TodoRef, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := map[string][]models.TodoRef{}
+	for rows.Next() {
+		var key string
+		var r models.TodoRef
+		if err := rows.Scan(&key, &r.ID, &r.Title, &r.Status); err != nil {
+			return nil, err
+		}
+		refs[key] = append(refs[key], r)
+	}
+	return refs, rows.Err()
+}
+
+// blockers returns the todos not in the trash that block each todo
+func (s *sqliteTodoStore) blockers(todoIDs []string) (map[string][]models.TodoRef, error) {
+	in, args := placeholders(todoIDs)
+	return s.todoRefs(`
+		SELECT dep.todo_id, t.id, t.title, `+TodoStatus+`
+		FROM todo_dependencies dep
+		JOIN todos t ON t.id = dep.blocked_by
+		WHERE dep.todo_id IN (`+in+`) AND t.deleted_at IS NULL
+		ORDER BY t.created_at
+	`, args...)
+}
+
+// progress counts the subtasks of each todo at every depth, and how many of
+// them are done. Todos without subtasks are left out.
+func (s *sqliteTodoStore) progress(todoIDs []string) (map[string]*models.TodoProgress, error) {
+	in, args := placeholders(todoIDs)
+	rows, err := s.db.Query(`
+		WITH RECURSIVE sub(root, id, status) AS (
+			SELECT parent_id, id, status FROM todos
+			WHERE parent_id IN (`+in+`) AND deleted_at IS NULL
+			UNION
+			SELECT sub.root, t.id, t.status FROM todos t
+			JOIN sub ON t.parent_id = sub.id
+			WHERE t.deleted_at IS NULL
+		)
+		SELECT root, COUNT(*), SUM(COALESCE(status, '') IN `+doneStatuses+`) FROM sub GROUP BY root
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := map[string]*models.TodoProgress{}
+	for rows.Next() {
+		var id string
+		var p models.TodoProgress
+		if err := rows.Scan(&id, &p.Total, &p.Completed); err != nil {
+			return nil, err
+		}
+		p.Percent = p.Completed * 100 / p.Total
+		progress[id] = &p
+	}
+	return progress, rows.Err()
+}
+
+// timers returns when the running timer of each todo started
+func (s *sqliteTodoStore) timers(todoIDs []string) (map[string]*time.Time, error) {
+	in, args := placeholders(todoIDs)
+	rows, err := s.db.Query(`
+		SELECT todo_id, started_at FROM time_entries
+		WHERE todo_id IN (`+in+`) AND ended_at IS NULL
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timers := map[string]*time.Time{}
+	for rows.Next() {
+		var todoID string
+		var startedAt time.Time
+		if err := rows.Scan(&todoID, &startedAt); err != nil {
+			return nil, err
+		}
+		timers[todoID] = &startedAt
+	}
+	return timers, rows.Err()
+}
+
+// linkedNotes returns the id and title of notes linked to each todo
+func (s *sqliteTodoStore) linkedNotes(todoIDs []string) (map[string][]models.Note, error) {
+	in, args := placeholders(todoIDs)
+	rows, err := s.db.Query(`
+		SELECT nt.todo_id, n.id, n.title
+		FROM note_todos nt
+		JOIN notes n ON nt.note_id = n.id
+		WHERE nt.todo_id IN (`+in+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	linked := map[string][]models.Note{}
+	for rows.Next() {
+		var todoID string
+		var n models.Note
+		if err := rows.Scan(&todoID, &n.ID, &n.Title); err != nil {
+			return nil, err
+		}
+		linked[todoID] = append(linked[todoID], n)
+	}
+	return linked, rows.Err()
+}
+
+func (s *sqliteTodoStore) ListDeleted() ([]models.Todo, error) {
+	return s.queryTodos(todoSelect + ` WHERE t.deleted_at IS NOT NULL ORDER BY t.deleted_at DESC`)
+}
+
+func (s *sqliteTodoStore) Get(id string) (*models.Todo, error) {
+	t, err := scanTodo(s.db.QueryRow(todoSelect+` WHERE t.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	todos := []models.Todo{*t}
+	if err := s.loadLinks(todos); err != nil {
+		return nil, err
+	}
+	subtasks, err := s.todoRefs(`
+		SELECT t.parent_id, t.id, t.title, `+TodoStatus+`
+		FROM todos t
+		WHERE t.parent_id = ? AND t.deleted_at IS NULL
+		ORDER BY t.created_at
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	todos[0].Subtasks = subtasks[id]
+	return &todos[0], nil
+}
+
+func (s *sqliteTodoStore) Create(t *models.Todo, noteID *string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createTodo(tx, t, noteID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func createTodo(tx *sql.Tx, t *models.Todo, noteID *string) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	if t.UpdatedAt.IsZero() {
+		t.UpdatedAt = t.CreatedAt
+	}
+	t.Version = 1
+	if t.Recurrence != "" && t.SeriesID == "" {
+		t.SeriesID = uuid.New().String()
+		t.Occurrence = 1
+	}
+
+	var err error
+	if t.Status == "" {
+		if t.Status, err = defaultStatus(tx); err != nil {
+			return err
+		}
+	} else if _, err := statusCategory(tx, t.Status); err != nil {
+		return err
+	}
+	if t.ParentID != nil {
+		if err := liveParent(tx, *t.ParentID); err != nil {
+			return err
+		}
+	}
+	reminders, err := reminderMinutes(t.Reminders)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO todos (id, title, description, status, priority, due_date, account_id,
+			recurrence, series_id, occurrence, parent_id, reminder_minutes, estimate_minutes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, 0), ?, ?, NULLIF(?, 0), ?, ?)
+	`, t.ID, t.Title, t.Description, t.Status, t.Priority, t.DueDate, t.AccountID,
+		t.Recurrence, t.SeriesID, t.Occurrence, t.ParentID, reminders, t.Estimate, t.CreatedAt, t.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if noteID != nil {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO note_todos (note_id, todo_id) VALUES (?, ?)", *noteID, t.ID); err != nil {
+			return err
+		}
+	}
+
+	if t.AccountID != nil {
+		tx.QueryRow("SELECT name FROM accounts WHERE id = ?", *t.AccountID).Scan(&t.AccountName)
+	}
+	return nil
+}
+
+func (s *sqliteTodoStore) Update(id string, u TodoUpdate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateTodo(tx, id, u); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func updateTodo(tx *sql.Tx, id string, u TodoUpdate) error {
+	updates := []string{}
+	args := []interface{}{}
+
+	if u.Title != nil {
+		updates = append(updates, "title = ?")
+		args = append(args, *u.Title)
+	}
+	if u.Description != nil {
+		updates = append(updates, "description = ?")
+		args = append(args, *u.Description)
+	}
+	if u.Status != nil {
+		updates = append(updates, "status = ?")
+		args = append(args, *u.Status)
+	}
+	if u.Priority != nil {
+		updates = append(updates, "priority = ?")
+		args = append(args, *u.Priority)
+	}
+	if u.DueDate != nil {
+		updates = append(updates, "due_date = ?")
+		args = append(args, *u.DueDate)
+	}
+	if u.AccountID != nil {
+		updates = append(updates, "account_id = ?")
+		args = append(args, *u.AccountID)
+	}
+	if u.Pinned != nil {
+		updates = append(updates, "pinned = ?")
+		args = append(args, *u.Pinned)
+	}
+	if u.ParentID != nil {
+		updates = append(updates, "parent_id = NULLIF(?, '')")
+		args = append(args, *u.ParentID)
+	}
+	if u.Estimate != nil {
+		updates = append(updates, "estimate_minutes = NULLIF(?, 0)")
+		args = append(args, *u.Estimate)
+	}
+	if u.Recurrence != nil {
+		updates = append(updates, "recurrence = NULLIF(?, '')")
+		args = append(args, *u.Recurrence)
+		if *u.Recurrence != "" {
+			// A todo that starts recurring begins a series; one that stops
+			// keeps its series so its history stays together
+			updates = append(updates, "series_id = COALESCE(series_id, ?)", "occurrence = COALESCE(occurrence, 1)")
+			args = append(args, uuid.New().String())
+		}
+	}
+
+	now := time.Now()
+	updates = append(updates, "updated_at = ?", "version = version + 1")
+	args = append(args, now)
+	args = append(args, id)
+
+	query := "UPDATE todos SET " + strings.Join(updates, ", ") + " WHERE id = ?"
+	if u.ExpectedVersion != nil {
+		query += " AND version = ?"
+		args = append(args, *u.ExpectedVersion)
+	}
+
+	var current string
+	var wasDone bool
+	err := tx.QueryRow(`
+		SELECT COALESCE(status, ''), COALESCE(status, '') IN `+doneStatuses+` FROM todos WHERE id = ?
+	`, id).Scan(&current, &wasDone)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	statusChanged := u.Status != nil && *u.Status != current
+	isDone := wasDone
+	if statusChanged {
+		category, err := statusCategory(tx, *u.Status)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition(tx, current, *u.Status); err != nil {
+			return err
+		}
+		isDone = category == "done"
+	}
+
+	if u.ParentID != nil && *u.ParentID != "" {
+		if err := liveParent(tx, *u.ParentID); err != nil {
+			return err
+		}
+		// The todo cannot go under itself or anything under it
+		cycle, err := reaches(tx, `
+			WITH RECURSIVE chain(id) AS (
+				SELECT ?
+				UNION
+				SELECT t.parent_id FROM todos t JOIN chain ON t.id = chain.id WHERE t.parent_id IS NOT NULL
+			)
+			SELECT 1 FROM chain WHERE id = ?
+		`, *u.ParentID, id)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrTodoCycle
+		}
+	}
+
+	if err := expectAffected(tx.Exec(query, args...)); err != nil {
+		if err == ErrNotFound && u.ExpectedVersion != nil {
+			// The todo exists, so it moved on
+			return ErrConflict
+		}
+		return err
+	}
+
+	if u.DueDate != nil {
+		// Reminders count from the new due date
+		if _, err := tx.Exec("DELETE FROM todo_reminders WHERE todo_id = ?", id); err != nil {
+			return err
+		}
+	}
+
+	if statusChanged {
+		if err := recordStatusChange(tx, id, current, *u.Status, now); err != nil {
+			return err
+		}
+		switch {
+		case isDone && !wasDone:
+			// Work on a todo ends when it is done
+			if _, err := stopTimer(tx, id, now); err != nil && err != ErrTimerStopped {
+				return err
+			}
+			if err := completeOccurrence(tx, id, now); err != nil {
+				return err
+			}
+		case !isDone && wasDone:
+			// Reopened, so it no longer counts as done
+			if _, err := tx.Exec("DELETE FROM todo_completions WHERE todo_id = ?", id); err != nil {
+				return err
+			}
+		}
+		if isDone != wasDone {
+			if err := syncTaskChecks(tx, id, isDone); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// completeOccurrence records a completed occurrence of a recurring todo and
+// creates the next one, unless the rule has ended or the next one already
+// exists because the todo was completed before. An occurrence without a
+// due date is treated as due when it was completed.
+func completeOccurrence(tx *sql.Tx, id string, now time.Time) error {
+	var t models.Todo
+	var dueDate sql.NullTime
+	var accountID, recurrence, seriesID, parentID, reminders sql.NullString
+	var occurrence, estimate sql.NullInt64
+	err := tx.QueryRow(`
+		SELECT title, COALESCE(description, ''), COALESCE(priority, ''), due_date, account_id,
+		       COALESCE(pinned, 0), recurrence, series_id, occurrence, parent_id, reminder_minutes, estimate_minutes
+		FROM todos WHERE id = ?
+	`, id).Scan(&t.Title, &t.Description, &t.Priority, &dueDate, &accountID, &t.Pinned,
+		&recurrence, &seriesID, &occurrence, &parentID, &reminders, &estimate)
+	if err != nil {
+		return err
+	}
+	if !recurrence.Valid || !seriesID.Valid {
+		return nil
+	}
+	n := int(occurrence.Int64)
+	if n < 1 {
+		n = 1
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO todo_completions (id, series_id, todo_id, occurrence, due_date, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, uuid.New().String(), seriesID.String, id, n, nullTimePtr(dueDate), now)
+	if err != nil {
+		return err
+	}
+
+	var exists int
+	err = tx.QueryRow("SELECT 1 FROM todos WHERE series_id = ? AND occurrence > ?", seriesID.String, n).Scan(&exists)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	rule, err := recur.Parse(recurrence.String)
+	if err != nil {
+		return err
+	}
+	due := now
+	if dueDate.Valid {
+		due = dueDate.Time
+	}
+	next, ok := rule.Next(due, n)
+	if !ok {
+		return nil
+	}
+
+	status, err := defaultStatus(tx)
+	if err != nil {
+		return err
+	}
+	nextID := uuid.New().String()
+	_, err = tx.Exec(`
+		INSERT INTO todos (id, title, description, status, priority, due_date, account_id, pinned,
+			recurrence, series_id, occurrence, parent_id, reminder_minutes, estimate_minutes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, nextID, t.Title, t.Description, status, t.Priority, next, nullStringPtr(accountID), t.Pinned,
+		recurrence.String, seriesID.String, n+1, nullStringPtr(parentID), nullStringPtr(reminders), estimate, now, now)
+	if err != nil {
+		return err
+	}
+
+	// The next occurrence keeps the notes and tags of this one, but not its
+	// blockers, which were for this occurrence
+	if _, err := tx.Exec("INSERT INTO note_todos (note_id, todo_id) SELECT note_id, ? FROM note_todos WHERE todo_id = ?", nextID, id); err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO todo_tags (todo_id, tag_id) SELECT ?, tag_id FROM todo_tags WHERE todo_id = ?", nextID, id)
+	return err
+}
+
+func (s *sqliteTodoStore) Delete(id string) error {
+	// Soft delete - set deleted_at timestamp
+	return expectAffected(s.db.Exec("UPDATE todos SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ? AND deleted_at IS NULL", id))
+}
+
+func (s *sqliteTodoStore) Restore(id string) error {
+	return expectAffected(s.db.Exec("UPDATE todos SET deleted_at = NULL, version = version + 1 WHERE id = ?", id))
+}
+
+func (s *sqliteTodoStore) PermanentDelete(id string) error {
+	return expectAffected(s.db.Exec("DELETE FROM todos WHERE id = ?", id))
+}
+
+func (s *sqliteTodoStore) EmptyTrash() (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM todos WHERE deleted_at IS NOT NULL`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteTodoStore) Bulk(ids []string, op TodoBulkOp) error {
+	op.Update.ExpectedVersion = nil
+	return bulk(s.db, ids, func(tx *sql.Tx, id string) error {
+		inTrash, err := trashed(tx, "todos", id)
+		switch {
+		case err != nil:
+			return err
+		case op.Delete && inTrash:
+			return nil
+		case op.Delete:
+			_, err = tx.Exec("UPDATE todos SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ?", id)
+			return err
+		case inTrash:
+			return ErrTrashed
+		}
+		return updateTodo(tx, id, op.Update)
+	})
+}
+
+func (s *sqliteTodoStore) TogglePin(id string) (bool, error) {
+	var pinned int
+	err := s.db.QueryRow("SELECT pinned FROM todos WHERE id = ?", id).Scan(&pinned)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	next := pinned != 1
+	if _, err := s.db.Exec("UPDATE todos SET pinned = ?, updated_at = ?, version = version + 1 WHERE id = ?", next, time.Now(), id); err != nil {
+		return false, err
+	}
+	return next, nil
+}
+
+func (s *sqliteTodoStore) LinkNote(todoID, noteID string) error {
+	_, err := s.db.Exec("INSERT OR IGNORE INTO note_todos (note_id, todo_id) VALUES (?, ?)", noteID, todoID)
+	return err
+}
+
+func (s *sqliteTodoStore) UnlinkNote(todoID, noteID string) error {
+	_, err := s.db.Exec("DELETE FROM note_todos WHERE note_id = ? AND todo_id = ?", noteID, todoID)
+	return err
+}
+
+// liveTodo returns ErrNotFound unless the todo exists and is not in the
+// trash
+func liveTodo(tx *sql.Tx, id string) error {
+	var one int
+	err := tx.QueryRow("SELECT 1 FROM todos WHERE id = ? AND deleted_at IS NULL", id).Scan(&one)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func liveParent(tx *sql.Tx, id string) error {
+	if err := liveTodo(tx, id); err != ErrNotFound {
+		return err
+	}
+	return ErrParentNotFound
+}
+
+// reaches runs a query that returns a row when following a chain of links
+// arrives back where it started
+func reaches(tx *sql.Tx, query string, args ...interface{}) (bool, error) {
+	var one int
+	err := tx.QueryRow(query, args...).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *sqliteTodoStore) AddBlocker(todoID, blockerID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range []string{todoID, blockerID} {
+		if err := liveTodo(tx, id); err != nil {
+			return err
+		}
+	}
+	// The blocker cannot be the todo itself or wait on it, directly or not
+	cycle, err := reaches(tx, `
+		WITH RECURSIVE chain(id) AS (
+			SELECT ?
+			UNION
+			SELECT dep.blocked_by FROM todo_dependencies dep JOIN chain ON dep.todo_id = chain.id
+		)
+		SELECT 1 FROM chain WHERE id = ?
+	`, blockerID, todoID)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrTodoCycle
+	}
+
+	if _, err := tx.Exec("INSERT OR IGNORE INTO todo_dependencies (todo_id, blocked_by) VALUES (?, ?)", todoID, blockerID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteTodoStore) RemoveBlocker(todoID, blockerID string) error {
+	_, err := s.db.Exec("DELETE FROM todo_dependencies WHERE todo_id = ? AND blocked_by = ?", todoID, blockerID)
+	return err
+}
+
+func (s *sqliteTodoStore) Completions(seriesID string) ([]models.TodoCompletion, error) {
+	rows, err := s.db.Query(`
+		SELECT id, series_id, todo_id, occurrence, due_date, completed_at
+		FROM todo_completions
+		WHERE series_id = ?
+		ORDER BY completed_at DESC, occurrence DESC
+	`, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	completions := []models.TodoCompletion{}
+	for rows.Next() {
+		var c models.TodoCompletion
+		var todoID sql.NullString
+		var dueDate sql.NullTime
+		if err := rows.Scan(&c.ID, &c.SeriesID, &todoID, &c.Occurrence, &dueDate, &c.CompletedAt); err != nil {
+			return nil, err
+		}
+		c.TodoID = nullStringPtr(todoID)
+		c.DueDate = nullTimePtr(dueDate)
+		completions = append(completions, c)
+	}
+	return completions, rows.Err()
+}
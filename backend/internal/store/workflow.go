@@ -0,0 +1,463 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// ErrUnknownStatus is returned for a todo status that is not in the workflow
+var ErrUnknownStatus = errors.New("unknown status")
+
+// TransitionError is returned when the workflow does not allow a todo to
+// change from one status to another
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("status cannot change from %s to %s", e.From, e.To)
+}
+
+// WorkflowError is returned for a workflow that cannot be saved
+type WorkflowError struct {
+	Reason string
+}
+
+func (e *WorkflowError) Error() string {
+	return "invalid workflow: " + e.Reason
+}
+
+// WorkflowStore keeps the todo statuses, the changes allowed between them and
+// the history of every change
+type WorkflowStore interface {
+	Get() (*models.Workflow, error)
+	// Replace saves a new workflow. A status that todos still have cannot be
+	// removed.
+	Replace(w models.Workflow) error
+	// History returns the status changes of a todo, oldest first
+	History(todoID string) ([]models.StatusChange, error)
+	// Report measures lead and cycle time and the time spent in each status
+	Report(f StatusFilter) (*models.StatusReport, error)
+}
+
+// StatusFilter narrows a status report; zero fields match every todo
+type StatusFilter struct {
+	AccountID string
+	// From and To bound when todos were completed and when they entered a
+	// status, To exclusively
+	From *time.Time
+	To   *time.Time
+}
+
+func (f StatusFilter) includes(t time.Time) bool {
+	return (f.From == nil || !t.Before(*f.From)) && (f.To == nil || t.Before(*f.To))
+}
+
+type sqliteWorkflowStore struct {
+	db *sql.DB
+}
+
+// doneStatuses are the keys of the statuses that count as done
+const doneStatuses = `(SELECT key FROM todo_statuses WHERE category = 'done')`
+
+var (
+	statusKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+	statusCategories = map[string]bool{"open": true, "active": true, "blocked": true, "done": true}
+)
+
+// defaultStatus returns the first open status, which new todos start in
+func defaultStatus(tx *sql.Tx) (string, error) {
+	var key string
+	err := tx.QueryRow("SELECT key FROM todo_statuses WHERE category = 'open' ORDER BY position LIMIT 1").Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", ErrUnknownStatus
+	}
+	return key, err
+}
+
+// statusCategory returns the category of a status in the workflow
+func statusCategory(tx *sql.Tx, key string) (string, error) {
+	var category string
+	err := tx.QueryRow("SELECT category FROM todo_statuses WHERE key = ?", key).Scan(&category)
+	if err == sql.ErrNoRows {
+		return "", ErrUnknownStatus
+	}
+	return category, err
+}
+
+// checkTransition returns a TransitionError unless the workflow allows
+// changing from one status to the other. A todo whose status has left the
+// workflow can change to any status.
+func checkTransition(tx *sql.Tx, from, to string) error {
+	var allowed, known bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM todo_status_transitions WHERE from_status = ? AND to_status = ?),
+		       EXISTS (SELECT 1 FROM todo_statuses WHERE key = ?)
+	`, from, to, from).Scan(&allowed, &known)
+	if err != nil {
+		return err
+	}
+	if !allowed && known {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// recordStatusChange adds a status change to a todo's history
+func recordStatusChange(tx *sql.Tx, todoID, from, to string, at time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO todo_status_history (id, todo_id, from_status, to_status, changed_at)
+		VALUES (?, ?, NULLIF(?, ''), ?, ?)
+	`, uuid.New().String(), todoID, from, to, at)
+	return err
+}
+
+func (s *sqliteWorkflowStore) Get() (*models.Workflow, error) {
+	rows, err := s.db.Query("SELECT key, label, category, color FROM todo_statuses ORDER BY position, key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	w := &models.Workflow{Statuses: []models.TodoStatus{}, Transitions: map[string][]string{}}
+	for rows.Next() {
+		var st models.TodoStatus
+		if err := rows.Scan(&st.Key, &st.Label, &st.Category, &st.Color); err != nil {
+			return nil, err
+		}
+		w.Statuses = append(w.Statuses, st)
+		w.Transitions[st.Key] = []string{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	trows, err := s.db.Query(`
+		SELECT tr.from_status, tr.to_status FROM todo_status_transitions tr
+		JOIN todo_statuses st ON st.key = tr.to_status
+		ORDER BY st.position
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer trows.Close()
+	for trows.Next() {
+		var from, to string
+		if err := trows.Scan(&from, &to); err != nil {
+			return nil, err
+		}
+		w.Transitions[from] = append(w.Transitions[from], to)
+	}
+	return w, trows.Err()
+}
+
+// validateWorkflow checks a workflow and fills in the transitions when
+// every change is allowed
+func validateWorkflow(w *models.Workflow) error {
+	if len(w.Statuses) == 0 {
+		return &WorkflowError{"at least one status is required"}
+	}
+	keys := map[string]bool{}
+	categories := map[string]bool{}
+	for _, st := range w.Statuses {
+		if !statusKeyPattern.MatchString(st.Key) {
+			return &WorkflowError{fmt.Sprintf("status key %q must be lowercase letters, digits and underscores", st.Key)}
+		}
+		if keys[st.Key] {
+			return &WorkflowError{fmt.Sprintf("status %q is listed twice", st.Key)}
+		}
+		if st.Label == "" {
+			return &WorkflowError{fmt.Sprintf("status %q needs a label", st.Key)}
+		}
+		if !statusCategories[st.Category] {
+			return &WorkflowError{fmt.Sprintf("status %q has category %q, must be open, active, blocked or done", st.Key, st.Category)}
+		}
+		keys[st.Key] = true
+		categories[st.Category] = true
+	}
+	if !categories["open"] || !categories["done"] {
+		return &WorkflowError{"an open and a done status are required"}
+	}
+
+	if w.Transitions == nil {
+		w.Transitions = map[string][]string{}
+		for _, from := range w.Statuses {
+			for _, to := range w.Statuses {
+				if from.Key != to.Key {
+					w.Transitions[from.Key] = append(w.Transitions[from.Key], to.Key)
+				}
+			}
+		}
+		return nil
+	}
+	for from, tos := range w.Transitions {
+		if !keys[from] {
+			return &WorkflowError{fmt.Sprintf("transition from unknown status %q", from)}
+		}
+		for _, to := range tos {
+			if !keys[to] {
+				return &WorkflowError{fmt.Sprintf("transition to unknown status %q", to)}
+			}
+			if to == from {
+				return &WorkflowError{fmt.Sprintf("status %q cannot change to itself", from)}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *sqliteWorkflowStore) Replace(w models.Workflow) error {
+	if err := validateWorkflow(&w); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	keys := make([]string, len(w.Statuses))
+	for i, st := range w.Statuses {
+		keys[i] = st.Key
+	}
+	in, args := placeholders(keys)
+	var used string
+	var count int
+	err = tx.QueryRow(`
+		SELECT status, COUNT(*) FROM todos WHERE status NOT IN (`+in+`) GROUP BY status ORDER BY status LIMIT 1
+	`, args...).Scan(&used, &count)
+	if err == nil {
+		return &WorkflowError{fmt.Sprintf("status %q is still used by %d todos", used, count)}
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM todo_status_transitions"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM todo_statuses WHERE key NOT IN ("+in+")", args...); err != nil {
+		return err
+	}
+	for i, st := range w.Statuses {
+		_, err := tx.Exec(`
+			INSERT INTO todo_statuses (key, label, category, color, position) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET label = excluded.label, category = excluded.category,
+				color = excluded.color, position = excluded.position
+		`, st.Key, st.Label, st.Category, st.Color, i)
+		if err != nil {
+			return err
+		}
+	}
+	for from, tos := range w.Transitions {
+		for _, to := range tos {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO todo_status_transitions (from_status, to_status) VALUES (?, ?)", from, to); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// statusChanges returns the history of todos not in the trash, oldest first
+// per todo, with the time each todo was created
+func (s *sqliteWorkflowStore) statusChanges(where string, args ...interface{}) (map[string][]models.StatusChange, error) {
+	rows, err := s.db.Query(`
+		SELECT h.id, h.todo_id, COALESCE(h.from_status, ''), h.to_status, h.changed_at
+		FROM todo_status_history h
+		JOIN todos t ON t.id = h.todo_id
+		WHERE t.deleted_at IS NULL`+where+`
+		ORDER BY h.todo_id, h.changed_at
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := map[string][]models.StatusChange{}
+	for rows.Next() {
+		var c models.StatusChange
+		if err := rows.Scan(&c.ID, &c.TodoID, &c.FromStatus, &c.ToStatus, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes[c.TodoID] = append(changes[c.TodoID], c)
+	}
+	return changes, rows.Err()
+}
+
+func (s *sqliteWorkflowStore) History(todoID string) ([]models.StatusChange, error) {
+	var createdAt time.Time
+	err := s.db.QueryRow("SELECT created_at FROM todos WHERE id = ? AND deleted_at IS NULL", todoID).Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.statusChanges(" AND t.id = ?", todoID)
+	if err != nil {
+		return nil, err
+	}
+	history := changes[todoID]
+	if history == nil {
+		history = []models.StatusChange{}
+	}
+	prev := createdAt
+	for i := range history {
+		history[i].Minutes = int(history[i].ChangedAt.Sub(prev) / time.Minute)
+		prev = history[i].ChangedAt
+	}
+	return history, nil
+}
+
+// hours rounds a duration to a tenth of an hour
+func hours(d time.Duration) float64 {
+	return math.Round(d.Hours()*10) / 10
+}
+
+func durationStats(ds []time.Duration) models.DurationStats {
+	if len(ds) == 0 {
+		return models.DurationStats{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	var total time.Duration
+	for _, d := range ds {
+		total += d
+	}
+	median := ds[len(ds)/2]
+	if len(ds)%2 == 0 {
+		median = (ds[len(ds)/2-1] + median) / 2
+	}
+	return models.DurationStats{AverageHours: hours(total / time.Duration(len(ds))), MedianHours: hours(median)}
+}
+
+// statusTotals adds up the time spent in one status
+type statusTotals struct {
+	models.StatusTime
+	total time.Duration
+}
+
+func (s *sqliteWorkflowStore) Report(f StatusFilter) (*models.StatusReport, error) {
+	w, err := s.Get()
+	if err != nil {
+		return nil, err
+	}
+	totals := map[string]*statusTotals{}
+	order := []string{}
+	category := map[string]string{}
+	for _, st := range w.Statuses {
+		totals[st.Key] = &statusTotals{StatusTime: models.StatusTime{Status: st.Key, Label: st.Label, Category: st.Category}}
+		order = append(order, st.Key)
+		category[st.Key] = st.Category
+	}
+	totalsFor := func(key string) *statusTotals {
+		if totals[key] == nil {
+			// A status that has since left the workflow
+			totals[key] = &statusTotals{StatusTime: models.StatusTime{Status: key, Label: key}}
+			order = append(order, key)
+		}
+		return totals[key]
+	}
+
+	query := "SELECT t.id, COALESCE(t.status, ''), t.created_at FROM todos t WHERE t.deleted_at IS NULL"
+	where, args := "", []interface{}{}
+	if f.AccountID != "" {
+		where, args = " AND t.account_id = ?", append(args, f.AccountID)
+	}
+	rows, err := s.db.Query(query+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	type todoState struct {
+		status    string
+		createdAt time.Time
+	}
+	todos := map[string]todoState{}
+	for rows.Next() {
+		var id string
+		var st todoState
+		if err := rows.Scan(&id, &st.status, &st.createdAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		todos[id] = st
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	changes, err := s.statusChanges(where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &models.StatusReport{}
+	var leadTimes, cycleTimes []time.Duration
+	for id, todo := range todos {
+		totalsFor(todo.status).Current++
+
+		history := changes[id]
+		status, since := todo.status, todo.createdAt
+		if len(history) > 0 {
+			status = history[0].FromStatus
+		}
+		// Cycle time starts when the todo first leaves the open statuses
+		var started *time.Time
+		if c := category[status]; c != "" && c != "open" {
+			started = &todo.createdAt
+		}
+		for _, c := range history {
+			if f.includes(since) {
+				t := totalsFor(status)
+				t.Periods++
+				t.total += c.ChangedAt.Sub(since)
+			}
+			if cat := category[c.ToStatus]; started == nil && cat != "" && cat != "open" {
+				changedAt := c.ChangedAt
+				started = &changedAt
+			}
+			status, since = c.ToStatus, c.ChangedAt
+		}
+		if f.includes(since) {
+			t := totalsFor(status)
+			t.Periods++
+			// Resting in a done status is not time spent on the todo
+			if category[status] != "done" {
+				t.total += now.Sub(since)
+			}
+		}
+
+		if category[todo.status] == "done" && len(history) > 0 && f.includes(since) {
+			report.Completed++
+			leadTimes = append(leadTimes, since.Sub(todo.createdAt))
+			if started != nil {
+				cycleTimes = append(cycleTimes, since.Sub(*started))
+			}
+		}
+	}
+
+	report.LeadTime = durationStats(leadTimes)
+	report.CycleTime = durationStats(cycleTimes)
+	report.Statuses = []models.StatusTime{}
+	for _, key := range order {
+		t := totals[key]
+		t.TotalHours = hours(t.total)
+		if t.Periods > 0 {
+			t.AverageHours = hours(t.total / time.Duration(t.Periods))
+		}
+		report.Statuses = append(report.Statuses, t.StatusTime)
+	}
+	return report, nil
+}
@@ -0,0 +1,218 @@
+// Package tasks reads the task items that TipTap writes into note HTML, so
+// that they can become todos, and writes the todo ids and checkbox states
+// back. An item is an <li data-type="taskItem">; once it has a todo it also
+// carries data-todo-id.
+//
+//	<li data-type="taskItem" data-checked="false"><p>Send pricing deck @due(2026-11-01) !high</p></li>
+//
+// Only the items' own tags are rewritten, so the rest of the note is kept
+// byte for byte.
+package tasks
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Item is one task item in a note
+type Item struct {
+	// TodoID is the todo the item was turned into, if any
+	TodoID  string
+	Checked bool
+	// Title is the item's text without its markers
+	Title string
+	// Due comes from an @due(2026-11-01) marker, as midnight UTC
+	Due *time.Time
+	// Priority comes from a !high, !medium or !low marker
+	Priority string
+}
+
+var (
+	dueMarker      = regexp.MustCompile(`@due\(\s*([0-9]{4}-[0-9]{2}-[0-9]{2})\s*\)`)
+	priorityMarker = regexp.MustCompile(`(?i)(^|[\s\x{a0}])!(high|medium|low)\b`)
+	spaces         = regexp.MustCompile(`[\s\x{a0}]+`) // Including &nbsp;
+)
+
+// frame is an open <li>. item is its task item, or -1 for a plain list
+// item; lists counts the lists opened inside it, whose items are not part
+// of its text.
+type frame struct {
+	item  int
+	lists int
+}
+
+// walk calls fn with every token of content, its raw text, and the task
+// item whose own text it is part of (-1 for none). opens is set on the
+// <li> tag that starts the item.
+func walk(content string, fn func(tok html.Token, raw string, item int, opens bool)) {
+	z := html.NewTokenizer(strings.NewReader(content))
+	var frames []frame
+	count := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return
+		}
+		raw := string(z.Raw())
+		tok := z.Token()
+
+		item, opens := -1, false
+		if n := len(frames); n > 0 && frames[n-1].lists == 0 {
+			item = frames[n-1].item
+		}
+		switch {
+		case tt == html.StartTagToken && (tok.Data == "ul" || tok.Data == "ol"):
+			if n := len(frames); n > 0 {
+				frames[n-1].lists++
+			}
+		case tt == html.EndTagToken && (tok.Data == "ul" || tok.Data == "ol"):
+			if n := len(frames); n > 0 && frames[n-1].lists > 0 {
+				frames[n-1].lists--
+			}
+		case tt == html.StartTagToken && tok.Data == "li":
+			f := frame{item: -1}
+			if attr(tok, "data-type") == "taskItem" {
+				f.item = count
+				count++
+			}
+			frames = append(frames, f)
+			item, opens = f.item, f.item >= 0
+		case tt == html.EndTagToken && tok.Data == "li":
+			if n := len(frames); n > 0 {
+				frames = frames[:n-1]
+			}
+		}
+		fn(tok, raw, item, opens)
+	}
+}
+
+// Parse returns the task items in content, in document order. Nested items
+// are items of their own and are not part of their parent's title.
+func Parse(content string) []Item {
+	var items []Item
+	var texts []string
+	walk(content, func(tok html.Token, raw string, item int, opens bool) {
+		if opens {
+			items = append(items, Item{
+				TodoID:  attr(tok, "data-todo-id"),
+				Checked: attr(tok, "data-checked") == "true",
+			})
+			texts = append(texts, "")
+			return
+		}
+		if item < 0 {
+			return
+		}
+		switch tok.Type {
+		case html.TextToken:
+			texts[item] += tok.Data
+		case html.StartTagToken, html.SelfClosingTagToken:
+			// Paragraphs and line breaks separate words
+			texts[item] += " "
+		}
+	})
+
+	for i := range items {
+		readMarkers(&items[i], texts[i])
+	}
+	return items
+}
+
+// readMarkers sets the item's title, due date and priority from its text
+func readMarkers(item *Item, text string) {
+	if m := dueMarker.FindStringSubmatch(text); m != nil {
+		if due, err := time.Parse("2006-01-02", m[1]); err == nil {
+			item.Due = &due
+			text = strings.Replace(text, m[0], " ", 1)
+		}
+	}
+	if m := priorityMarker.FindStringSubmatch(text); m != nil {
+		item.Priority = strings.ToLower(m[2])
+		text = strings.Replace(text, m[0], m[1], 1)
+	}
+	item.Title = strings.TrimSpace(spaces.ReplaceAllString(text, " "))
+}
+
+// Update writes the todo ids and checkbox states of items, as returned by
+// Parse and then changed, back into content. The checkbox <input> TipTap
+// renders inside an item is kept in step with data-checked.
+func Update(content string, items []Item) string {
+	var sb strings.Builder
+	var seenInput []bool
+	walk(content, func(tok html.Token, raw string, item int, opens bool) {
+		if item < 0 || item >= len(items) {
+			sb.WriteString(raw)
+			return
+		}
+		for len(seenInput) <= item {
+			seenInput = append(seenInput, false)
+		}
+
+		want := items[item]
+		changed := false
+		switch {
+		case opens:
+			changed = setAttr(&tok, "data-checked", strconv.FormatBool(want.Checked))
+			if want.TodoID != "" && setAttr(&tok, "data-todo-id", want.TodoID) {
+				changed = true
+			}
+		case (tok.Type == html.StartTagToken || tok.Type == html.SelfClosingTagToken) &&
+			tok.Data == "input" && attr(tok, "type") == "checkbox" && !seenInput[item]:
+			seenInput[item] = true
+			changed = setChecked(&tok, want.Checked)
+		}
+		if changed {
+			sb.WriteString(tok.String())
+		} else {
+			sb.WriteString(raw)
+		}
+	})
+	return sb.String()
+}
+
+func attr(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// setAttr sets an attribute and reports whether that changed the tag
+func setAttr(tok *html.Token, key, val string) bool {
+	for i, a := range tok.Attr {
+		if a.Key == key {
+			if a.Val == val {
+				return false
+			}
+			tok.Attr[i].Val = val
+			return true
+		}
+	}
+	tok.Attr = append(tok.Attr, html.Attribute{Key: key, Val: val})
+	return true
+}
+
+// setChecked adds or removes a checkbox's checked attribute and reports
+// whether that changed the tag
+func setChecked(tok *html.Token, checked bool) bool {
+	for i, a := range tok.Attr {
+		if a.Key == "checked" {
+			if checked {
+				return false
+			}
+			tok.Attr = append(tok.Attr[:i], tok.Attr[i+1:]...)
+			return true
+		}
+	}
+	if !checked {
+		return false
+	}
+	tok.Attr = append(tok.Attr, html.Attribute{Key: "checked", Val: "checked"})
+	return true
+}
@@ -0,0 +1,51 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// note has a task list the way the editor writes it, with a nested item and
+// a plain nested list inside the first one
+const note = `<h2>Next steps</h2>` +
+	`<ul data-type="taskList">` +
+	`<li data-checked="false" data-type="taskItem"><label><input type="checkbox"><span></span></label><div>` +
+	`<p>Send <strong>pricing</strong> deck @due(2026-11-01) !HIGH</p>` +
+	`<ul data-type="taskList"><li data-checked="true" data-type="taskItem" data-todo-id="todo-1"><label><input type="checkbox" checked="checked"><span></span></label><div><p>Book room</p></div></li></ul>` +
+	`<ul><li><p>not a task</p></li></ul>` +
+	`</div></li>` +
+	`<li data-type="taskItem" data-checked="false"><p>Ping&nbsp;legal @due(soon)</p></li>` +
+	`</ul><p>[ ] plain text is not a task</p>`
+
+func TestParse(t *testing.T) {
+	items := Parse(note)
+	require.Len(t, items, 3)
+
+	due := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, Item{Title: "Send pricing deck", Due: &due, Priority: "high"}, items[0])
+	assert.Equal(t, Item{TodoID: "todo-1", Checked: true, Title: "Book room"}, items[1])
+	assert.Equal(t, "Ping legal @due(soon)", items[2].Title, "a marker that does not parse stays in the title")
+	assert.Nil(t, items[2].Due)
+
+	assert.Empty(t, Parse(`<ul><li><p>plain</p></li></ul>`))
+}
+
+func TestUpdate(t *testing.T) {
+	items := Parse(note)
+	assert.Equal(t, note, Update(note, items), "unchanged items leave the HTML alone")
+
+	items[0].TodoID, items[0].Checked = "todo-0", true
+	items[1].Checked = false
+	updated := Update(note, items)
+	assert.Contains(t, updated, `<li data-checked="true" data-type="taskItem" data-todo-id="todo-0"><label><input type="checkbox" checked="checked">`)
+	assert.Contains(t, updated, `<li data-checked="false" data-type="taskItem" data-todo-id="todo-1"><label><input type="checkbox">`)
+	assert.Contains(t, updated, `<p>Ping&nbsp;legal @due(soon)</p>`, "the rest is kept as written")
+
+	reparsed := Parse(updated)
+	assert.Equal(t, "todo-0", reparsed[0].TodoID)
+	assert.True(t, reparsed[0].Checked)
+	assert.False(t, reparsed[1].Checked)
+}
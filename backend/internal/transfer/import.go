@@ -0,0 +1,588 @@
+package transfer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mode says what happens to the data already in the database
+type Mode string
+
+const (
+	// Merge adds the document's rows to the existing data
+	Merge Mode = "merge"
+	// Replace deletes everything first, except private settings
+	Replace Mode = "replace"
+)
+
+// OnConflict says what a merge does with a row that already exists, either
+// with the same key or the same unique value (a tag name, a contact email)
+type OnConflict string
+
+const (
+	Skip      OnConflict = "skip"
+	Overwrite OnConflict = "overwrite"
+	Fail      OnConflict = "fail"
+)
+
+// Options control an import
+type Options struct {
+	Mode       Mode
+	OnConflict OnConflict
+	// DryRun runs the import inside the transaction and rolls it back, so
+	// the report shows what would happen without changing anything
+	DryRun     bool
+	UploadsDir string
+}
+
+// ErrConflicts is returned when OnConflict is Fail and at least one row
+// already exists. The report lists them.
+var ErrConflicts = errors.New("import conflicts with existing data")
+
+// RowError is a row that could not be imported
+type RowError struct {
+	Table string
+	Key   string
+	Err   error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Table, e.Key, e.Err)
+}
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// Report describes what an import did, or would do in a dry run
+type Report struct {
+	Mode       Mode                    `json:"mode"`
+	OnConflict OnConflict              `json:"on_conflict"`
+	DryRun     bool                    `json:"dry_run"`
+	Tables     map[string]*TableReport `json:"tables"`
+	Conflicts  []Conflict              `json:"conflicts"`
+	// FilesWritten counts attachment files copied into the uploads directory
+	FilesWritten int `json:"files_written"`
+	// MissingFiles are attachments with no file in the document or on disk
+	MissingFiles []string `json:"missing_files"`
+	// FilesRemoved counts files of replaced attachments deleted from the
+	// uploads directory
+	FilesRemoved int `json:"files_removed"`
+}
+
+// TableReport counts rows per outcome
+type TableReport struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Skipped  int `json:"skipped"`
+}
+
+// Conflict is an imported row that matched an existing one
+type Conflict struct {
+	Table string `json:"table"`
+	Key   string `json:"key"`
+	// Field is the column that matched: the key or the table's unique column
+	Field string `json:"field"`
+	// ExistingKey is set when the match was on the unique column, and the
+	// existing row has a different key
+	ExistingKey string `json:"existing_key,omitempty"`
+	Resolution  string `json:"resolution"`
+}
+
+// importer holds the state of one import
+type importer struct {
+	tx     *sql.Tx
+	doc    *Document
+	opts   Options
+	report *Report
+	// remap maps imported ids to the id of an existing row they merged
+	// into, per table, so later references follow them
+	remap map[string]map[string]string
+	// files are the attachment filenames that need their content written
+	files []string
+	// cleared are the files of the attachments a replace deleted
+	cleared []string
+}
+
+// Import loads the document in a single transaction. If anything fails the
+// database is left as it was.
+func Import(db *sql.DB, doc *Document, opts Options) (*Report, error) {
+	if opts.Mode == "" {
+		opts.Mode = Merge
+	}
+	if opts.OnConflict == "" {
+		opts.OnConflict = Skip
+	}
+	if err := checkFilenames(doc); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	im := &importer{
+		tx:   tx,
+		doc:  doc,
+		opts: opts,
+		report: &Report{
+			Mode:         opts.Mode,
+			OnConflict:   opts.OnConflict,
+			DryRun:       opts.DryRun,
+			Tables:       map[string]*TableReport{},
+			Conflicts:    []Conflict{},
+			MissingFiles: []string{},
+		},
+		remap: map[string]map[string]string{},
+	}
+
+	if opts.Mode == Replace {
+		if err := im.clear(); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range tables {
+		if err := im.importTable(t); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.OnConflict == Fail && len(im.report.Conflicts) > 0 {
+		return im.report, ErrConflicts
+	}
+	if opts.DryRun {
+		return im.report, nil
+	}
+
+	staged, err := im.stageFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staged)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	im.placeFiles(staged)
+	im.removeClearedFiles()
+	return im.report, nil
+}
+
+// clear deletes every exported row, children first. Config tables the
+// document has no rows for are kept.
+func (im *importer) clear() error {
+	if err := im.listClearedFiles(); err != nil {
+		return err
+	}
+	for i := len(tables) - 1; i >= 0; i-- {
+		t := tables[i]
+		cols, err := existingColumns(im.tx, t)
+		if err != nil {
+			return err
+		}
+		if len(cols) == 0 || (t.config && len(im.doc.Tables[t.name]) == 0) {
+			continue
+		}
+		if t.private == nil {
+			if _, err := im.tx.Exec("DELETE FROM " + t.name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rows, err := exportTable(im.tx, t)
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if err := im.exec("DELETE FROM "+t.name+" WHERE "+keyWhere(t), keyArgs(t, r)...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (im *importer) importTable(t table) error {
+	rows := im.doc.Tables[t.name]
+	if len(rows) == 0 {
+		return nil
+	}
+	tr := &TableReport{}
+	im.report.Tables[t.name] = tr
+
+	cols, err := existingColumns(im.tx, t)
+	if err != nil {
+		return err
+	}
+	if len(cols) == 0 {
+		tr.Skipped = len(rows)
+		return nil
+	}
+
+	for _, r := range rows {
+		if t.private != nil && t.private(r) {
+			tr.Skipped++
+			continue
+		}
+		values, err := im.convert(t, cols, r)
+		if err != nil {
+			return &RowError{Table: t.name, Key: rowKey(t, r), Err: err}
+		}
+		key := rowKey(t, values)
+
+		conflict, err := im.findConflict(t, values)
+		if err != nil {
+			return &RowError{Table: t.name, Key: key, Err: err}
+		}
+		if conflict == nil {
+			if err := im.insert(t, values); err != nil {
+				return &RowError{Table: t.name, Key: key, Err: err}
+			}
+			tr.Inserted++
+			im.wantFile(t, values)
+			continue
+		}
+
+		// A link or setting already in place is not worth reporting
+		if t.link || t.config {
+			tr.Skipped++
+			continue
+		}
+
+		conflict.Table, conflict.Key = t.name, key
+		switch im.opts.OnConflict {
+		case Overwrite:
+			if err := im.update(t, values, conflict); err != nil {
+				return &RowError{Table: t.name, Key: key, Err: err}
+			}
+			conflict.Resolution = "overwritten"
+			tr.Updated++
+			im.wantFile(t, values)
+		case Fail:
+			conflict.Resolution = "failed"
+			tr.Skipped++
+		default:
+			conflict.Resolution = "skipped"
+			tr.Skipped++
+		}
+		im.report.Conflicts = append(im.report.Conflicts, *conflict)
+	}
+	return nil
+}
+
+// convert maps a row's JSON values to database values for the columns it
+// has, following remapped references
+func (im *importer) convert(t table, cols []column, r Row) (Row, error) {
+	values := Row{}
+	for _, c := range cols {
+		v, ok := r[c.name]
+		if !ok {
+			continue
+		}
+		converted, err := importValue(c, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.name, err)
+		}
+		if s, ok := converted.(string); ok && c.ref != "" {
+			if id, ok := im.remap[c.ref][s]; ok {
+				converted = id
+			}
+		}
+		values[c.name] = converted
+	}
+	for _, k := range t.key {
+		if values[k] == nil {
+			return nil, fmt.Errorf("%s is required", k)
+		}
+	}
+	return values, nil
+}
+
+// findConflict looks for an existing row with the same key, or with the same
+// unique value. A unique match remaps the imported id to the existing one.
+func (im *importer) findConflict(t table, values Row) (*Conflict, error) {
+	var one int
+	err := im.tx.QueryRow("SELECT 1 FROM "+t.name+" WHERE "+keyWhere(t), keyArgs(t, values)...).Scan(&one)
+	if err == nil {
+		return &Conflict{Field: strings.Join(t.key, ",")}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	unique, ok := values[t.unique]
+	if t.unique == "" || !ok {
+		return nil, nil
+	}
+	match := t.unique + " = ?"
+	if t.nocase {
+		match += " COLLATE NOCASE"
+	}
+	var existing string
+	err = im.tx.QueryRow("SELECT id FROM "+t.name+" WHERE "+match, unique).Scan(&existing)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if im.remap[t.name] == nil {
+		im.remap[t.name] = map[string]string{}
+	}
+	im.remap[t.name][values["id"].(string)] = existing
+	return &Conflict{Field: t.unique, ExistingKey: existing}, nil
+}
+
+func (im *importer) insert(t table, values Row) error {
+	names := make([]string, 0, len(values))
+	args := make([]interface{}, 0, len(values))
+	for _, c := range t.columns {
+		if v, ok := values[c.name]; ok {
+			names = append(names, c.name)
+			args = append(args, v)
+		}
+	}
+	query := "INSERT INTO " + t.name + " (" + strings.Join(names, ", ") + ") VALUES (" +
+		strings.TrimSuffix(strings.Repeat("?,", len(names)), ",") + ")"
+	return im.exec(query, args...)
+}
+
+// update overwrites the existing row the conflict matched, keeping its key
+func (im *importer) update(t table, values Row, conflict *Conflict) error {
+	updates := []string{}
+	args := []interface{}{}
+	for _, c := range t.columns {
+		v, ok := values[c.name]
+		if !ok || isKey(t, c.name) {
+			continue
+		}
+		updates = append(updates, c.name+" = ?")
+		args = append(args, v)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if conflict.ExistingKey != "" {
+		args = append(args, conflict.ExistingKey)
+	} else {
+		args = append(args, keyArgs(t, values)...)
+	}
+	return im.exec("UPDATE "+t.name+" SET "+strings.Join(updates, ", ")+" WHERE "+keyWhere(t), args...)
+}
+
+func (im *importer) exec(query string, args ...interface{}) error {
+	_, err := im.tx.Exec(query, args...)
+	return err
+}
+
+// wantFile notes that an imported attachment needs its file, and reports it
+// as missing when neither the document nor the uploads directory has it
+func (im *importer) wantFile(t table, values Row) {
+	if t.name != "attachments" {
+		return
+	}
+	name, _ := values["filename"].(string)
+	if _, ok := im.doc.Files[name]; ok {
+		im.files = append(im.files, name)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(im.opts.UploadsDir, name)); err != nil {
+		im.report.MissingFiles = append(im.report.MissingFiles, name)
+	}
+}
+
+// stageFiles writes the attachment files into a temporary directory inside
+// the uploads directory, so that they can be moved into place once the
+// transaction commits
+func (im *importer) stageFiles() (string, error) {
+	if len(im.files) == 0 {
+		return "", nil
+	}
+	if err := os.MkdirAll(im.opts.UploadsDir, 0755); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(im.opts.UploadsDir, ".import-")
+	if err != nil {
+		return "", err
+	}
+	for _, name := range im.files {
+		if err := os.WriteFile(filepath.Join(dir, name), im.doc.Files[name], 0644); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+func (im *importer) placeFiles(staged string) {
+	for _, name := range im.files {
+		if err := os.Rename(filepath.Join(staged, name), filepath.Join(im.opts.UploadsDir, name)); err == nil {
+			im.report.FilesWritten++
+		} else {
+			im.report.MissingFiles = append(im.report.MissingFiles, name)
+		}
+	}
+}
+
+// listClearedFiles records the files of the attachments clear is about to
+// delete
+func (im *importer) listClearedFiles() error {
+	rows, err := im.tx.Query("SELECT filename FROM attachments")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		im.cleared = append(im.cleared, name)
+	}
+	return rows.Err()
+}
+
+// removeClearedFiles deletes the files of replaced attachments that the
+// document did not bring back. It runs after the commit, so a failed import
+// keeps them.
+func (im *importer) removeClearedFiles() {
+	kept := map[string]bool{}
+	for _, r := range im.doc.Tables["attachments"] {
+		name, _ := r["filename"].(string)
+		kept[name] = true
+	}
+	for _, name := range im.cleared {
+		if kept[name] || !safeFilename(name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(im.opts.UploadsDir, name)); err == nil {
+			im.report.FilesRemoved++
+		}
+	}
+}
+
+// checkFilenames rejects attachment filenames that would escape the uploads
+// directory
+func checkFilenames(doc *Document) error {
+	for _, r := range doc.Tables["attachments"] {
+		name, _ := r["filename"].(string)
+		if !safeFilename(name) {
+			return &RowError{Table: "attachments", Key: fmt.Sprint(r["id"]), Err: fmt.Errorf("invalid filename %q", name)}
+		}
+	}
+	return nil
+}
+
+// safeFilename reports whether name stays inside the uploads directory
+func safeFilename(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+func isKey(t table, name string) bool {
+	for _, k := range t.key {
+		if k == name {
+			return true
+		}
+	}
+	return false
+}
+
+func keyWhere(t table) string {
+	return strings.Join(t.key, " = ? AND ") + " = ?"
+}
+
+func keyArgs(t table, r Row) []interface{} {
+	args := make([]interface{}, len(t.key))
+	for i, k := range t.key {
+		args[i] = r[k]
+	}
+	return args
+}
+
+func rowKey(t table, r Row) string {
+	parts := make([]string, len(t.key))
+	for i, k := range t.key {
+		parts[i] = fmt.Sprint(r[k])
+	}
+	return strings.Join(parts, "/")
+}
+
+// timestampLayouts are tried in order when importing a timestamp
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// importValue converts a decoded JSON value for the column. Version 1.0
+// exports wrote "" for missing timestamps and references, so those become
+// NULL.
+func importValue(c column, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch c.kind {
+	case boolean:
+		switch x := v.(type) {
+		case bool:
+			if x {
+				return 1, nil
+			}
+			return 0, nil
+		case json.Number:
+			n, err := x.Int64()
+			return n, err
+		}
+	case integer:
+		switch x := v.(type) {
+		case json.Number:
+			if n, err := x.Int64(); err == nil {
+				return n, nil
+			}
+			f, err := x.Float64()
+			return int64(f), err
+		case bool:
+			return importValue(column{kind: boolean}, x)
+		}
+	case real:
+		if x, ok := v.(json.Number); ok {
+			return x.Float64()
+		}
+	case timestamp:
+		s, ok := v.(string)
+		if !ok {
+			break
+		}
+		if s == "" {
+			return nil, nil
+		}
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid timestamp %q", s)
+	case text:
+		switch x := v.(type) {
+		case string:
+			if x == "" && c.nullable && c.ref != "" {
+				return nil, nil
+			}
+			return x, nil
+		case json.Number:
+			return x.String(), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected value %v", v)
+}
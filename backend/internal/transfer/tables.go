@@ -0,0 +1,334 @@
+package transfer
+
+import "strings"
+
+// kind is how a column's values are written to and read from JSON
+type kind int
+
+const (
+	text kind = iota
+	integer
+	real
+	boolean
+	timestamp
+)
+
+type column struct {
+	name     string
+	kind     kind
+	nullable bool
+	// ref names the table whose id this column holds, so that ids remapped
+	// during a merge are followed through
+	ref string
+}
+
+type table struct {
+	name    string
+	columns []column
+	// key is the primary key. Link tables use every column of the key as a
+	// reference and have no id of their own.
+	key  []string
+	link bool
+	// unique is a column, other than the key, that identifies the same
+	// entity across databases (a tag's name, a contact's email)
+	unique string
+	// nocase matches unique regardless of case, as the database does
+	nocase bool
+	// private rows are never exported or imported
+	private func(Row) bool
+	// config tables hold settings rather than data. A replace keeps their
+	// rows when the document has none, and a merge leaves existing rows
+	// alone without reporting a conflict.
+	config bool
+}
+
+func col(name string, k kind) column { return column{name: name, kind: k} }
+
+func null(name string, k kind) column { return column{name: name, kind: k, nullable: true} }
+
+func ref(name, table string, nullable bool) column {
+	return column{name: name, kind: text, nullable: nullable, ref: table}
+}
+
+// tables lists everything in an export in insertion order: a table only
+// references tables before it. Columns missing from the database (an older
+// schema) or from an imported row are left out.
+var tables = []table{
+	{
+		name: "accounts",
+		key:  []string{"id"},
+		columns: []column{
+			col("id", text),
+			col("name", text),
+			null("account_owner", text),
+			null("budget", real),
+			null("est_engineers", integer),
+			col("created_at", timestamp),
+			col("updated_at", timestamp),
+			null("deleted_at", timestamp),
+		},
+	},
+	{
+		name:   "tags",
+		key:    []string{"id"},
+		unique: "name",
+		nocase: true,
+		columns: []column{
+			col("id", text),
+			col("name", text),
+			col("color", text),
+			col("created_at", timestamp),
+		},
+	},
+	{
+		name:   "todo_statuses",
+		key:    []string{"key"},
+		config: true,
+		columns: []column{
+			col("key", text),
+			col("label", text),
+			col("category", text),
+			col("color", text),
+			col("position", integer),
+		},
+	},
+	{
+		name:   "todo_status_transitions",
+		key:    []string{"from_status", "to_status"},
+		link:   true,
+		config: true,
+		columns: []column{
+			ref("from_status", "todo_statuses", false),
+			ref("to_status", "todo_statuses", false),
+		},
+	},
+	{
+		name: "notes",
+		key:  []string{"id"},
+		columns: []column{
+			col("id", text),
+			col("title", text),
+			ref("account_id", "accounts", false),
+			col("template_type", text),
+			col("internal_participants", text),
+			col("external_participants", text),
+			col("content", text),
+			null("meeting_id", text),
+			null("meeting_date", timestamp),
+			col("pinned", boolean),
+			col("archived", boolean),
+			col("sort_order", integer),
+			col("version", integer),
+			col("created_at", timestamp),
+			col("updated_at", timestamp),
+			null("deleted_at", timestamp),
+		},
+	},
+	{
+		name: "todos",
+		key:  []string{"id"},
+		columns: []column{
+			col("id", text),
+			col("title", text),
+			col("description", text),
+			col("status", text),
+			col("priority", text),
+			null("due_date", timestamp),
+			ref("account_id", "accounts", true),
+			col("pinned", boolean),
+			null("recurrence", text),
+			null("series_id", text),
+			null("occurrence", integer),
+			ref("parent_id", "todos", true),
+			null("reminder_minutes", text),
+			null("estimate_minutes", integer),
+			col("version", integer),
+			col("created_at", timestamp),
+			col("updated_at", timestamp),
+			null("deleted_at", timestamp),
+		},
+	},
+	{
+		name:   "contacts",
+		key:    []string{"id"},
+		unique: "email",
+		columns: []column{
+			col("id", text),
+			col("email", text),
+			col("name", text),
+			col("company", text),
+			col("domain", text),
+			col("is_internal", boolean),
+			ref("account_id", "accounts", true),
+			ref("suggested_account_id", "accounts", true),
+			col("suggestion_confirmed", boolean),
+			col("source", text),
+			col("first_seen", timestamp),
+			col("last_seen", timestamp),
+			col("meeting_count", integer),
+			col("created_at", timestamp),
+			col("updated_at", timestamp),
+			null("deleted_at", timestamp),
+		},
+	},
+	{
+		name: "note_todos",
+		key:  []string{"note_id", "todo_id"},
+		link: true,
+		columns: []column{
+			ref("note_id", "notes", false),
+			ref("todo_id", "todos", false),
+			col("created_at", timestamp),
+		},
+	},
+	{
+		name: "note_tags",
+		key:  []string{"note_id", "tag_id"},
+		link: true,
+		columns: []column{
+			ref("note_id", "notes", false),
+			ref("tag_id", "tags", false),
+			col("created_at", timestamp),
+		},
+	},
+	{
+		name: "todo_tags",
+		key:  []string{"todo_id", "tag_id"},
+		link: true,
+		columns: []column{
+			ref("todo_id", "todos", false),
+			ref("tag_id", "tags", false),
+			col("created_at", timestamp),
+		},
+	},
+	{
+		name: "account_tags",
+		key:  []string{"account_id", "tag_id"},
+		link: true,
+		columns: []column{
+			ref("account_id", "accounts", false),
+			ref("tag_id", "tags", false),
+			col("created_at", timestamp),
+		},
+	},
+	{
+		name: "todo_dependencies",
+		key:  []string{"todo_id", "blocked_by"},
+		link: true,
+		columns: []column{
+			ref("todo_id", "todos", false),
+			ref("blocked_by", "todos", false),
+			col("created_at", timestamp),
+		},
+	},
+	{
+		name: "time_entries",
+		key:  []string{"id"},
+		columns: []column{
+			col("id", text),
+			ref("todo_id", "todos", false),
+			col("started_at", timestamp),
+			null("ended_at", timestamp),
+			null("minutes", integer),
+			col("note", text),
+			col("source", text),
+			col("created_at", timestamp),
+		},
+	},
+	{
+		name: "todo_status_history",
+		key:  []string{"id"},
+		columns: []column{
+			col("id", text),
+			ref("todo_id", "todos", false),
+			null("from_status", text),
+			col("to_status", text),
+			col("changed_at", timestamp),
+		},
+	},
+	{
+		name: "todo_completions",
+		key:  []string{"id"},
+		columns: []column{
+			col("id", text),
+			col("series_id", text),
+			ref("todo_id", "todos", true),
+			col("occurrence", integer),
+			null("due_date", timestamp),
+			col("completed_at", timestamp),
+		},
+	},
+	{
+		name: "note_revisions",
+		key:  []string{"note_id", "rev"},
+		columns: []column{
+			ref("note_id", "notes", false),
+			col("rev", integer),
+			col("title", text),
+			null("content", text),
+			col("created_at", timestamp),
+		},
+	},
+	{
+		name: "activities",
+		key:  []string{"id"},
+		columns: []column{
+			col("id", text),
+			ref("account_id", "accounts", false),
+			col("type", text),
+			col("title", text),
+			col("description", text),
+			null("entity_type", text),
+			null("entity_id", text),
+			col("created_at", timestamp),
+		},
+	},
+	{
+		name: "attachments",
+		key:  []string{"id"},
+		columns: []column{
+			col("id", text),
+			ref("note_id", "notes", false),
+			col("filename", text),
+			col("original_name", text),
+			col("mime_type", text),
+			col("size", integer),
+			null("text", text),
+			null("page_count", integer),
+			null("width", integer),
+			null("height", integer),
+			null("extracted_at", timestamp),
+			col("created_at", timestamp),
+		},
+	},
+	{
+		name:   "saved_searches",
+		key:    []string{"id"},
+		unique: "name",
+		columns: []column{
+			col("id", text),
+			col("name", text),
+			col("query", text),
+			col("filters", text),
+			col("created_at", timestamp),
+			col("updated_at", timestamp),
+		},
+	},
+	{
+		name: "settings",
+		key:  []string{"key"},
+		columns: []column{
+			col("key", text),
+			col("value", text),
+			col("updated_at", timestamp),
+		},
+		private: secretSetting,
+	},
+}
+
+// secretSetting keeps credentials such as the Google OAuth token out of
+// export files
+func secretSetting(r Row) bool {
+	key, _ := r["key"].(string)
+	return strings.HasSuffix(key, "_token")
+}
@@ -0,0 +1,308 @@
+// Package transfer moves the whole database in and out of a single JSON
+// document: every table, including link tables and settings, with attachment
+// files embedded as base64 or stored alongside it in a zip archive.
+package transfer
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Version is written to every export. Version 1.0 documents, which only
+// held accounts, notes, todos, tags and contacts, can still be imported.
+const Version = "2.0"
+
+var supportedVersions = map[string]bool{"1.0": true, Version: true}
+
+// ErrUnsupportedVersion is returned when decoding a document this build
+// does not understand
+var ErrUnsupportedVersion = errors.New("unsupported export version")
+
+// Row is one database row keyed by column name
+type Row map[string]interface{}
+
+// Document is an export of the database
+type Document struct {
+	Version    string
+	ExportedAt string
+	Tables     map[string][]Row
+	// Files holds attachment contents keyed by their stored filename
+	Files map[string][]byte
+}
+
+// zip layout
+const (
+	dataFile       = "data.json"
+	attachmentsDir = "attachments/"
+)
+
+func (d *Document) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{
+		"version":     d.Version,
+		"exported_at": d.ExportedAt,
+	}
+	for name, rows := range d.Tables {
+		out[name] = rows
+	}
+	return json.Marshal(out)
+}
+
+func (d *Document) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	json.Unmarshal(raw["version"], &d.Version)
+	json.Unmarshal(raw["exported_at"], &d.ExportedAt)
+	if !supportedVersions[d.Version] {
+		return fmt.Errorf("%w %q", ErrUnsupportedVersion, d.Version)
+	}
+
+	d.Tables = map[string][]Row{}
+	d.Files = map[string][]byte{}
+	for _, t := range tables {
+		msg, ok := raw[t.name]
+		if !ok {
+			continue
+		}
+		var rows []Row
+		dec := json.NewDecoder(bytes.NewReader(msg))
+		dec.UseNumber()
+		if err := dec.Decode(&rows); err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+		d.Tables[t.name] = rows
+	}
+
+	for _, r := range d.Tables["attachments"] {
+		encoded, ok := r["data"].(string)
+		if !ok {
+			continue
+		}
+		delete(r, "data")
+		content, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("attachments %v: invalid base64 data", r["id"])
+		}
+		name, _ := r["filename"].(string)
+		d.Files[name] = content
+	}
+	return nil
+}
+
+// Decode reads a JSON document
+func Decode(r io.Reader) (*Document, error) {
+	var doc Document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// ReadZip reads an archive written by WriteZip
+func ReadZip(r io.ReaderAt, size int64) (*Document, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc *Document
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if f.Name != dataFile && !strings.HasPrefix(f.Name, attachmentsDir) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		if f.Name == dataFile {
+			doc, err = Decode(rc)
+		} else {
+			files[path.Base(f.Name)], err = io.ReadAll(rc)
+		}
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("archive has no %s", dataFile)
+	}
+	for name, content := range files {
+		doc.Files[name] = content
+	}
+	return doc, nil
+}
+
+// Export reads every table. With embedFiles each attachment row carries
+// its file, base64 encoded, in a "data" field.
+func Export(db *sql.DB, uploadsDir string, embedFiles bool) (*Document, error) {
+	doc := &Document{
+		Version:    Version,
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Tables:     map[string][]Row{},
+	}
+	for _, t := range tables {
+		rows, err := exportTable(db, t)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.name, err)
+		}
+		if rows != nil {
+			doc.Tables[t.name] = rows
+		}
+	}
+
+	if embedFiles {
+		for _, r := range doc.Tables["attachments"] {
+			name, _ := r["filename"].(string)
+			content, err := os.ReadFile(filepath.Join(uploadsDir, name))
+			if err != nil {
+				continue
+			}
+			r["data"] = base64.StdEncoding.EncodeToString(content)
+		}
+	}
+	return doc, nil
+}
+
+// WriteZip writes the document as data.json with the attachment files from
+// uploadsDir next to it. Files missing from disk are left out.
+func WriteZip(w io.Writer, doc *Document, uploadsDir string) error {
+	zw := zip.NewWriter(w)
+	f, err := zw.Create(dataFile)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(doc); err != nil {
+		return err
+	}
+
+	for _, r := range doc.Tables["attachments"] {
+		name, _ := r["filename"].(string)
+		if err := addFile(zw, uploadsDir, name); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func addFile(zw *zip.Writer, dir, name string) error {
+	src, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil
+	}
+	defer src.Close()
+
+	dst, err := zw.Create(attachmentsDir + name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func exportTable(db querier, t table) ([]Row, error) {
+	cols, err := existingColumns(db, t)
+	if err != nil || len(cols) == 0 {
+		return nil, err
+	}
+
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.name
+	}
+	rows, err := db.Query("SELECT " + strings.Join(names, ", ") + " FROM " + t.name + " ORDER BY " + strings.Join(t.key, ", "))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Row{}
+	values := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		r := Row{}
+		for i, c := range cols {
+			r[c.name] = exportValue(c, values[i])
+		}
+		if t.private != nil && t.private(r) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func exportValue(c column, v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case int64:
+		if c.kind == boolean {
+			return x != 0
+		}
+	}
+	return v
+}
+
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// existingColumns returns the table's columns that exist in the database,
+// or none when the table itself does not
+func existingColumns(q querier, t table) ([]column, error) {
+	rows, err := q.Query("PRAGMA table_info(" + t.name + ")")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	present := map[string]bool{}
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		present[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var cols []column
+	for _, c := range t.columns {
+		if present[c.name] {
+			cols = append(cols, c)
+		}
+	}
+	return cols, nil
+}
@@ -0,0 +1,255 @@
+package transfer
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/factory-sagar/notes-droid/backend/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupDB(t *testing.T) *sql.DB {
+	database, err := db.Initialize(filepath.Join(t.TempDir(), "notes.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	require.NoError(t, db.Migrate(database))
+	return database
+}
+
+func seed(t *testing.T, database *sql.DB, uploads string) {
+	now := time.Now()
+	stmts := []string{
+		`INSERT INTO accounts (id, name, created_at, updated_at) VALUES ('acc-1', 'Acme', ?, ?)`,
+		`INSERT INTO notes (id, title, account_id, content, pinned, created_at, updated_at) VALUES ('note-1', 'Kickoff', 'acc-1', '<p>Hi</p>', 1, ?, ?)`,
+		`INSERT INTO todos (id, title, account_id, created_at, updated_at) VALUES ('todo-1', 'Follow up', 'acc-1', ?, ?)`,
+		`INSERT INTO tags (id, name, created_at) VALUES ('tag-1', 'urgent', ?)`,
+		`INSERT INTO note_tags (note_id, tag_id, created_at) VALUES ('note-1', 'tag-1', ?)`,
+		`INSERT INTO note_todos (note_id, todo_id, created_at) VALUES ('note-1', 'todo-1', ?)`,
+		`INSERT INTO activities (id, account_id, type, title, created_at) VALUES ('act-1', 'acc-1', 'note_created', 'Kickoff', ?)`,
+		`INSERT INTO attachments (id, note_id, filename, original_name, mime_type, size, created_at) VALUES ('att-1', 'note-1', 'att-1_plan.txt', 'plan.txt', 'text/plain', 4, ?)`,
+		`INSERT INTO settings (key, value, updated_at) VALUES ('theme', 'dark', ?)`,
+		`INSERT INTO settings (key, value, updated_at) VALUES ('google_oauth_token', 'secret', ?)`,
+	}
+	for _, stmt := range stmts {
+		args := []interface{}{now}
+		if strings.Count(stmt, "?") == 2 {
+			args = append(args, now)
+		}
+		_, err := database.Exec(stmt, args...)
+		require.NoError(t, err, stmt)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(uploads, "att-1_plan.txt"), []byte("plan"), 0644))
+}
+
+func roundTrip(t *testing.T, doc *Document) *Document {
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	decoded, err := Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	return decoded
+}
+
+func count(t *testing.T, database *sql.DB, table string) int {
+	var n int
+	require.NoError(t, database.QueryRow("SELECT COUNT(*) FROM "+table).Scan(&n))
+	return n
+}
+
+func TestExportImportReplace(t *testing.T) {
+	src, srcUploads := setupDB(t), t.TempDir()
+	seed(t, src, srcUploads)
+
+	doc, err := Export(src, srcUploads, true)
+	require.NoError(t, err)
+	assert.Equal(t, Version, doc.Version)
+	assert.Len(t, doc.Tables["note_tags"], 1)
+	assert.Equal(t, true, doc.Tables["notes"][0]["pinned"])
+	assert.Len(t, doc.Tables["settings"], 1, "tokens are not exported")
+
+	dst, dstUploads := setupDB(t), t.TempDir()
+	_, err = dst.Exec(`INSERT INTO accounts (id, name) VALUES ('old', 'Old account')`)
+	require.NoError(t, err)
+	_, err = dst.Exec(`INSERT INTO settings (key, value) VALUES ('google_oauth_token', 'mine')`)
+	require.NoError(t, err)
+	_, err = dst.Exec(`INSERT INTO notes (id, title, account_id) VALUES ('old-note', 'Old note', 'old')`)
+	require.NoError(t, err)
+	_, err = dst.Exec(`INSERT INTO attachments (id, note_id, filename, original_name, mime_type, size) VALUES ('att-old', 'old-note', 'att-old_quote.pdf', 'quote.pdf', 'application/pdf', 5)`)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dstUploads, "att-old_quote.pdf"), []byte("quote"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstUploads, "unrelated.txt"), []byte("keep"), 0644))
+
+	report, err := Import(dst, roundTrip(t, doc), Options{Mode: Replace, UploadsDir: dstUploads})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Tables["accounts"].Inserted)
+	assert.Equal(t, 1, report.FilesWritten)
+	assert.Equal(t, 1, report.FilesRemoved)
+
+	// The replaced attachment's file goes with it; files no attachment
+	// owns are left alone
+	_, err = os.Stat(filepath.Join(dstUploads, "att-old_quote.pdf"))
+	assert.True(t, os.IsNotExist(err), "replaced attachment file is removed")
+	_, err = os.Stat(filepath.Join(dstUploads, "unrelated.txt"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, count(t, dst, "accounts"))
+	assert.Equal(t, 1, count(t, dst, "note_todos"))
+	assert.Equal(t, 1, count(t, dst, "activities"))
+	var token string
+	require.NoError(t, dst.QueryRow(`SELECT value FROM settings WHERE key = 'google_oauth_token'`).Scan(&token))
+	assert.Equal(t, "mine", token, "replace keeps private settings")
+
+	content, err := os.ReadFile(filepath.Join(dstUploads, "att-1_plan.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "plan", string(content))
+
+	var createdAt time.Time
+	require.NoError(t, dst.QueryRow(`SELECT created_at FROM notes WHERE id = 'note-1'`).Scan(&createdAt))
+	assert.False(t, createdAt.IsZero())
+
+	// An export from before the status workflow keeps the current one
+	assert.Len(t, doc.Tables["todo_statuses"], 4)
+	delete(doc.Tables, "todo_statuses")
+	delete(doc.Tables, "todo_status_transitions")
+	report, err = Import(dst, roundTrip(t, doc), Options{Mode: Replace, UploadsDir: dstUploads})
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.FilesRemoved, "attachments the document brings back keep their file")
+	_, err = os.Stat(filepath.Join(dstUploads, "att-1_plan.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count(t, dst, "todo_statuses"))
+	assert.Equal(t, 12, count(t, dst, "todo_status_transitions"))
+}
+
+func TestImportMergeConflicts(t *testing.T) {
+	database, uploads := setupDB(t), t.TempDir()
+	seed(t, database, uploads)
+
+	doc := roundTrip(t, &Document{
+		Version: Version,
+		Tables: map[string][]Row{
+			"accounts": {{"id": "acc-1", "name": "Acme Renamed"}, {"id": "acc-2", "name": "Globex"}},
+			// Same name as tag-1, so the link below must follow it there
+			"tags":      {{"id": "tag-9", "name": "urgent", "color": "#ff0000"}},
+			"notes":     {{"id": "note-2", "title": "Intro", "account_id": "acc-2"}},
+			"note_tags": {{"note_id": "note-2", "tag_id": "tag-9"}},
+		},
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		report, err := Import(database, doc, Options{DryRun: true, UploadsDir: uploads})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Tables["accounts"].Inserted)
+		assert.Len(t, report.Conflicts, 2)
+		assert.Equal(t, 1, count(t, database, "accounts"), "dry run changes nothing")
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		report, err := Import(database, doc, Options{OnConflict: Fail, UploadsDir: uploads})
+		assert.ErrorIs(t, err, ErrConflicts)
+		assert.Equal(t, "failed", report.Conflicts[0].Resolution)
+		assert.Equal(t, 1, count(t, database, "accounts"), "nothing is written")
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		report, err := Import(database, doc, Options{UploadsDir: uploads})
+		require.NoError(t, err)
+		assert.Equal(t, []Conflict{
+			{Table: "accounts", Key: "acc-1", Field: "id", Resolution: "skipped"},
+			{Table: "tags", Key: "tag-9", Field: "name", ExistingKey: "tag-1", Resolution: "skipped"},
+		}, report.Conflicts)
+
+		var name string
+		require.NoError(t, database.QueryRow(`SELECT name FROM accounts WHERE id = 'acc-1'`).Scan(&name))
+		assert.Equal(t, "Acme", name)
+		var tagID string
+		require.NoError(t, database.QueryRow(`SELECT tag_id FROM note_tags WHERE note_id = 'note-2'`).Scan(&tagID))
+		assert.Equal(t, "tag-1", tagID)
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		report, err := Import(database, doc, Options{OnConflict: Overwrite, UploadsDir: uploads})
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Tables["accounts"].Updated)
+
+		var color string
+		require.NoError(t, database.QueryRow(`SELECT color FROM tags WHERE id = 'tag-1'`).Scan(&color))
+		assert.Equal(t, "#ff0000", color)
+	})
+}
+
+func TestImportRollsBackOnError(t *testing.T) {
+	database := setupDB(t)
+	doc := roundTrip(t, &Document{
+		Version: Version,
+		Tables: map[string][]Row{
+			"accounts": {{"id": "acc-1", "name": "Acme"}},
+			// References an account that does not exist
+			"notes": {{"id": "note-1", "title": "Orphan", "account_id": "missing"}},
+		},
+	})
+
+	_, err := Import(database, doc, Options{UploadsDir: t.TempDir()})
+	var rowErr *RowError
+	require.ErrorAs(t, err, &rowErr)
+	assert.Equal(t, "notes", rowErr.Table)
+	assert.Equal(t, 0, count(t, database, "accounts"))
+}
+
+func TestImportVersion1(t *testing.T) {
+	database := setupDB(t)
+	v1 := `{
+		"version": "1.0",
+		"accounts": [{"id": "acc-1", "name": "Acme", "account_owner": "", "budget": 0, "est_engineers": 0,
+			"created_at": "2024-01-15T10:00:00Z", "updated_at": "2024-01-15T10:00:00Z"}],
+		"todos": [{"id": "todo-1", "title": "Call", "description": "", "status": "not_started", "priority": "high",
+			"due_date": "", "account_id": "", "pinned": true,
+			"created_at": "2024-01-15T10:00:00Z", "updated_at": "2024-01-15T10:00:00Z"}]
+	}`
+	doc, err := Decode(strings.NewReader(v1))
+	require.NoError(t, err)
+
+	_, err = Import(database, doc, Options{UploadsDir: t.TempDir()})
+	require.NoError(t, err)
+
+	var accountID sql.NullString
+	var pinned int
+	require.NoError(t, database.QueryRow(`SELECT account_id, pinned FROM todos WHERE id = 'todo-1'`).Scan(&accountID, &pinned))
+	assert.False(t, accountID.Valid)
+	assert.Equal(t, 1, pinned)
+
+	_, err = Decode(strings.NewReader(`{"version": "9.0"}`))
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestZipRoundTrip(t *testing.T) {
+	src, srcUploads := setupDB(t), t.TempDir()
+	seed(t, src, srcUploads)
+
+	doc, err := Export(src, srcUploads, false)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, WriteZip(&buf, doc, srcUploads))
+
+	read, err := ReadZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("plan"), read.Files["att-1_plan.txt"])
+	assert.Len(t, read.Tables["attachments"], 1)
+}
+
+func TestImportRejectsUnsafeFilenames(t *testing.T) {
+	doc := roundTrip(t, &Document{
+		Version: Version,
+		Tables: map[string][]Row{
+			"attachments": {{"id": "att-1", "note_id": "note-1", "filename": "../../etc/passwd"}},
+		},
+	})
+	_, err := Import(setupDB(t), doc, Options{UploadsDir: t.TempDir()})
+	var rowErr *RowError
+	assert.ErrorAs(t, err, &rowErr)
+}
@@ -0,0 +1,19 @@
+import { TaskItem } from '@tiptap/extension-list';
+
+// TodoTaskItem is a task item that keeps the id of the todo the server made
+// from it, so the next save updates that todo instead of adding another.
+// A new item split off with Enter starts without one.
+export const TodoTaskItem = TaskItem.extend({
+  addAttributes() {
+    return {
+      ...this.parent?.(),
+      todoId: {
+        default: null,
+        keepOnSplit: false,
+        parseHTML: (element: HTMLElement) => element.getAttribute('data-todo-id'),
+        renderHTML: (attributes: { todoId?: string | null }) =>
+          attributes.todoId ? { 'data-todo-id': attributes.todoId } : {},
+      },
+    };
+  },
+});
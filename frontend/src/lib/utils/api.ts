@@ -8,6 +8,9 @@ declare global {
         };
       };
     };
+    runtime?: {
+      EventsOn: (name: string, callback: (...data: any[]) => void) => () => void;
+    };
   }
 }
 
@@ -107,16 +110,34 @@ export interface Todo {
   id: string;
   title: string;
   description: string;
-  status: 'not_started' | 'in_progress' | 'stuck' | 'completed';
+  status: string; // A workflow status key
   priority: 'low' | 'medium' | 'high';
   due_date?: string;
   account_id?: string;
   account_name?: string;
+  recurrence?: string;
+  series_id?: string;
+  occurrence?: number;
+  parent_id?: string | null;
+  blocked?: boolean;
+  blocked_by?: TodoRef[];
+  subtasks?: TodoRef[];
+  progress?: { total: number; completed: number; percent: number };
+  reminder_minutes?: number[] | null;
+  estimate_minutes?: number | null;
+  tracked_minutes?: number;
+  timer_started_at?: string | null;
   created_at: string;
   updated_at: string;
   linked_notes?: { id: string; title: string }[];
 }
 
+export interface TodoRef {
+  id: string;
+  title: string;
+  status: Todo['status'];
+}
+
 export interface CreateTodoRequest {
   title: string;
   description?: string;
@@ -125,6 +146,112 @@ export interface CreateTodoRequest {
   due_date?: string;
   note_id?: string;
   account_id?: string;
+  recurrence?: string;
+  parent_id?: string;
+  reminder_minutes?: number[] | null;
+  estimate_minutes?: number;
+}
+
+export interface TodoCompletion {
+  id: string;
+  series_id: string;
+  todo_id: string | null;
+  occurrence: number;
+  due_date?: string;
+  completed_at: string;
+}
+
+export interface TimeEntry {
+  id: string;
+  todo_id: string;
+  started_at: string;
+  ended_at?: string;
+  minutes: number;
+  note?: string;
+  source: 'timer' | 'manual';
+  created_at: string;
+}
+
+export interface AccountTime {
+  account_id: string | null;
+  account_name: string;
+  minutes: number;
+  todos: number;
+}
+
+export interface TimeReport {
+  total_minutes: number;
+  by_account: AccountTime[];
+  by_week: { week_start: string; minutes: number; accounts: AccountTime[] }[];
+}
+
+export interface TodoStatus {
+  key: string;
+  label: string;
+  category: 'open' | 'active' | 'blocked' | 'done';
+  color?: string;
+}
+
+export interface Workflow {
+  statuses: TodoStatus[];
+  transitions: Record<string, string[]> | null;
+}
+
+export interface StatusChange {
+  id: string;
+  todo_id: string;
+  from_status: string;
+  to_status: string;
+  changed_at: string;
+  minutes_in_from_status: number;
+}
+
+export interface DurationStats {
+  average_hours: number;
+  median_hours: number;
+}
+
+export interface StatusReport {
+  completed: number;
+  lead_time: DurationStats;
+  cycle_time: DurationStats;
+  statuses: {
+    status: string;
+    label: string;
+    category: string;
+    current: number;
+    periods: number;
+    total_hours: number;
+    average_hours: number;
+  }[];
+}
+
+export type NoteBulkAction =
+  | { action: 'set_account'; value: { account_id: string } }
+  | { action: 'add_tag' | 'remove_tag'; value: { tag_id: string } }
+  | { action: 'archive' | 'unarchive' | 'pin' | 'unpin' | 'delete' | 'restore' };
+
+export type TodoBulkAction =
+  | { action: 'set_status'; value: { status: string } }
+  | { action: 'set_priority'; value: { priority: 'high' | 'medium' | 'low' } }
+  | { action: 'set_due_date'; value: { due_date: string } }
+  | { action: 'set_account'; value: { account_id: string } }
+  | { action: 'delete' };
+
+export interface BulkResult {
+  id: string;
+  ok: boolean;
+  error?: string;
+}
+
+export interface Reminder {
+  todo_id: string;
+  title: string;
+  account_name?: string;
+  due_date: string;
+  lead_minutes: number;
+  remind_at: string;
+  snoozed: boolean;
 }
 
 // Analytics types
@@ -145,11 +272,32 @@ export interface IncompleteField {
 }
 
 export interface SearchResult {
-  type: 'note' | 'account' | 'todo';
+  type: 'note' | 'account' | 'todo' | 'contact' | 'attachment';
   id: string;
   title: string;
   snippet?: string;
   account_id?: string;
+  note_id?: string;
+}
+
+export interface SearchFilters {
+  type?: string;
+  account_id?: string;
+  template_type?: string;
+  from?: string;
+  to?: string;
+  archived?: string;
+  deleted?: string;
+}
+
+export interface SavedSearch {
+  id: string;
+  name: string;
+  query: string;
+  filters: SearchFilters;
+  count?: number;
+  created_at: string;
+  updated_at: string;
 }
 
 // Calendar types
@@ -185,7 +333,9 @@ export interface ParsedParticipants {
 export interface Tag {
   id: string;
   name: string;
+  parent?: string;
   color: string;
+  usage?: { notes: number; todos: number; accounts: number };
   created_at: string;
 }
 
@@ -223,6 +373,10 @@ export interface Attachment {
   original_name: string;
   mime_type: string;
   size: number;
+  page_count?: number;
+  width?: number;
+  height?: number;
+  text_preview?: string;
   created_at: string;
 }
 
@@ -265,10 +419,53 @@ export interface ContactStats {
   pending_suggestions: number;
 }
 
+export interface ListParams {
+  limit?: number;
+  cursor?: string;
+  sort?: string;
+  order?: 'asc' | 'desc';
+  fields?: string;
+}
+
+export interface NoteListParams extends ListParams {
+  tags?: string;
+  match?: 'all' | 'any';
+  account_id?: string;
+  tag?: string;
+  template_type?: string;
+  pinned?: boolean;
+  archived?: boolean;
+  from?: string;
+  to?: string;
+}
+
+export interface TodoListParams extends ListParams {
+  tags?: string;
+  match?: 'all' | 'any';
+  status?: string;
+  account_id?: string;
+  priority?: string;
+  pinned?: boolean;
+  parent_id?: string;
+  top_level?: boolean;
+  due_after?: string;
+  due_before?: string;
+}
+
+function listQuery(params: object = {}): string {
+  const query = new URLSearchParams();
+  for (const [key, value] of Object.entries(params)) {
+    if (value !== undefined && value !== '') query.append(key, String(value));
+  }
+  const s = query.toString();
+  return s ? `?${s}` : '';
+}
+
 // API functions
 export const api = {
   // Accounts
-  getAccounts: () => request<Account[]>('/accounts'),
+  getAccounts: (params?: ListParams & { owner?: string; tags?: string; match?: 'all' | 'any' }) =>
+    request<Account[]>(`/accounts${listQuery(params)}`),
   getAccount: (id: string) => request<Account>(`/accounts/${id}`),
   createAccount: (data: CreateAccountRequest) =>
     request<Account>('/accounts', { method: 'POST', body: JSON.stringify(data) }),
@@ -283,13 +480,15 @@ export const api = {
   getDeletedAccounts: () => request<Account[]>('/accounts/deleted'),
 
   // Notes
-  getNotes: () => request<Note[]>('/notes'),
+  getNotes: (params?: NoteListParams) => request<Note[]>(`/notes${listQuery(params)}`),
   getNote: (id: string) => request<Note>(`/notes/${id}`),
   getNotesByAccount: (accountId: string) => request<Note[]>(`/accounts/${accountId}/notes`),
   createNote: (data: CreateNoteRequest) =>
     request<Note>('/notes', { method: 'POST', body: JSON.stringify(data) }),
   updateNote: (id: string, data: Partial<CreateNoteRequest>) =>
     request<Note>(`/notes/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
+  extractNoteTodos: (id: string) =>
+    request<{ created: Todo[]; updated: number; content: string }>(`/notes/${id}/extract-todos`, { method: 'POST' }),
   deleteNote: (id: string) =>
     request<{ message: string }>(`/notes/${id}`, { method: 'DELETE' }),
   restoreNote: (id: string) =>
@@ -297,6 +496,8 @@ export const api = {
   permanentDeleteNote: (id: string) =>
     request<{ message: string }>(`/notes/${id}/permanent`, { method: 'DELETE' }),
   getDeletedNotes: () => request<Note[]>('/notes/deleted'),
+  bulkNotes: (noteIds: string[], change: NoteBulkAction) =>
+    request<{ updated: number; results: BulkResult[] }>('/notes/bulk', { method: 'POST', body: JSON.stringify({ note_ids: noteIds, ...change }) }),
   exportNote: (id: string, type: 'full' | 'minimal' = 'full') =>
     request<any>(`/notes/${id}/export?type=${type}`),
   
@@ -321,8 +522,7 @@ export const api = {
   },
 
   // Todos
-  getTodos: (status?: string) =>
-    request<Todo[]>(`/todos${status ? `?status=${status}` : ''}`),
+  getTodos: (params?: TodoListParams) => request<Todo[]>(`/todos${listQuery(params)}`),
   getTodo: (id: string) => request<Todo>(`/todos/${id}`),
   createTodo: (data: CreateTodoRequest) =>
     request<Todo>('/todos', { method: 'POST', body: JSON.stringify(data) }),
@@ -335,17 +535,77 @@ export const api = {
   permanentDeleteTodo: (id: string) =>
     request<{ message: string }>(`/todos/${id}/permanent`, { method: 'DELETE' }),
   getDeletedTodos: () => request<Todo[]>('/todos/deleted'),
+  getTodoCompletions: (id: string) => request<TodoCompletion[]>(`/todos/${id}/completions`),
+  addTodoBlocker: (todoId: string, blockerId: string) =>
+    request<{ message: string }>(`/todos/${todoId}/blocked-by/${blockerId}`, { method: 'POST' }),
+  removeTodoBlocker: (todoId: string, blockerId: string) =>
+    request<{ message: string }>(`/todos/${todoId}/blocked-by/${blockerId}`, { method: 'DELETE' }),
+  setTodoReminders: (id: string, minutes: number[] | null) =>
+    request<Todo>(`/todos/${id}/reminders`, { method: 'PUT', body: JSON.stringify({ minutes }) }),
+  snoozeReminder: (todoId: string, leadMinutes: number, minutes?: number) =>
+    request<{ todo_id: string; lead_minutes: number; snoozed_until: string }>(
+      `/todos/${todoId}/reminders/${leadMinutes}/snooze`,
+      { method: 'POST', body: JSON.stringify(minutes ? { minutes } : {}) }
+    ),
+  startTodoTimer: (id: string) => request<TimeEntry>(`/todos/${id}/timer/start`, { method: 'POST' }),
+  stopTodoTimer: (id: string) => request<TimeEntry>(`/todos/${id}/timer/stop`, { method: 'POST' }),
+  getTimeEntries: (todoId: string) => request<TimeEntry[]>(`/todos/${todoId}/time-entries`),
+  addTimeEntry: (todoId: string, data: { minutes: number; started_at?: string; note?: string }) =>
+    request<TimeEntry>(`/todos/${todoId}/time-entries`, { method: 'POST', body: JSON.stringify(data) }),
+  deleteTimeEntry: (id: string) =>
+    request<{ message: string }>(`/time-entries/${id}`, { method: 'DELETE' }),
+  getTodoHistory: (id: string) => request<StatusChange[]>(`/todos/${id}/history`),
+
+  // Workflow
+  getWorkflow: () => request<Workflow>('/workflow'),
+  updateWorkflow: (workflow: Workflow) =>
+    request<Workflow>('/workflow', { method: 'PUT', body: JSON.stringify(workflow) }),
+
+  getUpcomingReminders: (within?: string) =>
+    request<Reminder[]>(`/reminders/upcoming${within ? `?within=${encodeURIComponent(within)}` : ''}`),
   linkTodoToNote: (todoId: string, noteId: string) =>
     request<{ message: string }>(`/todos/${todoId}/notes/${noteId}`, { method: 'POST' }),
   unlinkTodoFromNote: (todoId: string, noteId: string) =>
     request<{ message: string }>(`/todos/${todoId}/notes/${noteId}`, { method: 'DELETE' }),
+  bulkTodos: (todoIds: string[], change: TodoBulkAction) =>
+    request<{ updated: number; results: BulkResult[] }>('/todos/bulk', { method: 'POST', body: JSON.stringify({ todo_ids: todoIds, ...change }) }),
 
   // Search
   search: (query: string) => request<SearchResult[]>(`/search?q=${encodeURIComponent(query)}`),
+  semanticSearch: (query: string) =>
+    request<SearchResult[]>(`/search?mode=semantic&q=${encodeURIComponent(query)}`),
+  getSimilarNotes: (noteId: string, limit = 5) =>
+    request<SearchResult[]>(`/notes/${noteId}/similar?limit=${limit}`),
+
+  // Saved searches
+  getSavedSearches: () => request<SavedSearch[]>('/saved-searches'),
+  createSavedSearch: (data: { name: string; query: string; filters?: SearchFilters }) =>
+    request<SavedSearch>('/saved-searches', { method: 'POST', body: JSON.stringify(data) }),
+  updateSavedSearch: (id: string, data: { name?: string; query?: string; filters?: SearchFilters }) =>
+    request<SavedSearch>(`/saved-searches/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
+  deleteSavedSearch: (id: string) =>
+    request<{ message: string }>(`/saved-searches/${id}`, { method: 'DELETE' }),
+  runSavedSearch: (id: string) => request<SearchResult[]>(`/saved-searches/${id}/results?limit=100`),
 
   // Analytics
   getAnalytics: () => request<Analytics>('/analytics'),
   getIncompleteFields: () => request<IncompleteField[]>('/analytics/incomplete'),
+  getTimeAnalytics: (params?: { from?: string; to?: string; account_id?: string }) => {
+    const query = new URLSearchParams();
+    Object.entries(params ?? {}).forEach(([key, value]) => {
+      if (value) query.set(key, value);
+    });
+    const qs = query.toString();
+    return request<TimeReport>(`/analytics/time${qs ? `?${qs}` : ''}`);
+  },
+  getStatusAnalytics: (params?: { from?: string; to?: string; account_id?: string }) => {
+    const query = new URLSearchParams();
+    Object.entries(params ?? {}).forEach(([key, value]) => {
+      if (value) query.set(key, value);
+    });
+    const qs = query.toString();
+    return request<StatusReport>(`/analytics/status${qs ? `?${qs}` : ''}`);
+  },
 
   // Data management
   exportAllData: () => request<Record<string, unknown>>('/export'),
@@ -380,11 +640,23 @@ export const api = {
     request<Tag>(`/tags/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
   deleteTag: (id: string) =>
     request<{ message: string }>(`/tags/${id}`, { method: 'DELETE' }),
+  mergeTag: (id: string, into: string) =>
+    request<Tag>(`/tags/${id}/merge`, { method: 'POST', body: JSON.stringify({ into }) }),
   getNoteTags: (noteId: string) => request<Tag[]>(`/notes/${noteId}/tags`),
   addTagToNote: (noteId: string, tagId: string) =>
     request<{ message: string }>(`/notes/${noteId}/tags/${tagId}`, { method: 'POST' }),
   removeTagFromNote: (noteId: string, tagId: string) =>
     request<{ message: string }>(`/notes/${noteId}/tags/${tagId}`, { method: 'DELETE' }),
+  getTodoTags: (todoId: string) => request<Tag[]>(`/todos/${todoId}/tags`),
+  addTagToTodo: (todoId: string, tagId: string) =>
+    request<{ message: string }>(`/todos/${todoId}/tags/${tagId}`, { method: 'POST' }),
+  removeTagFromTodo: (todoId: string, tagId: string) =>
+    request<{ message: string }>(`/todos/${todoId}/tags/${tagId}`, { method: 'DELETE' }),
+  getAccountTags: (accountId: string) => request<Tag[]>(`/accounts/${accountId}/tags`),
+  addTagToAccount: (accountId: string, tagId: string) =>
+    request<{ message: string }>(`/accounts/${accountId}/tags/${tagId}`, { method: 'POST' }),
+  removeTagFromAccount: (accountId: string, tagId: string) =>
+    request<{ message: string }>(`/accounts/${accountId}/tags/${tagId}`, { method: 'DELETE' }),
 
   // Activities
   getActivities: (accountId: string, limit?: number) =>
@@ -517,6 +789,30 @@ export interface ContactNote {
   created_at: string;
 }
 
+// Calls onReminder for each reminder as it fires and returns a function that
+// stops listening. The desktop app emits a runtime event; the browser
+// listens on the Server-Sent Events stream.
+export function subscribeReminders(onReminder: (r: Reminder) => void): () => void {
+  if (typeof window === 'undefined') {
+    return () => {};
+  }
+  if (window.go?.main?.App && window.runtime) {
+    return window.runtime.EventsOn('reminder', onReminder);
+  }
+
+  let source: EventSource | null = null;
+  let closed = false;
+  getApiBase().then((apiBase) => {
+    if (closed) return;
+    source = new EventSource(`${apiBase}/reminders/stream`);
+    source.addEventListener('reminder', (e) => onReminder(JSON.parse((e as MessageEvent).data)));
+  });
+  return () => {
+    closed = true;
+    source?.close();
+  };
+}
+
 // Helper for attachment download URL
 export const getAttachmentUrl = (filename: string) => {
   const base = getApiBaseSync().replace('/api', '');